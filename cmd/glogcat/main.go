@@ -0,0 +1,440 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command glogcat reads glog files, merges them by time, filters them and
+// converts them between text and JSON.
+//
+// Usage:
+//
+//	glogcat [flags] [file ...]
+//
+// Each file, or standard input if none is given, holds lines in the format
+// of the log files or JSON records, as written by glog.AddJSONWriter; the
+// format is detected line by line. The lines of all files are merged in time
+// order, which recombines the files of each severity when lines are not
+// copied to the files of lower severities (see
+// glog.SetDuplicateToLowerSeverity). Lines that do not start with a header,
+// such as stack traces, stay with the line they follow.
+//
+// Text lines do not record the year, which is taken from the "Log file
+// created at" header of the file, or the current year, and their time is
+// read in the local time zone.
+//
+// The flags are:
+//
+//	-since, -until
+//		Keep lines logged at or after, or before, a time given as RFC 3339
+//		or "2006-01-02 15:04:05" in the local time zone.
+//	-severity=INFO
+//		Keep lines of this severity and above.
+//	-file=""
+//		Keep lines logged from matching source files: a file name or
+//		glob pattern, such as "pay*.go", optionally followed by :line.
+//	-grep=""
+//		Keep lines whose message matches this regular expression.
+//	-json=false
+//		Write JSON records rather than text.
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/biyizhen/glog"
+)
+
+func main() {
+	fs := flag.NewFlagSet("glogcat", flag.ExitOnError)
+	var o options
+	since := fs.String("since", "", "keep lines logged at or after this time")
+	until := fs.String("until", "", "keep lines logged before this time")
+	severity := fs.String("severity", "INFO", "keep lines of this severity and above")
+	fs.StringVar(&o.file, "file", "", "keep lines logged from matching source files, as pattern[:line]")
+	grep := fs.String("grep", "", "keep lines whose message matches this regular expression")
+	fs.BoolVar(&o.json, "json", false, "write JSON records rather than text")
+	fs.Parse(os.Args[1:])
+
+	err := o.parse(*since, *until, *severity, *grep)
+	if err == nil {
+		err = run(&o, fs.Args(), os.Stdin, os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "glogcat:", err)
+		os.Exit(1)
+	}
+}
+
+// options holds the filters and the output format.
+type options struct {
+	since, until time.Time
+	severity     glog.Severity
+	file         string // pattern[:line]
+	grep         *regexp.Regexp
+	json         bool
+}
+
+// parse sets the filters given by the flag values.
+func (o *options) parse(since, until, severity, grep string) error {
+	var err error
+	if o.since, err = parseTime(since); err != nil {
+		return fmt.Errorf("-since: %v", err)
+	}
+	if o.until, err = parseTime(until); err != nil {
+		return fmt.Errorf("-until: %v", err)
+	}
+	if o.severity, err = glog.ParseSeverity(severity); err != nil {
+		return fmt.Errorf("-severity: %v", err)
+	}
+	if grep != "" {
+		if o.grep, err = regexp.Compile(grep); err != nil {
+			return fmt.Errorf("-grep: %v", err)
+		}
+	}
+	if _, err := path.Match(o.filePattern(), ""); err != nil {
+		return fmt.Errorf("-file: %v", err)
+	}
+	return nil
+}
+
+// parseTime parses the value of -since or -until; empty is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+}
+
+// filePattern returns the file pattern of -file, without the line.
+func (o *options) filePattern() string {
+	if i := strings.LastIndex(o.file, ":"); i >= 0 {
+		if _, err := strconv.Atoi(o.file[i+1:]); err == nil {
+			return o.file[:i]
+		}
+	}
+	return o.file
+}
+
+// keep reports whether e passes the filters.
+func (o *options) keep(e *entry) bool {
+	r := e.rec
+	sev, err := glog.ParseSeverity(r.Severity)
+	if err != nil || sev < o.severity {
+		return false
+	}
+	if !o.since.IsZero() && r.Time.Before(o.since) || !o.until.IsZero() && !r.Time.Before(o.until) {
+		return false
+	}
+	if o.file != "" {
+		pattern := o.filePattern()
+		if ok, _ := path.Match(pattern, r.File); !ok {
+			if ok, _ := path.Match(pattern, path.Base(r.File)); !ok {
+				return false
+			}
+		}
+		if pattern != o.file && o.file[len(pattern)+1:] != strconv.Itoa(r.Line) {
+			return false
+		}
+	}
+	if o.grep != nil && !o.grep.MatchString(r.Message) {
+		return false
+	}
+	return true
+}
+
+// An entry is a line read from a file, with the lines that follow it.
+type entry struct {
+	rec  *glog.Record
+	text string // The line as read, for text lines.
+}
+
+// write writes e to w as text or JSON.
+func (e *entry) write(w io.Writer, asJSON bool) error {
+	var err error
+	switch {
+	case asJSON:
+		var b []byte
+		if b, err = json.Marshal(e.rec); err == nil {
+			_, err = w.Write(append(b, '\n'))
+		}
+	case e.text != "":
+		_, err = io.WriteString(w, e.text)
+	default:
+		_, err = io.WriteString(w, e.rec.Text())
+	}
+	return err
+}
+
+// A reader reads the entries of a file.
+type reader struct {
+	name string
+	in   *bufio.Reader
+	year int
+	// month is that of the last text line, to notice the turn of the year.
+	month time.Month
+	next  *entry // Read ahead, waiting for its continuation lines.
+	err   error
+}
+
+func newReader(name string, r io.Reader) *reader {
+	return &reader{name: name, in: bufio.NewReader(r), year: time.Now().Year()}
+}
+
+// read returns the next entry, or nil at the end of the file or on error,
+// which is then in rd.err.
+func (rd *reader) read() *entry {
+	for rd.err == nil {
+		line, err := rd.in.ReadString('\n')
+		if err == io.EOF {
+			rd.err = err
+		} else if err != nil {
+			rd.err = fmt.Errorf("%s: %v", rd.name, err)
+		}
+		if line == "" {
+			continue
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		if strings.HasPrefix(line, "Log file continues in: ") {
+			// The last line of a rotated file.
+			continue
+		}
+		e := rd.parse(line)
+		if e == nil {
+			if rd.next != nil {
+				// A continuation line.
+				rd.next.text += line
+				rd.next.rec.Message += "\n" + strings.TrimSuffix(line, "\n")
+			}
+			continue
+		}
+		prev := rd.next
+		rd.next = e
+		if prev != nil {
+			return prev
+		}
+	}
+	if rd.err != io.EOF {
+		return nil
+	}
+	e := rd.next
+	rd.next = nil
+	return e
+}
+
+// parse parses a line of text or JSON. It returns nil for file headers and
+// for continuation lines, including JSON that is not a record.
+func (rd *reader) parse(line string) *entry {
+	if strings.HasPrefix(line, "{") {
+		if r, err := glog.ParseRecord([]byte(line)); err == nil {
+			return &entry{rec: r}
+		}
+		return nil
+	}
+	const created = "Log file created at: "
+	if strings.HasPrefix(line, created) {
+		words := strings.Fields(line[len(created):])
+		if len(words) == 0 {
+			return nil
+		}
+		if t, err := time.Parse("2006/01/02", words[0]); err == nil {
+			rd.year, rd.month = t.Year(), t.Month()
+		}
+		return nil
+	}
+	r, ok := parseText(line, rd.year)
+	if !ok {
+		return nil
+	}
+	if r.Time.Month() < rd.month {
+		rd.year++
+		r.Time = r.Time.AddDate(1, 0, 0)
+	}
+	rd.month = r.Time.Month()
+	return &entry{rec: r, text: line}
+}
+
+// parseText parses a line in the format of the log files,
+//
+//	Lmmdd hh:mm:ss.uuuuuu threadid [goid] [labels] file:line] msg
+//
+// and reports whether it has a header.
+func parseText(line string, year int) (*glog.Record, bool) {
+	const stamp = len("I0102 15:04:05.000000")
+	end := strings.Index(line, "] ")
+	if len(line) < stamp+2 || end < stamp {
+		return nil, false
+	}
+	sev, ok := map[byte]string{'I': "INFO", 'W': "WARNING", 'E': "ERROR", 'F': "FATAL"}[line[0]]
+	if !ok {
+		return nil, false
+	}
+	t, err := time.ParseInLocation("2006 0102 15:04:05.000000", strconv.Itoa(year)+" "+line[1:stamp], time.Local)
+	if err != nil {
+		return nil, false
+	}
+	words := splitWords(line[stamp:end])
+	if len(words) == 0 {
+		return nil, false
+	}
+	r := &glog.Record{Time: t, Severity: sev, Message: strings.TrimSuffix(line[end+2:], "\n")}
+	if r.PID, err = strconv.Atoi(words[0]); err != nil {
+		return nil, false
+	}
+	words = words[1:]
+	if n := len(words); n > 0 && !strings.Contains(words[n-1], "=") {
+		colon := strings.LastIndex(words[n-1], ":")
+		if colon < 0 {
+			return nil, false
+		}
+		r.File = words[n-1][:colon]
+		if r.Line, err = strconv.Atoi(words[n-1][colon+1:]); err != nil {
+			return nil, false
+		}
+		words = words[:n-1]
+	}
+	if len(words) > 0 && !strings.Contains(words[0], "=") {
+		// The goroutine or worker ID of -log_goroutine_id.
+		words = words[1:]
+	}
+	for _, w := range words {
+		eq := strings.Index(w, "=")
+		if eq < 0 {
+			return nil, false
+		}
+		if r.Labels == nil {
+			r.Labels = make(map[string]string)
+		}
+		v := w[eq+1:]
+		if u, err := strconv.Unquote(v); err == nil {
+			v = u
+		}
+		r.Labels[w[:eq]] = v
+	}
+	return r, true
+}
+
+// splitWords splits s at spaces outside double-quoted strings, which hold
+// the label values that contain spaces.
+func splitWords(s string) []string {
+	var words []string
+	start, quoted := -1, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == ' ' && !quoted:
+			if start >= 0 {
+				words = append(words, s[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// merger yields the entries of several readers in time order.
+type merger []*source
+
+// source is a reader with its next entry.
+type source struct {
+	rd    *reader
+	e     *entry
+	index int // Of the file, to keep the order of lines logged at the same time.
+}
+
+func (m merger) Len() int { return len(m) }
+func (m merger) Less(i, j int) bool {
+	if ti, tj := m[i].e.rec.Time, m[j].e.rec.Time; !ti.Equal(tj) {
+		return ti.Before(tj)
+	}
+	return m[i].index < m[j].index
+}
+func (m merger) Swap(i, j int)       { m[i], m[j] = m[j], m[i] }
+func (m *merger) Push(x interface{}) { *m = append(*m, x.(*source)) }
+func (m *merger) Pop() interface{} {
+	old := *m
+	s := old[len(old)-1]
+	*m = old[:len(old)-1]
+	return s
+}
+
+// run merges the entries of files, or of stdin if there are none, and writes
+// those passing the filters of o to stdout.
+func run(o *options, files []string, stdin io.Reader, stdout io.Writer) error {
+	var readers []*reader
+	if len(files) == 0 {
+		readers = append(readers, newReader("stdin", stdin))
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		readers = append(readers, newReader(name, f))
+	}
+	var m merger
+	for i, rd := range readers {
+		if e := rd.read(); e != nil {
+			m = append(m, &source{rd: rd, e: e, index: i})
+		}
+	}
+	heap.Init(&m)
+	out := bufio.NewWriter(stdout)
+	for m.Len() > 0 {
+		s := m[0]
+		if o.keep(s.e) {
+			if err := s.e.write(out, o.json); err != nil {
+				return err
+			}
+		}
+		if s.e = s.rd.read(); s.e != nil {
+			heap.Fix(&m, 0)
+		} else {
+			heap.Pop(&m)
+		}
+	}
+	for _, rd := range readers {
+		if rd.err != nil && rd.err != io.EOF {
+			out.Flush()
+			return rd.err
+		}
+	}
+	return out.Flush()
+}
@@ -0,0 +1,149 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/biyizhen/glog"
+)
+
+const (
+	infoFile = `Log file created at: 2023/12/31 23:00:00
+Running on machine: host
+Process ID: 1234
+I1231 23:59:58.000000    1234 main.go:10] starting
+I0101 00:00:02.000000    1234 pay.go:42] charged
+Log file continues in: /var/log/app.INFO.2
+`
+	errorFile = `Log file created at: 2023/12/31 23:00:00
+E1231 23:59:59.000000    1234 env=prod pay.go:40] declined
+goroutine 1 [running]:
+E0101 00:00:03.000000    1234 pay.go:50] refund failed
+`
+	jsonFile = `{"schema_version":1,"time":"2024-01-01T00:00:01Z","severity":"WARNING","pid":1234,"file":"db.go","line":7,"message":"slow query","fields":{"ms":900}}
+`
+)
+
+// write writes the test files and returns their paths.
+func write(t *testing.T) []string {
+	dir := t.TempDir()
+	var paths []string
+	for name, data := range map[string]string{"INFO": infoFile, "ERROR": errorFile, "WARNING.json": jsonFile} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestMerge(t *testing.T) {
+	defer func(loc *time.Location) { time.Local = loc }(time.Local)
+	time.Local = time.UTC
+	paths := write(t)
+
+	var out bytes.Buffer
+	if err := run(new(options), paths, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	want := []string{"starting", "declined", "goroutine 1 [running]:", "slow query ms=900", "charged", "refund failed", ""}
+	if len(lines) != len(want) {
+		t.Fatalf("got %q", out.String())
+	}
+	for i, w := range want {
+		if !strings.HasSuffix(lines[i], w) {
+			t.Errorf("line %d: got %q, want suffix %q", i, lines[i], w)
+		}
+	}
+}
+
+func TestFilters(t *testing.T) {
+	defer func(loc *time.Location) { time.Local = loc }(time.Local)
+	time.Local = time.UTC
+	paths := write(t)
+
+	for _, test := range []struct {
+		since, until, severity, file, grep string
+		want                               []string
+	}{
+		{severity: "WARNING", want: []string{"declined", "slow query", "refund failed"}},
+		{since: "2024-01-01T00:00:00Z", until: "2024-01-01 00:00:03", want: []string{"slow query", "charged"}},
+		{file: "pay*.go", want: []string{"declined", "charged", "refund failed"}},
+		{file: "pay.go:42", want: []string{"charged"}},
+		{grep: "^(starting|slow)", want: []string{"starting", "slow query"}},
+	} {
+		o := options{file: test.file, json: true}
+		if test.severity == "" {
+			test.severity = "INFO"
+		}
+		if err := o.parse(test.since, test.until, test.severity, test.grep); err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if err := run(&o, paths, nil, &out); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, line := range strings.SplitAfter(out.String(), "\n") {
+			if line == "" {
+				continue
+			}
+			r, err := glog.ParseRecord([]byte(line))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, strings.SplitN(r.Message, "\n", 2)[0])
+		}
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("%+v: got %q", test, got)
+		}
+	}
+
+	var o options
+	for _, bad := range [][4]string{{"yesterday", "", "INFO", ""}, {"", "", "LOUD", ""}, {"", "", "INFO", "("}} {
+		if err := o.parse(bad[0], bad[1], bad[2], bad[3]); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+// Test that text lines keep their labels when converted to JSON.
+func TestTextToJSON(t *testing.T) {
+	r, ok := parseText("E1231 23:59:59.123456    1234 env=prod zone=\"a b\" pay.go:40] declined\n", 2023)
+	if !ok {
+		t.Fatal("line not parsed")
+	}
+	if r.Severity != "ERROR" || r.PID != 1234 || r.File != "pay.go" || r.Line != 40 || r.Message != "declined" ||
+		r.Labels["env"] != "prod" || r.Labels["zone"] != "a b" || r.Time.Nanosecond() != 123456000 || r.Time.Year() != 2023 {
+		t.Errorf("got %+v", r)
+	}
+	r, ok = parseText("I1231 23:59:59.123456    1234 g17 env=prod pay.go:41] with goroutine\n", 2023)
+	if !ok || r.File != "pay.go" || r.Line != 41 || r.Labels["env"] != "prod" || r.Message != "with goroutine" {
+		t.Errorf("line with goroutine ID: got %+v, %v", r, ok)
+	}
+	if _, ok := parseText("goroutine 1 [running]:\n", 2023); ok {
+		t.Error("continuation line parsed")
+	}
+}
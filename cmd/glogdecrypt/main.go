@@ -0,0 +1,138 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command glogdecrypt decrypts the log files written with
+// glog.SetFileEncryption or the -log_encrypt_key_env flag.
+//
+// Usage:
+//
+//	glogdecrypt [-key_env NAME | -key_file FILE] [files ...]
+//
+// The key is the base64-encoded AES key the files were written with, read
+// from the environment variable NAME, GLOG_KEY by default, or from FILE.
+// After key rotations, FILE may instead hold a key per line, each as an ID
+// and a base64 key separated by spaces; files are then decrypted with the
+// keys of the IDs they record.
+//
+// Each file, or standard input if none is given, is decrypted to standard
+// output. A file cut short by a crash is decrypted up to the last complete
+// chunk, and reported.
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/biyizhen/glog"
+)
+
+func main() {
+	fs := flag.NewFlagSet("glogdecrypt", flag.ExitOnError)
+	keyEnv := fs.String("key_env", "GLOG_KEY", "environment variable holding the base64 key")
+	keyFile := fs.String("key_file", "", "file holding the base64 key, or lines of IDs and keys, instead of -key_env")
+	fs.Parse(os.Args[1:])
+	encoded := os.Getenv(*keyEnv)
+	if *keyFile != "" {
+		b, err := os.ReadFile(*keyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "glogdecrypt:", err)
+			os.Exit(1)
+		}
+		encoded = string(b)
+	}
+	if err := run(encoded, fs.Args(), os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "glogdecrypt:", err)
+		os.Exit(1)
+	}
+}
+
+// run decrypts files, or stdin if there are none, with keys, the base64
+// key or the lines of IDs and keys, and writes them to stdout.
+func run(keys string, files []string, stdin io.Reader, stdout io.Writer) error {
+	ring, err := parseKeys(keys)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		if _, err := io.Copy(stdout, glog.NewDecryptReader(stdin, ring)); err != nil {
+			return fmt.Errorf("stdin: %v", err)
+		}
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(stdout, glog.NewDecryptReader(f, ring))
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// keyRing holds the keys given to the command.
+type keyRing struct {
+	any  []byte            // Key of any ID.
+	byID map[string][]byte // Keys by ID.
+}
+
+// parseKeys parses a base64 key, or lines of IDs and base64 keys.
+func parseKeys(text string) (*keyRing, error) {
+	r := &keyRing{byID: make(map[string][]byte)}
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+		if err != nil || len(key) == 0 || len(fields) > 2 {
+			return nil, errors.New("keys must be a base64 key or lines of IDs and base64 keys")
+		}
+		if len(fields) == 1 {
+			r.any = key
+		} else {
+			r.byID[fields[0]] = key
+		}
+	}
+	if r.any == nil && len(r.byID) == 0 {
+		return nil, errors.New("no key")
+	}
+	return r, nil
+}
+
+// CurrentKey is part of the glog.KeyProvider interface; decryption does
+// not use it.
+func (r *keyRing) CurrentKey() (string, []byte, error) {
+	return "", nil, errors.New("no current key")
+}
+
+// GetKey is part of the glog.KeyProvider interface.
+func (r *keyRing) GetKey(keyID string) ([]byte, error) {
+	if key, ok := r.byID[keyID]; ok {
+		return key, nil
+	}
+	if r.any != nil {
+		return r.any, nil
+	}
+	return nil, fmt.Errorf("no key %q", keyID)
+}
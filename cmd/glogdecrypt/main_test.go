@@ -0,0 +1,78 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/biyizhen/glog"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	t.Setenv("TEST_LOG_KEY", key)
+	if err := glog.Init(glog.WithLogDir(dir), glog.WithEncryptKeyEnv("TEST_LOG_KEY")); err != nil {
+		t.Fatal(err)
+	}
+	glog.Info("card 4111 declined")
+	glog.Flush()
+	files, _ := filepath.Glob(filepath.Join(dir, "*.log.INFO.*"))
+	if len(files) != 1 {
+		t.Fatalf("log files %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("declined")) || bytes.Contains(data, []byte("Log file created")) {
+		t.Errorf("file in clear: %q", data)
+	}
+
+	var out bytes.Buffer
+	if err := run(key, files, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "Log file created at") || !strings.HasSuffix(out.String(), "] card 4111 declined\n") {
+		t.Errorf("got %q", out.String())
+	}
+
+	out.Reset()
+	if err := run("old "+other()+"\nenv:TEST_LOG_KEY "+key+"\n", nil, bytes.NewReader(data), &out); err != nil {
+		t.Errorf("key by ID: %v", err)
+	}
+	if err := run("old "+key+"\n", nil, bytes.NewReader(data), &out); err == nil {
+		t.Error("key of another ID accepted")
+	}
+	out.Reset()
+	if err := run(key, nil, bytes.NewReader(data[:len(data)-1]), &out); err == nil {
+		t.Error("truncated file accepted")
+	}
+	if err := run(other(), nil, bytes.NewReader(data), &out); err == nil {
+		t.Error("wrong key accepted")
+	}
+}
+
+// other returns a key that encrypted nothing.
+func other() string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, 32))
+}
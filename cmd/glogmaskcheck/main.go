@@ -0,0 +1,94 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command glogmaskcheck shows how glog would mask sample payloads, so that
+// masking policies can be reviewed before they are deployed.
+//
+// Usage:
+//
+//	glogmaskcheck [-config glog.yaml] [payload.json ...]
+//
+// Each file, or standard input if none is given, holds a sequence of JSON
+// values. They are decoded as a program would log a map or slice, masked by
+// the rules configured in the optional config file (see glog.LoadConfig),
+// and printed one per line. The rules are also checked with
+// glog.ValidateShrineRules; problems are reported and make the command exit
+// with status 1.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/biyizhen/glog"
+)
+
+func main() {
+	fs := flag.NewFlagSet("glogmaskcheck", flag.ExitOnError)
+	config := fs.String("config", "", "glog config file with the masking rules to check")
+	fs.Parse(os.Args[1:])
+	if err := run(*config, fs.Args(), os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "glogmaskcheck:", err)
+		os.Exit(1)
+	}
+}
+
+// run masks the payloads in files, or in stdin if there are none, and writes
+// them to stdout.
+func run(config string, files []string, stdin io.Reader, stdout io.Writer) error {
+	if config != "" {
+		if err := glog.LoadConfig(config); err != nil {
+			return err
+		}
+	}
+	if len(files) == 0 {
+		if err := check(stdin, stdout); err != nil {
+			return fmt.Errorf("stdin: %v", err)
+		}
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = check(f, stdout)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return glog.ValidateShrineRules()
+}
+
+// check masks each JSON value read from r and writes it to w.
+func check(r io.Reader, w io.Writer) error {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	for {
+		var v interface{}
+		if err := d.Decode(&v); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, glog.PreviewMasking(v)); err != nil {
+			return err
+		}
+	}
+}
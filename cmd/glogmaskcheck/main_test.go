@@ -0,0 +1,48 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	in := `{"mobile": "13812345678", "note": "ok"} ["plain"]`
+	var out bytes.Buffer
+	if err := run("", nil, strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	want := "map[mobile:138****5678 note:ok]\n[plain]\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	// A policy that shows whole card numbers fails validation.
+	config := filepath.Join(t.TempDir(), "glog.yaml")
+	data := "mask:\n  card:\n    keep_prefix: 7\n    keep_suffix: 8\n"
+	if err := os.WriteFile(config, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := run(config, nil, strings.NewReader(""), &out)
+	if err == nil || !strings.Contains(err.Error(), "card") {
+		t.Errorf("got error %v, want one about the card rule", err)
+	}
+}
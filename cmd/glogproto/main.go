@@ -0,0 +1,95 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command glogproto converts the protocol buffer records written by
+// glog.AddProtoWriter back to text.
+//
+// Usage:
+//
+//	glogproto [-json] [records ...]
+//
+// Each file, or standard input if none is given, holds a stream of
+// length-prefixed Record messages, as defined in glog.proto. They are
+// printed one per line in the format of the log files or, with -json, as
+// the JSON records of glog.AddJSONWriter.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/biyizhen/glog"
+)
+
+func main() {
+	fs := flag.NewFlagSet("glogproto", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print JSON records rather than text")
+	fs.Parse(os.Args[1:])
+	if err := run(*asJSON, fs.Args(), os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "glogproto:", err)
+		os.Exit(1)
+	}
+}
+
+// run converts the records in files, or in stdin if there are none, and
+// writes them to stdout.
+func run(asJSON bool, files []string, stdin io.Reader, stdout io.Writer) error {
+	if len(files) == 0 {
+		if err := convert(stdin, stdout, asJSON); err != nil {
+			return fmt.Errorf("stdin: %v", err)
+		}
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = convert(f, stdout, asJSON)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// convert writes each record read from r to w.
+func convert(r io.Reader, w io.Writer, asJSON bool) error {
+	pr := glog.NewProtoReader(r)
+	for {
+		rec, err := pr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var line []byte
+		if asJSON {
+			if line, err = json.Marshal(rec); err != nil {
+				return err
+			}
+			line = append(line, '\n')
+		} else {
+			line = []byte(rec.Text())
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+}
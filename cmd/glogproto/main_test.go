@@ -0,0 +1,57 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/biyizhen/glog"
+)
+
+func TestRun(t *testing.T) {
+	if err := glog.Init(glog.WithToStderr(true)); err != nil {
+		t.Fatal(err)
+	}
+	var records bytes.Buffer
+	remove := glog.AddProtoWriter("WARNING", &records)
+	glog.WithFields("order", 42).Warning("declined")
+	remove()
+	data := records.Bytes()
+
+	var out bytes.Buffer
+	if err := run(false, nil, bytes.NewReader(data), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "W") || !strings.HasSuffix(out.String(), " main_test.go:33] declined order=42\n") {
+		t.Errorf("got %q", out.String())
+	}
+
+	out.Reset()
+	if err := run(true, nil, bytes.NewReader(data), &out); err != nil {
+		t.Fatal(err)
+	}
+	r, err := glog.ParseRecord(out.Bytes())
+	if err != nil || r.Message != "declined" || r.Fields["order"] != float64(42) {
+		t.Errorf("got %+v, %v from %q", r, err, out.String())
+	}
+
+	if err := run(false, nil, bytes.NewReader(data[:len(data)-1]), &out); err == nil {
+		t.Error("truncated record accepted")
+	}
+}
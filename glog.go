@@ -35,20 +35,172 @@
 // Log output is buffered and written periodically using Flush. Programs
 // should call Flush before exiting to guarantee all log output is written.
 //
-// By default, all log statements write to files in a temporary directory.
+// By default, all log statements write to files in a temporary directory,
+// %TEMP% on Windows.
 // This package provides several flags that modify this behavior.
 // As a result, flag.Parse must be called before any logging is done.
+// Programs that do not use flags can call Init with the equivalent options
+// instead, and build with the glog_noflags tag to leave flag.CommandLine
+// untouched.
 //
 //	-logtostderr=false
 //		Logs are written to standard error instead of to files.
+//	-logtostdout=false
+//		Logs are written to standard output instead of to files or
+//		standard error.
+//	-log_container=false
+//		Container mode, for platforms such as Kubernetes that collect
+//		the output of processes: every line is written to standard
+//		output, unbuffered, as a JSON record like those of AddJSONWriter,
+//		and no log files are created.
 //	-alsologtostderr=false
 //		Logs are written to standard error as well as to files.
 //	-stderrthreshold=ERROR
 //		Log events at or above this severity are logged to standard
 //		error as well as to files.
+//	-alsologtostderr_v=-1
+//		Log events up to this V level are logged to standard error as
+//		well as to files, whatever their severity; lines not logged
+//		through V count as level 0. See SetAlsoToStderrV.
+//	-file_threshold=INFO
+//		Log events below this severity are not written to the log files,
+//		though they still go to standard error and added writers if
+//		their thresholds allow; see SetThreshold.
+//	-alsologtolower=true
+//		Log events are written to the log files of all lower severities
+//		as well as their own, so that the INFO file holds every line.
+//	-log_precreate=false
+//		Create the log files of all severities that can receive lines
+//		together with the first one. By default, each
+//		log file is created when the first line is written to it, so that
+//		processes that never log an ERROR have no ERROR file.
+//	-log_append=false
+//		Append to the log files left by an earlier run of the program
+//		if they were started in the current rotation interval and are
+//		below the size limit, rather than starting new ones; see
+//		SetAppendOnRestart.
 //	-log_dir=""
 //		Log files will be written to this directory instead of the
-//		default temporary directory.
+//		default temporary directory. A comma-separated list names
+//		directories tried in order; those that do not exist, are not
+//		writable or lack LogDirMinFree bytes are skipped and reported,
+//		see OnLogDirError and ValidateLogDirs.
+//	-log_failover=""
+//		Outputs, "stderr", "stdout" or "discard", that take the lines of
+//		a log file that cannot be created or written instead of the
+//		program exiting, per severity, as in
+//			-log_failover=INFO:stderr,discard;ERROR:stderr
+//		See SetFailover.
+//	-log_timezone=""
+//		Time zone used for log timestamps and rotation boundaries, such
+//		as "UTC" or "Asia/Shanghai". Empty means the local time zone.
+//		Daily rotation in a zone with daylight saving time gives days of
+//		23 and 25 hours; hourly rotation gives the hour repeated when the
+//		clocks go back a file of its own.
+//	-log_rotate_utc=false
+//		Start rotation intervals at the boundaries of UTC, whatever the
+//		time zone of timestamps; see SetRotateUTC.
+//	-log_encrypt_key_env=""
+//		Encrypt log files with AES-GCM under the base64-encoded AES key
+//		in this environment variable; see SetFileEncryption. Read them
+//		with the glogdecrypt command.
+//	-log_compress=""
+//		Compress log files once they are rotated, with "gzip" or a codec
+//		added with RegisterCodec such as zstd; see SetCompression.
+//	-log_caller=short
+//		How the source location is written in log headers: "short" for
+//		the file name, "full" for its full path, "package" for the file
+//		qualified by its package import path, or "none" to skip finding
+//		the caller altogether, which saves time at high volume.
+//	-log_caller_trim=""
+//		Directories, separated by commas, removed from the start of the
+//		file paths written with -log_caller=full, such as the root of
+//		the module; see SetCallerTrimPrefixes.
+//	-log_caller_func=false
+//		Write the name of the calling function after the file and line.
+//	-log_fingerprint=false
+//		End ERROR and FATAL lines with a fingerprint field that groups
+//		occurrences of the same error; see SetFingerprint.
+//	-log_exit_grace=10s
+//		How long Fatal, Exit and FlushAndExit wait for the log files to
+//		be flushed and for sinks such as TCPSink to send what they hold
+//		before the process exits; see SetExitGracePeriod.
+//	-log_fatal_stacks=all
+//		The goroutine stacks written after a FATAL line: "all", "current"
+//		for the failing goroutine only, or "none"; see SetFatalStacks.
+//	-log_fatal_stacks_max=0
+//		Limit in bytes of the stacks written after a FATAL line to the log
+//		files and standard error. Zero means no limit.
+//	-log_crash_file=false
+//		Also write FATAL lines with their whole stack dump to a crash
+//		file of their own, named with the time of the crash; see
+//		SetCrashFile.
+//	-log_crash_lines=0
+//		On a FATAL line that dumps the goroutine stacks, write the last N
+//		lines logged by any goroutine before it, kept in memory with
+//		-recent_log_kb, so that the lead-up to the crash is found next to
+//		the stacks. Zero writes none.
+//	-log_goroutine_id=false
+//		Write the ID of the logging goroutine, or the worker ID of the
+//		line, after the thread ID; see WorkerIDKey.
+//	-mask_max_depth=20
+//		Depth of the nested structs, maps and slices of logged values that
+//		are masked. Deeper values are replaced by "<max depth>" and values
+//		that contain themselves by "<cycle>". Zero means no limit.
+//	-mask_max_elements=0
+//		Number of elements of the maps and slices within logged values
+//		that are written; the others are replaced by a "..." marker. Zero
+//		means no limit.
+//	-mask_max_string=0
+//		Length in bytes beyond which the strings within logged values are
+//		cut and end with "...". Zero means no limit.
+//	-mask_rendered=false
+//		Mask values that implement fmt.Stringer or json.Marshaler by
+//		scrubbing the output of their String or MarshalJSON method.
+//	-mask_json=false
+//		Write masked structs, maps and slices as compact JSON rather than
+//		in the format of fmt.
+//	-leak_detection=false
+//		Scan logged lines for values that look like unmasked card numbers,
+//		identity card numbers and mobile phone numbers, counting them and
+//		warning once per call site.
+//	-log_labels=""
+//		Comma-separated list of key=value labels, such as
+//			-log_labels=service=api,env=prod
+//		written in the header of every line; see SetGlobalLabels.
+//	-flush_interval=30s
+//		How often the log files are flushed, by a daemon started with the
+//		first log file; see StopFlushDaemon.
+//	-log_batch_latency=0
+//		How long lines for standard output and standard error may wait
+//		to be written together with those that follow; see
+//		SetBatchLatency. Zero writes each line as it is logged.
+//	-flush_severity=""
+//		Lines at or above this severity, such as WARNING, flush the log
+//		files they are written to immediately, rather than at the next
+//		-flush_interval. Empty means that all lines are buffered.
+//	-flush_severity_sync=false
+//		Also sync the files to disk when -flush_severity flushes them.
+//	-log_sync=interval
+//		When the log files are synced to disk: "never"; "fatal", only on
+//		exit through Fatal or Exit; "interval", at each periodic flush; a
+//		duration such as 5s, at most that long after a line is written;
+//		or a severity such as ERROR, after each line at or above it. See
+//		SetSyncPolicy.
+//	-maxlogmessagelen=-1
+//		Lines longer than this, header included, are truncated. Values
+//		not greater than the header length, 64, disable truncation.
+//	-maxlogmessagelen_by_severity=""
+//		Comma-separated list of SEVERITY=limit overriding
+//		-maxlogmessagelen, such as
+//			-maxlogmessagelen_by_severity=ERROR=65536,INFO=4096
+//	-log_truncate_bytes=false
+//		Count the limits in bytes rather than runes, to bound disk usage
+//		precisely. Lines are still cut between runes.
+//	-log_truncate_suffix="..."
+//		Suffix of truncated lines, counted in the limit. "%d" is replaced
+//		by the number of bytes or runes removed, as in
+//			-log_truncate_suffix="...[truncated %d bytes]"
 //
 //	Other flags provide aids to debugging.
 //
@@ -67,6 +219,21 @@
 //		"glob" pattern and N is a V level. For instance,
 //			-vmodule=gopher*=3
 //		sets the V level to 3 in all Go files whose names begin "gopher".
+//		A pattern containing slashes is matched against as many trailing
+//		elements of the file's path, so that
+//			-vmodule=mypkg/internal/*=3,github.com/org/repo/db/conn=2
+//		covers the files of a directory, or one file by its import path,
+//		among files of the same name elsewhere.
+//	-timing_v=0
+//		V level at which TimeTrack and Scope log elapsed times. At 0 they
+//		are always logged; see SetTimingLevel.
+//	-vlogger=""
+//		Like -vmodule, but for loggers returned by Named: a comma-separated
+//		list of name=N, where name is a logger name or "glob" pattern. A
+//		setting also applies to the descendants of the loggers it matches;
+//		for instance,
+//			-vlogger=payments.*=3
+//		sets the V level to 3 for all loggers below "payments".
 //
 package glog
 
@@ -94,9 +261,9 @@ import (
 // the flag.Value interface. The -stderrthreshold flag is of type severity and
 // should be modified only through the flag.Value interface. The values match
 // the corresponding constants in C++.
-type severity int32  // sync/atomic int32
-type printtype int32 // print type int32
-type shrinetype int  // shrine type int
+type severity = Severity // sync/atomic int32
+type printtype int32     // print type int32
+type shrinetype int      // shrine type int
 // These constants identify the log levels in order of increasing severity.
 // A message written to a high-severity log file is also written to each
 // lower-severity log file.
@@ -110,6 +277,7 @@ const (
 	tprint printtype = iota
 	tprintln
 	tprintf
+	tmsg // The message is the format string, written as is.
 
 	shrineCardType shrinetype = iota
 	shrinePhoneType
@@ -126,6 +294,9 @@ var (
 	IsEmailRe              = regexp.MustCompile(`^([a-z_A-Z.0-9-])+@([a-zA-Z0-9_-])+\.([a-zA-Z0-9_-])+`)
 	IsPhoneNumberCountryRe = regexp.MustCompile(`^\d{1,}\-\d{1,}$`)
 	IsNumber               = regexp.MustCompile(`^[0-9]*$`)
+	IsBankAccountRe        = regexp.MustCompile(`^[0-9]{6,34}$`)
+	IsIBANRe               = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	IsPassportRe           = regexp.MustCompile(`^[A-Za-z0-9]{5,20}$`)
 )
 
 const severityChar = "IWEF"
@@ -147,11 +318,6 @@ func (s *severity) set(val severity) {
 	atomic.StoreInt32((*int32)(s), int32(val))
 }
 
-// String is part of the flag.Value interface.
-func (s *severity) String() string {
-	return strconv.FormatInt(int64(*s), 10)
-}
-
 // Get is part of the flag.Value interface.
 func (s *severity) Get() interface{} {
 	return *s
@@ -269,6 +435,7 @@ type modulePat struct {
 	pattern string
 	literal bool // The pattern is a literal string
 	level   Level
+	path    int // Number of path elements of the pattern, if more than one.
 }
 
 // match reports whether the file matches the pattern. It uses a string
@@ -305,6 +472,24 @@ var errVmoduleSyntax = errors.New("syntax error: expect comma-separated list of
 
 // Syntax: -vmodule=recordio=2,file=1,gfs*=3
 func (m *moduleSpec) Set(value string) error {
+	filter, err := parseVModule(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.setVState(logging.verbosity, filter, true)
+	return nil
+}
+
+// parseVModule parses the value of the -vmodule flag.
+func parseVModule(value string) ([]modulePat, error) {
+	return parsePatterns(value, false)
+}
+
+// parsePatterns parses a comma-separated list of pattern=N settings. Settings
+// of level 0 are dropped unless keepZero is set.
+func parsePatterns(value string, keepZero bool) ([]modulePat, error) {
 	var filter []modulePat
 	for _, pat := range strings.Split(value, ",") {
 		if len(pat) == 0 {
@@ -313,26 +498,58 @@ func (m *moduleSpec) Set(value string) error {
 		}
 		patLev := strings.Split(pat, "=")
 		if len(patLev) != 2 || len(patLev[0]) == 0 || len(patLev[1]) == 0 {
-			return errVmoduleSyntax
+			return nil, errVmoduleSyntax
 		}
 		pattern := patLev[0]
 		v, err := strconv.Atoi(patLev[1])
 		if err != nil {
-			return errors.New("syntax error: expect comma-separated list of filename=N")
+			return nil, errors.New("syntax error: expect comma-separated list of filename=N")
 		}
 		if v < 0 {
-			return errors.New("negative value for vmodule level")
+			return nil, errors.New("negative value for vmodule level")
 		}
-		if v == 0 {
+		if v == 0 && !keepZero {
 			continue // Ignore. It's harmless but no point in paying the overhead.
 		}
 		// TODO: check syntax of filter?
-		filter = append(filter, modulePat{pattern, isLiteral(pattern), Level(v)})
+		filter = append(filter, modulePat{pattern, isLiteral(pattern), Level(v), pathElems(pattern)})
 	}
-	logging.mu.Lock()
-	defer logging.mu.Unlock()
-	logging.setVState(logging.verbosity, filter, true)
-	return nil
+	return filter, nil
+}
+
+// pathElems returns the number of slash-separated elements of a -vmodule
+// pattern such as "mypkg/internal/*", or 0 if it is a plain file name.
+func pathElems(pattern string) int {
+	if n := strings.Count(pattern, "/"); n > 0 {
+		return n + 1
+	}
+	return 0
+}
+
+// pathTail returns the last n slash-separated elements of file, which must
+// have the ".go" suffix removed, or "" if it has fewer. Module versions, as in
+// "github.com/pkg/errors@v0.9.1", are dropped so that patterns can be
+// written as import paths.
+func pathTail(file string, n int) string {
+	i := len(file)
+	for ; n > 0; n-- {
+		j := strings.LastIndexByte(file[:i], '/')
+		if j < 0 && n > 1 {
+			return ""
+		}
+		i = j
+	}
+	tail := file[i+1:]
+	if strings.IndexByte(tail, '@') < 0 {
+		return tail
+	}
+	elems := strings.Split(tail, "/")
+	for k, e := range elems {
+		if at := strings.IndexByte(e, '@'); at >= 0 {
+			elems[k] = e[:at]
+		}
+	}
+	return strings.Join(elems, "/")
 }
 
 // isLiteral reports whether the pattern is a literal string, that is, has no metacharacters
@@ -408,34 +625,94 @@ func (t *traceLocation) Set(value string) error {
 	defer logging.mu.Unlock()
 	t.line = v
 	t.file = file
+	if t == &logging.traceLocation {
+		atomic.StoreInt32(&logging.traceActive, 1)
+	}
 	return nil
 }
 
-// flushSyncWriter is the interface satisfied by logging destinations.
-type flushSyncWriter interface {
-	Flush() error
-	Sync() error
-	io.Writer
-}
-
 func init() {
-	flag.BoolVar(&logging.toStderr, "logtostderr", false, "log to standard error instead of files")
-	flag.BoolVar(&logging.alsoToStderr, "alsologtostderr", false, "log to standard error as well as files")
-	flag.Var(&logging.verbosity, "v", "log level for V logs")
-	flag.Var(&logging.stderrThreshold, "stderrthreshold", "logs at or above this threshold go to stderr")
-	flag.Var(&logging.vmodule, "vmodule", "comma-separated list of pattern=N settings for file-filtered logging")
-	flag.Var(&logging.traceLocation, "log_backtrace_at", "when logging hits line file:N, emit a stack trace")
-	flag.DurationVar(&logging.flushInterval, "flush_interval", defaultFlushInterval, "how often flush file")
-	flag.IntVar(&logging.maxLogMessageLen, "maxlogmessagelen", -1, "when logging a very long log message, this value greater than 64 it will be truncate")
-
-	// Default stderrThreshold is ERROR.
+	// Defaults for settings that may be overridden by flags or Init.
 	logging.stderrThreshold = errorLog
+	logging.flushInterval = defaultFlushInterval
+	logging.flushSeverity = noFlushSeverity
+	logging.syncPolicy = SyncPolicy{Mode: SyncInterval}
+	logging.syncSeverity = errorLog
+	logging.maxLogMessageLen = -1
+	logging.truncateSuffix = defaultTruncateSuffix
+	logging.alsoToLower = true
+	logging.maskMaxDepth = defaultMaskMaxDepth
 
 	// Default filter card/salary/identity
 	logging.SetFilter(true, true, true, true, true, true, true)
 
 	logging.setVState(0, nil, false)
-	go logging.flushDaemon()
+}
+
+// RegisterFlags defines the logging flags, such as -v and -log_dir, on fs.
+// Unless the program is built with the glog_noflags tag, they are defined on
+// flag.CommandLine at init time; libraries that embed this package and want
+// to keep the host's flag namespace clean should use that tag and call
+// RegisterFlags with their own FlagSet, or configure logging with Init.
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&logging.toStderr, "logtostderr", logging.toStderr, "log to standard error instead of files")
+	fs.BoolVar(&logging.toStdout, "logtostdout", logging.toStdout, "log to standard output instead of files or standard error")
+	fs.BoolVar(&logging.container, "log_container", logging.container, "container mode: log JSON records to standard output and create no files")
+	fs.BoolVar(&logging.alsoToStderr, "alsologtostderr", logging.alsoToStderr, "log to standard error as well as files")
+	fs.BoolVar(&logging.alsoToLower, "alsologtolower", logging.alsoToLower, "write lines to the log files of lower severities as well as their own")
+	fs.BoolVar(&logging.appendFile, "log_append", logging.appendFile, "append to the current log files of an earlier run of the program rather than starting new ones")
+	fs.BoolVar(&logging.precreate, "log_precreate", logging.precreate, "create the log files of all severities with the first one rather than on their first line")
+	fs.Var(&logging.verbosity, "v", "log level for V logs")
+	fs.Var(&logging.stderrThreshold, "stderrthreshold", "logs at or above this threshold go to stderr")
+	fs.Var(alsoStderrVValue{}, "alsologtostderr_v", "logs up to this V level go to stderr as well as files, whatever their severity; plain lines are V level 0; negative means none")
+	fs.Var(&logging.fileThreshold, "file_threshold", "logs below this threshold are not written to the log files")
+	fs.Var(&logging.vmodule, "vmodule", "comma-separated list of pattern=N settings for file-filtered logging")
+	fs.Var(loggerSpecValue{}, "vlogger", "comma-separated list of name=N settings for named loggers")
+	fs.Var(maskDepthValue{}, "mask_max_depth", "depth to which nested logged values are masked; deeper values are replaced; 0 means no limit")
+	fs.Var(maskElementsValue{}, "mask_max_elements", "number of elements of nested maps and slices of logged values written; 0 means no limit")
+	fs.Var(maskStringValue{}, "mask_max_string", "length beyond which strings within logged values are cut; 0 means no limit")
+	fs.Var(maskRenderedValue{}, "mask_rendered", "mask Stringer and json.Marshaler values by scrubbing their rendering")
+	fs.Var(maskJSONValue{}, "mask_json", "write masked structs, maps and slices as compact JSON")
+	fs.Var(leakDetectionValue{}, "leak_detection", "scan logged lines for unmasked sensitive values and warn where they are logged")
+	fs.Var(labelsValue{}, "log_labels", "comma-separated list of key=value labels written in every log line")
+	fs.Var(&logging.traceLocation, "log_backtrace_at", "when logging hits line file:N, emit a stack trace")
+	fs.Var(flushIntervalValue{}, "flush_interval", "how often flush file")
+	fs.Var(batchLatencyValue{}, "log_batch_latency", "how long lines for standard output and error may wait to be written together; 0 writes each line at once")
+	fs.Var(flushSeverityValue{}, "flush_severity", "lines at or above this severity flush the log files immediately")
+	fs.Var(flushSyncValue{}, "flush_severity_sync", "also sync the log files to disk when -flush_severity flushes them")
+	fs.Var(timingLevelValue{}, "timing_v", "V level at which TimeTrack and Scope log elapsed times")
+	fs.Var(syncValue{}, "log_sync", "when log files are synced to disk: never, fatal, interval (at each flush), a duration such as 5s, or a severity such as ERROR")
+	fs.IntVar(&logging.maxLogMessageLen, "maxlogmessagelen", logging.maxLogMessageLen, "when logging a very long log message, this value greater than 64 it will be truncate")
+	fs.Var(maxLenValue{}, "maxlogmessagelen_by_severity", "per-severity overrides of -maxlogmessagelen, e.g. ERROR=65536,INFO=4096")
+	fs.Var(truncateBytesValue{}, "log_truncate_bytes", "count the -maxlogmessagelen limits in bytes rather than runes")
+	fs.Var(truncateSuffixValue{}, "log_truncate_suffix", "suffix of truncated lines; %d is replaced by the number of bytes or runes removed")
+	fs.Var(callerModeValue{}, "log_caller", "how to write the caller in log headers: short, full, package or none")
+	fs.Var(callerTrimValue{}, "log_caller_trim", "directories, separated by commas, removed from the start of the file paths written with -log_caller=full")
+	fs.Var(callerFuncValue{}, "log_caller_func", "write the calling function after the file and line in log headers")
+	fs.Var(fingerprintValue{}, "log_fingerprint", "end ERROR and FATAL lines with a fingerprint field that groups occurrences of the same error")
+	fs.Var(goroutineIDValue{}, "log_goroutine_id", "write the goroutine ID, or the worker ID of the line, after the thread ID in log headers")
+	fs.Var(locationValue{}, "log_timezone", "time zone for log timestamps and rotation boundaries, e.g. UTC; empty means local time")
+	fs.StringVar(logDir, "log_dir", *logDir, "If non-empty, write log files in this directory, or the first usable one of a comma-separated list")
+	fs.StringVar(LogRotateInterval, "rotate_interval", *LogRotateInterval,
+		"Set the rolling log intervals to be months, days, hours, minutes, and seconds, and values correspond to 'month', 'day', 'hour', 'minute', 'second' respectively")
+	fs.BoolVar(&logging.rotateUTC, "log_rotate_utc", logging.rotateUTC, "start rotation intervals at boundaries of UTC rather than of -log_timezone")
+	fs.DurationVar(&logging.maxAge, "log_max_age", logging.maxAge, "delete log files older than this when rotating; 0 keeps them")
+	fs.IntVar(&logging.maxFiles, "log_max_files", logging.maxFiles, "keep at most this many log files of each severity; 0 keeps them all")
+	fs.Var(encryptKeyEnvValue{}, "log_encrypt_key_env", "encrypt log files with the base64 AES key in this environment variable; see SetFileEncryption")
+	fs.Var(compressValue{}, "log_compress", "compress rotated log files with this codec: gzip, or one added with RegisterCodec such as zstd; empty leaves them as they are")
+	fs.Var(failoverValue{}, "log_failover", "per-severity outputs used when a log file fails, e.g. INFO:stderr,discard;ERROR:stderr")
+	fs.Var(rotationValue{}, "log_rotation", "per-severity rotation settings, e.g. ERROR:max_size=104857600,interval=hour,max_age=720h,max_files=10;WARNING:...")
+	fs.Var(recentSizeValue{}, "recent_log_kb", "keep the last N KB of each severity in memory for RecentLogs; 0 disables")
+	fs.Var(exitGraceValue{}, "log_exit_grace", "how long Fatal and FlushAndExit wait for the logs and sinks to be flushed before exiting")
+	fs.Var(fatalStacksValue{}, "log_fatal_stacks", "goroutine stacks written after a FATAL line: all, current or none")
+	fs.Var(stacksMaxValue{}, "log_fatal_stacks_max", "limit in bytes of the stacks written after a FATAL line to the log files and stderr; 0 means no limit")
+	fs.BoolVar(&logging.crashFile, "log_crash_file", logging.crashFile, "also write FATAL lines with the whole stack dump to a timestamped CRASH file in the log directory")
+	fs.IntVar(&logging.crashLines, "log_crash_lines", logging.crashLines, "on FATAL, write the last N lines kept by -recent_log_kb before the goroutine stacks")
+	if fs == flag.CommandLine {
+		logging.mu.Lock()
+		logging.needFlagParse = true
+		logging.mu.Unlock()
+	}
 }
 
 // Flush flushes all pending log I/O.
@@ -443,19 +720,54 @@ func Flush() {
 	logging.lockAndFlushAll()
 }
 
+// Close prepares for shutdown: it stops the flush daemon and any output
+// shards, then flushes, syncs and closes the log files, including sinks set
+// with SetSink, and the audit log,
+// returning the first error. Lines logged after Close are written to new log
+// files but are only flushed by Flush or Close. Writers added with AddWriter
+// and sinks such as TCPSink are flushed but not closed. Close may be called
+// more than once.
+func Close() error {
+	StopFlushDaemon()
+	SetOutputShards(0)
+	err := logging.closeFiles()
+	if e := closeAudit(); err == nil {
+		err = e
+	}
+	return err
+}
+
 // loggingT collects all the global state of the logging setup.
 type loggingT struct {
 	// Boolean flags. Not handled atomically because the flag.Value interface
 	// does not let us avoid the =true, and that shorthand is necessary for
 	// compatibility. TODO: does this matter enough to fix? Seems unlikely.
 	toStderr     bool // The -logtostderr flag.
+	toStdout     bool // The -logtostdout flag.
+	container    bool // The -log_container flag.
 	alsoToStderr bool // The -alsologtostderr flag.
+	alsoToLower  bool // The -alsologtolower flag.
+	precreate    bool // The -log_precreate flag.
+	appendFile   bool // The -log_append flag.
+	rotateUTC    bool // The -log_rotate_utc flag.
 
-	// Level flag. Handled atomically.
+	// Level flags. Handled atomically.
 	stderrThreshold severity // The -stderrthreshold flag.
+	fileThreshold   severity // The -file_threshold flag.
+	// V thresholds of the outputs, set by SetVThreshold. Handled atomically.
+	stderrV vThreshold
+	fileV   vThreshold
+	// alsoStderrV is the -alsologtostderr_v flag. Handled atomically.
+	alsoStderrV vThreshold
+	maxV        int32 // Highest V threshold of any output, including writers.
 
 	// freeList is a list of byte buffers, maintained under freeListMu.
 	freeList *buffer
+	// freeCount is the length of freeList, and bufPool sets how buffers
+	// are recycled, in freeList or syncPool. Both are guarded by freeListMu.
+	freeCount int
+	bufPool   BufferPool
+	syncPool  sync.Pool
 	// freeListMu maintains the free list. It is separate from the main mutex
 	// so buffers can be grabbed and printed to without holding the main lock,
 	// for better parallelization.
@@ -464,8 +776,9 @@ type loggingT struct {
 	// mu protects the remaining elements of this structure and is
 	// used to synchronize logging.
 	mu sync.Mutex
-	// file holds writer for each of the log types.
-	file [numSeverity]flushSyncWriter
+	// file holds writer for each of the log types: a *syncBuffer, a Sink set
+	// with SetSink, or nil until the first line creates the log file.
+	file [numSeverity]Sink
 	// pcs is used in V to avoid an allocation when computing the caller's PC.
 	pcs [1]uintptr
 	// vmap is a cache of the V Level for each V() call site, identified by PC.
@@ -481,8 +794,18 @@ type loggingT struct {
 	// safely using atomic.LoadInt32.
 	vmodule   moduleSpec // The state of the -vmodule flag.
 	verbosity Level      // V logging level, the value of the -v flag/
-	// how often flush file
+	// how often flush file; guarded by flushd.mu rather than mu.
 	flushInterval time.Duration
+	// Lines at or above flushSeverity flush their files as they are written,
+	// and sync them if flushSync is set; see SetFlushSeverity.
+	flushSeverity severity
+	flushSync     bool
+	// syncPolicy sets when files are synced, with syncSeverity its parsed
+	// Severity and lastSync the time of the last periodic sync; see
+	// SetSyncPolicy.
+	syncPolicy   SyncPolicy
+	syncSeverity severity
+	lastSync     time.Time
 	// usage:
 	// type User struct {
 	//     Name     string
@@ -505,8 +828,76 @@ type loggingT struct {
 	filterEmail    bool
 	filterPwd      bool
 	filterCompany  bool
+	// maskMaxDepth is the depth to which nested values are masked; deeper
+	// values are replaced. Zero means no limit. Accessed atomically.
+	maskMaxDepth int32
+	// maskMaxElements and maskMaxString limit the number of elements of
+	// maps and slices and the length of strings within logged values; zero
+	// means no limit. Accessed atomically.
+	maskMaxElements int32
+	maskMaxString   int32
+	// maskRendered is set if Stringer and json.Marshaler values are masked
+	// by scrubbing their rendering; see SetMaskRendered. Accessed atomically.
+	maskRendered uint32
 	// truncate long log message. default -1, less than headerLength(64) will not truncate
 	maxLogMessageLen int
+	// maxLen holds the per-severity overrides of maxLogMessageLen, and
+	// truncateBytes and truncateSuffix how lines are truncated; see
+	// SetTruncation.
+	maxLen         [numSeverity]int
+	truncateBytes  bool
+	truncateSuffix string
+	// location holds the *time.Location used for timestamps and rotation
+	// boundaries. A nil location means time.Local.
+	location atomic.Value
+	// rotation holds the per-severity overrides of the rotation settings,
+	// and maxAge and maxFiles the retention of log files; see
+	// SetRotationPolicy.
+	rotation [numSeverity]RotationPolicy
+	maxAge   time.Duration
+	maxFiles int
+	// compress names the codec compressing rotated files, if any; see
+	// SetCompression.
+	compress string
+	// fileKeys provides the keys encrypting log files, if any; see
+	// SetFileEncryption. encryptEnv is the -log_encrypt_key_env flag.
+	fileKeys   KeyProvider
+	encryptEnv string
+	// failover holds the fallback chains of the log files; see SetFailover.
+	failover [numSeverity]failover
+	// batchLatency is how long lines for standard output and error may
+	// wait in stdoutBatch and stderrBatch; see SetBatchLatency.
+	batchLatency             time.Duration
+	stdoutBatch, stderrBatch batch
+	// traceActive is non-zero if traceLocation is set. It may be read
+	// safely using atomic.LoadInt32.
+	traceActive int32
+	// shards holds the *shardedOutput installed by SetOutputShards, nil
+	// when lines are written synchronously.
+	shards atomic.Value
+	// callerMode and callerFunc control the caller information in log
+	// headers; see SetCallerMode and SetCallerFunc. They are accessed
+	// atomically.
+	callerMode int32
+	callerFunc uint32
+	// needFlagParse is set when the flags are registered on flag.CommandLine
+	// and cleared by Init. While it is set, logging before flag.Parse only
+	// goes to standard error.
+	needFlagParse bool
+	// tees holds the additional writers registered with AddWriter.
+	tees []*teeWriter
+	// recent holds the in-memory buffers read by RecentLogs, nil if disabled.
+	recent   [numSeverity]*ringBuffer
+	recentKB int
+	// crashLines is the number of recent lines written with the stacks of
+	// a FATAL line; see -log_crash_lines.
+	crashLines int
+	// fatalStacks and stacksMax select the stacks dumped by a FATAL line,
+	// and crashFile whether they also go to a crash file; see
+	// SetFatalStacks and SetCrashFile.
+	fatalStacks StackDump
+	stacksMax   int
+	crashFile   bool
 }
 
 // buffer holds a byte Buffer for reuse. The zero value is ready for use.
@@ -514,6 +905,19 @@ type buffer struct {
 	bytes.Buffer
 	tmp  [64]byte // temporary byte array for creating headers.
 	next *buffer
+	// Set by formatHeader for building structured records.
+	when   time.Time // Timestamp of the log line.
+	hdrLen int       // Length of the header preceding the message.
+	// Set by printEntry for lines of a Logger or Entry.
+	name     string  // Name of the Logger, if any.
+	fields   []Field // Fields of the Entry, masked.
+	fieldsAt int     // Offset of the fields following the message.
+	// Set by attachStack, or for FATAL lines by output.
+	stack   []byte // Goroutine stack of the line, if any.
+	stackAt int    // Offset of the stack in the line, if written there.
+	v        vLine   // V level of a line of Logger.V.
+	// Set by formatHeader with -log_goroutine_id.
+	idAt, idEnd int // Offsets of the goroutine or worker ID in the header.
 }
 
 var logging loggingT
@@ -563,15 +967,28 @@ func (l *loggingT) setVState(verbosity Level, filter []modulePat, setFilter bool
 // getBuffer returns a new, ready-to-use buffer.
 func (l *loggingT) getBuffer() *buffer {
 	l.freeListMu.Lock()
+	p := l.bufPool
 	b := l.freeList
 	if b != nil {
 		l.freeList = b.next
+		l.freeCount--
 	}
 	l.freeListMu.Unlock()
+	if p.SyncPool {
+		b, _ = l.syncPool.Get().(*buffer)
+	}
 	if b == nil {
 		b = new(buffer)
+		if p.InitialSize > 0 {
+			b.Grow(p.InitialSize)
+		}
 	} else {
 		b.next = nil
+		b.name = ""
+		b.fields, b.fieldsAt = nil, 0
+		b.stack, b.stackAt = nil, 0
+		b.idAt, b.idEnd = 0, 0
+		b.v = vLine{}
 		b.Reset()
 	}
 	return b
@@ -579,17 +996,69 @@ func (l *loggingT) getBuffer() *buffer {
 
 // putBuffer returns a buffer to the free list.
 func (l *loggingT) putBuffer(b *buffer) {
-	if b.Len() >= 256 {
+	l.freeListMu.Lock()
+	defer l.freeListMu.Unlock()
+	max := l.bufPool.MaxRetained
+	if max == 0 {
+		max = defaultMaxRetained
+	}
+	if b.Len() >= max {
 		// Let big buffers die a natural death.
 		return
 	}
-	l.freeListMu.Lock()
+	if l.bufPool.SyncPool {
+		l.syncPool.Put(b)
+		return
+	}
+	if l.bufPool.MaxBuffers > 0 && l.freeCount >= l.bufPool.MaxBuffers {
+		return
+	}
 	b.next = l.freeList
 	l.freeList = b
-	l.freeListMu.Unlock()
+	l.freeCount++
 }
 
-var timeNow = time.Now // Stubbed out for testing.
+// now returns the current time in the configured log location.
+func (l *loggingT) now() time.Time {
+	t := currentClock().Now()
+	if loc, ok := l.location.Load().(*time.Location); ok && loc != nil {
+		return t.In(loc)
+	}
+	return t
+}
+
+// SetLocation sets the time zone used for log timestamps, file names and
+// rotation boundaries. A nil location restores the default, time.Local.
+// Using the same zone (typically time.UTC) on every host makes files roll
+// at consistent boundaries regardless of where the process runs.
+func SetLocation(loc *time.Location) {
+	logging.location.Store(loc)
+}
+
+// locationValue implements flag.Value for the -log_timezone flag.
+type locationValue struct{}
+
+// String is part of the flag.Value interface.
+func (locationValue) String() string {
+	if loc, ok := logging.location.Load().(*time.Location); ok && loc != nil {
+		return loc.String()
+	}
+	return ""
+}
+
+// Set is part of the flag.Value interface.
+func (locationValue) Set(value string) error {
+	if value == "" {
+		SetLocation(nil)
+		return nil
+	}
+	loc, err := time.LoadLocation(value)
+	if err != nil {
+		return err
+	}
+	SetLocation(loc)
+	return nil
+}
 
 /*
 header formats a log header as defined by the C++ implementation.
@@ -597,34 +1066,38 @@ It returns a buffer containing the formatted header and the user's file and line
 The depth specifies how many stack frames above lives the source line to be identified in the log message.
 
 Log lines have this form:
-	Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg...
+	Lmmdd hh:mm:ss.uuuuuu threadid[ goid][ labels] file:line[ func]] msg...
 where the fields are defined as follows:
 	L                A single character, representing the log level (eg 'I' for INFO)
 	mm               The month (zero padded; ie May is '05')
 	dd               The day (zero padded)
 	hh:mm:ss.uuuuuu  Time in hours, minutes and fractional seconds
 	threadid         The space-padded thread ID as returned by GetTID()
-	file             The file name
+	goid             The goroutine ID, as g17, or the worker ID of the line,
+	                 if -log_goroutine_id is set
+	labels           The key=value labels set by SetGlobalLabels, if any
+	file             The file name, as selected by -log_caller
 	line             The line number
+	func             The function name, if -log_caller_func is set
 	msg              The user-supplied message
 */
 func (l *loggingT) header(s severity, depth int) (*buffer, string, int) {
-	_, file, line, ok := runtime.Caller(3 + depth)
-	if !ok {
-		file = "???"
-		line = 1
-	} else {
-		slash := strings.LastIndex(file, "/")
-		if slash >= 0 {
-			file = file[slash+1:]
-		}
+	file, line, fn, _ := l.caller(3 + depth)
+	buf := l.formatHeader(s, file, line)
+	if fn != "" {
+		// Insert the function name before the closing "] ".
+		buf.Truncate(buf.Len() - 2)
+		buf.WriteByte(' ')
+		buf.WriteString(fn)
+		buf.WriteString("] ")
+		buf.hdrLen = buf.Len()
 	}
-	return l.formatHeader(s, file, line), file, line
+	return buf, file, line
 }
 
 // formatHeader formats a log header using the provided file name and line number.
 func (l *loggingT) formatHeader(s severity, file string, line int) *buffer {
-	now := timeNow()
+	now := l.now()
 	if line < 0 {
 		line = 0 // not a real line number, but acceptable to someDigits
 	}
@@ -651,14 +1124,25 @@ func (l *loggingT) formatHeader(s severity, file string, line int) *buffer {
 	buf.nDigits(6, 15, now.Nanosecond()/1000, '0')
 	buf.tmp[21] = ' '
 	buf.nDigits(7, 22, pid, ' ') // TODO: should be TID
-	buf.tmp[29] = ' '
-	buf.Write(buf.tmp[:30])
-	buf.WriteString(file)
-	buf.tmp[0] = ':'
-	n := buf.someDigits(1, line)
-	buf.tmp[n+1] = ']'
-	buf.tmp[n+2] = ' '
-	buf.Write(buf.tmp[:n+3])
+	buf.Write(buf.tmp[:29])
+	buf.writeGoroutineID()
+	if ls := globalLabels.Load().(*labelSet); ls.text != "" {
+		buf.WriteString(ls.text)
+	}
+	if file == "" {
+		// The caller is not captured; see CallerNone.
+		buf.WriteString("] ")
+	} else {
+		buf.WriteByte(' ')
+		buf.WriteString(file)
+		buf.tmp[0] = ':'
+		n := buf.someDigits(1, line)
+		buf.tmp[n+1] = ']'
+		buf.tmp[n+2] = ' '
+		buf.Write(buf.tmp[:n+3])
+	}
+	buf.when = now
+	buf.hdrLen = buf.Len()
 	return buf
 }
 
@@ -705,11 +1189,7 @@ func (buf *buffer) someDigits(i, d int) int {
 
 func (l *loggingT) println(s severity, args ...interface{}) {
 	buf, file, line := l.header(s, 0)
-	if l.filterCard || l.filterIdentity || l.filterPhone {
-		l.filter(tprintln, buf, "", args...)
-	} else {
-		fmt.Fprintln(buf, args...)
-	}
+	l.formatArgs(buf, tprintln, "", args)
 	l.output(s, buf, file, line, false)
 }
 
@@ -719,11 +1199,7 @@ func (l *loggingT) print(s severity, args ...interface{}) {
 
 func (l *loggingT) printDepth(s severity, depth int, args ...interface{}) {
 	buf, file, line := l.header(s, depth)
-	if l.filterCard || l.filterIdentity || l.filterPhone {
-		l.filter(tprint, buf, "", args...)
-	} else {
-		fmt.Fprint(buf, args...)
-	}
+	l.formatArgs(buf, tprint, "", args)
 	if buf.Bytes()[buf.Len()-1] != '\n' {
 		buf.WriteByte('\n')
 	}
@@ -732,11 +1208,7 @@ func (l *loggingT) printDepth(s severity, depth int, args ...interface{}) {
 
 func (l *loggingT) printf(s severity, format string, args ...interface{}) {
 	buf, file, line := l.header(s, 0)
-	if l.filterCard || l.filterIdentity || l.filterPhone {
-		l.filter(tprintf, buf, format, args...)
-	} else {
-		fmt.Fprintf(buf, format, args...)
-	}
+	l.formatArgs(buf, tprintf, format, args)
 	if buf.Bytes()[buf.Len()-1] != '\n' {
 		buf.WriteByte('\n')
 	}
@@ -745,23 +1217,134 @@ func (l *loggingT) printf(s severity, format string, args ...interface{}) {
 
 func (l *loggingT) printfDepth(s severity, depth int, format string, args ...interface{}) {
 	buf, file, line := l.header(s, depth)
-	if l.filterCard || l.filterIdentity || l.filterPhone {
-		l.filter(tprintf, buf, format, args...)
-	} else {
-		fmt.Fprint(buf, args...)
+	l.formatArgs(buf, tprintf, format, args)
+	if buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
 	}
+	l.output(s, buf, file, line, false)
+}
+
+// printfStackDepth is like printfDepth, but attaches stack, a goroutine stack
+// as returned by runtime.Stack, to the line.
+func (l *loggingT) printfStackDepth(s severity, depth int, stack []byte, format string, args ...interface{}) {
+	buf, file, line := l.header(s, depth)
+	l.formatArgs(buf, tprintf, format, args)
 	if buf.Bytes()[buf.Len()-1] != '\n' {
 		buf.WriteByte('\n')
 	}
+	buf.attachStack(stack)
 	l.output(s, buf, file, line, false)
 }
 
+// formatArgs formats args in the manner of fmt.Print, Println or Printf,
+// according to t, masking sensitive values if masking is on. Tokens in the
+// format and string arguments are redacted if the pwd filter is on. Arguments of
+// basic types, which never need masking, take a fast path that avoids
+// reflection and, for Print, fmt.
+func (l *loggingT) formatArgs(buf *buffer, t printtype, format string, args []interface{}) {
+	resolveLazy(args)
+	if l.filterPwd {
+		l.redactArgs(args)
+		if t == tprintf || t == tmsg {
+			format = l.redactTokens(format)
+		}
+	}
+	if l.masking() && !basicArgs(args) {
+		l.filter(t, buf, format, args)
+		return
+	}
+	formatPlain(buf, t, format, args)
+}
+
+// formatPlain formats args like formatArgs, without masking.
+func formatPlain(buf *buffer, t printtype, format string, args []interface{}) {
+	switch t {
+	case tprint:
+		if !appendPrint(buf, args) {
+			fmt.Fprint(buf, args...)
+		}
+	case tprintln:
+		fmt.Fprintln(buf, args...)
+	case tprintf:
+		fmt.Fprintf(buf, format, args...)
+	case tmsg:
+		buf.WriteString(format)
+	}
+}
+
+// resolveLazy replaces the arguments of type func() interface{} or
+// func() string by their results.
+func resolveLazy(args []interface{}) {
+	for i, arg := range args {
+		switch f := arg.(type) {
+		case func() interface{}:
+			args[i] = f()
+		case func() string:
+			args[i] = f()
+		}
+	}
+}
+
+// basicArgs reports whether all args are of basic types or errors, which
+// transform leaves unchanged.
+func basicArgs(args []interface{}) bool {
+	for _, arg := range args {
+		switch arg.(type) {
+		case string, bool, int, int8, int16, int32, int64,
+			uint, uint8, uint16, uint32, uint64, uintptr, float32, float64, error:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// appendPrint writes args to buf as fmt.Print would if they are all strings,
+// integers or booleans, and reports whether it did. Like fmt.Print, it adds
+// spaces between operands when neither is a string.
+func appendPrint(buf *buffer, args []interface{}) bool {
+	for _, arg := range args {
+		switch arg.(type) {
+		case string, int, int64, int32, uint, uint64, uint32, bool:
+		default:
+			return false
+		}
+	}
+	for i, arg := range args {
+		if i > 0 {
+			_, prevString := args[i-1].(string)
+			if _, isString := arg.(string); !isString && !prevString {
+				buf.WriteByte(' ')
+			}
+		}
+		switch v := arg.(type) {
+		case string:
+			buf.WriteString(v)
+		case int:
+			buf.Write(strconv.AppendInt(buf.tmp[:0], int64(v), 10))
+		case int64:
+			buf.Write(strconv.AppendInt(buf.tmp[:0], v, 10))
+		case int32:
+			buf.Write(strconv.AppendInt(buf.tmp[:0], int64(v), 10))
+		case uint:
+			buf.Write(strconv.AppendUint(buf.tmp[:0], uint64(v), 10))
+		case uint64:
+			buf.Write(strconv.AppendUint(buf.tmp[:0], v, 10))
+		case uint32:
+			buf.Write(strconv.AppendUint(buf.tmp[:0], uint64(v), 10))
+		case bool:
+			buf.Write(strconv.AppendBool(buf.tmp[:0], v))
+		}
+	}
+	return true
+}
+
 // filter - if type is struct, it's tag is card or identity or phone, mask this.
 // handle if type is []*Type, *Type, Struct in log struct.
-func (l *loggingT) filter(t printtype, buf io.Writer, format string, args ...interface{}) {
+func (l *loggingT) filter(t printtype, buf io.Writer, format string, args []interface{}) {
 	if len(args) > 0 {
 		for i := range args {
-			args[i] = l.transform(args[i])
+			args[i] = asMaskedJSON(l.transform(args[i]))
 		}
 	}
 
@@ -835,6 +1418,24 @@ func (l *loggingT) switchTag(val *reflect.Value, field *reflect.StructField, con
 			} else {
 				container[field.Name] = val.Interface()
 			}
+		case tag == "bankacct":
+			if ok && l.filterCard {
+				container[field.Name] = ShrineBankAccount(str)
+			} else {
+				container[field.Name] = val.Interface()
+			}
+		case tag == "iban":
+			if ok && l.filterCard {
+				container[field.Name] = ShrineIBAN(str)
+			} else {
+				container[field.Name] = val.Interface()
+			}
+		case tag == "passport":
+			if ok && l.filterIdentity {
+				container[field.Name] = ShrinePassport(str)
+			} else {
+				container[field.Name] = val.Interface()
+			}
 		default:
 			container[field.Name] = val.Interface()
 		}
@@ -865,6 +1466,12 @@ func (l *loggingT) switchTagSlice(val *reflect.Value, field *reflect.StructField
 			*container = append(*container, ShrinePwdStr())
 		case tag == "company":
 			*container = append(*container, ShrineCompanyName(str))
+		case tag == "bankacct":
+			*container = append(*container, ShrineBankAccount(str))
+		case tag == "iban":
+			*container = append(*container, ShrineIBAN(str))
+		case tag == "passport":
+			*container = append(*container, ShrinePassport(str))
 		default:
 			*container = append(*container, val.Interface())
 		}
@@ -874,9 +1481,99 @@ func (l *loggingT) switchTagSlice(val *reflect.Value, field *reflect.StructField
 // and the mobile phone number through the reflection structure tag
 // and slice key
 func (l *loggingT) transform(v interface{}) interface{} {
+	st := &maskState{
+		maxDepth:    int(atomic.LoadInt32(&l.maskMaxDepth)),
+		maxElements: int(atomic.LoadInt32(&l.maskMaxElements)),
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		st.path = append(st.path, rv.Pointer())
+	}
+	ret := l.transformValue(v, st)
+	if max := atomic.LoadInt32(&l.maskMaxString); max > 0 {
+		ret = limitStrings(ret, int(max))
+	}
+	return ret
+}
+
+// maskState tracks the progress of transform through nested values.
+type maskState struct {
+	depth       int
+	maxDepth    int
+	maxElements int       // Elements of maps and slices written; 0 for all.
+	path        []uintptr // Pointers, maps and slices being transformed.
+}
+
+const (
+	maskCycle    = "<cycle>"     // Replaces values that contain themselves.
+	maskTooDeep  = "<max depth>" // Replaces values nested too deeply.
+	maxDerefHops = 16            // Bounds pointer chains, which may loop.
+)
+
+// deref follows the pointers and interfaces in v. It returns the zero Value
+// for nil.
+func deref(v reflect.Value) reflect.Value {
+	for i := 0; i < maxDerefHops && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface); i++ {
+		v = v.Elem()
+	}
+	return v
+}
+
+// transformNested transforms v, found inside the value being transformed,
+// replacing it if it is part of a cycle or nested too deeply.
+func (l *loggingT) transformNested(v reflect.Value, st *maskState) interface{} {
+	if st.maxDepth > 0 && st.depth >= st.maxDepth {
+		return maskTooDeep
+	}
+	if v.IsValid() && v.CanInterface() {
+		// Before deref, which loses pointer receiver methods.
+		if s, ok := l.renderMasked(v.Interface()); ok {
+			return s
+		}
+	}
+	n := len(st.path)
+	defer func() { st.path = st.path[:n] }()
+	for i := 0; i <= maxDerefHops; i++ {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice:
+			if v.IsNil() {
+				return nil
+			}
+			p := v.Pointer()
+			for _, q := range st.path {
+				if p == q && (v.Kind() != reflect.Slice || v.Len() > 0) {
+					return maskCycle
+				}
+			}
+			st.path = append(st.path, p)
+		case reflect.Interface:
+			if v.IsNil() {
+				return nil
+			}
+		}
+		if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface {
+			break
+		}
+		v = v.Elem()
+	}
+	st.depth++
+	defer func() { st.depth-- }()
+	return l.transformValue(v.Interface(), st)
+}
+
+// transformValue does the work of transform.
+func (l *loggingT) transformValue(v interface{}, st *maskState) interface{} {
+	if m, ok := maskByType(reflect.ValueOf(v)); ok {
+		return m
+	}
 	if _, ok := v.(error); ok {
 		return v
 	}
+	if m, ok := l.maskHTTP(v); ok {
+		return m
+	}
+	if s, ok := l.renderMasked(v); ok {
+		return s
+	}
 	// returns the value that v points to.
 	val := reflect.Indirect(reflect.ValueOf(v))
 	// Avoid panic
@@ -892,68 +1589,10 @@ func (l *loggingT) transform(v interface{}) interface{} {
 
 	switch val.Kind() {
 	case reflect.Struct:
-		// ret used to temporarily store information to be printed
-		ret := make(map[string]interface{}, val.NumField())
-		for i := 0; i < val.NumField(); i++ {
-			outerVal := reflect.Indirect(val.Field(i))
-
-			if !outerVal.IsValid() || !outerVal.CanInterface() {
-				continue
-			}
-			field := val.Type().Field(i)
-
-			if outerVal.Kind() == reflect.Interface {
-				outerVal = reflect.Indirect(reflect.ValueOf(outerVal.Interface()))
-			}
-
-			if !outerVal.IsValid() || !outerVal.CanInterface() {
-				continue
-			}
-
-			switch outerVal.Kind() {
-			case reflect.Map, reflect.Array, reflect.Struct, reflect.Interface:
-				ret[field.Name] = l.transform(outerVal.Interface())
-			case reflect.Slice:
-				strSliceFlag := false
-				tmpSlice := make([]interface{}, 0)
-
-				for idx := 0; idx < outerVal.Len(); idx++ {
-					innerVal := reflect.Indirect(outerVal.Index(idx))
-					if !innerVal.IsValid() || !innerVal.CanInterface() {
-						continue
-					}
-
-					if innerVal.Kind() == reflect.Interface {
-						innerVal = reflect.Indirect(reflect.ValueOf(innerVal.Interface()))
-					}
-
-					if !innerVal.IsValid() || !innerVal.CanInterface() {
-						continue
-					}
-
-					switch innerVal.Kind() {
-					case reflect.String:
-						l.switchTagSlice(&innerVal, &field, &tmpSlice, idx)
-					default:
-						strSliceFlag = true
-						break
-					}
-				}
-				if strSliceFlag {
-					ret[field.Name] = l.transform(outerVal.Interface())
-				} else {
-					ret[field.Name] = tmpSlice
-				}
-			case reflect.String:
-				l.switchTag(&outerVal, &field, ret, i)
-			default:
-				ret[field.Name] = outerVal.Interface()
-			}
-		}
-		return ret
+		return l.transformStruct(val, st)
 	case reflect.Map:
 		ret := make(map[string]interface{}, val.Len())
-		keys := val.MapKeys()
+		keys := st.limitKeys(val.MapKeys())
 		for _, key := range keys {
 			if !key.IsValid() || !key.CanInterface() {
 				continue
@@ -963,38 +1602,28 @@ func (l *loggingT) transform(v interface{}) interface{} {
 			if !ok {
 				continue
 			}
-			mapVal := reflect.Indirect(val.MapIndex(key))
+			elem := val.MapIndex(key)
+			mapVal := deref(elem)
 
 			if !mapVal.IsValid() || !mapVal.CanInterface() {
 				continue
 			}
-
-			if mapVal.Kind() == reflect.Interface {
-				mapVal = reflect.Indirect(reflect.ValueOf(mapVal.Interface()))
-			}
-
-			if !mapVal.IsValid() || !mapVal.CanInterface() {
+			if m, ok := maskByType(elem); ok {
+				ret[keyStr] = m
 				continue
 			}
 
 			switch mapVal.Kind() {
 			case reflect.Map, reflect.Array, reflect.Struct, reflect.Interface:
-				ret[keyStr] = l.transform(mapVal.Interface())
+				ret[keyStr] = l.transformNested(val.MapIndex(key), st)
 			case reflect.Slice:
 				// for handle type of map[string][]string
 				strSliceFlag := false
-				tmpSlice := make([]interface{}, mapVal.Len())
-
-				for i := 0; i < mapVal.Len(); i++ {
-					innerVal := reflect.Indirect(mapVal.Index(i))
-					if !innerVal.IsValid() || !innerVal.CanInterface() {
-						continue
-					}
-
-					if innerVal.Kind() == reflect.Interface {
-						innerVal = reflect.Indirect(reflect.ValueOf(innerVal.Interface()))
-					}
+				n := st.elements(mapVal.Len())
+				tmpSlice := make([]interface{}, n, n+1)
 
+				for i := 0; i < n; i++ {
+					innerVal := deref(mapVal.Index(i))
 					if !innerVal.IsValid() || !innerVal.CanInterface() {
 						continue
 					}
@@ -1028,9 +1657,12 @@ func (l *loggingT) transform(v interface{}) interface{} {
 				}
 
 				if strSliceFlag {
+					if n < mapVal.Len() {
+						tmpSlice = append(tmpSlice, moreElements(mapVal.Len()-n))
+					}
 					ret[keyStr] = tmpSlice
 				} else {
-					ret[keyStr] = l.transform(mapVal.Interface())
+					ret[keyStr] = l.transformNested(val.MapIndex(key), st)
 				}
 			case reflect.String:
 				// handle type of map[string]string, map["card_no"] = "612846129387468123" etc.
@@ -1046,6 +1678,10 @@ func (l *loggingT) transform(v interface{}) interface{} {
 				haveEmail := keyStr == "email" || keyStr == "Email" || strings.Contains(keyStr, "EMAIL")
 				havePwd := strings.Contains(strings.ToUpper(keyStr), "PWD") || strings.Contains(strings.ToUpper(keyStr), "PASSWORD")
 				haveCompany := strings.Contains(strings.ToUpper(keyStr), "PRODUCT_NAME") || strings.Contains(strings.ToUpper(keyStr), "BROKER_NAME") || strings.Contains(strings.ToUpper(keyStr), "DEALER_NAME") || strings.Contains(strings.ToUpper(keyStr), "ENTERPRISE_NAME")
+				haveBankAccount := keyStr == "bank_account" || keyStr == "bankAccount" || keyStr == "account_no"
+				haveIBAN := strings.ToUpper(keyStr) == "IBAN"
+				havePassport := strings.Contains(strings.ToUpper(keyStr), "PASSPORT")
+				haveToken := isTokenKey(keyStr)
 
 				switch {
 				case haveCard:
@@ -1102,48 +1738,151 @@ func (l *loggingT) transform(v interface{}) interface{} {
 					} else {
 						ret[keyStr] = mapVal.Interface()
 					}
+				case haveBankAccount:
+					if l.filterCard {
+						ret[keyStr] = ShrineBankAccount(mapVal.Interface().(string))
+					} else {
+						ret[keyStr] = mapVal.Interface()
+					}
+				case haveIBAN:
+					if l.filterCard {
+						ret[keyStr] = ShrineIBAN(mapVal.Interface().(string))
+					} else {
+						ret[keyStr] = mapVal.Interface()
+					}
+				case havePassport:
+					if l.filterIdentity {
+						ret[keyStr] = ShrinePassport(mapVal.Interface().(string))
+					} else {
+						ret[keyStr] = mapVal.Interface()
+					}
+				case haveToken:
+					if l.filterPwd {
+						ret[keyStr] = ShrinePwdStr()
+					} else {
+						ret[keyStr] = mapVal.Interface()
+					}
 				default:
-					ret[keyStr] = mapVal.Interface()
+					if str, ok := mapVal.Interface().(string); ok {
+						ret[keyStr] = l.redactTokens(str)
+					} else {
+						ret[keyStr] = mapVal.Interface()
+					}
 				}
 			default:
 				ret[keyStr] = mapVal.Interface()
 			}
 		}
+		if len(keys) < val.Len() {
+			ret[maskMore] = moreMarker(strconv.Itoa(val.Len()-len(keys)) + " more")
+		}
 		return ret
 	case reflect.Array, reflect.Slice:
-		ret := make([]interface{}, val.Len())
-		for i := 0; i < val.Len(); i++ {
-			outerVal := reflect.Indirect(val.Index(i))
+		n := st.elements(val.Len())
+		ret := make([]interface{}, n, n+1)
+		for i := 0; i < n; i++ {
+			outerVal := deref(val.Index(i))
 			if !outerVal.IsValid() || !outerVal.CanInterface() {
 				continue
 			}
-
-			if outerVal.Kind() == reflect.Interface {
-				outerVal = reflect.Indirect(reflect.ValueOf(outerVal.Interface()))
-			}
-
-			if !outerVal.IsValid() || !outerVal.CanInterface() {
+			if m, ok := maskByType(val.Index(i)); ok {
+				ret[i] = m
 				continue
 			}
 
 			switch outerVal.Kind() {
 			case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Interface:
-				ret[i] = l.transform(outerVal.Interface())
+				ret[i] = l.transformNested(val.Index(i), st)
 			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 				return v
 			default:
 				ret[i] = outerVal.Interface()
 			}
 		}
+		if n < val.Len() {
+			ret = append(ret, moreElements(val.Len()-n))
+		}
 		return ret
 	case reflect.Interface:
 		tempVal := val.Interface()
-		return l.transform(tempVal)
+		return l.transformValue(tempVal, st)
 	default:
 		return v
 	}
+}
 
-	return v
+// transformStruct transforms the struct val into a map of its fields.
+func (l *loggingT) transformStruct(val reflect.Value, st *maskState) map[string]interface{} {
+	// ret used to temporarily store information to be printed
+	ret := make(map[string]interface{}, val.NumField())
+	var embedded []map[string]interface{}
+	for i := 0; i < val.NumField(); i++ {
+		outerVal := deref(val.Field(i))
+		field := val.Type().Field(i)
+
+		if field.Anonymous && val.Field(i).Kind() == reflect.Struct && !val.Field(i).CanInterface() {
+			// The fields promoted from an unexported embedded struct
+			// are written as the struct's own, as by encoding/json.
+			embedded = append(embedded, l.transformStruct(val.Field(i), st))
+			continue
+		}
+		if !outerVal.IsValid() || !outerVal.CanInterface() {
+			continue
+		}
+		if m, ok := maskByType(val.Field(i)); ok {
+			ret[field.Name] = m
+			continue
+		}
+
+		switch outerVal.Kind() {
+		case reflect.Map, reflect.Array, reflect.Struct, reflect.Interface:
+			ret[field.Name] = l.transformNested(val.Field(i), st)
+		case reflect.Slice:
+			strSliceFlag := false
+			tmpSlice := make([]interface{}, 0)
+			n := st.elements(outerVal.Len())
+
+			for idx := 0; idx < n; idx++ {
+				innerVal := deref(outerVal.Index(idx))
+				if !innerVal.IsValid() || !innerVal.CanInterface() {
+					continue
+				}
+				if m, ok := maskByType(outerVal.Index(idx)); ok {
+					tmpSlice = append(tmpSlice, m)
+					continue
+				}
+
+				switch innerVal.Kind() {
+				case reflect.String:
+					l.switchTagSlice(&innerVal, &field, &tmpSlice, idx)
+				default:
+					strSliceFlag = true
+					break
+				}
+			}
+			if strSliceFlag {
+				ret[field.Name] = l.transformNested(val.Field(i), st)
+			} else {
+				if n < outerVal.Len() {
+					tmpSlice = append(tmpSlice, moreElements(outerVal.Len()-n))
+				}
+				ret[field.Name] = tmpSlice
+			}
+		case reflect.String:
+			l.switchTag(&outerVal, &field, ret, i)
+		default:
+			ret[field.Name] = outerVal.Interface()
+		}
+	}
+	// The struct's own fields take precedence over promoted ones.
+	for _, fields := range embedded {
+		for name, v := range fields {
+			if _, ok := ret[name]; !ok {
+				ret[name] = v
+			}
+		}
+	}
+	return ret
 }
 
 // printWithFileLine behaves like print but uses the provided file and line number.  If
@@ -1160,65 +1899,73 @@ func (l *loggingT) printWithFileLine(s severity, file string, line int, alsoToSt
 
 // output writes the data to the log files and releases the buffer.
 func (l *loggingT) output(s severity, buf *buffer, file string, line int, alsoToStderr bool) {
+	if l.suppressed(s, buf, file, line) || l.sampledOut(s, buf.when) {
+		l.putBuffer(buf)
+		return
+	}
+	if s >= errorLog && atomic.LoadUint32(&fingerprints) != 0 {
+		buf.addFingerprint(file, line)
+	}
+	if !l.runHooks(s, buf, file, line) {
+		l.putBuffer(buf)
+		return
+	}
+	if atomic.LoadUint32(&leakDetection) != 0 {
+		l.detectLeaks(buf, file, line)
+	}
+	if o := l.sharded(); o != nil {
+		if s < fatalLog && atomic.LoadInt32(&l.traceActive) == 0 && o.enqueue(s, buf, file, line, alsoToStderr) {
+			return
+		}
+		// Keep the order of lines, and run traces and exits on the
+		// logging goroutine.
+		o.drain()
+	}
 	l.mu.Lock()
 	if l.traceLocation.isSet() {
 		if l.traceLocation.match(file, line) {
-			buf.Write(stacks(false))
+			buf.attachStack(stacks(false))
 		}
 	}
-	data := buf.Bytes()
-
-	if l.maxLogMessageLen > headerLength {
-		runes := []rune(string(data))
-		if len(runes) > l.maxLogMessageLen {
-			data = []byte(string(runes[:l.maxLogMessageLen-3]) + "...\n")
-		}
-	}
-	if !flag.Parsed() {
-		os.Stderr.Write([]byte("ERROR: logging before flag.Parse: "))
-		os.Stderr.Write(data)
-	} else if l.toStderr {
-		os.Stderr.Write(data)
-	} else {
-		if alsoToStderr || l.alsoToStderr || s >= l.stderrThreshold.get() {
-			os.Stderr.Write(data)
-		}
-		if l.file[s] == nil {
-			if err := l.createFiles(s); err != nil {
-				os.Stderr.Write(data) // Make sure the message appears somewhere.
-				l.exit(err)
-			}
-		}
-		switch s {
-		case fatalLog:
-			l.file[fatalLog].Write(data)
-			fallthrough
-		case errorLog:
-			l.file[errorLog].Write(data)
-			fallthrough
-		case warningLog:
-			l.file[warningLog].Write(data)
-			fallthrough
-		case infoLog:
-			l.file[infoLog].Write(data)
-		}
+	if s == fatalLog && buf.stack == nil && l.fatalStacks != StacksNone && atomic.LoadUint32(&fatalNoStacks) == 0 {
+		// For the records of the line; the text is followed by the dump.
+		buf.stack = stacks(false)
 	}
+	n := l.writeLine(s, buf, file, line, alsoToStderr)
 	if s == fatalLog {
+		// Only one goroutine at a time dumps and exits: a FATAL line logged
+		// while another is exiting waits, so that it cannot end the process
+		// before the first dump is flushed.
+		if !fatalMu.TryLock() {
+			l.mu.Unlock()
+			fatalMu.Lock()
+			l.mu.Lock()
+		}
+		defer fatalMu.Unlock()
+		l.flushBatches() // So that the stacks follow the line.
+		exit := osExit
 		// If we got here via Exit rather than Fatal, print no stacks.
-		if atomic.LoadUint32(&fatalNoStacks) > 0 {
+		if atomic.SwapUint32(&fatalNoStacks, 0) > 0 {
 			l.mu.Unlock()
-			timeoutFlush(10 * time.Second)
-			os.Exit(1)
+			exitFlush()
+			exit(int(atomic.LoadInt32(&exitCode)))
+			l.putBuffer(buf) // If the exit function returns.
+			countLine(s, n)
+			return
 		}
-		// Dump all goroutine stacks before exiting.
+		// Dump the goroutine stacks selected by SetFatalStacks before exiting.
 		// First, make sure we see the trace for the current goroutine on standard error.
 		// If -logtostderr has been specified, the loop below will do that anyway
 		// as the first stack in the full dump.
+		crash := l.crashContext()
+		files, stderr, whole := l.stackDump()
 		if !l.toStderr {
-			os.Stderr.Write(stacks(false))
+			os.Stderr.Write(crash)
+			os.Stderr.Write(stderr)
 		}
+		l.writeCrashFile(append(append(append([]byte(nil), buf.Bytes()...), crash...), whole...))
 		// Write the stack trace for all goroutines to the files.
-		trace := stacks(true)
+		trace := append(crash, files...)
 		logExitFunc = func(error) {} // If we get a write error, we'll still exit below.
 		for log := fatalLog; log >= infoLog; log-- {
 			if f := l.file[log]; f != nil { // Can be nil if -logtostderr is set.
@@ -1226,31 +1973,97 @@ func (l *loggingT) output(s severity, buf *buffer, file string, line int, alsoTo
 			}
 		}
 		l.mu.Unlock()
-		timeoutFlush(10 * time.Second)
-		os.Exit(255) // C++ uses -1, which is silly because it's anded with 255 anyway.
+		exitFlush()
+		exit(int(atomic.LoadInt32(&fatalExitCode)))
+		l.putBuffer(buf)
+		countLine(s, n)
+		return
 	}
 	l.putBuffer(buf)
 	l.mu.Unlock()
-	if stats := severityStats[s]; stats != nil {
-		atomic.AddInt64(&stats.lines, 1)
-		atomic.AddInt64(&stats.bytes, int64(len(data)))
+	countLine(s, n)
+}
+
+// writeLine writes the line formatted in buf to the outputs for severity s
+// and returns the number of bytes written, which is less than the length of
+// buf if the line was truncated.
+// l.mu is held.
+func (l *loggingT) writeLine(s severity, buf *buffer, file string, line int, alsoToStderr bool) int {
+	data := l.truncate(s, buf.Bytes())
+	if l.needFlagParse && !flag.Parsed() {
+		os.Stderr.Write([]byte("ERROR: logging before flag.Parse: "))
+		os.Stderr.Write(data)
+	} else if l.toStdout || l.container {
+		l.writeStdout(s, buf, file, line, data)
+	} else if l.toStderr {
+		if l.stderrV.allows(buf.v) {
+			l.writeStderr(data)
+		}
+	} else {
+		toStderr := alsoToStderr || l.alsoToStderr || s >= l.stderrThreshold.get() || l.alsoStderrV.selects(buf.v)
+		var written outputSet // Fallback outputs that have the line.
+		if toStderr && l.stderrV.allows(buf.v) {
+			l.writeStderr(data)
+			written = outputBit(OutputStderr)
+		}
+		// Lines go to the file of their severity and, unless disabled,
+		// to those of all lower severities.
+		lowest := s
+		if l.alsoToLower {
+			lowest = infoLog
+		}
+		if s < l.fileThreshold.get() || !l.fileV.allows(buf.v) {
+			lowest = s + 1
+		}
+		for f := s; f >= lowest; f-- {
+			if !l.failedOver(f) && l.file[f] == nil {
+				if err := l.createFiles(f); err != nil && !l.failOver(f, err) {
+					l.writeStderr(data) // Make sure the message appears somewhere.
+					l.exit(err)
+					continue
+				}
+			}
+			if !l.failedOver(f) {
+				// A failure fails over, in Write for log files.
+				if _, err := l.file[f].Write(data); err != nil {
+					if _, ok := l.file[f].(*syncBuffer); !ok {
+						l.fileError(f, err)
+					}
+				}
+			}
+			if l.failedOver(f) {
+				l.writeFailover(f, data, &written)
+				continue
+			}
+			l.flushLine(s, f)
+		}
+		if l.syncDue(buf.when, false) {
+			l.flushFiles(true)
+		}
+	}
+	l.writeTees(s, buf, file, line, data)
+	l.writeRecent(s, data)
+	return len(data)
+}
+
+// writeStdout writes data, a line of severity s formatted in buf, to standard
+// output, as a JSON record in container mode.
+// l.mu is held.
+func (l *loggingT) writeStdout(s severity, buf *buffer, file string, line int, data []byte) {
+	if !l.container {
+		l.writeBatched(&l.stdoutBatch, &os.Stdout, data)
+		return
 	}
+	rec := newLogRecord(s, buf, file, line, buf.Bytes())
+	l.fitRecord(s, rec)
+	l.writeBatched(&l.stdoutBatch, &os.Stdout, rec.encodeJSON())
 }
 
-// timeoutFlush calls Flush and returns when it completes or after timeout
-// elapses, whichever happens first.  This is needed because the hooks invoked
-// by Flush may deadlock when glog.Fatal is called from a hook that holds
-// a lock.
-func timeoutFlush(timeout time.Duration) {
-	done := make(chan bool, 1)
-	go func() {
-		Flush() // calls logging.lockAndFlushAll()
-		done <- true
-	}()
-	select {
-	case <-done:
-	case <-time.After(timeout):
-		fmt.Fprintln(os.Stderr, "glog: Flush took longer than", timeout)
+// countLine updates the statistics for a line of n bytes of severity s.
+func countLine(s severity, n int) {
+	if stats := severityStats[s]; stats != nil {
+		atomic.AddInt64(&stats.lines, 1)
+		atomic.AddInt64(&stats.bytes, int64(n))
 	}
 }
 
@@ -1279,10 +2092,46 @@ func stacks(all bool) []byte {
 // would make its use clumsier.
 var logExitFunc func(error)
 
+// osExit terminates the process. It is os.Exit unless replaced by SetExitFunc.
+var osExit = os.Exit
+
+// Exit statuses used when Fatal and Exit terminate the process.
+var (
+	fatalExitCode int32 = 255 // C++ uses -1, which is silly because it's anded with 255 anyway.
+	exitCode      int32 = 1
+)
+
+// SetExitFunc replaces os.Exit as the function used to terminate the process
+// after Fatal, Exit or an unrecoverable error writing log files. Passing nil
+// restores os.Exit. The logs have been flushed by the time f is called.
+//
+// Tests may install a function that does not exit in order to observe fatal
+// logging; in that case the logging call returns normally after f returns.
+func SetExitFunc(f func(code int)) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	if f == nil {
+		f = os.Exit
+	}
+	osExit = f
+}
+
+// SetExitCodes sets the exit statuses passed to the exit function by Fatal
+// (default 255) and by Exit (default 1), so that supervisors can tell a
+// fatal-log exit apart from other failures.
+func SetExitCodes(fatal, exit int) {
+	atomic.StoreInt32(&fatalExitCode, int32(fatal))
+	atomic.StoreInt32(&exitCode, int32(exit))
+}
+
 // exit is called if there is trouble creating or writing log files.
 // It flushes the logs and exits the program; there's no point in hanging around.
+// If SetErrorHandler installed a handler, it is called instead.
 // l.mu is held.
 func (l *loggingT) exit(err error) {
+	if handleError(err) {
+		return
+	}
 	fmt.Fprintf(os.Stderr, "log: exiting because of error: %s\n", err)
 	// If logExitFunc is set, we do that instead of exiting.
 	if logExitFunc != nil {
@@ -1290,7 +2139,7 @@ func (l *loggingT) exit(err error) {
 		return
 	}
 	l.flushAll()
-	os.Exit(2)
+	osExit(2)
 }
 
 // syncBuffer joins a bufio.Writer to its underlying file, providing access to the
@@ -1301,58 +2150,136 @@ type syncBuffer struct {
 	logger *loggingT
 	*bufio.Writer
 	file           *os.File
+	out            io.Writer // Written by Writer: file, or an encryptWriter around it.
+	name           string // The path of file.
+	base           string // The name of file without its sequence number.
+	seq            int    // The sequence number of file, among those started within a second.
 	sev            severity
 	nbytes         uint64    // The number of bytes written to this file
 	nextRotateTime time.Time // Time of next rotate
+	rotateTimer    Timer     // Fires at nextRotateTime.
+	rotateDue      bool      // nextRotateTime has passed.
+	rotateGen      uint64    // Counts scheduled rotations, to ignore stale timers.
 }
 
 func (sb *syncBuffer) Sync() error {
+	if sb.file == nil {
+		return nil // Not created; Write will try again.
+	}
 	return sb.file.Sync()
 }
 
+// Close closes the file. Buffered data must have been flushed.
+func (sb *syncBuffer) Close() error {
+	if sb.rotateTimer != nil {
+		sb.rotateTimer.Stop()
+	}
+	return sb.file.Close()
+}
+
 func (sb *syncBuffer) Write(p []byte) (n int, err error) {
-	if sb.shouldRotateFile(uint64(len(p))) {
-		if err := sb.rotateFile(time.Now()); err != nil {
-			sb.logger.exit(err)
+	// A nil file is one that could not be created: try again.
+	if sb.file == nil || sb.shouldRotateFile(uint64(len(p))) {
+		if err := sb.rotateFile(sb.logger.now()); err != nil {
+			sb.logger.fileError(sb.sev, err)
+			return 0, err
 		}
 	}
 	n, err = sb.Writer.Write(p)
 	sb.nbytes += uint64(n)
 	if err != nil {
-		sb.logger.exit(err)
+		sb.logger.fileError(sb.sev, err)
 	}
 	return
 }
 
-// shouldRotateFile check whether should rotate file
+// shouldRotateFile check whether should rotate file. Time-based rotation
+// is marked due by the timer set by scheduleRotation, so that lines do not
+// read the clock.
 func (sb *syncBuffer) shouldRotateFile(l uint64) bool {
-	return sb.nbytes+l >= MaxSize ||
-		!time.Now().Before(sb.nextRotateTime)
+	return sb.rotateDue || sb.nbytes+l >= sb.logger.maxSize(sb.sev)
 }
 
-// rotateFile closes the syncBuffer's file and starts a new one.
+// rotateFile closes the syncBuffer's file and starts a new one. The closed
+// file ends with a line naming the new one, which starts with header lines,
+// including one naming the closed file.
 func (sb *syncBuffer) rotateFile(now time.Time) error {
+	if sb.name == "" && sb.logger.appendFile {
+		if ok, err := sb.appendExisting(now); ok {
+			return err
+		}
+	}
+	tag := severityName[sb.sev]
+	base, _ := logName(tag, now, 0)
+	seq := 0
+	if base == sb.base {
+		// Rotated again within the second in the last name.
+		seq = sb.seq + 1
+	}
+	file, name, seq, err := create(tag, now, seq)
+	sb.base, sb.seq = base, seq
+	oldName := ""
 	if sb.file != nil {
+		if err == nil {
+			fmt.Fprintf(sb.Writer, "Log file continues in: %s\n", name)
+		}
 		sb.Flush()
 		sb.file.Close()
+		oldName = sb.name
 	}
-	var err error
-	sb.file, _, err = create(severityName[sb.sev], now)
+	sb.file, sb.name = file, name
 	sb.nbytes = 0
-	sb.nextRotateTime = getStartOfNextTime(time.Now())
+	sb.scheduleRotation(now)
 	if err != nil {
 		return err
 	}
+	if oldName != "" {
+		rotated(oldName, sb.name, sb.sev, sb.logger.compress)
+	}
+	sb.pruneLogs(now)
 
-	sb.Writer = bufio.NewWriterSize(sb.file, bufferSize)
+	if err := sb.startFile(); err != nil {
+		return err
+	}
+	return sb.writeHeader("Log file created at", now, oldName)
+}
 
-	// Write header.
+// startFile sets up the writers of sb.file, which encrypt it if
+// SetFileEncryption is set.
+// l.mu is held.
+func (sb *syncBuffer) startFile() error {
+	sb.out = sb.file
+	if sb.logger.fileKeys != nil {
+		id, key, err := sb.logger.fileKeys.CurrentKey()
+		if err != nil {
+			return err
+		}
+		w, err := newEncryptWriter(sb.file, id, key)
+		if err != nil {
+			return err
+		}
+		sb.out = w
+	}
+	sb.Writer = bufio.NewWriterSize(sb.out, bufferSize)
+	return nil
+}
+
+// writeHeader writes the header lines that start the part of sb's file
+// written by this process, the first of which says what happened at now.
+func (sb *syncBuffer) writeHeader(what string, now time.Time, oldName string) error {
 	var buf bytes.Buffer
-	fmt.Fprintf(&buf, "Log file created at: %s\n", now.Format("2006/01/02 15:04:05"))
+	fmt.Fprintf(&buf, "%s: %s\n", what, now.Format("2006/01/02 15:04:05"))
 	fmt.Fprintf(&buf, "Running on machine: %s\n", host)
+	fmt.Fprintf(&buf, "Process ID: %d\n", pid)
+	if oldName != "" {
+		fmt.Fprintf(&buf, "Previous log file: %s\n", oldName)
+	}
 	fmt.Fprintf(&buf, "Binary: Built with %s %s for %s/%s\n", runtime.Compiler, runtime.Version(), runtime.GOOS, runtime.GOARCH)
 	fmt.Fprintf(&buf, "Log line format: [IWEF]mmdd hh:mm:ss.uuuuuu threadid file:line] msg\n")
-	n, err := sb.file.Write(buf.Bytes())
+	if b := currentBuild(); b != nil {
+		buf.WriteString(b.banner())
+	}
+	n, err := sb.out.Write(buf.Bytes())
 	sb.nbytes += uint64(n)
 	return err
 }
@@ -1362,13 +2289,27 @@ func (sb *syncBuffer) rotateFile(now time.Time) error {
 // on disk I/O. The flushDaemon will block instead.
 const bufferSize = 256 * 1024
 
-// createFiles creates all the log files for severity from sev down to infoLog.
+// createFiles creates all the log files for severity from sev down to infoLog,
+// or only that of sev if lines are not copied to lower severities.
 // l.mu is held.
 func (l *loggingT) createFiles(sev severity) error {
-	now := time.Now()
-	// Files are created in decreasing severity order, so as soon as we find one
-	// has already been created, we can stop.
-	for s := sev; s >= infoLog && l.file[s] == nil; s-- {
+	now := l.now()
+	// Each file is created when the first line is written to it, unless
+	// -log_precreate is set, in which case all the files are created at
+	// once.
+	lowest, highest := sev, sev
+	if l.precreate {
+		lowest, highest = infoLog, fatalLog
+		// Without -alsologtolower, the files below the threshold receive
+		// no lines.
+		if t := l.fileThreshold.get(); !l.alsoToLower && t > lowest {
+			lowest = t
+		}
+	}
+	for s := highest; s >= lowest; s-- {
+		if l.file[s] != nil {
+			continue
+		}
 		sb := &syncBuffer{
 			logger: l,
 			sev:    s,
@@ -1378,37 +2319,119 @@ func (l *loggingT) createFiles(sev severity) error {
 		}
 		l.file[s] = sb
 	}
+	ensureFlushDaemon()
 	return nil
 }
 
-// flushDaemon periodically flushes the log file buffers.
-func (l *loggingT) flushDaemon() {
-	if l.flushInterval < time.Second {
-		l.flushInterval = time.Second
+// SetDuplicateToLowerSeverity controls whether lines are also written to the
+// log files of lower severities, so that the INFO file holds every line, as
+// by default. With on false, an ERROR line goes to the ERROR file only.
+func SetDuplicateToLowerSeverity(on bool) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.alsoToLower = on
+}
+
+// SetPrecreateFiles controls whether the log files of all severities that can
+// receive lines are created together with the first one, as by the
+// -log_precreate flag. By default, each log file is created when the
+// first line is written to it.
+func SetPrecreateFiles(on bool) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.precreate = on
+}
+
+// flushDaemon periodically flushes the log file buffers until stop is closed.
+func (l *loggingT) flushDaemon(interval time.Duration, stop <-chan struct{}) {
+	if interval < time.Second {
+		interval = time.Second
 	}
-	for _ = range time.NewTicker(l.flushInterval).C {
-		l.lockAndFlushAll()
+	ticker := currentClock().NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			l.mu.Lock()
+			l.flushFiles(l.syncDue(currentClock().Now(), true))
+			l.checkFiles()
+			l.mu.Unlock()
+		case <-stop:
+			return
+		}
 	}
 }
 
 // lockAndFlushAll is like flushAll but locks l.mu first.
-func (l *loggingT) lockAndFlushAll() {
+func (l *loggingT) lockAndFlushAll() []SinkFlushError {
+	if o := l.sharded(); o != nil {
+		o.drain()
+	}
 	l.mu.Lock()
-	l.flushAll()
-	l.mu.Unlock()
+	defer l.mu.Unlock()
+	return l.flushAll()
+}
+
+// flushAll flushes all the logs and attempts to "sync" their data to disk,
+// unless the sync policy is SyncNever.
+// l.mu is held.
+func (l *loggingT) flushAll() []SinkFlushError {
+	return l.flushFiles(l.syncPolicy.Mode != SyncNever)
 }
 
-// flushAll flushes all the logs and attempts to "sync" their data to disk.
+// flushFiles flushes all the logs and, if sync is set, syncs them. It
+// returns the failures not handled by failing over.
 // l.mu is held.
-func (l *loggingT) flushAll() {
+func (l *loggingT) flushFiles(sync bool) []SinkFlushError {
+	var failed []SinkFlushError
 	// Flush from fatal down, in case there's trouble flushing.
 	for s := fatalLog; s >= infoLog; s-- {
 		file := l.file[s]
-		if file != nil {
-			file.Flush() // ignore error
-			file.Sync()  // ignore error
+		if file != nil && !l.failedOver(s) {
+			// Errors are ignored unless there is a failover chain or an
+			// error handler.
+			if err := file.Flush(); err != nil && !l.failOver(s, err) {
+				handleError(err)
+				failed = append(failed, SinkFlushError{severityName[s] + " log file", err})
+			}
+			if sync {
+				if err := file.Sync(); err != nil && !l.failOver(s, err) {
+					handleError(err)
+					failed = append(failed, SinkFlushError{severityName[s] + " log file", err})
+				}
+			}
+		}
+	}
+	failed = append(failed, l.flushTees()...)
+	l.flushBatches()
+	return failed
+}
+
+// closeFiles flushes, syncs and closes the log files, forgetting them so that
+// later lines create new ones.
+func (l *loggingT) closeFiles() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var first error
+	for s := fatalLog; s >= infoLog; s-- {
+		file := l.file[s]
+		if file == nil {
+			continue
+		}
+		err := file.Flush()
+		if e := file.Sync(); err == nil {
+			err = e
+		}
+		if e := file.Close(); err == nil {
+			err = e
 		}
+		if first == nil {
+			first = err
+		}
+		l.file[s] = nil
 	}
+	l.flushTees()
+	return first
 }
 
 // CopyStandardLogTo arranges for messages written to the Go "log" package's
@@ -1418,6 +2441,10 @@ func (l *loggingT) flushAll() {
 //
 // Valid names are "INFO", "WARNING", "ERROR", and "FATAL".  If the name is not
 // recognized, CopyStandardLogTo panics.
+//
+// Messages that begin with one of the prefixes "ERROR:", "WARNING:", "WARN:",
+// "INFO:" or "DEBUG:" are routed to the corresponding severity instead (DEBUG
+// goes to INFO), with the prefix removed.
 func CopyStandardLogTo(name string) {
 	sev, ok := severityByName(name)
 	if !ok {
@@ -1433,8 +2460,32 @@ func CopyStandardLogTo(name string) {
 // Go's standard logs to the logs provided by this package.
 type logBridge severity
 
+// stdLogPrefixes lists the message prefixes recognized by logBridge and the
+// severity each one selects.
+var stdLogPrefixes = []struct {
+	prefix string
+	sev    severity
+}{
+	{"ERROR:", errorLog},
+	{"WARNING:", warningLog},
+	{"WARN:", warningLog},
+	{"INFO:", infoLog},
+	{"DEBUG:", infoLog},
+}
+
+// stdLogSeverity returns the severity selected by a prefix of text, and text
+// with the prefix removed. If text has no known prefix it returns def.
+func stdLogSeverity(def severity, text string) (severity, string) {
+	for _, p := range stdLogPrefixes {
+		if strings.HasPrefix(text, p.prefix) {
+			return p.sev, strings.TrimLeft(text[len(p.prefix):], " ")
+		}
+	}
+	return def, text
+}
+
 // Write parses the standard logging line and passes its components to the
-// logger for severity(lb).
+// logger for severity(lb), or for the severity named by the message prefix.
 func (lb logBridge) Write(b []byte) (n int, err error) {
 	var (
 		file = "???"
@@ -1453,35 +2504,47 @@ func (lb logBridge) Write(b []byte) (n int, err error) {
 			line = 1
 		}
 	}
+	sev, text := stdLogSeverity(severity(lb), text)
 	// printWithFileLine with alsoToStderr=true, so standard log messages
 	// always appear on standard error.
-	logging.printWithFileLine(severity(lb), file, line, true, text)
+	logging.printWithFileLine(sev, file, line, true, text)
 	return len(b), nil
 }
 
 // setV computes and remembers the V level for a given PC
 // when vmodule is enabled.
-// File pattern matching takes the basename of the file, stripped
-// of its .go suffix, and uses filepath.Match, which is a little more
-// general than the *? matching used in C++.
 // l.mu is held.
 func (l *loggingT) setV(pc uintptr) Level {
 	fn := runtime.FuncForPC(pc)
 	file, _ := fn.FileLine(pc)
-	// The file is something like /a/b/c/d.go. We want just the d.
-	if strings.HasSuffix(file, ".go") {
-		file = file[:len(file)-3]
-	}
+	v := l.moduleLevel(file)
+	l.vmap[pc] = v
+	return v
+}
+
+// moduleLevel returns the -vmodule level of the named source file, or 0 if
+// no pattern matches it.
+// File pattern matching takes the basename of the file, stripped
+// of its .go suffix, and uses filepath.Match, which is a little more
+// general than the *? matching used in C++.
+// l.mu is held.
+func (l *loggingT) moduleLevel(full string) Level {
+	// The file is something like /a/b/c/d.go. We want just the d, or the
+	// trailing elements c/d for a pattern such as c/*.
+	full = strings.TrimSuffix(full, ".go")
+	file := full
 	if slash := strings.LastIndex(file, "/"); slash >= 0 {
 		file = file[slash+1:]
 	}
 	for _, filter := range l.vmodule.filter {
-		if filter.match(file) {
-			l.vmap[pc] = filter.level
+		name := file
+		if filter.path > 0 {
+			name = pathTail(full, filter.path)
+		}
+		if filter.match(name) {
 			return filter.level
 		}
 	}
-	l.vmap[pc] = 0
 	return 0
 }
 
@@ -1497,7 +2560,9 @@ type Verbose bool
 // or
 //	glog.V(2).Info("log this")
 // The second form is shorter but the first is cheaper if logging is off because it does
-// not evaluate its arguments.
+// not evaluate its arguments. Arguments of type func() interface{} or func() string
+// are called only when the line is written, which makes the second form as cheap:
+//	glog.V(2).Info("state: ", func() interface{} { return dump(state) })
 //
 // Whether an individual call to V generates a log record depends on the setting of
 // the -v and --vmodule flags; both are off by default. If the level in the call to
@@ -1513,6 +2578,12 @@ func V(level Level) Verbose {
 	}
 
 	// It's off globally but it vmodule may still be set.
+	return moduleV(level)
+}
+
+// moduleV reports whether the -vmodule level of the caller of its caller is
+// at least level.
+func moduleV(level Level) Verbose {
 	// Here is another cheap but safe test to see if vmodule is enabled.
 	if atomic.LoadInt32(&logging.filterLength) > 0 {
 		// Now we need a proper lock to use the logging structure. The pcs field
@@ -1520,7 +2591,7 @@ func V(level Level) Verbose {
 		// but if V logging is enabled we're slow anyway.
 		logging.mu.Lock()
 		defer logging.mu.Unlock()
-		if runtime.Callers(2, logging.pcs[:]) == 0 {
+		if runtime.Callers(3, logging.pcs[:]) == 0 {
 			return Verbose(false)
 		}
 		v, ok := logging.vmap[logging.pcs[0]]
@@ -1536,7 +2607,7 @@ func V(level Level) Verbose {
 // See the documentation of V for usage.
 func (v Verbose) Info(args ...interface{}) {
 	if v {
-		logging.print(infoLog, args...)
+		logging.printEntryV(0, infoLog, globalV, "", nil, maskDefault, tprint, "", args)
 	}
 }
 
@@ -1544,7 +2615,7 @@ func (v Verbose) Info(args ...interface{}) {
 // See the documentation of V for usage.
 func (v Verbose) Infoln(args ...interface{}) {
 	if v {
-		logging.println(infoLog, args...)
+		logging.printEntryV(0, infoLog, globalV, "", nil, maskDefault, tprintln, "", args)
 	}
 }
 
@@ -1552,7 +2623,16 @@ func (v Verbose) Infoln(args ...interface{}) {
 // See the documentation of V for usage.
 func (v Verbose) Infof(format string, args ...interface{}) {
 	if v {
-		logging.printf(infoLog, format, args...)
+		logging.printEntryV(0, infoLog, globalV, "", nil, maskDefault, tprintf, format, args)
+	}
+}
+
+// InfoLazy logs the result of f to the INFO log if v is true; otherwise f is
+// not called. It suits messages that are expensive to build:
+//	glog.V(3).InfoLazy(func() string { return dump(state) })
+func (v Verbose) InfoLazy(f func() string) {
+	if v {
+		logging.printEntryV(0, infoLog, globalV, "", nil, maskDefault, tprint, "", []interface{}{f()})
 	}
 }
 
@@ -1644,7 +2724,8 @@ func ErrorfDepth(depth int, format string, args ...interface{}) {
 }
 
 // Fatal logs to the FATAL, ERROR, WARNING, and INFO logs,
-// including a stack trace of all running goroutines, then calls os.Exit(255).
+// including a stack trace of all running goroutines, then calls os.Exit(255)
+// or the function installed by SetExitFunc.
 // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func Fatal(args ...interface{}) {
 	logging.print(fatalLog, args...)
@@ -1657,14 +2738,16 @@ func FatalDepth(depth int, args ...interface{}) {
 }
 
 // Fatalln logs to the FATAL, ERROR, WARNING, and INFO logs,
-// including a stack trace of all running goroutines, then calls os.Exit(255).
+// including a stack trace of all running goroutines, then calls os.Exit(255)
+// or the function installed by SetExitFunc.
 // Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
 func Fatalln(args ...interface{}) {
 	logging.println(fatalLog, args...)
 }
 
 // Fatalf logs to the FATAL, ERROR, WARNING, and INFO logs,
-// including a stack trace of all running goroutines, then calls os.Exit(255).
+// including a stack trace of all running goroutines, then calls os.Exit(255)
+// or the function installed by SetExitFunc.
 // Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func Fatalf(format string, args ...interface{}) {
 	logging.printf(fatalLog, format, args...)
@@ -1759,6 +2842,9 @@ func (l *loggingT) shrineRequestField(str string, sepOut string, sepInner string
 }
 
 func ShrineCardNo(cardNo string) (shrineStr string) {
+	if out, ok := shrineWithPolicy("card", cardNo); ok {
+		return out
+	}
 	// 长度 [0,5] 的不作处理
 	l := len(cardNo)
 	if l <= 5 {
@@ -1791,14 +2877,23 @@ func ShrineCardNo(cardNo string) (shrineStr string) {
 }
 
 func ShrineIdentity(id string) string {
+	if out, ok := shrineWithPolicy("identity", id); ok {
+		return out
+	}
 	return strMask(id, 4, 10)
 }
 
 func ShrinePhoneNumber(phone string) (shrineStr string) {
+	if out, ok := shrineWithPolicy("phone", phone); ok {
+		return out
+	}
 	return strMask(phone, 3, 4)
 }
 
 func ShrineAlipayAccountNumber(alipayAccountNumber string) (shrineStr string) {
+	if out, ok := shrineWithPolicy("card", alipayAccountNumber); ok {
+		return out
+	}
 	//支付宝账号是11位手机号
 	if isPhoneNumber := IsValidPhoneNumber(alipayAccountNumber); isPhoneNumber {
 		shrineStr = ShrinePhoneNumber(alipayAccountNumber)
@@ -1815,6 +2910,9 @@ func ShrineAlipayAccountNumber(alipayAccountNumber string) (shrineStr string) {
 }
 
 func ShrineEmail(email string) (shrineStr string) {
+	if out, ok := shrineWithPolicy("email", email); ok {
+		return out
+	}
 	endPos := len(email)
 	startPos := strings.Index(email, "@")
 	headStr, err := SubString(email, 0, startPos)
@@ -1846,6 +2944,9 @@ func ShrineEmail(email string) (shrineStr string) {
 
 // ShrineRealName mask real name
 func ShrineRealName(realName string) (out string) {
+	if out, ok := shrineWithPolicy("realname", realName); ok {
+		return out
+	}
 	if len(realName) > 0 {
 		if realName[0] >= 0x61 && realName[0] <= 0x7a {
 
@@ -1949,7 +3050,15 @@ func SubString(str string, start int, end int) (subString string, err error) {
 
 // getStartOfNextTime gets the next time period by time
 func getStartOfNextTime(t time.Time) time.Time {
-	switch *LogRotateInterval {
+	return getStartOfNextInterval(*LogRotateInterval, t)
+}
+
+// getStartOfNextInterval gets the start of the rotation interval following t,
+// in the wall clock of t's location. Days and months start at midnight, or
+// at the first time after it when daylight saving time skips midnight; see
+// startOfNextWallUnit for hours and minutes.
+func getStartOfNextInterval(interval string, t time.Time) time.Time {
+	switch interval {
 	case "month":
 		return getStartOfNextMonth(t)
 	case "day":
@@ -1958,6 +3067,8 @@ func getStartOfNextTime(t time.Time) time.Time {
 		return getStartOfNextHour(t)
 	case "minute":
 		return getStartOfNextMinute(t)
+	case "second":
+		return startOfNextWallUnit(t, time.Second)
 	default:
 		return getStartOfNextDay(t)
 	}
@@ -1982,23 +3093,43 @@ func getStartOfNextDay(t time.Time) time.Time {
 
 // getStartOfNextHour start time of next hour
 func getStartOfNextHour(t time.Time) time.Time {
-	t = t.Add(time.Hour)
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	return startOfNextWallUnit(t, time.Hour)
 }
 
 // getStartOfNextMinute start time of next minute
 func getStartOfNextMinute(t time.Time) time.Time {
-	t = t.Add(time.Minute)
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+	return startOfNextWallUnit(t, time.Minute)
+}
+
+// startOfNextWallUnit returns the first time after t at which the wall clock
+// of t's location shows a whole unit, an hour, a minute or a second. When the clocks go
+// back, the repeated hour is an interval of its own; when they go forward,
+// the skipped hour has none.
+func startOfNextWallUnit(t time.Time, unit time.Duration) time.Time {
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	next := t.Add(shift).Truncate(unit).Add(unit).Add(-shift)
+	if _, o := next.Zone(); time.Duration(o-offset)*time.Second%unit != 0 {
+		// The offset changed by part of a unit, as the half-hour
+		// daylight saving time of Lord Howe Island: next is not whole.
+		return startOfNextWallUnit(next, unit)
+	}
+	return next
 }
 
 // ShrinePwdStr password mask
 func ShrinePwdStr() string {
+	if out, ok := shrineWithPolicy("pwd", "******"); ok {
+		return out
+	}
 	return "******"
 }
 
 // ShrineCompanyName company name mask
 func ShrineCompanyName(str string) string {
+	if out, ok := shrineWithPolicy("company", str); ok {
+		return out
+	}
 	if len(str) == 0 {
 		return ""
 	}
@@ -2012,3 +3143,59 @@ func ShrineCompanyName(str string) string {
 	// Returns the first character + "****" + the last two characters
 	return string(com[0]) + "****" + string(com[comLen-2:])
 }
+
+// ShrineBankAccount masks a domestic bank account number, keeping only the
+// last four digits. Spaces and hyphens are ignored. It returns "" if the
+// value is not 6 to 34 digits long.
+func ShrineBankAccount(account string) string {
+	if out, ok := shrineWithPolicy("bankacct", account); ok {
+		return out
+	}
+	account = strings.NewReplacer(" ", "", "-", "").Replace(account)
+	if !IsBankAccountRe.MatchString(account) {
+		return ""
+	}
+	return strMask(account, 0, len(account)-4)
+}
+
+// ShrineIBAN masks an International Bank Account Number, keeping the country
+// code, check digits and last four characters. Spaces are ignored and letters
+// may be lower case. It returns "" if the value is not a well-formed IBAN
+// with a valid checksum.
+func ShrineIBAN(iban string) string {
+	if out, ok := shrineWithPolicy("iban", iban); ok {
+		return out
+	}
+	iban = strings.ToUpper(strings.Replace(iban, " ", "", -1))
+	if !IsIBANRe.MatchString(iban) || !validIBANChecksum(iban) {
+		return ""
+	}
+	return strMask(iban, 4, len(iban)-8)
+}
+
+// validIBANChecksum reports whether iban passes the ISO 7064 mod 97-10 check.
+func validIBANChecksum(iban string) bool {
+	rearranged := iban[4:] + iban[:4]
+	mod := 0
+	for _, c := range rearranged {
+		if c >= 'A' && c <= 'Z' {
+			n := int(c-'A') + 10
+			mod = (mod*100 + n) % 97
+		} else {
+			mod = (mod*10 + int(c-'0')) % 97
+		}
+	}
+	return mod == 1
+}
+
+// ShrinePassport masks a passport number, keeping the first character and the
+// last three. It returns "" if the value is not 5 to 20 letters and digits.
+func ShrinePassport(passport string) string {
+	if out, ok := shrineWithPolicy("passport", passport); ok {
+		return out
+	}
+	if !IsPassportRe.MatchString(passport) {
+		return ""
+	}
+	return strMask(passport, 1, len(passport)-4)
+}
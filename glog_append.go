@@ -0,0 +1,97 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Appending to the log files of an earlier process.
+
+package glog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SetAppendOnRestart controls whether a process appends to the current log
+// file of each severity left by an earlier run of the program, as by the
+// -log_append flag, rather than starting a new one. A file is current if it
+// was started in the rotation interval now running and is below the size
+// limit. This keeps a service that restarts again and again from leaving a
+// small file for each run. By default, each process starts its own files.
+func SetAppendOnRestart(on bool) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.appendFile = on
+}
+
+// stampLayout is the layout of the start time in log file names.
+const stampLayout = "20060102-150405"
+
+// appendExisting opens for appending the current file of sb's severity left
+// in the log directories, the one written last if there are several, and
+// reports whether there was one. The part written by this process starts
+// with header lines of its own.
+// l.mu is held.
+func (sb *syncBuffer) appendExisting(now time.Time) (bool, error) {
+	onceLogDirs.Do(createLogDirs)
+	l := sb.logger
+	tag := severityName[sb.sev]
+	prefix := fmt.Sprintf("%s.%s.%s.log.%s.", program, host, userName, tag)
+	next := l.nextRotation(sb.sev, now)
+	var (
+		path    string
+		dir     string
+		modTime time.Time
+		size    int64
+	)
+	for _, d := range logDirs {
+		entries, err := os.ReadDir(d)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			stamp := strings.TrimPrefix(e.Name(), prefix)
+			if len(stamp) < len(stampLayout) || stamp == e.Name() || !e.Type().IsRegular() || isCompressed(stamp) {
+				continue
+			}
+			start, err := time.ParseInLocation(stampLayout, stamp[:len(stampLayout)], now.Location())
+			if err != nil || start.After(now) || !l.nextRotation(sb.sev, start).Equal(next) {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil || uint64(info.Size()) >= l.maxSize(sb.sev) || !info.ModTime().After(modTime) {
+				continue
+			}
+			path, dir, modTime, size = filepath.Join(d, e.Name()), d, info.ModTime(), info.Size()
+		}
+	}
+	if path == "" {
+		return false, nil
+	}
+	f, err := openLogFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return false, nil
+	}
+	updateLink(dir, filepath.Base(path), program+"."+tag)
+	sb.file, sb.name = f, path
+	sb.nbytes = uint64(size)
+	sb.scheduleRotation(now)
+	if err := sb.startFile(); err != nil {
+		return true, err
+	}
+	return true, sb.writeHeader("Log file reopened at", now, "")
+}
@@ -0,0 +1,78 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that the current file of an earlier run is appended to, and one of an
+// earlier rotation interval is not.
+func TestAppendOnRestart(t *testing.T) {
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	dir := t.TempDir()
+	logDirs = []string{dir}
+	defer func(previous string) { *LogRotateInterval = previous }(*LogRotateInterval)
+	*LogRotateInterval = "day"
+	defer SetAppendOnRestart(false)
+	SetAppendOnRestart(true)
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+	earlier := func(age time.Duration, pid int) string {
+		start := now.Add(-age)
+		path := filepath.Join(dir, fmt.Sprintf("%s.%s.%s.log.INFO.%s.%d", program, host, userName, start.Format(stampLayout), pid))
+		if err := os.WriteFile(path, []byte("previous\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, start, start)
+		return path
+	}
+	current := earlier(time.Hour, pid+1)
+	yesterday := earlier(24*time.Hour, pid+2)
+
+	sb := &syncBuffer{logger: &logging, sev: infoLog}
+	logging.mu.Lock()
+	err := sb.rotateFile(now)
+	if err == nil {
+		sb.Write([]byte("new line\n"))
+		sb.Flush()
+		sb.Close()
+	}
+	logging.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sb.name != current {
+		t.Fatalf("writing to %s, want %s", sb.name, current)
+	}
+	data, err := os.ReadFile(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); !strings.HasPrefix(s, "previous\nLog file reopened at: 2026/10/16 12:00:00\n") || !strings.HasSuffix(s, "new line\n") {
+		t.Errorf("appended file is %q", s)
+	}
+	if data, err := os.ReadFile(yesterday); err != nil || string(data) != "previous\n" {
+		t.Errorf("file of yesterday changed: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,211 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Tamper-evident audit log.
+
+package glog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditRecord is a line of the audit log, without its hash.
+type auditRecord struct {
+	Time   time.Time `json:"time"`
+	Seq    uint64    `json:"seq"`
+	Event  string    `json:"event"`
+	Host   string    `json:"host"`
+	PID    int       `json:"pid"`
+	File   string    `json:"file"`
+	Line   int       `json:"line"`
+	Fields fieldList `json:"fields,omitempty"`
+	Prev   string    `json:"prev"`
+}
+
+// hashKey introduces the hash at the end of each audit line.
+const hashKey = `,"hash":"`
+
+// zeroHash is the previous hash of the first record of an audit log.
+var zeroHash = strings.Repeat("0", 2*sha256.Size)
+
+// audit is the state of the audit log.
+var audit struct {
+	mu   sync.Mutex
+	path string   // Set by SetAuditFile; empty means a file in the log dir.
+	file *os.File // Nil until the first record.
+	seq  uint64   // Sequence number of the last record.
+	prev string   // Hash of the last record.
+}
+
+// SetAuditFile directs audit records to the file at path, which is created if
+// needed. Records are appended to an existing file, continuing its hash
+// chain. By default, the first call to Audit creates a file named like the
+// log files, with AUDIT as the severity, in the log directory.
+func SetAuditFile(path string) error {
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	closeAuditLocked()
+	audit.path = path
+	return openAuditLocked()
+}
+
+// Audit records a security-relevant event, with fields given as for
+// WithFields, in the audit log. Each record is a line of JSON holding the
+// time, a sequence number, the event, the caller, the fields, masked as in the
+// logs, the hash of the previous record and its own hash, a SHA-256 of the
+// line up to it. Deleting, reordering or altering records thus breaks the
+// chain, as reported by VerifyAudit. The file is synced before Audit returns;
+// an error means that the event may not have been recorded.
+func Audit(event string, kv ...interface{}) error {
+	fields := (&Entry{}).WithFields(kv...).fields
+	if len(fields) > 0 && logging.masking() {
+		fields = logging.maskFields(fields)
+	}
+	file, line, _, _ := logging.caller(1)
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	if audit.file == nil {
+		if err := openAuditLocked(); err != nil {
+			return err
+		}
+	}
+	rec := auditRecord{
+		Time:   logging.now(),
+		Seq:    audit.seq + 1,
+		Event:  event,
+		Host:   host,
+		PID:    pid,
+		File:   file,
+		Line:   line,
+		Fields: fields,
+		Prev:   audit.prev,
+	}
+	body, err := json.Marshal(&rec)
+	if err != nil {
+		return fmt.Errorf("log: audit: %v", err)
+	}
+	hash := auditHash(body)
+	data := append(body[:len(body)-1], hashKey+hash+"\"}\n"...)
+	if _, err := audit.file.Write(data); err != nil {
+		return fmt.Errorf("log: audit: %v", err)
+	}
+	if err := audit.file.Sync(); err != nil {
+		return fmt.Errorf("log: audit: %v", err)
+	}
+	audit.seq, audit.prev = rec.Seq, hash
+	return nil
+}
+
+// auditHash returns the hex SHA-256 of body.
+func auditHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// openAuditLocked opens the audit file and recovers the end of its chain.
+// audit.mu is held.
+func openAuditLocked() error {
+	audit.seq, audit.prev = 0, zeroHash
+	if audit.path == "" {
+		f, _, _, err := create("AUDIT", logging.now(), 0)
+		if err != nil {
+			return err
+		}
+		audit.file = f
+		return nil
+	}
+	f, err := os.OpenFile(audit.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	seq, prev, err := verifyAudit(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("log: audit file %s: %v", audit.path, err)
+	}
+	audit.file, audit.seq, audit.prev = f, seq, prev
+	return nil
+}
+
+// closeAudit closes the audit file, if open. The next record reopens it.
+func closeAudit() error {
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	return closeAuditLocked()
+}
+
+// closeAuditLocked closes the audit file, if open. audit.mu is held.
+func closeAuditLocked() error {
+	if audit.file == nil {
+		return nil
+	}
+	err := audit.file.Close()
+	audit.file = nil
+	return err
+}
+
+// VerifyAudit checks the hash chain of the audit log read from r. It returns
+// an error naming the first line that was altered, or follows a deleted or
+// reordered one.
+func VerifyAudit(r io.Reader) error {
+	_, _, err := verifyAudit(r)
+	return err
+}
+
+// verifyAudit checks the chain read from r and returns the sequence number
+// and hash of its last record.
+func verifyAudit(r io.Reader) (seq uint64, prev string, err error) {
+	prev = zeroHash
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 16*1024*1024)
+	for n := 1; s.Scan(); n++ {
+		line := s.Bytes()
+		i := bytes.LastIndex(line, []byte(hashKey))
+		if i < 0 || !bytes.HasSuffix(line, []byte(`"}`)) {
+			return 0, "", fmt.Errorf("line %d: no hash", n)
+		}
+		hash := string(line[i+len(hashKey) : len(line)-2])
+		body := append(line[:i:i], '}')
+		if auditHash(body) != hash {
+			return 0, "", fmt.Errorf("line %d: hash mismatch", n)
+		}
+		var rec struct {
+			Seq  uint64
+			Prev string
+		}
+		if err := json.Unmarshal(body, &rec); err != nil {
+			return 0, "", fmt.Errorf("line %d: %v", n, err)
+		}
+		if rec.Prev != prev || rec.Seq != seq+1 {
+			return 0, "", fmt.Errorf("line %d: broken chain", n)
+		}
+		seq, prev = rec.Seq, hash
+	}
+	if err := s.Err(); err != nil {
+		return 0, "", err
+	}
+	return seq, prev, nil
+}
@@ -0,0 +1,77 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that audit records form a hash chain that detects tampering.
+func TestAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := SetAuditFile(path); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		closeAudit()
+		audit.path = ""
+	}()
+	if err := Audit("login", "user", "alice", "mobile", "13812345678"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Audit("grant", "role", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	// Reopening continues the chain.
+	if err := SetAuditFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := Audit("logout"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if len(lines) != 4 || lines[3] != "" {
+		t.Fatalf("got %q", data)
+	}
+	if !strings.Contains(lines[0], `"event":"login"`) || !strings.Contains(lines[0], `"file":"glog_audit_test.go"`) ||
+		!strings.Contains(lines[0], `"mobile":"138****5678"`) || !strings.Contains(lines[2], `"seq":3`) {
+		t.Errorf("unexpected records: %q", data)
+	}
+	if err := VerifyAudit(bytes.NewReader(data)); err != nil {
+		t.Errorf("VerifyAudit: %v", err)
+	}
+
+	for name, tampered := range map[string]string{
+		"altered":   strings.Replace(string(data), "admin", "guest", 1),
+		"deleted":   lines[0] + lines[2],
+		"reordered": lines[1] + lines[0] + lines[2],
+		"truncated": lines[1] + lines[2],
+	} {
+		if err := VerifyAudit(strings.NewReader(tampered)); err == nil {
+			t.Errorf("%s log verified", name)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// AWS credentials and request signing, for the CloudWatch Logs sink.
+
+package glog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSCredentials are the credentials that sign requests to AWS.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string    // Of temporary credentials.
+	Expires         time.Time // Of temporary credentials; zero if they do not expire.
+}
+
+// DefaultAWSCredentials returns the credentials found first, in the order of
+// the AWS SDKs, in:
+//   - the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+//     environment variables, as set in AWS Lambda;
+//   - the shared credentials file, $AWS_SHARED_CREDENTIALS_FILE or
+//     ~/.aws/credentials, for the profile $AWS_PROFILE or "default";
+//   - the container credentials endpoint of ECS, named by
+//     AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or _FULL_URI;
+//   - the instance metadata service of EC2, unless
+//     AWS_EC2_METADATA_DISABLED is true.
+func DefaultAWSCredentials() (AWSCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return AWSCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+	if c, ok := sharedAWSCredentials(); ok {
+		return c, nil
+	}
+	client := &http.Client{Timeout: 2 * time.Second}
+	if uri, token := containerCredentialsURI(); uri != "" {
+		req, err := http.NewRequest("GET", uri, nil)
+		if err != nil {
+			return AWSCredentials{}, err
+		}
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		return fetchAWSCredentials(client, req)
+	}
+	if !strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return instanceAWSCredentials(client)
+	}
+	return AWSCredentials{}, errors.New("log: no AWS credentials found")
+}
+
+// sharedAWSCredentials reads the credentials of the current profile from the
+// shared credentials file.
+func sharedAWSCredentials() (AWSCredentials, bool) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return AWSCredentials{}, false
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return AWSCredentials{}, false
+	}
+	defer f.Close()
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	var c AWSCredentials
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			c.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			c.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			c.SessionToken = strings.TrimSpace(value)
+		}
+	}
+	return c, c.AccessKeyID != "" && c.SecretAccessKey != ""
+}
+
+// containerCredentialsURI returns the URI of the ECS credentials endpoint and
+// the authorization token for it, if any.
+func containerCredentialsURI() (uri, token string) {
+	token = os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if file := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); file != "" {
+		if b, err := os.ReadFile(file); err == nil {
+			token = strings.TrimSpace(string(b))
+		}
+	}
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); rel != "" {
+		return "http://169.254.170.2" + rel, token
+	}
+	return os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"), token
+}
+
+// instanceMetadataURL is the address of the EC2 instance metadata service.
+var instanceMetadataURL = "http://169.254.169.254"
+
+// instanceAWSCredentials fetches the credentials of the role of the EC2
+// instance with IMDSv2.
+func instanceAWSCredentials(client *http.Client) (AWSCredentials, error) {
+	req, err := http.NewRequest("PUT", instanceMetadataURL+"/latest/api/token", nil)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+	token, err := readHTTPResponse(client, req)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("log: instance metadata: %v", err)
+	}
+	const path = "/latest/meta-data/iam/security-credentials/"
+	req, _ = http.NewRequest("GET", instanceMetadataURL+path, nil)
+	req.Header.Set("X-Aws-Ec2-Metadata-Token", string(token))
+	role, err := readHTTPResponse(client, req)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("log: instance role: %v", err)
+	}
+	name, _, _ := strings.Cut(strings.TrimSpace(string(role)), "\n")
+	req, _ = http.NewRequest("GET", instanceMetadataURL+path+name, nil)
+	req.Header.Set("X-Aws-Ec2-Metadata-Token", string(token))
+	return fetchAWSCredentials(client, req)
+}
+
+// fetchAWSCredentials fetches credentials in the JSON form of the ECS and EC2
+// endpoints.
+func fetchAWSCredentials(client *http.Client, req *http.Request) (AWSCredentials, error) {
+	body, err := readHTTPResponse(client, req)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("log: AWS credentials: %v", err)
+	}
+	var resp struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return AWSCredentials{}, fmt.Errorf("log: AWS credentials: %v", err)
+	}
+	return AWSCredentials{resp.AccessKeyID, resp.SecretAccessKey, resp.Token, resp.Expiration}, nil
+}
+
+// readHTTPResponse sends req and returns the body of a successful response.
+func readHTTPResponse(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = errors.New(resp.Status)
+	}
+	return body, err
+}
+
+// signAWS signs req, whose body is body, with Signature Version 4 for service
+// in region at time now. The headers already set on req are signed too.
+func signAWS(req *http.Request, body []byte, c AWSCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	stamp := now.Format("20060102T150405Z")
+	day := stamp[:8]
+	req.Header.Set("X-Amz-Date", stamp)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonical bytes.Buffer
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	fmt.Fprintf(&canonical, "%s\n%s\n%s\n", req.Method, path, canonicalQuery(req))
+	for _, k := range names {
+		fmt.Fprintf(&canonical, "%s:%s\n", k, strings.TrimSpace(headers[k]))
+	}
+	signed := strings.Join(names, ";")
+	bodyHash := sha256.Sum256(body)
+	fmt.Fprintf(&canonical, "\n%s\n%s", signed, hex.EncodeToString(bodyHash[:]))
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256(canonical.Bytes())
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKeyID, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+// canonicalQuery returns the query of req in the canonical form of
+// Signature Version 4.
+func canonicalQuery(req *http.Request) string {
+	q := req.URL.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes s as Signature Version 4 requires: all but
+// unreserved characters.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
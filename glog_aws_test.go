@@ -0,0 +1,132 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test signing against the get-vanilla example of the Signature Version 4
+// test suite.
+func TestSignAWS(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	creds := AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signAWS(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date = %q", got)
+	}
+}
+
+// clearAWSEnv unsets the variables read by DefaultAWSCredentials for the
+// duration of the test.
+func clearAWSEnv(t *testing.T) {
+	for _, k := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE",
+		"AWS_SHARED_CREDENTIALS_FILE", "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN", "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+func TestDefaultAWSCredentials(t *testing.T) {
+	clearAWSEnv(t)
+	if _, err := DefaultAWSCredentials(); err == nil {
+		t.Error("no error without credentials")
+	}
+
+	// Shared credentials file.
+	file := filepath.Join(t.TempDir(), "credentials")
+	os.WriteFile(file, []byte("[default]\naws_access_key_id = AKID1\naws_secret_access_key = s1\n\n"+
+		"[ci]\naws_access_key_id=AKID2\naws_secret_access_key=s2\naws_session_token=tok2\n"), 0600)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", file)
+	t.Setenv("AWS_PROFILE", "ci")
+	c, err := DefaultAWSCredentials()
+	if err != nil || c.AccessKeyID != "AKID2" || c.SecretAccessKey != "s2" || c.SessionToken != "tok2" {
+		t.Errorf("shared file: got %+v, %v", c, err)
+	}
+
+	// Environment, first in the chain.
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID3")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "s3")
+	if c, err := DefaultAWSCredentials(); err != nil || c.AccessKeyID != "AKID3" || c.SecretAccessKey != "s3" {
+		t.Errorf("environment: got %+v, %v", c, err)
+	}
+}
+
+func TestContainerAWSCredentials(t *testing.T) {
+	clearAWSEnv(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "secret" {
+			http.Error(w, "denied", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"AccessKeyId":"ASIA1","SecretAccessKey":"s","Token":"tok","Expiration":"2030-01-02T03:04:05Z"}`))
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", srv.URL+"/creds")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "secret")
+	c, err := DefaultAWSCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AWSCredentials{"ASIA1", "s", "tok", time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if c != want {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+}
+
+func TestInstanceAWSCredentials(t *testing.T) {
+	clearAWSEnv(t)
+	const path = "/latest/meta-data/iam/security-credentials/"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" && r.Method == "PUT" {
+			w.Write([]byte("imds-token"))
+			return
+		}
+		if r.Header.Get("X-Aws-Ec2-Metadata-Token") != "imds-token" {
+			http.Error(w, "no token", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case path:
+			w.Write([]byte("web-role\n"))
+		case path + "web-role":
+			w.Write([]byte(`{"AccessKeyId":"ASIA2","SecretAccessKey":"s","Token":"tok"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	defer func(u string) { instanceMetadataURL = u }(instanceMetadataURL)
+	instanceMetadataURL = srv.URL
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+	c, err := DefaultAWSCredentials()
+	if err != nil || c.AccessKeyID != "ASIA2" || c.SessionToken != "tok" {
+		t.Errorf("got %+v, %v", c, err)
+	}
+}
@@ -0,0 +1,122 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Batching of lines written to standard output and standard error.
+
+package glog
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// batchSize is the number of bytes at which a batch is written without
+// waiting for the batch latency.
+const batchSize = 64 * 1024
+
+// batch holds lines for standard output or standard error until they are
+// written together.
+type batch struct {
+	out   **os.File // &os.Stdout or &os.Stderr, looked up when written.
+	buf   []byte
+	timer Timer // Writes buf once the latency has elapsed.
+}
+
+// SetBatchLatency makes lines written to standard output and standard error
+// wait up to d, or until 64 KiB accumulate, to be written in a single system
+// call together with the lines that follow, which saves time for programs
+// that write many short lines. Flush, Fatal and Exit write the waiting
+// lines. Zero, the default, writes each line as it is logged. Log files are
+// always buffered; see -flush_interval.
+func SetBatchLatency(d time.Duration) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.batchLatency = d
+	if d <= 0 {
+		logging.flushBatches()
+	}
+}
+
+// writeBatched writes data to *out, through b if batching is enabled.
+// l.mu is held.
+func (l *loggingT) writeBatched(b *batch, out **os.File, data []byte) {
+	if l.batchLatency <= 0 {
+		(*out).Write(data)
+		return
+	}
+	b.out = out
+	b.buf = append(b.buf, data...)
+	if len(b.buf) >= batchSize {
+		b.flush()
+		return
+	}
+	if b.timer == nil {
+		b.timer = currentClock().AfterFunc(l.batchLatency, func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.flushBatches()
+		})
+	}
+}
+
+// flush writes the lines of b. l.mu is held.
+func (b *batch) flush() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.buf) > 0 {
+		(*b.out).Write(b.buf)
+		b.buf = b.buf[:0]
+	}
+}
+
+// flushBatches writes the lines waiting for standard output and standard
+// error. l.mu is held.
+func (l *loggingT) flushBatches() {
+	l.stdoutBatch.flush()
+	l.stderrBatch.flush()
+}
+
+// writeStderr writes data to standard error, batched if SetBatchLatency is
+// set. l.mu is held.
+func (l *loggingT) writeStderr(data []byte) {
+	l.writeBatched(&l.stderrBatch, &os.Stderr, data)
+}
+
+// batchLatencyValue implements flag.Value for the -log_batch_latency flag.
+type batchLatencyValue struct{}
+
+// String is part of the flag.Value interface.
+func (batchLatencyValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	return logging.batchLatency.String()
+}
+
+// Set is part of the flag.Value interface.
+func (batchLatencyValue) Set(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if d < 0 {
+		return fmt.Errorf("negative batch latency %v", d)
+	}
+	SetBatchLatency(d)
+	return nil
+}
@@ -0,0 +1,65 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that batched lines for standard error wait for Flush or the latency.
+func TestBatchLatency(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func() { logging.toStderr = false }()
+	defer SetBatchLatency(0)
+	logging.toStderr = true
+
+	size := func() int64 {
+		fi, err := os.Stderr.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi.Size()
+	}
+	SetBatchLatency(time.Hour)
+	out := capture(t, &os.Stderr, func() {
+		Info("first")
+		Info("second")
+		if n := size(); n != 0 {
+			t.Errorf("%d bytes written before Flush", n)
+		}
+		Flush()
+	})
+	if !strings.Contains(out, "] first\n") || !strings.HasSuffix(out, "] second\n") {
+		t.Errorf("standard error is %q, want both lines", out)
+	}
+
+	SetBatchLatency(10 * time.Millisecond)
+	capture(t, &os.Stderr, func() {
+		Info("third")
+		deadline := time.Now().Add(5 * time.Second)
+		for size() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("line not written after the batch latency")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// Benchmarks of the costlier paths: masking, fields, structured writers and
+// rotation. BenchmarkInfoLiteral and the other basic ones are in
+// glog_test.go, the parallel ones in glog_shard_test.go. Compare runs with
+//
+//	go test -run NONE -bench . -count 10 > new.txt
+//	benchstat old.txt new.txt
+
+type benchAddress struct {
+	City  string
+	Phone string `filter:"phone"`
+}
+
+type benchOrder struct {
+	ID        int
+	Card      string `filter:"card"`
+	Addresses []benchAddress
+	Meta      map[string]interface{}
+}
+
+func newBenchOrder() *benchOrder {
+	return &benchOrder{
+		ID:   7,
+		Card: "6222021234567890123",
+		Addresses: []benchAddress{
+			{City: "Shanghai", Phone: "13812345678"},
+			{City: "Beijing", Phone: "13987654321"},
+		},
+		Meta: map[string]interface{}{"mobile": "13812345678", "note": "gift", "n": 3},
+	}
+}
+
+func BenchmarkInfoNestedStruct(b *testing.B) {
+	o := newBenchOrder()
+	benchmarkOutput(b, func() { Info("order ", o) })
+}
+
+func BenchmarkInfoMap(b *testing.B) {
+	m := map[string]interface{}{"mobile": "13812345678", "idcard": "110101199003071234", "note": "gift"}
+	benchmarkOutput(b, func() { Info(m) })
+}
+
+func BenchmarkInfoError(b *testing.B) {
+	err := errors.New("connection refused")
+	benchmarkOutput(b, func() { Infof("dial: %v", err) })
+}
+
+func BenchmarkWithFields(b *testing.B) {
+	benchmarkOutput(b, func() { WithFields("user", "jone", "count", 12345, "ok", true).Info("charged") })
+}
+
+func BenchmarkInfoFieldsTyped(b *testing.B) {
+	benchmarkOutput(b, func() { InfoFields("charged", String("user", "jone"), Int("count", 12345), Bool("ok", true)) })
+}
+
+func BenchmarkVDisabled(b *testing.B) {
+	benchmarkOutput(b, func() { V(3).Infof("request %s took 100 ms", "GET /", 12) })
+}
+
+func BenchmarkVDisabledVModule(b *testing.B) {
+	if err := logging.vmodule.Set("other=3"); err != nil {
+		b.Fatal(err)
+	}
+	defer logging.vmodule.Set("")
+	benchmarkOutput(b, func() { V(3).Infof("request %s took 100 ms", "GET /", 12) })
+}
+
+func BenchmarkJSONWriter(b *testing.B) {
+	remove := AddJSONWriter("INFO", io.Discard)
+	defer remove()
+	benchmarkOutput(b, func() { WithFields("user", "jone", "count", 12345).Info("charged") })
+}
+
+// BenchmarkRotation writes to real files small enough to rotate often.
+func BenchmarkRotation(b *testing.B) {
+	setFlags()
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	logDirs = []string{b.TempDir()}
+	defer SetRotationPolicy("INFO", RotationPolicy{})
+	SetRotationPolicy("INFO", RotationPolicy{MaxSize: 1 << 20, MaxFiles: 3})
+	defer logging.swap(logging.swap([numSeverity]Sink{}))
+	defer func() {
+		logging.mu.Lock()
+		logging.flushFiles(false)
+		for _, f := range logging.file {
+			if c, ok := f.(io.Closer); ok {
+				c.Close()
+			}
+		}
+		logging.mu.Unlock()
+	}()
+	var line bytes.Buffer
+	for line.Len() < 200 {
+		line.WriteString("payload ")
+	}
+	msg := line.String()
+	b.SetBytes(int64(len(msg)))
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			Info(msg)
+		}
+	})
+}
+
+// Test that the common paths do not allocate more than they used to. The
+// budgets are counts of allocations per line, which unlike timings barely
+// depend on the machine, with some room for changes between Go releases.
+// Raise one only with a reason.
+func TestAllocBudgets(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector adds allocations")
+	}
+	setFlags()
+	defer logging.swap(logging.swap([numSeverity]Sink{discardWriter{}, discardWriter{}, discardWriter{}, discardWriter{}}))
+	o := newBenchOrder()
+	for _, test := range []struct {
+		name   string
+		budget float64
+		log    func()
+	}{
+		{"Info literal", 0, func() { Info("a literal message") }},
+		{"Infof basic", 0, func() { Infof("request %s took %d ms", "GET /", 12) }},
+		{"V disabled", 0, func() { V(3).Info("off") }},
+		{"InfoFields typed", 30, func() { InfoFields("charged", String("user", "jone"), Int("count", 12345)) }},
+		{"nested struct", 90, func() { Info("order ", o) }},
+	} {
+		if got := testing.AllocsPerRun(100, test.log); got > test.budget {
+			t.Errorf("%s: %v allocations per line, budget %v", test.name, got, test.budget)
+		}
+	}
+}
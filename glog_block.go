@@ -0,0 +1,168 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Blocks of lines written together.
+
+package glog
+
+import "sync/atomic"
+
+// LogBlock collects lines that Commit writes together, without lines of other
+// goroutines in between, such as the rows of a table:
+//
+//	b := glog.Block()
+//	b.Info("name   size")
+//	for _, f := range files {
+//		b.Infof("%-6s %d", f.name, f.size)
+//	}
+//	b.Commit()
+//
+// Each line is formatted, with its own time and source location, when it is
+// added, but nothing is written until Commit. Lines of a block that is never
+// committed are lost. A LogBlock is not safe for concurrent use.
+type LogBlock struct {
+	e     *Entry
+	lines []blockLine
+}
+
+// blockLine is a formatted line of a LogBlock.
+type blockLine struct {
+	s    severity
+	buf  *buffer
+	file string
+	line int
+}
+
+// Block returns an empty LogBlock.
+func Block() *LogBlock {
+	return &LogBlock{e: &Entry{}}
+}
+
+// Block returns an empty LogBlock whose lines carry the name and fields of e.
+func (e *Entry) Block() *LogBlock {
+	return &LogBlock{e: e}
+}
+
+// add formats a line of severity s and adds it to b.
+func (b *LogBlock) add(s severity, t printtype, format string, args []interface{}) {
+	buf, file, line := logging.formatEntry(1, s, vLine{}, b.e.name, b.e.fields, b.e.mask, t, format, args)
+	b.lines = append(b.lines, blockLine{s, buf, file, line})
+}
+
+// Len returns the number of lines waiting in b.
+func (b *LogBlock) Len() int {
+	return len(b.lines)
+}
+
+// Commit writes the lines added to b since the last Commit, in order and
+// without other lines in between, and empties b.
+func (b *LogBlock) Commit() {
+	lines := b.lines
+	b.lines = nil
+	logging.outputBlock(lines)
+}
+
+// Info adds a line for the INFO log, like the global Info.
+func (b *LogBlock) Info(args ...interface{}) {
+	b.add(infoLog, tprint, "", args)
+}
+
+// Infoln adds a line for the INFO log, like the global Infoln.
+func (b *LogBlock) Infoln(args ...interface{}) {
+	b.add(infoLog, tprintln, "", args)
+}
+
+// Infof adds a line for the INFO log, like the global Infof.
+func (b *LogBlock) Infof(format string, args ...interface{}) {
+	b.add(infoLog, tprintf, format, args)
+}
+
+// Warning adds a line for the WARNING and INFO logs, like the global Warning.
+func (b *LogBlock) Warning(args ...interface{}) {
+	b.add(warningLog, tprint, "", args)
+}
+
+// Warningln adds a line for the WARNING and INFO logs, like the global
+// Warningln.
+func (b *LogBlock) Warningln(args ...interface{}) {
+	b.add(warningLog, tprintln, "", args)
+}
+
+// Warningf adds a line for the WARNING and INFO logs, like the global
+// Warningf.
+func (b *LogBlock) Warningf(format string, args ...interface{}) {
+	b.add(warningLog, tprintf, format, args)
+}
+
+// Error adds a line for the ERROR, WARNING, and INFO logs, like the global
+// Error.
+func (b *LogBlock) Error(args ...interface{}) {
+	b.add(errorLog, tprint, "", args)
+}
+
+// Errorln adds a line for the ERROR, WARNING, and INFO logs, like the global
+// Errorln.
+func (b *LogBlock) Errorln(args ...interface{}) {
+	b.add(errorLog, tprintln, "", args)
+}
+
+// Errorf adds a line for the ERROR, WARNING, and INFO logs, like the global
+// Errorf.
+func (b *LogBlock) Errorf(format string, args ...interface{}) {
+	b.add(errorLog, tprintf, format, args)
+}
+
+// outputBlock is output for the lines of a LogBlock, which it writes under a
+// single hold of l.mu.
+func (l *loggingT) outputBlock(lines []blockLine) {
+	kept := lines[:0]
+	for _, bl := range lines {
+		if l.suppressed(bl.s, bl.buf, bl.file, bl.line) || l.sampledOut(bl.s, bl.buf.when) {
+			l.putBuffer(bl.buf)
+			continue
+		}
+		if bl.s >= errorLog && atomic.LoadUint32(&fingerprints) != 0 {
+			bl.buf.addFingerprint(bl.file, bl.line)
+		}
+		if !l.runHooks(bl.s, bl.buf, bl.file, bl.line) {
+			l.putBuffer(bl.buf)
+			continue
+		}
+		if atomic.LoadUint32(&leakDetection) != 0 {
+			l.detectLeaks(bl.buf, bl.file, bl.line)
+		}
+		kept = append(kept, bl)
+	}
+	if len(kept) == 0 {
+		return
+	}
+	if o := l.sharded(); o != nil {
+		// Keep the order of lines.
+		o.drain()
+	}
+	n := make([]int, len(kept))
+	l.mu.Lock()
+	for i, bl := range kept {
+		if l.traceLocation.isSet() && l.traceLocation.match(bl.file, bl.line) {
+			bl.buf.attachStack(stacks(false))
+		}
+		n[i] = l.writeLine(bl.s, bl.buf, bl.file, bl.line, false)
+		l.putBuffer(bl.buf)
+	}
+	l.mu.Unlock()
+	for i, bl := range kept {
+		countLine(bl.s, n[i])
+	}
+}
@@ -0,0 +1,90 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Test that the lines of a block are written together, in order, at Commit.
+func TestBlock(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+
+	b := WithFields("table", "sizes").Block()
+	b.Info("header")
+	b.Warningf("row %d", 1)
+	if b.Len() != 2 || contains(infoLog, "header", t) {
+		t.Fatalf("lines written before Commit: %q", contents(infoLog))
+	}
+	b.Commit()
+	if b.Len() != 0 {
+		t.Errorf("block holds %d lines after Commit", b.Len())
+	}
+	lines := strings.Split(strings.TrimSuffix(contents(infoLog), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "] header table=sizes") || !strings.HasSuffix(lines[1], "] row 1 table=sizes") {
+		t.Errorf("unexpected lines %q", lines)
+	}
+	if !strings.Contains(lines[0], "glog_block_test.go:") {
+		t.Errorf("wrong caller in %q", lines[0])
+	}
+	if !contains(warningLog, "row 1", t) || contains(warningLog, "header", t) {
+		t.Errorf("unexpected WARNING log %q", contents(warningLog))
+	}
+}
+
+// Test that lines of other goroutines are not interleaved with a block.
+func TestBlockAtomic(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				b := Block()
+				for j := 0; j < 5; j++ {
+					b.Infof("block-%d-%d row %d", g, i, j)
+				}
+				b.Commit()
+			}
+		}(g)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				Info("noise")
+			}
+		}()
+	}
+	wg.Wait()
+	lines := strings.Split(contents(infoLog), "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, "row 0") {
+			continue
+		}
+		id := line[strings.Index(line, "block-"):strings.Index(line, " row")]
+		for j := 1; j < 5; j++ {
+			if i+j >= len(lines) || !strings.Contains(lines[i+j], fmt.Sprintf("%s row %d", id, j)) {
+				t.Fatalf("block %s interrupted at row %d: %q", id, j, lines[i:])
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Sizing of the pool of buffers in which lines are formatted.
+
+package glog
+
+import "fmt"
+
+// defaultMaxRetained is the default BufferPool.MaxRetained.
+const defaultMaxRetained = 256
+
+// BufferPool sets how the buffers in which lines are formatted are recycled.
+// The zero value is the default: buffers that held a line shorter than 256
+// bytes are kept on a free list of unlimited length.
+type BufferPool struct {
+	// InitialSize is the capacity, in bytes, of new buffers, so that long
+	// lines do not grow them step by step.
+	InitialSize int
+	// MaxRetained is the length of line from which a buffer is dropped
+	// rather than reused. Zero means 256 bytes; programs that log long
+	// lines, such as large masked structs, should raise it.
+	MaxRetained int
+	// MaxBuffers is the number of buffers kept on the free list at most.
+	// Zero means no limit.
+	MaxBuffers int
+	// SyncPool keeps the buffers in a sync.Pool instead of the free list,
+	// which lets the garbage collector reclaim them when logging is quiet.
+	// MaxBuffers does not apply.
+	SyncPool bool
+}
+
+// SetBufferPool sets how the buffers in which lines are formatted are
+// recycled. The buffers kept so far are dropped.
+func SetBufferPool(p BufferPool) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	logging.freeListMu.Lock()
+	defer logging.freeListMu.Unlock()
+	logging.bufPool = p
+	logging.freeList, logging.freeCount = nil, 0
+	return nil
+}
+
+// validate reports whether p holds valid settings.
+func (p BufferPool) validate() error {
+	if p.InitialSize < 0 || p.MaxRetained < 0 || p.MaxBuffers < 0 {
+		return fmt.Errorf("log: negative buffer pool size in %+v", p)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Test that the buffer pool honors its sizes.
+func TestSetBufferPool(t *testing.T) {
+	defer SetBufferPool(BufferPool{})
+	if err := SetBufferPool(BufferPool{MaxBuffers: -1}); err == nil {
+		t.Error("negative size accepted")
+	}
+	if err := SetBufferPool(BufferPool{InitialSize: 1024, MaxRetained: 128 << 10, MaxBuffers: 2}); err != nil {
+		t.Fatal(err)
+	}
+	var bufs []*buffer
+	for i := 0; i < 3; i++ {
+		b := logging.getBuffer()
+		if b.Cap() < 1024 {
+			t.Errorf("new buffer has capacity %d, want at least 1024", b.Cap())
+		}
+		bufs = append(bufs, b)
+	}
+	bufs[0].Write(bytes.Repeat([]byte("x"), 64<<10))
+	for _, b := range bufs {
+		logging.putBuffer(b)
+	}
+	logging.freeListMu.Lock()
+	n, first := logging.freeCount, logging.freeList
+	logging.freeListMu.Unlock()
+	if n != 2 {
+		t.Errorf("%d buffers kept, want 2", n)
+	}
+	if first != bufs[1] {
+		t.Error("long line's buffer not kept")
+	}
+	if b := logging.getBuffer(); b != bufs[1] || b.Len() != 0 {
+		t.Error("kept buffer not reused")
+	}
+
+	if err := SetBufferPool(BufferPool{SyncPool: true}); err != nil {
+		t.Fatal(err)
+	}
+	b := logging.getBuffer()
+	b.WriteString("line")
+	logging.putBuffer(b)
+	if b := logging.getBuffer(); b.Len() != 0 {
+		t.Errorf("buffer from the pool holds %q", b.String())
+	}
+	if logging.freeList != nil {
+		t.Error("free list used with SyncPool")
+	}
+}
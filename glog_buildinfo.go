@@ -0,0 +1,84 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Build information of the program in log files and records.
+
+package glog
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// BuildInfo identifies the build of the program, as set by SetBuildInfo.
+type BuildInfo struct {
+	Version string `json:"version,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+}
+
+var (
+	// buildInfo holds the *BuildInfo set by SetBuildInfo, or nil.
+	buildInfo atomic.Value
+	// buildInRecords is set by SetBuildInfoInRecords. Accessed atomically.
+	buildInRecords uint32
+)
+
+// SetBuildInfo records the version and commit of the program, so that log
+// files are self-describing: each new log file starts with a line such as
+//
+//	Build: version 1.4.2, commit 3f2c1ab
+//
+// after the other header lines. Call it at startup, before the first log
+// file is created; SetBuildInfoInRecords adds the build to JSON records too.
+func SetBuildInfo(version, commit string) {
+	buildInfo.Store(&BuildInfo{Version: version, Commit: commit})
+}
+
+// SetBuildInfoInRecords controls whether JSON records, such as those of
+// AddJSONWriter, carry the build set by SetBuildInfo in their "build" field.
+func SetBuildInfoInRecords(on bool) {
+	var v uint32
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&buildInRecords, v)
+}
+
+// currentBuild returns the build set by SetBuildInfo, or nil.
+func currentBuild() *BuildInfo {
+	b, _ := buildInfo.Load().(*BuildInfo)
+	return b
+}
+
+// recordBuild returns the build to put in JSON records, or nil.
+func recordBuild() *BuildInfo {
+	if atomic.LoadUint32(&buildInRecords) == 0 {
+		return nil
+	}
+	return currentBuild()
+}
+
+// banner returns the header line of log files describing b.
+func (b *BuildInfo) banner() string {
+	var parts []string
+	if b.Version != "" {
+		parts = append(parts, "version "+b.Version)
+	}
+	if b.Commit != "" {
+		parts = append(parts, "commit "+b.Commit)
+	}
+	return "Build: " + strings.Join(parts, ", ") + "\n"
+}
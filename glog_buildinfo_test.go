@@ -0,0 +1,74 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that the build set by SetBuildInfo heads new log files and, if
+// enabled, is added to JSON records.
+func TestBuildInfo(t *testing.T) {
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	logDirs = []string{t.TempDir()}
+	defer buildInfo.Store((*BuildInfo)(nil))
+	defer SetBuildInfoInRecords(false)
+
+	SetBuildInfo("1.4.2", "3f2c1ab")
+	sb := &syncBuffer{logger: &logging, sev: infoLog}
+	logging.mu.Lock()
+	err := sb.rotateFile(time.Now())
+	logging.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb.Flush()
+	sb.Close()
+	data, err := os.ReadFile(sb.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "\nBuild: version 1.4.2, commit 3f2c1ab\n") {
+		t.Errorf("build missing from the file header %q", data)
+	}
+
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var buf bytes.Buffer
+	defer AddJSONWriter("INFO", &buf)()
+	Info("without build")
+	SetBuildInfoInRecords(true)
+	Info("with build")
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d records: %q", len(lines), buf.String())
+	}
+	for i, want := range []*BuildInfo{nil, {Version: "1.4.2", Commit: "3f2c1ab"}} {
+		r, err := ParseRecord([]byte(lines[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (r.Build == nil) != (want == nil) || want != nil && *r.Build != *want {
+			t.Errorf("record %d has build %+v, want %+v", i, r.Build, want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Control over the caller information in log headers.
+
+package glog
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// CallerMode selects how the source location of a log line is written in its
+// header.
+type CallerMode int32
+
+const (
+	// CallerShort writes the base name of the file, "server.go:42". It is
+	// the default.
+	CallerShort CallerMode = iota
+	// CallerFull writes the full path of the file as recorded by the
+	// compiler, "/home/me/src/app/server/server.go:42".
+	CallerFull
+	// CallerPackage writes the file qualified by its package import path,
+	// "example.com/app/server/server.go:42".
+	CallerPackage
+	// CallerNone omits the location, saving the cost of runtime.Caller on
+	// every line. -log_backtrace_at has no effect in this mode.
+	CallerNone
+)
+
+var callerModeName = []string{
+	CallerShort:   "short",
+	CallerFull:    "full",
+	CallerPackage: "package",
+	CallerNone:    "none",
+}
+
+// String returns the name of m as used by the -log_caller flag.
+func (m CallerMode) String() string {
+	if m >= 0 && int(m) < len(callerModeName) {
+		return callerModeName[m]
+	}
+	return fmt.Sprintf("CallerMode(%d)", int32(m))
+}
+
+// parseCallerMode returns the mode with the given -log_caller name.
+func parseCallerMode(name string) (CallerMode, bool) {
+	for m, n := range callerModeName {
+		if strings.EqualFold(n, name) {
+			return CallerMode(m), true
+		}
+	}
+	return 0, false
+}
+
+// SetCallerMode selects how the source location is written in log headers.
+func SetCallerMode(m CallerMode) {
+	atomic.StoreInt32(&logging.callerMode, int32(m))
+}
+
+// SetCallerFunc controls whether the name of the calling function, such as
+// "server.(*Server).handle", follows the source location in log headers.
+func SetCallerFunc(on bool) {
+	var v uint32
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&logging.callerFunc, v)
+}
+
+// callerTrim holds the prefixes of SetCallerTrimPrefixes, as a []string.
+var callerTrim atomic.Value
+
+// SetCallerTrimPrefixes sets directory prefixes, such as the root of the
+// module or $GOPATH/src, that are removed from the file paths written with
+// -log_caller=full and from the files of stack frames in records, so that a
+// file is logged as "app/server/server.go:42" whatever the directory it was
+// built in. Where several prefixes match, the longest is removed. Prefixes
+// match whole directories only, and may use backslashes as separators; an
+// empty list restores the full paths.
+func SetCallerTrimPrefixes(prefixes []string) {
+	ps := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		if p = strings.TrimSuffix(strings.Replace(p, `\`, "/", -1), "/"); p != "" {
+			ps = append(ps, p)
+		}
+	}
+	callerTrim.Store(ps)
+}
+
+// callerTrimPrefixes returns the prefixes of SetCallerTrimPrefixes.
+func callerTrimPrefixes() []string {
+	ps, _ := callerTrim.Load().([]string)
+	return ps
+}
+
+// trimCaller returns file, a path as recorded by the compiler, without the
+// longest of the prefixes of SetCallerTrimPrefixes that it starts with.
+func trimCaller(file string) string {
+	trim := 0
+	for _, p := range callerTrimPrefixes() {
+		if len(p) >= trim && len(file) > len(p)+1 && file[len(p)] == '/' && strings.HasPrefix(file, p) {
+			trim = len(p) + 1
+		}
+	}
+	return file[trim:]
+}
+
+// callSite is the resolved location of a program counter.
+type callSite struct {
+	file    string // Full path of the file.
+	base    string // Base name of the file.
+	pkgFile string // File qualified by its package import path.
+	line    int
+	fn      string // Function name, without the package path.
+}
+
+// callSites caches resolved program counters, as resolving them with
+// runtime.Caller on every line allocates. Call sites are few, so it is never
+// pruned.
+var callSites struct {
+	sync.RWMutex
+	m map[uintptr]*callSite
+}
+
+// lookupCallSite returns the location of pc, as returned by runtime.Callers.
+func lookupCallSite(pc uintptr) *callSite {
+	callSites.RLock()
+	site := callSites.m[pc]
+	callSites.RUnlock()
+	if site != nil {
+		return site
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	site = &callSite{file: frame.File, base: frame.File, line: frame.Line}
+	if slash := strings.LastIndex(frame.File, "/"); slash >= 0 {
+		site.base = frame.File[slash+1:]
+	}
+	site.pkgFile = site.base
+	if pkg := funcPackage(frame.Function); pkg != "" {
+		site.pkgFile = pkg + "/" + site.base
+	}
+	// Drop the package path, keeping the package name.
+	site.fn = frame.Function[strings.LastIndex(frame.Function, "/")+1:]
+	callSites.Lock()
+	if callSites.m == nil {
+		callSites.m = make(map[uintptr]*callSite)
+	}
+	callSites.m[pc] = site
+	callSites.Unlock()
+	return site
+}
+
+// caller returns the location written in the header for the caller depth
+// frames above the caller of caller, and its function name if requested.
+// ok is false if the location is not captured or is unknown.
+func (l *loggingT) caller(depth int) (file string, line int, fn string, ok bool) {
+	mode := CallerMode(atomic.LoadInt32(&l.callerMode))
+	if mode == CallerNone {
+		return "", 0, "", false
+	}
+	var pcs [1]uintptr
+	if runtime.Callers(depth+2, pcs[:]) == 0 {
+		return "???", 1, "", false
+	}
+	site := lookupCallSite(pcs[0])
+	if site.file == "" {
+		return "???", 1, "", false
+	}
+	switch mode {
+	case CallerFull:
+		file = trimCaller(site.file)
+	case CallerPackage:
+		file = site.pkgFile
+	default:
+		file = site.base
+	}
+	if atomic.LoadUint32(&l.callerFunc) != 0 {
+		fn = site.fn
+	}
+	return file, site.line, fn, true
+}
+
+// funcPackage returns the import path of the package of the function with the
+// given fully qualified name, such as "example.com/app/server.(*Server).handle".
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+	return name[:slash+1+dot]
+}
+
+// callerModeValue implements flag.Value for the -log_caller flag.
+type callerModeValue struct{}
+
+// String is part of the flag.Value interface.
+func (callerModeValue) String() string {
+	return CallerMode(atomic.LoadInt32(&logging.callerMode)).String()
+}
+
+// Set is part of the flag.Value interface.
+func (callerModeValue) Set(value string) error {
+	m, ok := parseCallerMode(value)
+	if !ok {
+		return fmt.Errorf("unknown caller mode %q: want short, full, package or none", value)
+	}
+	SetCallerMode(m)
+	return nil
+}
+
+// callerFuncValue implements flag.Value for the -log_caller_func flag.
+type callerFuncValue struct{}
+
+// String is part of the flag.Value interface.
+func (callerFuncValue) String() string {
+	return fmt.Sprint(atomic.LoadUint32(&logging.callerFunc) != 0)
+}
+
+// Set is part of the flag.Value interface.
+func (callerFuncValue) Set(value string) error {
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	SetCallerFunc(on)
+	return nil
+}
+
+// IsBoolFlag lets -log_caller_func be given without a value.
+func (callerFuncValue) IsBoolFlag() bool { return true }
+
+// callerTrimValue implements flag.Value for the -log_caller_trim flag.
+type callerTrimValue struct{}
+
+// String is part of the flag.Value interface.
+func (callerTrimValue) String() string {
+	return strings.Join(callerTrimPrefixes(), ",")
+}
+
+// Set is part of the flag.Value interface.
+func (callerTrimValue) Set(value string) error {
+	SetCallerTrimPrefixes(splitLogDirs(value))
+	return nil
+}
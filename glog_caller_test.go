@@ -0,0 +1,94 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"testing"
+)
+
+// Test the caller modes and function names in headers.
+func TestCallerMode(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetCallerMode(CallerShort)
+	defer SetCallerFunc(false)
+	tests := []struct {
+		mode CallerMode
+		fn   bool
+		re   string
+	}{
+		{CallerShort, false, `^I[0-9. :]+ +[0-9]+ glog_caller_test\.go:[0-9]+\] caller\n$`},
+		{CallerFull, false, `^I[0-9. :]+ +[0-9]+ /.+/glog_caller_test\.go:[0-9]+\] caller\n$`},
+		{CallerPackage, false, `^I[0-9. :]+ +[0-9]+ github\.com/biyizhen/glog/glog_caller_test\.go:[0-9]+\] caller\n$`},
+		{CallerNone, false, `^I[0-9. :]+ +[0-9]+\] caller\n$`},
+		{CallerShort, true, `^I[0-9. :]+ +[0-9]+ glog_caller_test\.go:[0-9]+ glog\.TestCallerMode\] caller\n$`},
+		{CallerNone, true, `^I[0-9. :]+ +[0-9]+\] caller\n$`},
+	}
+	for _, test := range tests {
+		logging.newBuffers()
+		SetCallerMode(test.mode)
+		SetCallerFunc(test.fn)
+		Info("caller")
+		if got := contents(infoLog); !regexp.MustCompile(test.re).MatchString(got) {
+			t.Errorf("%v, func %t: got %q", test.mode, test.fn, got)
+		}
+	}
+}
+
+func TestParseCallerMode(t *testing.T) {
+	for _, m := range []CallerMode{CallerShort, CallerFull, CallerPackage, CallerNone} {
+		if got, ok := parseCallerMode(m.String()); !ok || got != m {
+			t.Errorf("%v: got %v, %t", m, got, ok)
+		}
+	}
+	if _, ok := parseCallerMode("long"); ok {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestCallerTrimPrefixes(t *testing.T) {
+	defer SetCallerTrimPrefixes(nil)
+	SetCallerTrimPrefixes([]string{"/src", "/src/app/", "/sr", `C:\build`})
+	tests := []struct{ file, want string }{
+		{"/src/app/server/server.go", "server/server.go"}, // The longest prefix.
+		{"/src/lib/lib.go", "lib/lib.go"},
+		{"/srcs/main.go", "/srcs/main.go"}, // Whole directories only.
+		{"/src/app", "app"},
+		{"C:/build/main.go", "main.go"},
+		{"/other/main.go", "/other/main.go"},
+	}
+	for _, test := range tests {
+		if got := trimCaller(test.file); got != test.want {
+			t.Errorf("trimCaller(%q) = %q, want %q", test.file, got, test.want)
+		}
+	}
+
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetCallerMode(CallerShort)
+	SetCallerMode(CallerFull)
+	_, file, _, _ := runtime.Caller(0)
+	SetCallerTrimPrefixes([]string{filepath.Dir(filepath.Dir(file))})
+	Info("trimmed")
+	re := `^I[0-9. :]+ +[0-9]+ ` + regexp.QuoteMeta(filepath.Base(filepath.Dir(file))) + `/glog_caller_test\.go:[0-9]+\] trimmed\n$`
+	if got := contents(infoLog); !regexp.MustCompile(re).MatchString(got) {
+		t.Errorf("got %q", got)
+	}
+}
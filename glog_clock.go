@@ -0,0 +1,99 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The clock that times log lines, rotation and periodic work.
+
+package glog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the time of log headers, file names and rotation, and makes
+// the tickers and timers of periodic work: flushing, batching and watching
+// level files. SetClock replaces the system clock with another Clock, such
+// as one that a test or simulation advances by hand; see glogtest.FakeClock.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	// AfterFunc calls f in its own goroutine once d has elapsed, unless
+	// the returned Timer is stopped first. The Timer's channel is unused.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single event made by a Clock, like time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker is a periodic event made by a Clock, like time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SetClock makes c the source of time of the package. A nil c restores the
+// system clock. Timers and tickers already running keep their clock, so
+// SetClock is best called before logging starts.
+func SetClock(c Clock) {
+	if c == nil {
+		c = systemClock{}
+	}
+	clock.Store(clockBox{c})
+}
+
+// clockBox holds a Clock in an atomic.Value, which needs one concrete type.
+type clockBox struct{ Clock }
+
+// clock holds the current clockBox.
+var clock atomic.Value
+
+func init() {
+	SetClock(nil)
+}
+
+// currentClock returns the Clock set by SetClock.
+func currentClock() Clock {
+	return clock.Load().(clockBox).Clock
+}
+
+// systemClock is the Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+func (systemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct{ t *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.t.C }
+
+func (t systemTimer) Stop() bool { return t.t.Stop() }
+
+type systemTicker struct{ t *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.t.C }
+
+func (t systemTicker) Stop() { t.t.Stop() }
@@ -0,0 +1,78 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixedClock is a Clock stopped at t whose AfterFunc timers fire only when
+// fire is called.
+type fixedClock struct {
+	systemClock
+	t       time.Time
+	pending *[]func()
+}
+
+func (c fixedClock) Now() time.Time { return c.t }
+
+func (c fixedClock) AfterFunc(d time.Duration, f func()) Timer {
+	*c.pending = append(*c.pending, f)
+	return systemTimer{time.NewTimer(time.Hour)}
+}
+
+// fire calls the functions passed to AfterFunc.
+func (c fixedClock) fire() {
+	fs := *c.pending
+	*c.pending = nil
+	for _, f := range fs {
+		f()
+	}
+}
+
+// Test that timestamps and the batch latency follow the clock.
+func TestSetClock(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetClock(nil)
+	defer SetBatchLatency(0)
+	defer func() { logging.toStderr = false }()
+	c := fixedClock{t: time.Date(2030, 5, 6, 7, 8, 9, 0, time.Local), pending: new([]func())}
+	SetClock(c)
+
+	Info("stamped")
+	if !contains(infoLog, "I0506 07:08:09.000000", t) {
+		t.Errorf("header not from the clock: %q", contents(infoLog))
+	}
+
+	logging.toStderr = true
+	SetBatchLatency(time.Millisecond)
+	out := capture(t, &os.Stderr, func() {
+		Info("batched")
+		time.Sleep(10 * time.Millisecond)
+		if fi, err := os.Stderr.Stat(); err != nil || fi.Size() != 0 {
+			t.Errorf("batch written before the clock's timer fired")
+		}
+		c.fire()
+	})
+	if !strings.HasSuffix(out, "] batched\n") {
+		t.Errorf("standard error is %q", out)
+	}
+}
@@ -0,0 +1,465 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Sink sending log records to Google Cloud Logging.
+
+package glog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits of the entries.write API, with a margin for the request envelope.
+const (
+	gcpMaxEntrySize  = 250 * 1024
+	gcpMaxBatchSize  = 5 * 1024 * 1024
+	gcpMaxBatchCount = 1000
+	gcpMaxAttempts   = 5
+)
+
+// gcpSeverity maps glog severities to Cloud Logging severities.
+var gcpSeverity = map[string]string{
+	"INFO":    "INFO",
+	"WARNING": "WARNING",
+	"ERROR":   "ERROR",
+	"FATAL":   "CRITICAL",
+}
+
+// GCPResource is the monitored resource that log entries are attached to,
+// such as {Type: "gce_instance", Labels: {"instance_id": ..., "zone": ...}}.
+type GCPResource struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// CloudLoggingConfig configures a CloudLoggingSink.
+type CloudLoggingConfig struct {
+	// ProjectID is the project receiving the entries. The default is
+	// $GOOGLE_CLOUD_PROJECT, or else the project of the credentials or of
+	// the metadata server.
+	ProjectID string
+	// LogID names the log, as in projects/PROJECT/logs/LOG_ID. The default
+	// is the program name.
+	LogID string
+	// Resource is the monitored resource of the entries. The default is a
+	// cloud_run_revision on Cloud Run, a gce_instance where the metadata
+	// server answers, and global otherwise. Set it on GKE, for instance, to
+	// a k8s_container.
+	Resource *GCPResource
+	// Labels are added to every entry, next to the global labels of
+	// SetLabels.
+	Labels map[string]string
+	// Token returns the access token authorizing each request. It is called
+	// again when the token it returned expires or is rejected. The default
+	// is DefaultGCPToken.
+	Token func() (GCPToken, error)
+	// Encoder encodes the records sent. Records whose encoding is a JSON
+	// object, as with the default JSONEncoder, are sent as structured
+	// jsonPayload; others as textPayload.
+	Encoder Encoder
+	// Endpoint is the URL of the Cloud Logging API. The default is
+	// "https://logging.googleapis.com".
+	Endpoint string
+	// FlushInterval is the longest time a record waits before it is sent.
+	// The default is 5s.
+	FlushInterval time.Duration
+	// BufferSize is the number of records held in memory while they cannot
+	// be sent; the oldest are dropped beyond it. The default is 10000.
+	BufferSize int
+	// Timeout bounds each request. The default is 10s.
+	Timeout time.Duration
+	// Client sends the requests. The default is a client with Timeout.
+	Client *http.Client
+}
+
+// CloudLoggingSink sends log records to Google Cloud Logging (formerly
+// Stackdriver), with their severity mapped to a Cloud Logging severity
+// (FATAL is CRITICAL), their source location, and the global labels.
+// Register it with AddWriter:
+//
+//	cl, err := glog.NewCloudLoggingSink(glog.CloudLoggingConfig{})
+//	...
+//	glog.AddWriter("INFO", cl)
+//	defer cl.Close()
+//
+// Records are queued and sent in batches by a background goroutine every
+// FlushInterval, when a batch is full, or on Flush, so logging never waits
+// on the network. Batches that cannot be sent after retries are dropped and
+// reported to the SetErrorHandler function as a *WriteError.
+type CloudLoggingSink struct {
+	cfg      CloudLoggingConfig
+	logName  string
+	endpoint string
+	dropped  int64 // Updated atomically.
+
+	mu     sync.Mutex
+	queue  []*gcpEntry
+	size   int // Approximate bytes in queue.
+	closed bool
+	// sending is the number of records taken by the sender goroutine
+	// and not yet sent or dropped.
+	sending int
+
+	token GCPToken // Cached token; used by the sender goroutine only.
+
+	kick chan struct{} // Wakes the sender goroutine to send now.
+	quit chan struct{} // Closed by Close.
+	done chan struct{} // Closed when the sender goroutine exits.
+}
+
+// gcpEntry is a LogEntry of the Cloud Logging API.
+type gcpEntry struct {
+	Timestamp      string             `json:"timestamp"`
+	Severity       string             `json:"severity"`
+	TextPayload    string             `json:"textPayload,omitempty"`
+	JSONPayload    json.RawMessage    `json:"jsonPayload,omitempty"`
+	Labels         map[string]string  `json:"labels,omitempty"`
+	SourceLocation *gcpSourceLocation `json:"sourceLocation,omitempty"`
+
+	size int // Approximate encoded size.
+}
+
+type gcpSourceLocation struct {
+	File string `json:"file"`
+	Line int    `json:"line,string"`
+}
+
+// NewCloudLoggingSink returns a CloudLoggingSink for cfg and starts its
+// sender goroutine.
+func NewCloudLoggingSink(cfg CloudLoggingConfig) (*CloudLoggingSink, error) {
+	if cfg.ProjectID == "" {
+		p, err := defaultGCPProject()
+		if err != nil {
+			return nil, err
+		}
+		cfg.ProjectID = p
+	}
+	if cfg.LogID == "" {
+		cfg.LogID = program
+	}
+	if cfg.Resource == nil {
+		cfg.Resource = detectGCPResource(cfg.ProjectID)
+	}
+	if cfg.Token == nil {
+		cfg.Token = DefaultGCPToken
+	}
+	if cfg.Encoder == nil {
+		cfg.Encoder = JSONEncoder
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://logging.googleapis.com"
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 10000
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: cfg.Timeout}
+	}
+	s := &CloudLoggingSink{
+		cfg:      cfg,
+		logName:  "projects/" + cfg.ProjectID + "/logs/" + url.PathEscape(cfg.LogID),
+		endpoint: cfg.Endpoint + "/v2/entries:write",
+		kick:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// detectGCPResource returns the monitored resource the program runs on.
+func detectGCPResource(project string) *GCPResource {
+	if service := os.Getenv("K_SERVICE"); service != "" {
+		r := &GCPResource{Type: "cloud_run_revision", Labels: map[string]string{
+			"project_id":         project,
+			"service_name":       service,
+			"revision_name":      os.Getenv("K_REVISION"),
+			"configuration_name": os.Getenv("K_CONFIGURATION"),
+		}}
+		// Of the form projects/NUMBER/regions/REGION.
+		if region, err := gcpMetadata("instance/region"); err == nil {
+			r.Labels["location"] = region[strings.LastIndexByte(region, '/')+1:]
+		}
+		return r
+	}
+	if id, err := gcpMetadata("instance/id"); err == nil {
+		zone, _ := gcpMetadata("instance/zone")
+		return &GCPResource{Type: "gce_instance", Labels: map[string]string{
+			"project_id":  project,
+			"instance_id": id,
+			"zone":        zone[strings.LastIndexByte(zone, '/')+1:],
+		}}
+	}
+	return &GCPResource{Type: "global", Labels: map[string]string{"project_id": project}}
+}
+
+// Write queues p, a formatted log line, as the message of a record.
+// AddWriter does not call it; it sends fully structured records instead.
+func (s *CloudLoggingSink) Write(p []byte) (int, error) {
+	rec := &logRecord{Time: currentClock().Now(), Host: host, PID: pid, Message: string(p), Labels: globalLabels.Load().(*labelSet).labels}
+	return len(p), s.writeRecord(rec)
+}
+
+func (s *CloudLoggingSink) writeRecord(r *logRecord) error {
+	e := &gcpEntry{
+		Timestamp: r.Time.UTC().Format(time.RFC3339Nano),
+		Severity:  gcpSeverity[r.Severity],
+		Labels:    r.Labels,
+	}
+	if e.Severity == "" {
+		e.Severity = "DEFAULT"
+	}
+	if r.File != "" {
+		e.SourceLocation = &gcpSourceLocation{r.File, r.Line}
+	}
+	payload := bytes.TrimSpace(encode(s.cfg.Encoder, r))
+	if len(payload) > 0 && payload[0] == '{' && len(payload) <= gcpMaxEntrySize && json.Valid(payload) {
+		e.JSONPayload = payload
+	} else {
+		if max := gcpMaxEntrySize; len(payload) > max {
+			// Cut at a character boundary.
+			for max > 0 && !utf8.RuneStart(payload[max]) {
+				max--
+			}
+			payload = payload[:max]
+		}
+		e.TextPayload = string(payload)
+		if r.Logger != "" {
+			// Kept by jsonPayload, but lost from text.
+			labels := map[string]string{"logger": r.Logger}
+			for k, v := range r.Labels {
+				labels[k] = v
+			}
+			e.Labels = labels
+		}
+	}
+	e.size = len(payload) + len(e.Timestamp) + len(r.File) + 100
+	for k, v := range e.Labels {
+		e.size += len(k) + len(v) + 6
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errSinkClosed
+	}
+	if len(s.queue) >= s.cfg.BufferSize {
+		s.size -= s.queue[0].size
+		s.queue = s.queue[1:]
+		atomic.AddInt64(&s.dropped, 1)
+	}
+	s.queue = append(s.queue, e)
+	s.size += e.size
+	if len(s.queue) >= gcpMaxBatchCount || s.size >= gcpMaxBatchSize {
+		s.wake()
+	}
+	return nil
+}
+
+// wake asks the sender goroutine to send the queue now.
+func (s *CloudLoggingSink) wake() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Flush asks the sender goroutine to send the queued records now. It does
+// not wait for them to be sent.
+func (s *CloudLoggingSink) Flush() error {
+	s.wake()
+	return nil
+}
+
+// Dropped returns the number of records discarded because the buffer was
+// full or their batch could not be sent.
+func (s *CloudLoggingSink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Close sends the queued records, making a single attempt per batch, and
+// stops the sender goroutine.
+func (s *CloudLoggingSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errSinkClosed
+	}
+	s.closed = true
+	close(s.quit)
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
+
+// take removes the queued records from the queue.
+func (s *CloudLoggingSink) take() []*gcpEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queue
+	s.queue, s.size = nil, 0
+	s.sending = len(q)
+	return q
+}
+
+// unsent returns the number of records not yet sent or dropped.
+func (s *CloudLoggingSink) unsent() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue) + s.sending
+}
+
+// FlushContext sends the queued records now and waits until they are sent
+// or dropped, or until ctx is done, in which case it returns an error
+// wrapping that of ctx.
+func (s *CloudLoggingSink) FlushContext(ctx context.Context) error {
+	s.wake()
+	return waitSent(ctx, "cloud logging "+s.logName, s.unsent)
+}
+
+// run is the sender goroutine.
+func (s *CloudLoggingSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		quit := false
+		select {
+		case <-ticker.C:
+		case <-s.kick:
+		case <-s.quit:
+			quit = true
+		}
+		entries := s.take()
+		for len(entries) > 0 {
+			n, size := 0, 0
+			for n < len(entries) && n < gcpMaxBatchCount && (n == 0 || size+entries[n].size <= gcpMaxBatchSize) {
+				size += entries[n].size
+				n++
+			}
+			if err := s.send(entries[:n]); err != nil {
+				atomic.AddInt64(&s.dropped, int64(n))
+				handleError(&WriteError{s, err})
+			}
+			entries = entries[n:]
+		}
+		s.mu.Lock()
+		s.sending = 0
+		s.mu.Unlock()
+		if quit {
+			return
+		}
+	}
+}
+
+// gcpError is an error returned by a Google API.
+type gcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+func (e *gcpError) Error() string {
+	return fmt.Sprintf("log: cloud logging: %d %s: %s", e.Code, e.Status, e.Message)
+}
+
+// send writes batch with entries.write, retrying as needed. Once the sink is
+// closed, it makes a single attempt.
+func (s *CloudLoggingSink) send(batch []*gcpEntry) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"logName":  s.logName,
+		"resource": s.cfg.Resource,
+		"labels":   s.cfg.Labels,
+		"entries":  batch,
+		// Write the valid entries of a batch even if some are rejected.
+		"partialSuccess": true,
+	})
+	if err != nil {
+		return err
+	}
+	backoff := 200 * time.Millisecond
+	for attempt := 0; attempt < gcpMaxAttempts; attempt++ {
+		err = s.post(body)
+		gerr, ok := err.(*gcpError)
+		switch {
+		case err == nil:
+			return nil
+		case ok && gerr.Code == http.StatusUnauthorized:
+			s.token = GCPToken{}
+		case ok && gerr.Code != http.StatusTooManyRequests && gerr.Code < 500:
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-s.quit:
+			return err
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+// post posts body to entries.write.
+func (s *CloudLoggingSink) post(body []byte) error {
+	if s.token.AccessToken == "" || !s.token.Expires.IsZero() && time.Now().Add(time.Minute).After(s.token.Expires) {
+		t, err := s.cfg.Token()
+		if err != nil {
+			return err
+		}
+		s.token = t
+	}
+	req, err := http.NewRequest("POST", s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.token.AccessToken)
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var e struct{ Error *gcpError }
+	if json.Unmarshal(data, &e) != nil || e.Error == nil {
+		e.Error = &gcpError{Message: resp.Status}
+	}
+	e.Error.Code = resp.StatusCode
+	return e.Error
+}
@@ -0,0 +1,204 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCloudLogging records the requests to entries.write.
+type fakeCloudLogging struct {
+	mu       sync.Mutex
+	requests []map[string]json.RawMessage
+	entries  []gcpEntry
+	tokens   []string // Authorization headers.
+	fail     []int    // Status codes of the next responses.
+}
+
+func (f *fakeCloudLogging) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens = append(f.tokens, r.Header.Get("Authorization"))
+	if r.URL.Path != "/v2/entries:write" {
+		http.NotFound(w, r)
+		return
+	}
+	if len(f.fail) > 0 {
+		code := f.fail[0]
+		f.fail = f.fail[1:]
+		w.WriteHeader(code)
+		w.Write([]byte(`{"error":{"code":0,"message":"failed","status":"UNAVAILABLE"}}`))
+		return
+	}
+	var req map[string]json.RawMessage
+	json.NewDecoder(r.Body).Decode(&req)
+	var entries []gcpEntry
+	json.Unmarshal(req["entries"], &entries)
+	f.requests = append(f.requests, req)
+	f.entries = append(f.entries, entries...)
+	w.Write([]byte("{}"))
+}
+
+func (f *fakeCloudLogging) waitEntries(t *testing.T, n int) []gcpEntry {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		f.mu.Lock()
+		e := f.entries
+		f.mu.Unlock()
+		if len(e) >= n {
+			return e
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("got fewer than %d entries", n)
+	return nil
+}
+
+func newTestCloudLoggingSink(t *testing.T, f *fakeCloudLogging, cfg CloudLoggingConfig) *CloudLoggingSink {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	cfg.Endpoint = srv.URL
+	cfg.ProjectID = "proj"
+	cfg.FlushInterval = time.Hour
+	n := 0
+	cfg.Token = func() (GCPToken, error) {
+		n++
+		return GCPToken{AccessToken: strings.Repeat("t", n)}, nil
+	}
+	s, err := NewCloudLoggingSink(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCloudLoggingSink(t *testing.T) {
+	f := &fakeCloudLogging{}
+	s := newTestCloudLoggingSink(t, f, CloudLoggingConfig{
+		LogID:    "my/app",
+		Resource: &GCPResource{Type: "k8s_container", Labels: map[string]string{"pod_name": "web-1"}},
+		Labels:   map[string]string{"env": "prod"},
+	})
+	defer s.Close()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.writeRecord(&logRecord{Time: now, Severity: "FATAL", File: "main.go", Line: 7, Message: "boom",
+		Labels: map[string]string{"region": "eu"}})
+	s.Flush()
+	entries := f.waitEntries(t, 1)
+	e := entries[0]
+	if e.Severity != "CRITICAL" || e.Timestamp != "2024-05-01T12:00:00Z" || e.Labels["region"] != "eu" ||
+		e.SourceLocation == nil || *e.SourceLocation != (gcpSourceLocation{"main.go", 7}) {
+		t.Errorf("entry = %+v", e)
+	}
+	var payload logRecord
+	if err := json.Unmarshal(e.JSONPayload, &payload); err != nil || payload.Message != "boom" {
+		t.Errorf("jsonPayload = %s, %v", e.JSONPayload, err)
+	}
+	f.mu.Lock()
+	req := f.requests[0]
+	f.mu.Unlock()
+	for k, want := range map[string]string{
+		"logName":  `"projects/proj/logs/my%2Fapp"`,
+		"resource": `{"type":"k8s_container","labels":{"pod_name":"web-1"}}`,
+		"labels":   `{"env":"prod"}`,
+	} {
+		if got := string(req[k]); got != want {
+			t.Errorf("%s = %s, want %s", k, got, want)
+		}
+	}
+}
+
+func TestCloudLoggingText(t *testing.T) {
+	f := &fakeCloudLogging{}
+	s := newTestCloudLoggingSink(t, f, CloudLoggingConfig{Encoder: TextEncoder, Resource: &GCPResource{Type: "global"}})
+	defer s.Close()
+	s.writeRecord(&logRecord{Time: time.Now(), Severity: "WARNING", Logger: "db", text: []byte("W0501 careful\n")})
+	s.Flush()
+	e := f.waitEntries(t, 1)[0]
+	if e.Severity != "WARNING" || e.TextPayload != "W0501 careful" || e.JSONPayload != nil || e.Labels["logger"] != "db" {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestCloudLoggingRetry(t *testing.T) {
+	// An expired token is replaced, and unavailability retried.
+	f := &fakeCloudLogging{fail: []int{http.StatusUnauthorized, http.StatusServiceUnavailable}}
+	s := newTestCloudLoggingSink(t, f, CloudLoggingConfig{Resource: &GCPResource{Type: "global"}})
+	defer s.Close()
+	s.writeRecord(&logRecord{Time: time.Now(), Severity: "INFO"})
+	s.Flush()
+	f.waitEntries(t, 1)
+	f.mu.Lock()
+	if got, want := strings.Join(f.tokens, ","), "Bearer t,Bearer tt,Bearer tt"; got != want {
+		t.Errorf("tokens = %s, want %s", got, want)
+	}
+	f.mu.Unlock()
+
+	// Other client errors drop the batch.
+	var handled error
+	SetErrorHandler(func(err error) { handled = err })
+	defer SetErrorHandler(nil)
+	f.mu.Lock()
+	f.fail = []int{http.StatusBadRequest}
+	f.mu.Unlock()
+	s.writeRecord(&logRecord{Time: time.Now(), Severity: "INFO"})
+	s.Close()
+	var gerr *gcpError
+	if !errors.As(handled, &gerr) || gerr.Code != http.StatusBadRequest || s.Dropped() != 1 {
+		t.Errorf("handled %v, dropped %d", handled, s.Dropped())
+	}
+}
+
+func TestDetectGCPResource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/region":
+			w.Write([]byte("projects/123/regions/europe-west1"))
+		case "/computeMetadata/v1/instance/id":
+			w.Write([]byte("4567"))
+		case "/computeMetadata/v1/instance/zone":
+			w.Write([]byte("projects/123/zones/europe-west1-b"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	clearGCPEnv(t, srv)
+	t.Setenv("K_SERVICE", "")
+	r := detectGCPResource("proj")
+	if r.Type != "gce_instance" || r.Labels["instance_id"] != "4567" || r.Labels["zone"] != "europe-west1-b" {
+		t.Errorf("on GCE: %+v", r)
+	}
+	t.Setenv("K_SERVICE", "api")
+	t.Setenv("K_REVISION", "api-00001")
+	r = detectGCPResource("proj")
+	if r.Type != "cloud_run_revision" || r.Labels["service_name"] != "api" || r.Labels["location"] != "europe-west1" {
+		t.Errorf("on Cloud Run: %+v", r)
+	}
+	t.Setenv("K_SERVICE", "")
+	gcpMetadataURL = "http://127.0.0.1:1"
+	if r := detectGCPResource("proj"); r.Type != "global" || r.Labels["project_id"] != "proj" {
+		t.Errorf("elsewhere: %+v", r)
+	}
+}
@@ -0,0 +1,474 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Sink sending log records to AWS CloudWatch Logs.
+
+package glog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits of the PutLogEvents API.
+const (
+	cwEventOverhead = 26             // Bytes counted per event on top of its message.
+	cwMaxEventSize  = 256 * 1024     // Including the overhead.
+	cwMaxBatchSize  = 1024 * 1024    // Including the overhead of each event.
+	cwMaxBatchCount = 10000          // Events per batch.
+	cwMaxBatchSpan  = 24 * time.Hour // Between the first and last event.
+	cwMaxAttempts   = 5              // Per batch, before it is dropped.
+)
+
+// CloudWatchConfig configures a CloudWatchSink.
+type CloudWatchConfig struct {
+	// LogGroup is the log group receiving the records. It must be set.
+	LogGroup string
+	// LogStream is the log stream in LogGroup. The default is
+	// "host/program/pid". The stream is created if it does not exist.
+	LogStream string
+	// CreateLogGroup makes the sink create LogGroup if it does not exist,
+	// which requires the logs:CreateLogGroup permission.
+	CreateLogGroup bool
+	// Region is the AWS region. The default is $AWS_REGION, or else
+	// $AWS_DEFAULT_REGION.
+	Region string
+	// Endpoint is the URL of the CloudWatch Logs API. The default is the
+	// endpoint of Region.
+	Endpoint string
+	// Credentials returns the credentials signing each request. It is called
+	// again when the credentials it returned expire or are rejected. The
+	// default is DefaultAWSCredentials.
+	Credentials func() (AWSCredentials, error)
+	// Encoder encodes the records sent. The default is JSONEncoder.
+	Encoder Encoder
+	// FlushInterval is the longest time a record waits before it is sent.
+	// The default is 5s.
+	FlushInterval time.Duration
+	// BufferSize is the number of records held in memory while they cannot
+	// be sent; the oldest are dropped beyond it. The default is 10000.
+	BufferSize int
+	// Timeout bounds each request. The default is 10s.
+	Timeout time.Duration
+	// Client sends the requests. The default is a client with Timeout.
+	Client *http.Client
+}
+
+// CloudWatchSink sends log records, by default as JSON, to a log stream of
+// AWS CloudWatch Logs, for deployments such as Lambda functions and ECS tasks
+// that cannot run a log shipper. Register it with AddWriter:
+//
+//	cw, err := glog.NewCloudWatchSink(glog.CloudWatchConfig{LogGroup: "/app/api"})
+//	...
+//	glog.AddWriter("INFO", cw)
+//	defer cw.Close()
+//
+// Records are queued and sent in batches by a background goroutine every
+// FlushInterval, when a batch is full, or on Flush, so logging never waits
+// on the network. Batches that cannot be sent after retries are dropped and
+// reported to the SetErrorHandler function as a *WriteError.
+type CloudWatchSink struct {
+	cfg      CloudWatchConfig
+	endpoint string
+	dropped  int64 // Updated atomically.
+
+	mu     sync.Mutex
+	queue  []cwEvent
+	size   int // Bytes in queue, as counted by the API.
+	closed bool
+	// sending is the number of records taken by the sender goroutine
+	// and not yet sent or dropped.
+	sending int
+
+	// Used by the sender goroutine only.
+	token     string         // Sequence token for the next PutLogEvents.
+	creds     AWSCredentials // Cached credentials.
+	haveCreds bool
+
+	kick chan struct{} // Wakes the sender goroutine to send now.
+	quit chan struct{} // Closed by Close.
+	done chan struct{} // Closed when the sender goroutine exits.
+}
+
+// cwEvent is a log event as sent to CloudWatch Logs.
+type cwEvent struct {
+	Timestamp int64  `json:"timestamp"` // Milliseconds since the epoch.
+	Message   string `json:"message"`
+}
+
+func (e cwEvent) size() int { return len(e.Message) + cwEventOverhead }
+
+// NewCloudWatchSink returns a CloudWatchSink for cfg and starts its sender
+// goroutine.
+func NewCloudWatchSink(cfg CloudWatchConfig) (*CloudWatchSink, error) {
+	if cfg.LogGroup == "" {
+		return nil, errors.New("log: cloudwatch: no log group")
+	}
+	if cfg.LogStream == "" {
+		cfg.LogStream = fmt.Sprintf("%s/%s/%d", host, program, pid)
+	}
+	// ':' and '*' are not allowed in stream names.
+	cfg.LogStream = strings.NewReplacer(":", "_", "*", "_").Replace(cfg.LogStream)
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_REGION")
+	}
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("log: cloudwatch: no region")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://logs." + cfg.Region + ".amazonaws.com"
+		if strings.HasPrefix(cfg.Region, "cn-") {
+			endpoint += ".cn"
+		}
+	}
+	if cfg.Credentials == nil {
+		cfg.Credentials = DefaultAWSCredentials
+	}
+	if cfg.Encoder == nil {
+		cfg.Encoder = JSONEncoder
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 10000
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: cfg.Timeout}
+	}
+	s := &CloudWatchSink{
+		cfg:      cfg,
+		endpoint: endpoint,
+		kick:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Write queues p, a formatted log line, as the message of a record.
+// AddWriter does not call it; it sends fully structured records instead.
+func (s *CloudWatchSink) Write(p []byte) (int, error) {
+	rec := &logRecord{Time: currentClock().Now(), Host: host, PID: pid, Message: string(p), Labels: globalLabels.Load().(*labelSet).labels}
+	return len(p), s.writeRecord(rec)
+}
+
+func (s *CloudWatchSink) writeRecord(r *logRecord) error {
+	msg := bytes.TrimSuffix(encode(s.cfg.Encoder, r), []byte{'\n'})
+	if max := cwMaxEventSize - cwEventOverhead; len(msg) > max {
+		// Cut at a character boundary.
+		for max > 0 && !utf8.RuneStart(msg[max]) {
+			max--
+		}
+		msg = msg[:max]
+	}
+	e := cwEvent{r.Time.UnixNano() / int64(time.Millisecond), string(msg)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errSinkClosed
+	}
+	if len(s.queue) >= s.cfg.BufferSize {
+		s.size -= s.queue[0].size()
+		s.queue = s.queue[1:]
+		atomic.AddInt64(&s.dropped, 1)
+	}
+	s.queue = append(s.queue, e)
+	s.size += e.size()
+	if len(s.queue) >= cwMaxBatchCount || s.size >= cwMaxBatchSize {
+		s.wake()
+	}
+	return nil
+}
+
+// wake asks the sender goroutine to send the queue now.
+func (s *CloudWatchSink) wake() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Flush asks the sender goroutine to send the queued records now. It does
+// not wait for them to be sent.
+func (s *CloudWatchSink) Flush() error {
+	s.wake()
+	return nil
+}
+
+// Dropped returns the number of records discarded because the buffer was
+// full or their batch could not be sent.
+func (s *CloudWatchSink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Close sends the queued records, making a single attempt per batch, and
+// stops the sender goroutine.
+func (s *CloudWatchSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errSinkClosed
+	}
+	s.closed = true
+	close(s.quit)
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
+
+// take removes the queued records from the queue.
+func (s *CloudWatchSink) take() []cwEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queue
+	s.queue, s.size = nil, 0
+	s.sending = len(q)
+	return q
+}
+
+// unsent returns the number of records not yet sent or dropped.
+func (s *CloudWatchSink) unsent() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue) + s.sending
+}
+
+// FlushContext sends the queued records now and waits until they are sent
+// or dropped, or until ctx is done, in which case it returns an error
+// wrapping that of ctx.
+func (s *CloudWatchSink) FlushContext(ctx context.Context) error {
+	s.wake()
+	return waitSent(ctx, "cloudwatch "+s.cfg.LogGroup, s.unsent)
+}
+
+// run is the sender goroutine.
+func (s *CloudWatchSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		quit := false
+		select {
+		case <-ticker.C:
+		case <-s.kick:
+		case <-s.quit:
+			quit = true
+		}
+		for _, batch := range cloudWatchBatches(s.take()) {
+			if err := s.send(batch); err != nil {
+				atomic.AddInt64(&s.dropped, int64(len(batch)))
+				handleError(&WriteError{s, err})
+			}
+		}
+		s.mu.Lock()
+		s.sending = 0
+		s.mu.Unlock()
+		if quit {
+			return
+		}
+	}
+}
+
+// cloudWatchBatches sorts events by time, as PutLogEvents requires, and
+// splits them into batches within the limits of the API.
+func cloudWatchBatches(events []cwEvent) [][]cwEvent {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp < events[j].Timestamp })
+	var batches [][]cwEvent
+	start, size := 0, 0
+	for i, e := range events {
+		span := time.Duration(e.Timestamp-events[start].Timestamp) * time.Millisecond
+		if i > start && (i-start == cwMaxBatchCount || size+e.size() > cwMaxBatchSize || span >= cwMaxBatchSpan) {
+			batches = append(batches, events[start:i])
+			start, size = i, 0
+		}
+		size += e.size()
+	}
+	if start < len(events) {
+		batches = append(batches, events[start:])
+	}
+	return batches
+}
+
+// awsError is an error returned by an AWS JSON API.
+type awsError struct {
+	Status  int
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+	// ExpectedSequenceToken is set by InvalidSequenceTokenException and
+	// DataAlreadyAcceptedException.
+	ExpectedSequenceToken string `json:"expectedSequenceToken"`
+}
+
+func (e *awsError) Error() string {
+	return fmt.Sprintf("log: cloudwatch: %d %s: %s", e.Status, e.Type, e.Message)
+}
+
+// retryable reports whether the request may succeed if sent again later.
+func (e *awsError) retryable() bool {
+	return e.Status >= 500 || strings.Contains(e.Type, "Throttling") || e.Type == "ServiceUnavailableException"
+}
+
+// send sends batch with PutLogEvents, creating the log stream and retrying
+// as needed. Once the sink is closed, it makes a single attempt.
+func (s *CloudWatchSink) send(batch []cwEvent) error {
+	backoff := 200 * time.Millisecond
+	var err error
+	for attempt := 0; attempt < cwMaxAttempts; attempt++ {
+		req := map[string]interface{}{
+			"logGroupName":  s.cfg.LogGroup,
+			"logStreamName": s.cfg.LogStream,
+			"logEvents":     batch,
+		}
+		if s.token != "" {
+			req["sequenceToken"] = s.token
+		}
+		var resp struct {
+			NextSequenceToken string `json:"nextSequenceToken"`
+		}
+		err = s.call("PutLogEvents", req, &resp)
+		if err == nil {
+			s.token = resp.NextSequenceToken
+			return nil
+		}
+		aerr, ok := err.(*awsError)
+		switch {
+		case ok && aerr.Type == "ResourceNotFoundException":
+			if err = s.createStream(); err != nil {
+				return err
+			}
+			s.token = ""
+			continue
+		case ok && aerr.Type == "InvalidSequenceTokenException":
+			s.token = aerr.ExpectedSequenceToken
+			continue
+		case ok && aerr.Type == "DataAlreadyAcceptedException":
+			s.token = aerr.ExpectedSequenceToken
+			return nil
+		case ok && (aerr.Type == "ExpiredTokenException" || aerr.Type == "UnrecognizedClientException"):
+			s.haveCreds = false
+		case ok && !aerr.retryable():
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-s.quit:
+			return err
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+// createStream creates the log stream, and the log group if so configured.
+func (s *CloudWatchSink) createStream() error {
+	group := map[string]interface{}{"logGroupName": s.cfg.LogGroup}
+	stream := map[string]interface{}{"logGroupName": s.cfg.LogGroup, "logStreamName": s.cfg.LogStream}
+	err := s.call("CreateLogStream", stream, nil)
+	if aerr, ok := err.(*awsError); ok && aerr.Type == "ResourceNotFoundException" && s.cfg.CreateLogGroup {
+		if err = s.call("CreateLogGroup", group, nil); err == nil || isAlreadyExists(err) {
+			err = s.call("CreateLogStream", stream, nil)
+		}
+	}
+	if isAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func isAlreadyExists(err error) bool {
+	aerr, ok := err.(*awsError)
+	return ok && aerr.Type == "ResourceAlreadyExistsException"
+}
+
+// credentials returns the cached credentials, fetching them if they are
+// missing or about to expire.
+func (s *CloudWatchSink) credentials() (AWSCredentials, error) {
+	now := time.Now()
+	if s.haveCreds && (s.creds.Expires.IsZero() || now.Add(5*time.Minute).Before(s.creds.Expires)) {
+		return s.creds, nil
+	}
+	c, err := s.cfg.Credentials()
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	s.creds, s.haveCreds = c, true
+	return c, nil
+}
+
+// call calls the CloudWatch Logs action with the JSON request in, decoding
+// the response into out if it is non-nil.
+func (s *CloudWatchSink) call(action string, in, out interface{}) error {
+	creds, err := s.credentials()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Logs_20140328."+action)
+	signAWS(req, body, creds, s.cfg.Region, "logs", time.Now())
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		aerr := &awsError{Status: resp.StatusCode}
+		json.Unmarshal(data, aerr) // A body that is not JSON leaves only the status.
+		if i := strings.LastIndex(aerr.Type, "#"); i >= 0 {
+			aerr.Type = aerr.Type[i+1:]
+		}
+		if aerr.Message == "" {
+			aerr.Message = resp.Status
+		}
+		return aerr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,236 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCloudWatch emulates the log stream actions of CloudWatch Logs.
+type fakeCloudWatch struct {
+	mu      sync.Mutex
+	streams map[string][]cwEvent // By group/stream; a group without streams has a nil entry.
+	token   int                  // Sequence number of the next expected token.
+	actions []string
+	fail    int // Number of PutLogEvents calls to fail with a throttling error.
+}
+
+func (f *fakeCloudWatch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		f.error(w, 400, "UnrecognizedClientException", "")
+		return
+	}
+	var req struct {
+		LogGroupName  string
+		LogStreamName string
+		SequenceToken string
+		LogEvents     []cwEvent
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "Logs_20140328.")
+	f.actions = append(f.actions, action)
+	key := req.LogGroupName + "/" + req.LogStreamName
+	_, groupExists := f.streams[req.LogGroupName]
+	events, streamExists := f.streams[key]
+	switch action {
+	case "CreateLogGroup":
+		if groupExists {
+			f.error(w, 400, "ResourceAlreadyExistsException", "")
+			return
+		}
+		f.streams[req.LogGroupName] = nil
+	case "CreateLogStream":
+		if !groupExists {
+			f.error(w, 400, "ResourceNotFoundException", "group")
+			return
+		}
+		f.streams[key] = []cwEvent{}
+	case "PutLogEvents":
+		if f.fail > 0 {
+			f.fail--
+			f.error(w, 400, "ThrottlingException", "")
+			return
+		}
+		if !streamExists {
+			f.error(w, 400, "ResourceNotFoundException", "stream")
+			return
+		}
+		if want := f.tokenString(); len(events) > 0 && req.SequenceToken != want {
+			f.error(w, 400, "InvalidSequenceTokenException", want)
+			return
+		}
+		f.streams[key] = append(events, req.LogEvents...)
+		f.token++
+		fmt.Fprintf(w, `{"nextSequenceToken":%q}`, f.tokenString())
+		return
+	}
+	w.Write([]byte("{}"))
+}
+
+func (f *fakeCloudWatch) tokenString() string { return fmt.Sprintf("token-%d", f.token) }
+
+func (f *fakeCloudWatch) error(w http.ResponseWriter, code int, typ, expected string) {
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `{"__type":"com.amazonaws.logs#%s","message":"failed","expectedSequenceToken":%q}`, typ, expected)
+}
+
+func (f *fakeCloudWatch) events(key string) []cwEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.streams[key]
+}
+
+func newTestCloudWatchSink(t *testing.T, f *fakeCloudWatch, cfg CloudWatchConfig) *CloudWatchSink {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	cfg.Endpoint = srv.URL
+	cfg.Region = "eu-west-1"
+	cfg.Credentials = func() (AWSCredentials, error) {
+		return AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+	}
+	cfg.Encoder = TextEncoder
+	cfg.FlushInterval = time.Hour
+	s, err := NewCloudWatchSink(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// waitEvents waits until the stream key holds n events.
+func waitEvents(t *testing.T, f *fakeCloudWatch, key string, n int) []cwEvent {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		if e := f.events(key); len(e) >= n {
+			return e
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("stream %s holds %d events, want %d", key, len(f.events(key)), n)
+	return nil
+}
+
+func TestCloudWatchSink(t *testing.T) {
+	f := &fakeCloudWatch{streams: map[string][]cwEvent{}}
+	s := newTestCloudWatchSink(t, f, CloudWatchConfig{LogGroup: "app", LogStream: "web:1", CreateLogGroup: true})
+	defer s.Close()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.writeRecord(&logRecord{Time: now.Add(time.Second), text: []byte("second\n")})
+	s.writeRecord(&logRecord{Time: now, text: []byte("first\n")})
+	s.Flush()
+	// The stream name is sanitized, and the group and stream created.
+	events := waitEvents(t, f, "app/web_1", 2)
+	if events[0].Message != "first" || events[1].Message != "second" || events[0].Timestamp != now.UnixNano()/1e6 {
+		t.Errorf("events = %+v; want sorted by time, without newlines", events)
+	}
+
+	// A stale sequence token, as after another writer, is replaced by the
+	// expected one.
+	f.mu.Lock()
+	f.token += 5
+	f.mu.Unlock()
+	s.writeRecord(&logRecord{Time: now, text: []byte("third\n")})
+	s.Flush()
+	waitEvents(t, f, "app/web_1", 3)
+
+	// Throttling is retried.
+	f.mu.Lock()
+	f.fail = 2
+	f.mu.Unlock()
+	s.writeRecord(&logRecord{Time: now, text: []byte("fourth\n")})
+	s.Flush()
+	waitEvents(t, f, "app/web_1", 4)
+	if d := s.Dropped(); d != 0 {
+		t.Errorf("Dropped() = %d", d)
+	}
+	f.mu.Lock()
+	want := "PutLogEvents CreateLogStream CreateLogGroup CreateLogStream PutLogEvents PutLogEvents PutLogEvents " +
+		"PutLogEvents PutLogEvents PutLogEvents"
+	if got := strings.Join(f.actions, " "); got != want {
+		t.Errorf("actions = %s, want %s", got, want)
+	}
+	f.mu.Unlock()
+}
+
+func TestCloudWatchSinkDrop(t *testing.T) {
+	var handled error
+	SetErrorHandler(func(err error) { handled = err })
+	defer SetErrorHandler(nil)
+	// Without CreateLogGroup, a missing group makes the batch fail.
+	f := &fakeCloudWatch{streams: map[string][]cwEvent{}}
+	s := newTestCloudWatchSink(t, f, CloudWatchConfig{LogGroup: "app", BufferSize: 2})
+	for i := 0; i < 3; i++ {
+		s.writeRecord(&logRecord{Time: time.Now(), text: []byte("line\n")})
+	}
+	s.Close()
+	if d := s.Dropped(); d != 3 {
+		t.Errorf("Dropped() = %d, want 3: one over the buffer size, two in the failed batch", d)
+	}
+	var werr *WriteError
+	if !errors.As(handled, &werr) || werr.Writer != s {
+		t.Errorf("error handler got %v, want a *WriteError of the sink", handled)
+	}
+	if err := s.writeRecord(&logRecord{}); err != errSinkClosed {
+		t.Errorf("writeRecord after Close = %v", err)
+	}
+}
+
+func TestCloudWatchBatches(t *testing.T) {
+	var events []cwEvent
+	for i := 0; i < cwMaxBatchCount+1; i++ {
+		events = append(events, cwEvent{Timestamp: int64(i), Message: "x"})
+	}
+	big := strings.Repeat("x", cwMaxEventSize-cwEventOverhead)
+	for i := 0; i < 5; i++ {
+		events = append(events, cwEvent{Timestamp: int64(cwMaxBatchCount + 1), Message: big})
+	}
+	day := int64(24 * time.Hour / time.Millisecond)
+	events = append(events, cwEvent{Timestamp: int64(cwMaxBatchCount+1) + day, Message: "tomorrow"})
+	var sizes []int
+	for _, b := range cloudWatchBatches(events) {
+		sizes = append(sizes, len(b))
+	}
+	// By count, then by size (the small event and three maximal ones fill a
+	// batch), then by span.
+	if got, want := fmt.Sprint(sizes), "[10000 4 2 1]"; got != want {
+		t.Errorf("batch sizes = %s, want %s", got, want)
+	}
+}
+
+func TestCloudWatchTruncate(t *testing.T) {
+	f := &fakeCloudWatch{streams: map[string][]cwEvent{}}
+	s := newTestCloudWatchSink(t, f, CloudWatchConfig{LogGroup: "app"})
+	defer s.Close()
+	s.writeRecord(&logRecord{text: []byte("a" + strings.Repeat("é", cwMaxEventSize))})
+	s.mu.Lock()
+	msg := s.queue[0].Message
+	s.mu.Unlock()
+	if len(msg) > cwMaxEventSize-cwEventOverhead || !strings.HasSuffix(msg, "é") {
+		t.Errorf("message of %d bytes ending in %q", len(msg), msg[len(msg)-2:])
+	}
+}
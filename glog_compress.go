@@ -0,0 +1,186 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Compression of rotated log files.
+
+package glog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Codec compresses rotated log files; see RegisterCodec.
+type Codec struct {
+	// Ext is appended to the names of compressed files, such as ".zst".
+	Ext string
+	// NewWriter returns a writer compressing to w. Its Close must finish
+	// the compressed stream but not close w.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		"gzip": {Ext: ".gz", NewWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }},
+	}
+)
+
+// RegisterCodec makes c available under name to SetCompression and the
+// -log_compress flag. "gzip" is built in; others come from compression
+// packages, as in
+//
+//	glog.RegisterCodec("zstd", glog.Codec{Ext: ".zst", NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+//		return zstd.NewWriter(w)
+//	}})
+//	glog.RegisterCodec("lz4", glog.Codec{Ext: ".lz4", NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+//		return lz4.NewWriter(w), nil
+//	}})
+//
+// with zstd from github.com/klauspost/compress/zstd and lz4 from
+// github.com/pierrec/lz4/v4. Register codecs before flags are parsed. A
+// Codec with a nil NewWriter removes the registration.
+func RegisterCodec(name string, c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if c.NewWriter == nil {
+		delete(codecs, name)
+	} else {
+		codecs[name] = c
+	}
+}
+
+// lookupCodec returns the codec registered under name.
+func lookupCodec(name string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[name]
+	return c, ok
+}
+
+// codecNames returns the names of the registered codecs, in order.
+func codecNames() []string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isCompressed reports whether name ends with the extension of a registered
+// codec.
+func isCompressed(name string) bool {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for _, c := range codecs {
+		if c.Ext != "" && strings.HasSuffix(name, c.Ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCompression sets the codec, registered with RegisterCodec, that
+// compresses log files once they are rotated, as by the -log_compress flag.
+// The compressed file, named with the extension of the codec, replaces the
+// closed one, keeping its modification time, and is the oldPath passed to
+// the OnRotate functions; the file following it still names the closed file
+// as the previous one. Compression runs in the background, one file at a
+// time. An empty name, the default, leaves rotated files as they are.
+func SetCompression(name string) error {
+	if name != "" {
+		if _, ok := lookupCodec(name); !ok {
+			return fmt.Errorf("log: unknown codec %q; have %s", name, strings.Join(codecNames(), ", "))
+		}
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.compress = name
+	return nil
+}
+
+// compressFile compresses the file at path with the codec registered under
+// name, replacing it, and returns the path of the compressed file.
+func compressFile(path, name string) (string, error) {
+	c, ok := lookupCodec(name)
+	if !ok {
+		return "", fmt.Errorf("log: unknown codec %q", name)
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+	// A name outside the patterns of log files until it is complete.
+	out, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmp := out.Name()
+	zw, err := c.NewWriter(out)
+	if err == nil {
+		_, err = io.Copy(zw, in)
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	compressed := path + c.Ext
+	if err == nil {
+		err = os.Rename(tmp, compressed)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("log: compressing %s: %v", path, err)
+	}
+	os.Chtimes(compressed, info.ModTime(), info.ModTime())
+	in.Close()
+	os.Remove(path)
+	return compressed, nil
+}
+
+// compressValue is the -log_compress flag.
+type compressValue struct{}
+
+// String is part of the flag.Value interface.
+func (compressValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	return logging.compress
+}
+
+// Set is part of the flag.Value interface.
+func (compressValue) Set(value string) error {
+	return SetCompression(value)
+}
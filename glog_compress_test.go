@@ -0,0 +1,128 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompressFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.INFO.20240501-120000.1")
+	data := strings.Repeat("I0501 12:00:00.000000 1 a.go:1] line\n", 100)
+	os.WriteFile(path, []byte(data), 0644)
+	mtime := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	os.Chtimes(path, mtime, mtime)
+
+	got, err := compressFile(path, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != path+".gz" {
+		t.Errorf("compressed to %s", got)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("%s not removed: %v", path, err)
+	}
+	f, err := os.Open(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if info, _ := f.Stat(); !info.ModTime().Equal(mtime) {
+		t.Errorf("modification time %v, want %v", info.ModTime(), mtime)
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != data {
+		t.Errorf("decompressed %d bytes, want %d", len(b), len(data))
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("%d files left, want only the compressed one", len(entries))
+	}
+}
+
+// upperWriter "compresses" by upper-casing, for tests.
+type upperWriter struct{ w io.Writer }
+
+func (u upperWriter) Write(p []byte) (int, error) { return u.w.Write(bytes.ToUpper(p)) }
+func (u upperWriter) Close() error                { return nil }
+
+func TestCompressRotated(t *testing.T) {
+	RegisterCodec("upper", Codec{Ext: ".up", NewWriter: func(w io.Writer) (io.WriteCloser, error) { return upperWriter{w}, nil }})
+	defer RegisterCodec("upper", Codec{})
+	if err := SetCompression("lz77"); err == nil {
+		t.Error("no error for an unknown codec")
+	}
+	if err := SetCompression("upper"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetCompression("")
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	logDirs = []string{t.TempDir()}
+
+	got := make(chan string, 1)
+	defer OnRotate(func(oldPath, newPath string, sev Severity) { got <- oldPath })()
+	now := time.Now()
+	sb := &syncBuffer{logger: &logging, sev: warningLog}
+	logging.mu.Lock()
+	err := sb.rotateFile(now)
+	first := sb.name
+	if err == nil {
+		err = sb.rotateFile(now.Add(time.Second))
+	}
+	logging.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Close()
+	select {
+	case path := <-got:
+		if path != first+".up" {
+			t.Errorf("OnRotate got %s, want %s.up", path, first)
+		}
+		b, _ := os.ReadFile(path)
+		if !bytes.HasPrefix(b, []byte("LOG FILE CREATED AT")) {
+			t.Errorf("compressed file holds %q", b)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("rotation not reported")
+	}
+
+	// A compressed file is never appended to, even if written last.
+	later := now.Add(time.Minute)
+	os.Chtimes(first+".up", later, later)
+	logging.mu.Lock()
+	sb2 := &syncBuffer{logger: &logging, sev: warningLog}
+	ok, _ := sb2.appendExisting(now.Add(2 * time.Second))
+	logging.mu.Unlock()
+	if ok && sb2.name != sb.name {
+		t.Errorf("appending to %s", sb2.name)
+	}
+	if ok {
+		sb2.Close()
+	}
+}
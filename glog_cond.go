@@ -0,0 +1,45 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Conditional logging.
+
+package glog
+
+// ErrorIf logs to the ERROR, WARNING, and INFO logs if err is not nil, with
+// the message formatted from format and args followed by ": " and err, and
+// returns err. It shortens the common pattern
+//
+//	if err := save(u); err != nil {
+//		glog.Errorf("saving user %s: %v", u.ID, err)
+//		return err
+//	}
+//
+// to
+//
+//	return glog.ErrorIf(save(u), "saving user %s", u.ID)
+func ErrorIf(err error, format string, args ...interface{}) error {
+	if err != nil {
+		logging.printf(errorLog, format+": %v", append(args[:len(args):len(args)], err)...)
+	}
+	return err
+}
+
+// InfoIf logs to the INFO log, like Info, if cond is true.
+func InfoIf(cond bool, args ...interface{}) {
+	if cond {
+		logging.print(infoLog, args...)
+	}
+}
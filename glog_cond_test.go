@@ -0,0 +1,47 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorIf(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	if err := ErrorIf(nil, "saving user %s", "u1"); err != nil || contents(errorLog) != "" {
+		t.Errorf("logged nil error: %v, %q", err, contents(errorLog))
+	}
+	errDisk := errors.New("disk full")
+	if err := ErrorIf(errDisk, "saving user %s", "u1"); err != errDisk {
+		t.Errorf("got %v, want %v", err, errDisk)
+	}
+	if !contains(errorLog, "glog_cond_test.go:31] saving user u1: disk full", t) {
+		t.Errorf("got %q", contents(errorLog))
+	}
+}
+
+func TestInfoIf(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	InfoIf(false, "hidden")
+	InfoIf(true, "shown")
+	if contains(infoLog, "hidden", t) || !contains(infoLog, "glog_cond_test.go:43] shown", t) {
+		t.Errorf("got %q", contents(infoLog))
+	}
+}
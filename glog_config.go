@@ -0,0 +1,562 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Programmatic configuration, as an alternative to command-line flags.
+
+package glog
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Config holds the settings otherwise given by command-line flags. The flag
+// corresponding to each field is noted in its comment.
+type Config struct {
+	LogDir           string                    // -log_dir, directories separated by commas
+	ToStderr         bool                      // -logtostderr
+	ToStdout         bool                      // -logtostdout
+	ContainerMode    bool                      // -log_container
+	AlsoToStderr     bool                      // -alsologtostderr
+	AlsoToStderrV    Level                     // -alsologtostderr_v; negative means none
+	AlsoToLower      bool                      // -alsologtolower
+	PrecreateFiles   bool                      // -log_precreate
+	AppendOnRestart  bool                      // -log_append
+	StderrThreshold  string                    // -stderrthreshold, a severity name such as "ERROR"
+	FileThreshold    string                    // -file_threshold; empty means INFO
+	Verbosity        Level                     // -v
+	TimingLevel      Level                     // -timing_v
+	VModule          string                    // -vmodule
+	VLogger          string                    // -vlogger
+	BacktraceAt      string                    // -log_backtrace_at
+	RotateInterval   string                    // -rotate_interval
+	RotateUTC        bool                      // -log_rotate_utc
+	MaxSize          uint64                    // MaxSize
+	MaxAge           time.Duration             // -log_max_age
+	MaxFiles         int                       // -log_max_files
+	Compression      string                    // -log_compress; empty means none
+	EncryptKeyEnv    string                    // -log_encrypt_key_env; empty means no encryption
+	Rotation         map[string]RotationPolicy // -log_rotation, keyed by severity name
+	Failover         map[string][]string       // -log_failover, keyed by severity name
+	FlushInterval    time.Duration             // -flush_interval
+	BatchLatency     time.Duration             // -log_batch_latency
+	FlushSeverity    string                    // -flush_severity; empty means none
+	FlushSync        bool                      // -flush_severity_sync
+	Sync             SyncPolicy                // -log_sync
+	MaxLogMessageLen int                       // -maxlogmessagelen
+	MaxMessageLen    map[string]int            // -maxlogmessagelen_by_severity, keyed by severity name
+	TruncateBytes    bool                      // -log_truncate_bytes
+	TruncateSuffix   string                    // -log_truncate_suffix
+	TimeZone         string                    // -log_timezone
+	Caller           string                    // -log_caller: "short", "full", "package" or "none"
+	CallerTrim       []string                  // -log_caller_trim
+	CallerFunc       bool                      // -log_caller_func
+	GoroutineID      bool                      // -log_goroutine_id
+	Fingerprint      bool                      // -log_fingerprint
+	RecentLogKB      int                       // -recent_log_kb
+	CrashLines       int                       // -log_crash_lines
+	ExitGrace        time.Duration             // -log_exit_grace
+	FatalStacks      string                    // -log_fatal_stacks: "all", "current" or "none"
+	FatalStacksMax   int                       // -log_fatal_stacks_max
+	CrashFile        bool                      // -log_crash_file
+	BufferPool       BufferPool                // SetBufferPool
+	MaskMaxDepth     int                       // -mask_max_depth
+	MaskMaxElements  int                       // -mask_max_elements
+	MaskMaxString    int                       // -mask_max_string
+	MaskRendered     bool                      // -mask_rendered
+	MaskJSON         bool                      // -mask_json
+	LeakDetection    bool                      // -leak_detection
+	AllowUnmasked    bool                      // SetAllowUnmasked; never set in production
+	Labels           map[string]string         // -log_labels
+}
+
+// DefaultConfig returns the configuration in effect when no flags are given.
+func DefaultConfig() Config {
+	return Config{
+		StderrThreshold:  severityName[errorLog],
+		FileThreshold:    severityName[infoLog],
+		AlsoToLower:      true,
+		AlsoToStderrV:    -1,
+		RotateInterval:   "day",
+		Caller:           "short",
+		ExitGrace:        defaultExitGrace,
+		FatalStacks:      "all",
+		MaxSize:          1024 * 1024 * 1800,
+		FlushInterval:    defaultFlushInterval,
+		Sync:             SyncPolicy{Mode: SyncInterval},
+		MaxLogMessageLen: -1,
+		TruncateSuffix:   defaultTruncateSuffix,
+		MaskMaxDepth:     defaultMaskMaxDepth,
+	}
+}
+
+// Option modifies a Config; see Init.
+type Option func(*Config)
+
+// WithConfig replaces the whole configuration with c.
+func WithConfig(c Config) Option { return func(dst *Config) { *dst = c } }
+
+// WithLogDir sets the directory for log files.
+func WithLogDir(dir string) Option { return func(c *Config) { c.LogDir = dir } }
+
+// WithToStderr sends logs to standard error instead of files.
+func WithToStderr(on bool) Option { return func(c *Config) { c.ToStderr = on } }
+
+// WithToStdout sends logs to standard output instead of files or standard
+// error.
+func WithToStdout(on bool) Option { return func(c *Config) { c.ToStdout = on } }
+
+// WithContainerMode sends every line to standard output as a JSON record and
+// creates no log files, for platforms that collect the output of processes.
+func WithContainerMode(on bool) Option { return func(c *Config) { c.ContainerMode = on } }
+
+// WithAlsoToStderr sends logs to standard error as well as files.
+func WithAlsoToStderr(on bool) Option { return func(c *Config) { c.AlsoToStderr = on } }
+
+// WithAlsoToStderrV sends lines up to V level level to standard error as well
+// as files, whatever their severity; see SetAlsoToStderrV.
+func WithAlsoToStderrV(level Level) Option { return func(c *Config) { c.AlsoToStderrV = level } }
+
+// WithAlsoToLower controls whether lines are also written to the log files of
+// lower severities.
+func WithAlsoToLower(on bool) Option { return func(c *Config) { c.AlsoToLower = on } }
+
+// WithPrecreateFiles controls whether the log files of all severities are
+// created together with the first one; see SetPrecreateFiles.
+func WithPrecreateFiles(on bool) Option { return func(c *Config) { c.PrecreateFiles = on } }
+
+// WithAppendOnRestart controls whether the current log files of an earlier
+// run of the program are appended to; see SetAppendOnRestart.
+func WithAppendOnRestart(on bool) Option { return func(c *Config) { c.AppendOnRestart = on } }
+
+// WithStderrThreshold sets the named severity at or above which logs also go
+// to standard error.
+func WithStderrThreshold(name string) Option { return func(c *Config) { c.StderrThreshold = name } }
+
+// WithFileThreshold sets the named severity below which logs are not written
+// to the log files.
+func WithFileThreshold(name string) Option { return func(c *Config) { c.FileThreshold = name } }
+
+// WithVerbosity sets the V logging level.
+func WithVerbosity(v Level) Option { return func(c *Config) { c.Verbosity = v } }
+
+// WithVModule sets per-file V levels, in the syntax of the -vmodule flag.
+func WithVModule(spec string) Option { return func(c *Config) { c.VModule = spec } }
+
+// WithVLogger sets the V levels of named loggers, in the syntax of the
+// -vlogger flag.
+func WithVLogger(spec string) Option { return func(c *Config) { c.VLogger = spec } }
+
+// WithRotateInterval sets the time-based rotation interval: "month", "day",
+// "hour", "minute" or "second".
+func WithRotateInterval(interval string) Option {
+	return func(c *Config) { c.RotateInterval = interval }
+}
+
+// WithRotateUTC starts rotation intervals at the boundaries of UTC; see
+// SetRotateUTC.
+func WithRotateUTC(on bool) Option { return func(c *Config) { c.RotateUTC = on } }
+
+// WithMaxSize sets the size in bytes at which log files are rotated.
+func WithMaxSize(n uint64) Option { return func(c *Config) { c.MaxSize = n } }
+
+// WithRetention deletes log files older than maxAge, and all but the newest
+// maxFiles files of each severity, when rotating. Zero disables the limit.
+func WithRetention(maxAge time.Duration, maxFiles int) Option {
+	return func(c *Config) { c.MaxAge, c.MaxFiles = maxAge, maxFiles }
+}
+
+// WithCompression compresses rotated log files with the named codec; see
+// SetCompression.
+func WithCompression(codec string) Option { return func(c *Config) { c.Compression = codec } }
+
+// WithEncryptKeyEnv encrypts log files with the key in the named
+// environment variable; see SetFileEncryption.
+func WithEncryptKeyEnv(name string) Option { return func(c *Config) { c.EncryptKeyEnv = name } }
+
+// WithRotationPolicy overrides the rotation and retention of the files of the
+// named severity.
+func WithRotationPolicy(name string, p RotationPolicy) Option {
+	return func(c *Config) {
+		rotation := make(map[string]RotationPolicy, len(c.Rotation)+1)
+		for k, v := range c.Rotation {
+			rotation[k] = v
+		}
+		rotation[name] = p
+		c.Rotation = rotation
+	}
+}
+
+// WithFailover sets the outputs that take the lines of the log file of the
+// named severity when it fails; see SetFailover.
+func WithFailover(name string, outputs ...string) Option {
+	return func(c *Config) {
+		failover := make(map[string][]string, len(c.Failover)+1)
+		for k, v := range c.Failover {
+			failover[k] = v
+		}
+		failover[name] = outputs
+		c.Failover = failover
+	}
+}
+
+// WithTruncation sets how long lines are truncated; see SetTruncation.
+func WithTruncation(bytes bool, suffix string) Option {
+	return func(c *Config) { c.TruncateBytes, c.TruncateSuffix = bytes, suffix }
+}
+
+// WithMaxMessageLen sets the length limit of the lines of the named severity;
+// see SetMaxMessageLen.
+func WithMaxMessageLen(name string, n int) Option {
+	return func(c *Config) {
+		limits := make(map[string]int, len(c.MaxMessageLen)+1)
+		for k, v := range c.MaxMessageLen {
+			limits[k] = v
+		}
+		limits[name] = n
+		c.MaxMessageLen = limits
+	}
+}
+
+// WithFlushSeverity makes lines at or above the named severity flush the log
+// files immediately; see SetFlushSeverity.
+func WithFlushSeverity(name string, sync bool) Option {
+	return func(c *Config) { c.FlushSeverity, c.FlushSync = name, sync }
+}
+
+// WithBatchLatency batches lines for standard output and standard error; see
+// SetBatchLatency.
+func WithBatchLatency(d time.Duration) Option { return func(c *Config) { c.BatchLatency = d } }
+
+// WithBufferPool sets how the buffers in which lines are formatted are
+// recycled; see SetBufferPool.
+func WithBufferPool(p BufferPool) Option { return func(c *Config) { c.BufferPool = p } }
+
+// WithSyncPolicy sets when the log files are synced to disk; see
+// SetSyncPolicy.
+func WithSyncPolicy(p SyncPolicy) Option { return func(c *Config) { c.Sync = p } }
+
+// WithTimeZone sets the time zone for timestamps and rotation boundaries.
+func WithTimeZone(name string) Option { return func(c *Config) { c.TimeZone = name } }
+
+// WithCaller sets how the caller is written in log headers: "short", "full",
+// "package" or "none".
+func WithCaller(mode string) Option { return func(c *Config) { c.Caller = mode } }
+
+// WithCallerTrimPrefixes sets the directories removed from the start of the
+// file paths in log headers; see SetCallerTrimPrefixes.
+func WithCallerTrimPrefixes(prefixes ...string) Option {
+	return func(c *Config) { c.CallerTrim = prefixes }
+}
+
+// WithCallerFunc writes the calling function in log headers.
+func WithCallerFunc(on bool) Option { return func(c *Config) { c.CallerFunc = on } }
+
+// WithGoroutineID writes the goroutine or worker ID in log headers; see
+// SetGoroutineID.
+func WithGoroutineID(on bool) Option { return func(c *Config) { c.GoroutineID = on } }
+
+// WithFingerprint ends ERROR and FATAL lines with a fingerprint field; see
+// SetFingerprint.
+func WithFingerprint(on bool) Option { return func(c *Config) { c.Fingerprint = on } }
+
+// WithLabels sets the static labels written in every line; see
+// SetGlobalLabels.
+func WithLabels(labels map[string]string) Option {
+	return func(c *Config) { c.Labels = labels }
+}
+
+// WithMaskRendered masks Stringer and json.Marshaler values by scrubbing
+// their rendering; see SetMaskRendered.
+func WithMaskRendered(on bool) Option { return func(c *Config) { c.MaskRendered = on } }
+
+// WithMaskLimits limits the number of elements of the maps and slices and
+// the length of the strings within logged values; see SetMaskMaxElements and
+// SetMaskMaxString.
+func WithMaskLimits(elements, stringLen int) Option {
+	return func(c *Config) { c.MaskMaxElements, c.MaskMaxString = elements, stringLen }
+}
+
+// WithMaskJSON writes masked structs, maps and slices as compact JSON; see
+// SetMaskJSON.
+func WithMaskJSON(on bool) Option { return func(c *Config) { c.MaskJSON = on } }
+
+// WithCrashLines writes the last n lines kept in memory, which RecentLogKB
+// must enable, before the goroutine stacks of a FATAL line.
+func WithCrashLines(n int) Option { return func(c *Config) { c.CrashLines = n } }
+
+// WithExitGrace sets how long exiting waits for the logs and sinks to be
+// flushed; see SetExitGracePeriod.
+func WithExitGrace(d time.Duration) Option { return func(c *Config) { c.ExitGrace = d } }
+
+// WithFatalStacks selects the goroutine stacks written after a FATAL line,
+// "all", "current" or "none", and limits them to maxBytes; see
+// SetFatalStacks.
+func WithFatalStacks(dump string, maxBytes int) Option {
+	return func(c *Config) { c.FatalStacks, c.FatalStacksMax = dump, maxBytes }
+}
+
+// WithCrashFile also writes FATAL lines and their stacks to a crash file; see
+// SetCrashFile.
+func WithCrashFile(on bool) Option { return func(c *Config) { c.CrashFile = on } }
+
+// WithLeakDetection scans logged lines for unmasked sensitive values; see
+// SetLeakDetection.
+func WithLeakDetection(on bool) Option { return func(c *Config) { c.LeakDetection = on } }
+
+// Init configures logging without command-line flags. It starts from
+// DefaultConfig, applies opts in order, validates the result and, if it is
+// valid, makes it current; otherwise nothing changes. After Init, logging no
+// longer waits for flag.Parse.
+func Init(opts ...Option) error {
+	c := DefaultConfig()
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c.apply()
+}
+
+// validRotateInterval reports whether s is a known -rotate_interval value.
+func validRotateInterval(s string) bool {
+	switch s {
+	case "month", "day", "hour", "minute", "second":
+		return true
+	}
+	return false
+}
+
+// apply validates c and installs it.
+func (c Config) apply() error {
+	threshold, ok := severityByName(c.StderrThreshold)
+	if !ok {
+		return fmt.Errorf("log: unknown stderr threshold %q", c.StderrThreshold)
+	}
+	fileThreshold := infoLog
+	if c.FileThreshold != "" {
+		if fileThreshold, ok = severityByName(c.FileThreshold); !ok {
+			return fmt.Errorf("log: unknown file threshold %q", c.FileThreshold)
+		}
+	}
+	if c.Verbosity < 0 {
+		return fmt.Errorf("log: negative verbosity %d", c.Verbosity)
+	}
+	filter, err := parseVModule(c.VModule)
+	if err != nil {
+		return fmt.Errorf("log: vmodule: %v", err)
+	}
+	loggerFilter, err := parsePatterns(c.VLogger, true)
+	if err != nil {
+		return fmt.Errorf("log: vlogger: %v", err)
+	}
+	var trace traceLocation
+	if c.BacktraceAt != "" {
+		if err := trace.Set(c.BacktraceAt); err != nil {
+			return fmt.Errorf("log: backtrace_at: %v", err)
+		}
+	}
+	if !validRotateInterval(c.RotateInterval) {
+		return fmt.Errorf("log: unknown rotate interval %q", c.RotateInterval)
+	}
+	if c.MaxSize == 0 {
+		return fmt.Errorf("log: zero max size")
+	}
+	if c.MaxAge < 0 || c.MaxFiles < 0 {
+		return fmt.Errorf("log: negative retention")
+	}
+	if c.Compression != "" {
+		if _, ok := lookupCodec(c.Compression); !ok {
+			return fmt.Errorf("log: unknown codec %q", c.Compression)
+		}
+	}
+	var rotation [numSeverity]RotationPolicy
+	for name, p := range c.Rotation {
+		sev, ok := severityByName(name)
+		if !ok {
+			return fmt.Errorf("log: rotation: unknown severity %q", name)
+		}
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("log: rotation: %s: %v", name, err)
+		}
+		rotation[sev] = p
+	}
+	var chains [numSeverity][]string
+	for name, outputs := range c.Failover {
+		sev, ok := severityByName(name)
+		if !ok {
+			return fmt.Errorf("log: failover: unknown severity %q", name)
+		}
+		for _, o := range outputs {
+			if !validFailoverOutput(o) {
+				return fmt.Errorf("log: failover: %s: unknown output %q", name, o)
+			}
+		}
+		chains[sev] = outputs
+	}
+	var maxLen [numSeverity]int
+	for name, n := range c.MaxMessageLen {
+		sev, ok := severityByName(name)
+		if !ok {
+			return fmt.Errorf("log: message length: unknown severity %q", name)
+		}
+		maxLen[sev] = n
+	}
+	flushSeverity, err := parseFlushSeverity(c.FlushSeverity)
+	if err != nil {
+		return err
+	}
+	syncSeverity, err := c.Sync.validate()
+	if err != nil {
+		return err
+	}
+	loc := time.Local
+	if c.TimeZone != "" {
+		if loc, err = time.LoadLocation(c.TimeZone); err != nil {
+			return fmt.Errorf("log: time zone: %v", err)
+		}
+	}
+	callerMode, ok := parseCallerMode(c.Caller)
+	if !ok {
+		return fmt.Errorf("log: unknown caller mode %q", c.Caller)
+	}
+	if err := c.BufferPool.validate(); err != nil {
+		return err
+	}
+	if c.BatchLatency < 0 {
+		return fmt.Errorf("log: negative batch latency %v", c.BatchLatency)
+	}
+	if c.RecentLogKB < 0 {
+		return fmt.Errorf("log: negative recent log size %d", c.RecentLogKB)
+	}
+	if c.ExitGrace <= 0 {
+		return fmt.Errorf("log: exit grace period %v is not positive", c.ExitGrace)
+	}
+	fatalStacks, ok := parseStackDump(c.FatalStacks)
+	if !ok {
+		return fmt.Errorf("log: unknown stack dump %q", c.FatalStacks)
+	}
+	if c.FatalStacksMax < 0 {
+		return fmt.Errorf("log: negative stack dump limit %d", c.FatalStacksMax)
+	}
+	if c.CrashLines < 0 {
+		return fmt.Errorf("log: negative crash line count %d", c.CrashLines)
+	}
+	if c.CrashLines > 0 && c.RecentLogKB == 0 {
+		return fmt.Errorf("log: crash lines need a recent log size")
+	}
+	if c.MaskMaxDepth < 0 {
+		return fmt.Errorf("log: negative mask depth %d", c.MaskMaxDepth)
+	}
+	if c.MaskMaxElements < 0 {
+		return fmt.Errorf("log: negative element limit %d", c.MaskMaxElements)
+	}
+	if c.MaskMaxString < 0 {
+		return fmt.Errorf("log: negative string limit %d", c.MaskMaxString)
+	}
+	labels, err := newLabelSet(c.Labels)
+	if err != nil {
+		return err
+	}
+
+	SetLocation(loc)
+	setLoggerFilter(loggerFilter)
+	SetCallerMode(callerMode)
+	SetCallerTrimPrefixes(c.CallerTrim)
+	SetCallerFunc(c.CallerFunc)
+	SetGoroutineID(c.GoroutineID)
+	SetFingerprint(c.Fingerprint)
+	globalLabels.Store(labels)
+	SetMaskMaxDepth(c.MaskMaxDepth)
+	SetMaskMaxElements(c.MaskMaxElements)
+	SetMaskMaxString(c.MaskMaxString)
+	SetTimingLevel(c.TimingLevel)
+	SetAlsoToStderrV(c.AlsoToStderrV)
+	SetMaskRendered(c.MaskRendered)
+	SetMaskJSON(c.MaskJSON)
+	SetLeakDetection(c.LeakDetection)
+	SetExitGracePeriod(c.ExitGrace)
+	SetAllowUnmasked(c.AllowUnmasked || unmaskedBuild)
+	logging.freeListMu.Lock()
+	pool := logging.bufPool
+	logging.freeListMu.Unlock()
+	if c.BufferPool != pool {
+		SetBufferPool(c.BufferPool)
+	}
+	if c.RecentLogKB != logging.recentKB {
+		SetRecentLogSize(c.RecentLogKB)
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.needFlagParse = false
+	logging.crashLines = c.CrashLines
+	logging.fatalStacks = fatalStacks
+	logging.stacksMax = c.FatalStacksMax
+	logging.crashFile = c.CrashFile
+	logging.toStderr = c.ToStderr
+	logging.toStdout = c.ToStdout
+	logging.container = c.ContainerMode
+	logging.alsoToStderr = c.AlsoToStderr
+	logging.alsoToLower = c.AlsoToLower
+	logging.precreate = c.PrecreateFiles
+	logging.appendFile = c.AppendOnRestart
+	logging.stderrThreshold.set(threshold)
+	logging.fileThreshold.set(fileThreshold)
+	logging.setVState(c.Verbosity, filter, true)
+	logging.traceLocation = trace
+	var traceActive int32
+	if trace.isSet() {
+		traceActive = 1
+	}
+	atomic.StoreInt32(&logging.traceActive, traceActive)
+	SetFlushInterval(c.FlushInterval)
+	logging.batchLatency = c.BatchLatency
+	if c.BatchLatency <= 0 {
+		logging.flushBatches()
+	}
+	logging.flushSeverity = flushSeverity
+	logging.flushSync = c.FlushSync
+	logging.syncPolicy = c.Sync
+	logging.syncSeverity = syncSeverity
+	logging.maxLogMessageLen = c.MaxLogMessageLen
+	logging.maxLen = maxLen
+	logging.truncateBytes = c.TruncateBytes
+	logging.truncateSuffix = c.TruncateSuffix
+	*LogRotateInterval = c.RotateInterval
+	logging.rotateUTC = c.RotateUTC
+	MaxSize = c.MaxSize
+	logging.maxAge = c.MaxAge
+	logging.maxFiles = c.MaxFiles
+	logging.compress = c.Compression
+	if c.EncryptKeyEnv != logging.encryptEnv {
+		// Keep a key set with SetFileEncryption.
+		logging.setEncryptKeyEnv(c.EncryptKeyEnv)
+	}
+	logging.rotation = rotation
+	for s, outputs := range chains {
+		// A chain in use is kept unless it changes.
+		if strings.Join(outputs, ",") != strings.Join(logging.failover[s].outputs, ",") {
+			logging.failover[s] = failover{outputs: append([]string(nil), outputs...)}
+		}
+	}
+	if c.LogDir != *logDir {
+		*logDir = c.LogDir
+		onceLogDirs.Do(func() {})
+		logDirs = nil
+		createLogDirs()
+	}
+	return nil
+}
@@ -0,0 +1,517 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Loading the configuration from YAML, TOML or JSON files.
+
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// LoadConfig reads logging settings from a file and applies them. The format
+// is chosen by the file extension: .yaml or .yml, .toml, or .json. Settings
+// are named after the command-line flags, per-severity rotation is configured
+// under "rotation.<SEVERITY>", masking rules under "mask.<rule>" and global
+// labels under "labels", for example in YAML:
+//
+//	log_dir: /var/log/myapp
+//	v: 1
+//	vmodule: gopher*=3
+//	stderrthreshold: WARNING
+//	rotate_interval: hour
+//	max_size: 104857600
+//	flush_interval: 5s
+//	log_max_age: 168h
+//	rotation:
+//	  ERROR:
+//	    max_size: 10485760
+//	    max_age: 720h
+//	mask:
+//	  card:
+//	    keep_prefix: 6
+//	    keep_suffix: 4
+//	  pwd:
+//	    redact: true
+//	  identity:
+//	    mode: tokenize
+//	labels:
+//	  service: api
+//	  env: prod
+//
+// or equivalently in TOML, with [rotation.ERROR], [mask.card] and [labels]
+// tables and so on. Rotation settings take the fields of the -log_rotation
+// flag. Mask rules take the fields of ShrinePolicy (mode is "mask", "encrypt"
+// or "tokenize") and, for the rules with an on/off switch, "enabled".
+//
+// Settings missing from the file take their default values. The whole file
+// is validated before anything is applied: unknown settings or bad values
+// leave the current configuration unchanged and are reported as an error.
+// Only the subset of YAML and TOML needed for such files is understood:
+// nested mappings or tables of scalar values.
+func LoadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values, err := parseConfigFile(path, data)
+	if err != nil {
+		return fmt.Errorf("log: %s: %v", path, err)
+	}
+	if err := applyConfigValues(values); err != nil {
+		return fmt.Errorf("log: %s: %v", path, err)
+	}
+	return nil
+}
+
+// parseConfigFile returns the settings in data as a map from dotted names to
+// scalar values.
+func parseConfigFile(path string, data []byte) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return parseYAML(data)
+	case ".toml":
+		return parseTOML(data)
+	case ".json":
+		return parseJSONConfig(data)
+	}
+	return nil, fmt.Errorf("unknown config file format %q", filepath.Ext(path))
+}
+
+// stripComment removes a trailing # comment from line, ignoring # characters
+// inside quoted strings.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquoteScalar interprets a quoted string in YAML or TOML syntax. Unquoted
+// values are returned as they are.
+func unquoteScalar(v string) (string, error) {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		return strconv.Unquote(v)
+	}
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return strings.Replace(v[1:len(v)-1], "''", "'", -1), nil
+	}
+	if strings.HasPrefix(v, "[") || strings.HasPrefix(v, "{") {
+		return "", fmt.Errorf("unsupported value %s: only scalar values are allowed", v)
+	}
+	return v, nil
+}
+
+// setConfigValue records a setting, rejecting duplicates.
+func setConfigValue(values map[string]string, key, value string, n int) error {
+	if _, dup := values[key]; dup {
+		return fmt.Errorf("line %d: duplicate setting %q", n, key)
+	}
+	values[key] = value
+	return nil
+}
+
+// parseYAML parses block mappings of scalars, nested by indentation.
+func parseYAML(data []byte) (map[string]string, error) {
+	type level struct {
+		indent int
+		prefix string
+	}
+	values := make(map[string]string)
+	stack := []level{{-1, ""}}
+	for i, raw := range strings.Split(string(data), "\n") {
+		n := i + 1
+		line := strings.TrimRight(stripComment(strings.TrimRight(raw, "\r")), " \t")
+		text := strings.TrimSpace(line)
+		if text == "" || text == "---" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if strings.Contains(line[:indent], "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed in indentation", n)
+		}
+		if strings.HasPrefix(text, "- ") || text == "-" {
+			return nil, fmt.Errorf("line %d: lists are not supported", n)
+		}
+		colon := strings.Index(text, ":")
+		if colon <= 0 {
+			return nil, fmt.Errorf("line %d: expected key: value", n)
+		}
+		key, err := unquoteScalar(strings.TrimSpace(text[:colon]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		for indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		if parent := stack[len(stack)-1].prefix; parent != "" {
+			key = parent + "." + key
+		}
+		rest := strings.TrimSpace(text[colon+1:])
+		if rest == "" {
+			stack = append(stack, level{indent, key})
+			continue
+		}
+		value, err := unquoteScalar(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if err := setConfigValue(values, key, value, n); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// parseTOML parses tables of key = value pairs with scalar values.
+func parseTOML(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	section := ""
+	for i, raw := range strings.Split(string(data), "\n") {
+		n := i + 1
+		text := strings.TrimSpace(stripComment(strings.TrimRight(raw, "\r")))
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "[[") {
+			return nil, fmt.Errorf("line %d: arrays of tables are not supported", n)
+		}
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") {
+				return nil, fmt.Errorf("line %d: malformed table header", n)
+			}
+			section = strings.TrimSpace(text[1 : len(text)-1])
+			continue
+		}
+		eq := strings.Index(text, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key, err := unquoteScalar(strings.TrimSpace(text[:eq]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		value, err := unquoteScalar(strings.TrimSpace(text[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if err := setConfigValue(values, key, value, n); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// parseJSONConfig flattens a JSON object of scalars and nested objects.
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	var flatten func(prefix string, obj map[string]interface{}) error
+	flatten = func(prefix string, obj map[string]interface{}) error {
+		for k, v := range obj {
+			if prefix != "" {
+				k = prefix + "." + k
+			}
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if err := flatten(k, v); err != nil {
+					return err
+				}
+			case string:
+				values[k] = v
+			case json.Number:
+				values[k] = v.String()
+			case bool:
+				values[k] = strconv.FormatBool(v)
+			default:
+				return fmt.Errorf("unsupported value for %q: only scalar values are allowed", k)
+			}
+		}
+		return nil
+	}
+	return values, flatten("", obj)
+}
+
+// filterSwitches maps the rules that can be turned off to their switches.
+var filterSwitches = map[string]*bool{
+	"card":     &logging.filterCard,
+	"identity": &logging.filterIdentity,
+	"phone":    &logging.filterPhone,
+	"realname": &logging.filterRealName,
+	"email":    &logging.filterEmail,
+	"pwd":      &logging.filterPwd,
+	"company":  &logging.filterCompany,
+}
+
+// applyConfigValues validates settings parsed from a config file and, if they
+// are all valid, applies them.
+func applyConfigValues(values map[string]string) error {
+	c := DefaultConfig()
+	c.Rotation = make(map[string]RotationPolicy)
+	c.Labels = make(map[string]string)
+	policies := make(map[string]ShrinePolicy)
+	enabled := make(map[string]bool)
+	for key, value := range values {
+		var err error
+		switch key {
+		case "log_dir":
+			c.LogDir = value
+		case "logtostderr":
+			c.ToStderr, err = strconv.ParseBool(value)
+		case "logtostdout":
+			c.ToStdout, err = strconv.ParseBool(value)
+		case "log_container":
+			c.ContainerMode, err = strconv.ParseBool(value)
+		case "alsologtostderr":
+			c.AlsoToStderr, err = strconv.ParseBool(value)
+		case "alsologtostderr_v":
+			var n int
+			n, err = strconv.Atoi(value)
+			c.AlsoToStderrV = Level(n)
+		case "alsologtolower":
+			c.AlsoToLower, err = strconv.ParseBool(value)
+		case "log_precreate":
+			c.PrecreateFiles, err = strconv.ParseBool(value)
+		case "log_append":
+			c.AppendOnRestart, err = strconv.ParseBool(value)
+		case "stderrthreshold":
+			c.StderrThreshold = value
+		case "file_threshold":
+			c.FileThreshold = value
+		case "v":
+			var v int
+			v, err = strconv.Atoi(value)
+			c.Verbosity = Level(v)
+		case "timing_v":
+			var v int
+			v, err = strconv.Atoi(value)
+			c.TimingLevel = Level(v)
+		case "vmodule":
+			c.VModule = value
+		case "vlogger":
+			c.VLogger = value
+		case "log_backtrace_at":
+			c.BacktraceAt = value
+		case "rotate_interval":
+			c.RotateInterval = value
+		case "log_rotate_utc":
+			c.RotateUTC, err = strconv.ParseBool(value)
+		case "max_size":
+			c.MaxSize, err = strconv.ParseUint(value, 10, 64)
+		case "log_max_age":
+			c.MaxAge, err = time.ParseDuration(value)
+		case "log_max_files":
+			c.MaxFiles, err = strconv.Atoi(value)
+		case "log_encrypt_key_env":
+			c.EncryptKeyEnv = value
+		case "log_compress":
+			c.Compression = value
+		case "log_rotation":
+			var rotation map[string]RotationPolicy
+			if rotation, err = parseRotation(value); err == nil {
+				for name, p := range rotation {
+					c.Rotation[name] = p
+				}
+			}
+		case "log_failover":
+			c.Failover, err = parseFailover(value)
+		case "flush_interval":
+			c.FlushInterval, err = time.ParseDuration(value)
+		case "log_batch_latency":
+			c.BatchLatency, err = time.ParseDuration(value)
+		case "flush_severity":
+			c.FlushSeverity = value
+		case "flush_severity_sync":
+			c.FlushSync, err = strconv.ParseBool(value)
+		case "log_sync":
+			c.Sync, err = parseSyncPolicy(value)
+		case "maxlogmessagelen":
+			c.MaxLogMessageLen, err = strconv.Atoi(value)
+		case "maxlogmessagelen_by_severity":
+			c.MaxMessageLen, err = parseMaxLen(value)
+		case "log_truncate_bytes":
+			c.TruncateBytes, err = strconv.ParseBool(value)
+		case "log_truncate_suffix":
+			c.TruncateSuffix = value
+		case "log_timezone":
+			c.TimeZone = value
+		case "log_caller":
+			c.Caller = value
+		case "log_caller_trim":
+			c.CallerTrim = splitLogDirs(value)
+		case "log_caller_func":
+			c.CallerFunc, err = strconv.ParseBool(value)
+		case "log_fingerprint":
+			c.Fingerprint, err = strconv.ParseBool(value)
+		case "log_goroutine_id":
+			c.GoroutineID, err = strconv.ParseBool(value)
+		case "recent_log_kb":
+			c.RecentLogKB, err = strconv.Atoi(value)
+		case "log_crash_lines":
+			c.CrashLines, err = strconv.Atoi(value)
+		case "log_exit_grace":
+			c.ExitGrace, err = time.ParseDuration(value)
+		case "log_fatal_stacks":
+			c.FatalStacks = value
+		case "log_fatal_stacks_max":
+			c.FatalStacksMax, err = strconv.Atoi(value)
+		case "log_crash_file":
+			c.CrashFile, err = strconv.ParseBool(value)
+		case "mask_max_depth":
+			c.MaskMaxDepth, err = strconv.Atoi(value)
+		case "mask_max_elements":
+			c.MaskMaxElements, err = strconv.Atoi(value)
+		case "mask_max_string":
+			c.MaskMaxString, err = strconv.Atoi(value)
+		case "mask_rendered":
+			c.MaskRendered, err = strconv.ParseBool(value)
+		case "mask_json":
+			c.MaskJSON, err = strconv.ParseBool(value)
+		case "leak_detection":
+			c.LeakDetection, err = strconv.ParseBool(value)
+		case "allow_unmasked":
+			c.AllowUnmasked, err = strconv.ParseBool(value)
+		default:
+			if strings.HasPrefix(key, "rotation.") {
+				err = setRotationValue(c.Rotation, key, value)
+			} else if strings.HasPrefix(key, "labels.") {
+				c.Labels[strings.TrimPrefix(key, "labels.")] = value
+			} else {
+				err = setMaskValue(policies, enabled, key, value)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	if err := c.apply(); err != nil {
+		return err
+	}
+	for _, rule := range shrineRules {
+		if p, ok := policies[rule]; ok {
+			SetShrinePolicy(rule, p) // validated by setMaskValue
+		} else {
+			ClearShrinePolicy(rule)
+		}
+	}
+	for rule, sw := range filterSwitches {
+		on, ok := enabled[rule]
+		*sw = on || !ok
+	}
+	return nil
+}
+
+// setRotationValue applies a "rotation.<SEVERITY>.<field>" setting.
+func setRotationValue(rotation map[string]RotationPolicy, key, value string) error {
+	parts := strings.Split(key, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("unknown setting")
+	}
+	name := strings.ToUpper(parts[1])
+	if _, ok := severityByName(name); !ok {
+		return fmt.Errorf("unknown severity %q", parts[1])
+	}
+	p := rotation[name]
+	if err := p.set(parts[2], value); err != nil {
+		return err
+	}
+	rotation[name] = p
+	return nil
+}
+
+// setMaskValue applies a "mask.<rule>.<field>" setting to policies or enabled.
+func setMaskValue(policies map[string]ShrinePolicy, enabled map[string]bool, key, value string) error {
+	parts := strings.Split(key, ".")
+	if len(parts) != 3 || parts[0] != "mask" {
+		return fmt.Errorf("unknown setting")
+	}
+	rule, field := parts[1], parts[2]
+	if !isShrineRule(rule) {
+		return fmt.Errorf("unknown shrine rule %q", rule)
+	}
+	var err error
+	p := policies[rule]
+	switch field {
+	case "enabled":
+		if _, ok := filterSwitches[rule]; !ok {
+			return fmt.Errorf("rule %q cannot be disabled", rule)
+		}
+		enabled[rule], err = strconv.ParseBool(value)
+		return err
+	case "mode":
+		switch value {
+		case "mask":
+			p.Mode = ShrineMask
+		case "encrypt":
+			p.Mode = ShrineEncrypt
+		case "tokenize":
+			p.Mode = ShrineTokenize
+		default:
+			return fmt.Errorf("unknown mode %q", value)
+		}
+	case "mask_rune":
+		r, size := utf8.DecodeRuneInString(value)
+		if size == 0 || size != len(value) {
+			return fmt.Errorf("mask_rune must be a single character")
+		}
+		p.MaskRune = r
+	case "keep_prefix":
+		p.KeepPrefix, err = strconv.Atoi(value)
+	case "keep_suffix":
+		p.KeepSuffix, err = strconv.Atoi(value)
+	case "redact":
+		p.Redact, err = strconv.ParseBool(value)
+	default:
+		return fmt.Errorf("unknown setting")
+	}
+	if err != nil {
+		return err
+	}
+	if p.KeepPrefix < 0 || p.KeepSuffix < 0 {
+		return fmt.Errorf("negative keep count")
+	}
+	policies[rule] = p
+	return nil
+}
@@ -0,0 +1,155 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const yamlConfig = `# service logging
+v: 2
+vmodule: "glog_configfile_test=3"   # hot spot
+stderrthreshold: WARNING
+rotate_interval: hour
+mask:
+  card:
+    keep_prefix: 6
+    keep_suffix: 4
+    mask_rune: "#"
+  pwd:
+    redact: true
+  phone:
+    enabled: false
+`
+
+const tomlConfig = `# service logging
+v = 2
+vmodule = "glog_configfile_test=3"   # hot spot
+stderrthreshold = 'WARNING'
+rotate_interval = "hour"
+
+[mask.card]
+keep_prefix = 6
+keep_suffix = 4
+mask_rune = "#"
+
+[mask.pwd]
+redact = true
+
+[mask.phone]
+enabled = false
+`
+
+func TestParseConfigFile(t *testing.T) {
+	want := map[string]string{
+		"v":                     "2",
+		"vmodule":               "glog_configfile_test=3",
+		"stderrthreshold":       "WARNING",
+		"rotate_interval":       "hour",
+		"mask.card.keep_prefix": "6",
+		"mask.card.keep_suffix": "4",
+		"mask.card.mask_rune":   "#",
+		"mask.pwd.redact":       "true",
+		"mask.phone.enabled":    "false",
+	}
+	for _, f := range []struct{ name, data string }{
+		{"glog.yaml", yamlConfig},
+		{"glog.toml", tomlConfig},
+		{"glog.json", `{"v": 2, "vmodule": "glog_configfile_test=3", "stderrthreshold": "WARNING", "rotate_interval": "hour",
+			"mask": {"card": {"keep_prefix": 6, "keep_suffix": 4, "mask_rune": "#"}, "pwd": {"redact": true}, "phone": {"enabled": false}}}`},
+	} {
+		got, err := parseConfigFile(f.name, []byte(f.data))
+		if err != nil {
+			t.Errorf("%s: %v", f.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", f.name, got, want)
+		}
+	}
+	for _, f := range []struct{ name, data string }{
+		{"bad.yaml", "v: 1\nv: 2\n"},
+		{"bad.yaml", "outputs:\n  - stderr\n"},
+		{"bad.toml", "[[sinks]]\n"},
+		{"bad.toml", "levels = [1, 2]\n"},
+		{"bad.ini", "v=1\n"},
+	} {
+		if _, err := parseConfigFile(f.name, []byte(f.data)); err == nil {
+			t.Errorf("%s: %q accepted", f.name, f.data)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func() {
+		logging.mu.Lock()
+		logging.needFlagParse = true
+		logging.setVState(0, nil, true)
+		logging.mu.Unlock()
+		logging.stderrThreshold.set(errorLog)
+		*LogRotateInterval = "day"
+		logging.SetFilter(true, true, true, true, true, true, true)
+		for _, rule := range shrineRules {
+			ClearShrinePolicy(rule)
+		}
+		SetGlobalLabels(nil)
+	}()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "glog.yaml")
+	if err := os.WriteFile(path, []byte(yamlConfig+"labels:\n  service: api\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if !V(3) || V(4) || logging.stderrThreshold.get() != warningLog || *LogRotateInterval != "hour" {
+		t.Error("settings not applied")
+	}
+	if got := ShrineCardNo("6225880137706868"); got != "622588######6868" {
+		t.Errorf("card policy not applied: got %q", got)
+	}
+	if logging.filterPhone || !logging.filterCard {
+		t.Error("filter switches not applied")
+	}
+	if GlobalLabels()["service"] != "api" {
+		t.Errorf("labels not applied: %v", GlobalLabels())
+	}
+
+	bad := filepath.Join(dir, "bad.toml")
+	for _, data := range []string{
+		"v = 1\nflush_interval = \"soon\"\n",
+		"v = 1\nstderrthreshold = \"LOUD\"\n",
+		"v = 1\n[mask.card]\nmode = \"scramble\"\n",
+		"v = 1\n[mask.iban]\nenabled = false\n",
+		"v = 1\noutput = \"stderr\"\n",
+	} {
+		if err := os.WriteFile(bad, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := LoadConfig(bad); err == nil {
+			t.Errorf("%q accepted", data)
+		}
+	}
+	if _, ok := GetShrinePolicy("card"); !ok || logging.verbosity.get() != 2 {
+		t.Error("invalid configuration partially applied")
+	}
+}
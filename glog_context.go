@@ -0,0 +1,52 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Fields carried by contexts.
+
+package glog
+
+import "context"
+
+// contextKey is the key of the fields in a context.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the fields given by kv, in the
+// syntax of WithFields, after those already in ctx. Attach request-scoped
+// fields once, where the request starts, and every function passed the
+// context can log them with FromContext:
+//
+//	ctx = glog.NewContext(ctx, "trace_id", traceID, "user_id", uid)
+//	...
+//	glog.FromContext(ctx).Info("charged")
+func NewContext(ctx context.Context, kv ...interface{}) context.Context {
+	fields, _ := ctx.Value(contextKey{}).([]Field)
+	e := (&Entry{fields: fields}).WithFields(kv...)
+	return context.WithValue(ctx, contextKey{}, e.fields)
+}
+
+// FromContext returns an Entry carrying the fields in ctx, added by
+// NewContext. It carries no fields if ctx has none.
+func FromContext(ctx context.Context) *Entry {
+	fields, _ := ctx.Value(contextKey{}).([]Field)
+	return &Entry{fields: fields}
+}
+
+// WithContext returns an Entry logging as lg and carrying the fields in ctx;
+// see FromContext.
+func (lg *Logger) WithContext(ctx context.Context) *Entry {
+	fields, _ := ctx.Value(contextKey{}).([]Field)
+	return &Entry{name: lg.name, fields: fields}
+}
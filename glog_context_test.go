@@ -0,0 +1,43 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"context"
+	"testing"
+)
+
+// Test that fields attached to a context are logged through it.
+func TestContextFields(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	ctx := NewContext(context.Background(), "trace_id", "t1")
+	inner := NewContext(ctx, Int("user_id", 7))
+
+	FromContext(inner).Info("charged")
+	if !contains(infoLog, "] charged trace_id=t1 user_id=7\n", t) {
+		t.Errorf("got %q", contents(infoLog))
+	}
+	Named("payments").WithContext(ctx).Warning("retry")
+	if !contains(warningLog, "] [payments] retry trace_id=t1\n", t) {
+		t.Errorf("outer context changed by NewContext: %q", contents(warningLog))
+	}
+	FromContext(context.Background()).Error("plain")
+	if !contains(errorLog, "] plain\n", t) {
+		t.Errorf("got %q", contents(errorLog))
+	}
+}
@@ -0,0 +1,201 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Control over the goroutine stacks dumped by FATAL lines.
+
+package glog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// StackDump selects the goroutine stacks written after a FATAL line.
+type StackDump int
+
+const (
+	// StacksAll writes the stacks of all goroutines to the log files, and
+	// that of the failing goroutine to standard error. It is the default.
+	StacksAll StackDump = iota
+	// StacksCurrent writes only the stack of the failing goroutine, which
+	// keeps the dump small in programs with many goroutines.
+	StacksCurrent
+	// StacksNone writes no stacks.
+	StacksNone
+)
+
+var stackDumpName = []string{
+	StacksAll:     "all",
+	StacksCurrent: "current",
+	StacksNone:    "none",
+}
+
+// String returns the name of d as used by the -log_fatal_stacks flag.
+func (d StackDump) String() string {
+	if d >= 0 && int(d) < len(stackDumpName) {
+		return stackDumpName[d]
+	}
+	return fmt.Sprintf("StackDump(%d)", int(d))
+}
+
+// parseStackDump returns the dump with the given -log_fatal_stacks name.
+func parseStackDump(name string) (StackDump, bool) {
+	for d, n := range stackDumpName {
+		if strings.EqualFold(n, name) {
+			return StackDump(d), true
+		}
+	}
+	return 0, false
+}
+
+// SetFatalStacks selects the goroutine stacks written after a FATAL line, and
+// limits the dump written to the log files and standard error to maxBytes,
+// cut at a line boundary; zero means no limit. Exit and its relatives write no
+// stacks whatever the setting.
+func SetFatalStacks(d StackDump, maxBytes int) error {
+	if _, ok := parseStackDump(d.String()); !ok {
+		return fmt.Errorf("log: unknown stack dump %v", d)
+	}
+	if maxBytes < 0 {
+		return fmt.Errorf("log: negative stack dump limit %d", maxBytes)
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.fatalStacks = d
+	logging.stacksMax = maxBytes
+	return nil
+}
+
+// SetCrashFile controls whether FATAL lines that dump stacks also write the
+// line, the recent lines of -log_crash_lines and the whole dump, whatever the
+// limit of SetFatalStacks, to a crash file of their own, named like the log
+// files with the tag CRASH and the time of the crash, as in
+// "prog.host.user.log.CRASH.20060102-150405.1234". It is encrypted like the
+// log files. The file name is reported on standard error.
+func SetCrashFile(on bool) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.crashFile = on
+}
+
+// stackDump returns the stacks to write after a FATAL line, to the log
+// files and to standard error, under the limit of SetFatalStacks, and the
+// whole dump for the crash file.
+// l.mu is held.
+func (l *loggingT) stackDump() (files, stderr, whole []byte) {
+	switch l.fatalStacks {
+	case StacksAll:
+		whole = stacks(true)
+		stderr = stacks(false)
+	case StacksCurrent:
+		whole = stacks(false)
+		stderr = whole
+	}
+	return l.capStacks(whole), l.capStacks(stderr), whole
+}
+
+// capStacks cuts trace to the limit of SetFatalStacks.
+// l.mu is held.
+func (l *loggingT) capStacks(trace []byte) []byte {
+	if l.stacksMax <= 0 || len(trace) <= l.stacksMax {
+		return trace
+	}
+	n := bytes.LastIndexByte(trace[:l.stacksMax], '\n') + 1
+	note := fmt.Sprintf("... %d bytes of stacks omitted\n", len(trace)-n)
+	return append(trace[:n:n], note...)
+}
+
+// writeCrashFile writes data, the FATAL line and what follows it, to a crash
+// file if SetCrashFile asked for one.
+// l.mu is held.
+func (l *loggingT) writeCrashFile(data []byte) {
+	if !l.crashFile {
+		return
+	}
+	f, name, _, err := create("CRASH", l.now(), 0)
+	if err == nil {
+		var w io.Writer = f
+		if l.fileKeys != nil {
+			var id string
+			var key []byte
+			if id, key, err = l.fileKeys.CurrentKey(); err == nil {
+				w, err = newEncryptWriter(f, id, key)
+			}
+		}
+		if err == nil {
+			_, err = w.Write(data)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "log: cannot write crash file: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "log: crash dump written to %s\n", name)
+}
+
+// fatalStacksValue implements flag.Value for the -log_fatal_stacks flag.
+type fatalStacksValue struct{}
+
+// String is part of the flag.Value interface.
+func (fatalStacksValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	return logging.fatalStacks.String()
+}
+
+// Set is part of the flag.Value interface.
+func (fatalStacksValue) Set(value string) error {
+	d, ok := parseStackDump(value)
+	if !ok {
+		return fmt.Errorf("unknown stack dump %q: want all, current or none", value)
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.fatalStacks = d
+	return nil
+}
+
+// stacksMaxValue implements flag.Value for the -log_fatal_stacks_max flag.
+type stacksMaxValue struct{}
+
+// String is part of the flag.Value interface.
+func (stacksMaxValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	return strconv.Itoa(logging.stacksMax)
+}
+
+// Set is part of the flag.Value interface.
+func (stacksMaxValue) Set(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("negative value for log_fatal_stacks_max: %d", n)
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.stacksMax = n
+	return nil
+}
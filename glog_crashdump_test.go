@@ -0,0 +1,87 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCapStacks(t *testing.T) {
+	l := &loggingT{stacksMax: 12}
+	trace := []byte("goroutine 1\nmain.main()\n\tmain.go:3\n")
+	if got := string(l.capStacks(trace)); got != "goroutine 1\n... 23 bytes of stacks omitted\n" {
+		t.Errorf("got %q", got)
+	}
+	l.stacksMax = 0
+	if got := string(l.capStacks(trace)); got != string(trace) {
+		t.Errorf("uncapped: got %q", got)
+	}
+}
+
+// Test that Fatal dumps the selected stacks, and the whole dump to a crash
+// file.
+func TestFatalStacks(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func(previous func(error)) { logExitFunc = previous }(logExitFunc)
+	defer SetExitFunc(nil)
+	defer SetFatalStacks(StacksAll, 0)
+	defer SetCrashFile(false)
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	dir := t.TempDir()
+	logDirs = []string{dir}
+	SetExitFunc(func(int) {})
+
+	if err := SetFatalStacks(StacksCurrent, 64); err != nil {
+		t.Fatal(err)
+	}
+	SetCrashFile(true)
+	stderr := capture(t, &os.Stderr, func() { Fatal("crashed") })
+	out := contents(fatalLog)
+	if strings.Count(out, "goroutine ") != 1 || !strings.Contains(out, "bytes of stacks omitted\n") {
+		t.Errorf("not the capped stack of the current goroutine: %q", out)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.log.CRASH.*"))
+	if len(files) != 1 || !strings.Contains(stderr, "log: crash dump written to "+files[0]) {
+		t.Fatalf("crash files %v, stderr %q", files, stderr)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); !strings.Contains(s, "] crashed\ngoroutine ") || strings.Contains(s, "omitted") {
+		t.Errorf("crash file: %q", s)
+	}
+
+	SetFatalStacks(StacksNone, 0)
+	SetCrashFile(false)
+	capture(t, &os.Stderr, func() { Fatal("crashed again") })
+	if i := strings.Index(contents(fatalLog), "crashed again"); i < 0 || strings.Contains(contents(fatalLog)[i:], "goroutine ") {
+		t.Errorf("stacks written: %q", contents(fatalLog))
+	}
+
+	if err := SetFatalStacks(StackDump(7), 0); err == nil {
+		t.Error("unknown stack dump accepted")
+	}
+	if err := Init(WithFatalStacks("some", 0)); err == nil {
+		t.Error("unknown stack dump name accepted")
+	}
+}
@@ -0,0 +1,119 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Verbosity raised for HTTP requests that carry a signed debug header.
+
+package glog
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DebugHeader is the request header read by DebugHandler.
+const DebugHeader = "X-Debug-Log"
+
+// DebugHandler returns a handler that serves h, raising the V level of the
+// requests that carry a valid DebugHeader, as signed by SignDebugHeader with
+// a key of keys, to the level it names: the context of the request is given
+// that level with WithVLevel, so that VContext and Logger.VContext log the
+// lines of the request up to it. Requests without the header, or with one
+// that is badly signed or expired, are served unchanged.
+//
+//	keys := glog.EnvKeyProvider("DEBUG_LOG_KEY")
+//	http.ListenAndServe(addr, glog.DebugHandler(mux, keys))
+func DebugHandler(h http.Handler, keys KeyProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if v := req.Header.Get(DebugHeader); v != "" {
+			if level, err := verifyDebugHeader(v, keys); err == nil {
+				req = req.WithContext(WithVLevel(req.Context(), level))
+			}
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// SignDebugHeader returns a value of DebugHeader that makes DebugHandler log
+// the request at V level up to level until expires, signed with the current
+// key of keys by HMAC-SHA256. It has the form
+//
+//	4;exp=1767225600;key=ID;sig=SIGNATURE
+//
+// where the key ID is omitted if empty, and must not contain ';'.
+func SignDebugHeader(keys KeyProvider, level Level, expires time.Time) (string, error) {
+	id, key, err := keys.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(id, ";") {
+		return "", fmt.Errorf("log: key ID %q contains ';'", id)
+	}
+	v := fmt.Sprintf("%d;exp=%d", level, expires.Unix())
+	if id != "" {
+		v += ";key=" + id
+	}
+	return v + ";sig=" + debugSignature(key, v), nil
+}
+
+// debugSignature returns the signature of the value v of DebugHeader.
+func debugSignature(key []byte, v string) string {
+	return base64.RawURLEncoding.EncodeToString(hmacSHA256(key, v))
+}
+
+// verifyDebugHeader returns the V level of v, a value of DebugHeader, if it is
+// signed by a key of keys and has not expired.
+func verifyDebugHeader(v string, keys KeyProvider) (Level, error) {
+	i := strings.LastIndex(v, ";sig=")
+	if i < 0 {
+		return 0, fmt.Errorf("log: %s is not signed", DebugHeader)
+	}
+	signed, sig := v[:i], v[i+len(";sig="):]
+	parts := strings.Split(signed, ";")
+	level, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil || level < 0 {
+		return 0, fmt.Errorf("log: bad %s level %q", DebugHeader, parts[0])
+	}
+	var id string
+	var expires int64
+	for _, p := range parts[1:] {
+		switch {
+		case strings.HasPrefix(p, "exp="):
+			if expires, err = strconv.ParseInt(p[len("exp="):], 10, 64); err != nil {
+				return 0, fmt.Errorf("log: bad %s expiry %q", DebugHeader, p)
+			}
+		case strings.HasPrefix(p, "key="):
+			id = p[len("key="):]
+		default:
+			return 0, fmt.Errorf("log: unknown %s parameter %q", DebugHeader, p)
+		}
+	}
+	key, err := keys.GetKey(id)
+	if err != nil {
+		return 0, err
+	}
+	if !hmac.Equal([]byte(sig), []byte(debugSignature(key, signed))) {
+		return 0, fmt.Errorf("log: bad %s signature", DebugHeader)
+	}
+	if expires == 0 || !currentClock().Now().Before(time.Unix(expires, 0)) {
+		return 0, fmt.Errorf("log: %s expired", DebugHeader)
+	}
+	return Level(level), nil
+}
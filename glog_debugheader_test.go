@@ -0,0 +1,72 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugHandler(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	defer SetClock(nil)
+	SetClock(fixedClock{t: now, pending: new([]func())})
+
+	keys := KeyRing{Current: "k2", Keys: map[string][]byte{"k1": []byte("old secret"), "k2": []byte("new secret")}}
+	h := DebugHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		VContext(r.Context(), 3).Info("debug ", r.URL.Path)
+	}), keys)
+	sign := func(keys KeyProvider, level Level, expires time.Time) string {
+		v, err := SignDebugHeader(keys, level, expires)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	valid := sign(keys, 4, now.Add(time.Hour))
+	tests := []struct {
+		path, header string
+		logged       bool
+	}{
+		{"/valid", valid, true},
+		{"/old-key", sign(KeyRing{Current: "k1", Keys: keys.Keys}, 4, now.Add(time.Hour)), true},
+		{"/none", "", false},
+		{"/low", sign(keys, 2, now.Add(time.Hour)), false},
+		{"/expired", sign(keys, 4, now.Add(-time.Second)), false},
+		{"/tampered", "9" + valid[1:], false},
+		{"/unsigned", "4", false},
+		{"/unknown-key", sign(StaticKey("k3", []byte("secret")), 4, now.Add(time.Hour)), false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		if test.header != "" {
+			req.Header.Set(DebugHeader, test.header)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if got := strings.Contains(contents(infoLog), "debug "+test.path+"\n"); got != test.logged {
+			t.Errorf("%s with %q: logged %t, want %t", test.path, test.header, got, test.logged)
+		}
+	}
+	if !strings.HasPrefix(valid, "4;exp=1767326645;key=k2;sig=") {
+		t.Errorf("SignDebugHeader = %q", valid)
+	}
+}
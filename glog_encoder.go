@@ -0,0 +1,206 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Encoders of log lines for writers and sinks.
+
+package glog
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// Encoder turns log lines into the bytes written to a destination, so that
+// destinations can take the same lines in different formats: the log files
+// in text while a TCPSink sends JSON, for instance. r is the structured form
+// of a line and text the line as written to the log files, with its header
+// and trailing newline, or nil for a line written to the destination
+// directly. The result includes any delimiter, such as a trailing newline.
+// Encode must not keep r or text.
+type Encoder interface {
+	Encode(r *Record, text []byte) []byte
+}
+
+// The encoders of the formats of this package.
+var (
+	// TextEncoder writes lines in the format of the log files.
+	TextEncoder Encoder = textEncoder{}
+	// JSONEncoder writes lines as JSON records, one per line; see
+	// AddJSONWriter.
+	JSONEncoder Encoder = jsonEncoder{}
+	// ProtoEncoder writes lines as length-prefixed Record messages of
+	// glog.proto; see AddProtoWriter.
+	ProtoEncoder Encoder = protoEncoder{}
+)
+
+// AddEncodedWriter is like AddWriter, but each line is passed to w as
+// encoded by enc.
+func AddEncodedWriter(name string, enc Encoder, w io.Writer) (remove func()) {
+	return AddWriter(name, encodedWriter{enc, w})
+}
+
+// recordEncoder is implemented by the encoders of this package, which
+// encode records in their internal form.
+type recordEncoder interface {
+	encodeRecord(r *logRecord) []byte
+}
+
+// encode returns r encoded by enc.
+func encode(enc Encoder, r *logRecord) []byte {
+	if re, ok := enc.(recordEncoder); ok {
+		return re.encodeRecord(r)
+	}
+	return enc.Encode(r.export(), r.text)
+}
+
+// export returns r as a Record, with the values of its fields as logged.
+func (r *logRecord) export() *Record {
+	rec := &Record{
+		SchemaVersion: r.SchemaVersion,
+		Time:          r.Time,
+		Severity:      r.Severity,
+		Host:          r.Host,
+		PID:           r.PID,
+		File:          r.File,
+		Line:          r.Line,
+		Logger:        r.Logger,
+		Message:       r.Message,
+		Labels:        r.Labels,
+		Build:         r.Build,
+		Stack:         r.Stack,
+		Truncated:     r.Truncated,
+	}
+	if len(r.Fields) > 0 {
+		rec.Fields = make(map[string]interface{}, len(r.Fields))
+		for _, f := range r.Fields {
+			rec.Fields[f.Key] = f.value()
+		}
+	}
+	return rec
+}
+
+// recordOf returns r in its internal form, with its fields in key order.
+func recordOf(r *Record) *logRecord {
+	rec := &logRecord{
+		SchemaVersion: r.SchemaVersion,
+		Time:          r.Time,
+		Severity:      r.Severity,
+		Host:          r.Host,
+		PID:           r.PID,
+		File:          r.File,
+		Line:          r.Line,
+		Logger:        r.Logger,
+		Message:       r.Message,
+		Labels:        r.Labels,
+		Build:         r.Build,
+		Stack:         r.Stack,
+		Truncated:     r.Truncated,
+	}
+	keys := make([]string, 0, len(r.Fields))
+	for k := range r.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		rec.Fields = append(rec.Fields, Any(k, r.Fields[k]))
+	}
+	return rec
+}
+
+type textEncoder struct{}
+
+func (textEncoder) Encode(r *Record, text []byte) []byte {
+	if text != nil {
+		return append([]byte(nil), text...)
+	}
+	return []byte(r.Text())
+}
+
+func (textEncoder) encodeRecord(r *logRecord) []byte {
+	if r.text != nil {
+		return append([]byte(nil), r.text...)
+	}
+	return []byte(r.export().Text())
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(r *Record, text []byte) []byte {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return recordOf(r).encodeJSON()
+	}
+	return append(b, '\n')
+}
+
+func (jsonEncoder) encodeRecord(r *logRecord) []byte {
+	return r.encodeJSON()
+}
+
+type protoEncoder struct{}
+
+func (protoEncoder) Encode(r *Record, text []byte) []byte {
+	return protoEncoder{}.encodeRecord(recordOf(r))
+}
+
+func (protoEncoder) encodeRecord(r *logRecord) []byte {
+	msg := r.encodeProto()
+	b := make([]byte, 0, binary.MaxVarintLen64+len(msg))
+	b = binary.AppendUvarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
+
+// encodedWriter encodes the records it is passed with enc.
+type encodedWriter struct {
+	enc Encoder
+	w   io.Writer
+}
+
+// Write encodes p, a formatted log line, as the message of a record.
+// writeTees does not call it; it passes records instead.
+func (e encodedWriter) Write(p []byte) (int, error) {
+	rec := &logRecord{SchemaVersion: SchemaVersion, Time: currentClock().Now(), Host: host, PID: pid, Message: string(p), Labels: globalLabels.Load().(*labelSet).labels}
+	if err := e.writeRecord(rec); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (e encodedWriter) writeRecord(r *logRecord) error {
+	_, err := e.w.Write(encode(e.enc, r))
+	return err
+}
+
+// Flush flushes w if it supports it.
+func (e encodedWriter) Flush() error {
+	if f, ok := e.w.(interface {
+		Flush() error
+	}); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// FlushContext calls the FlushContext method of w if it has one.
+func (e encodedWriter) FlushContext(ctx context.Context) error {
+	if f, ok := e.w.(contextFlusher); ok {
+		return f.FlushContext(ctx)
+	}
+	return nil
+}
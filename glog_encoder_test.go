@@ -0,0 +1,87 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// upperEncoder is an Encoder of another package, in effect.
+type upperEncoder struct {
+	records []*Record
+}
+
+func (e *upperEncoder) Encode(r *Record, text []byte) []byte {
+	e.records = append(e.records, r)
+	return bytes.ToUpper(text)
+}
+
+// Test that writers take the same lines in different formats at once.
+func TestAddEncodedWriter(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var text, js, proto, upper bytes.Buffer
+	custom := new(upperEncoder)
+	for _, w := range []struct {
+		enc Encoder
+		buf *bytes.Buffer
+	}{{TextEncoder, &text}, {JSONEncoder, &js}, {ProtoEncoder, &proto}, {custom, &upper}} {
+		defer AddEncodedWriter("INFO", w.enc, w.buf)()
+	}
+
+	WithFields("order", 42).Warning("declined")
+	want := contents(infoLog)
+	if text.String() != want {
+		t.Errorf("text is %q, want %q", text.String(), want)
+	}
+	r, err := ParseRecord(js.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Text() != want {
+		t.Errorf("JSON record is %q, want %q", r.Text(), want)
+	}
+	if r, err := NewProtoReader(&proto).Next(); err != nil || r.Text() != want {
+		t.Errorf("proto record is %+v, %v; want %q", r, err, want)
+	}
+	if upper.String() != strings.ToUpper(want) {
+		t.Errorf("custom encoding is %q", upper.String())
+	}
+	if len(custom.records) != 1 || custom.records[0].Message != "declined" || custom.records[0].Fields["order"] != 42 {
+		t.Errorf("custom encoder got %+v", custom.records)
+	}
+}
+
+// Test that the encoders of the package encode Records from elsewhere.
+func TestEncodeRecord(t *testing.T) {
+	r, err := ParseRecord([]byte(`{"time":"2026-10-16T12:00:00Z","severity":"ERROR","pid":7,"file":"a.go","line":3,"message":"failed","fields":{"b":2,"a":"x"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := r.Text()
+	if got := string(TextEncoder.Encode(r, nil)); got != want {
+		t.Errorf("text: got %q, want %q", got, want)
+	}
+	if back, err := ParseRecord(JSONEncoder.Encode(r, nil)); err != nil || back.Text() != want {
+		t.Errorf("JSON: got %+v, %v", back, err)
+	}
+	if back, err := NewProtoReader(bytes.NewReader(ProtoEncoder.Encode(r, nil))).Next(); err != nil || back.Text() != want {
+		t.Errorf("proto: got %+v, %v", back, err)
+	}
+}
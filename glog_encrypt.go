@@ -0,0 +1,262 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Encryption of log files at rest.
+
+package glog
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SetFileEncryption encrypts the log files opened from now on with AES-GCM
+// under the current key of p, which is asked for each time a file is
+// created, reopened or appended to, so that keys rotate with the files. If p
+// fails, the file is not written rather than written in clear. Encrypted
+// files are read with NewDecryptReader or the glogdecrypt command. A nil p,
+// the default, writes files in clear.
+//
+// Files are written as a series of chunks, each sealed when the log buffer
+// is flushed, so that a crash loses at most the unflushed lines and a
+// truncated chunk at the end of a file does not hide those before it.
+func SetFileEncryption(p KeyProvider) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.fileKeys = p
+	logging.encryptEnv = ""
+}
+
+// The format of encrypted files: a series of segments, each started when the
+// file is opened, of a header followed by chunks.
+//
+//	header: encMagic, key ID length (1 byte), key ID, nonce prefix (8 bytes)
+//	chunk:  ciphertext length (4 bytes, big endian), ciphertext
+//
+// The nonce of a chunk is the prefix of its segment followed by its index in
+// the segment (4 bytes, big endian), and its additional data the header of
+// its segment. The first 4 bytes of encMagic, read as a length, exceed
+// encMaxChunk, which tells headers from chunks.
+const (
+	encMagic    = "GLOGENC\x01"
+	encMaxChunk = 1 << 20 // Bytes of plaintext.
+)
+
+// encryptWriter encrypts what is written to it into w.
+type encryptWriter struct {
+	w      io.Writer
+	keyID  string
+	aead   cipher.AEAD
+	header []byte
+	nonce  [12]byte
+	n      uint32 // Chunks written in the segment.
+}
+
+// newEncryptWriter returns a writer encrypting to w under key, whose ID is
+// keyID.
+func newEncryptWriter(w io.Writer, keyID string, key []byte) (*encryptWriter, error) {
+	if len(keyID) > 255 {
+		return nil, errors.New("log: key ID longer than 255 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("log: key %q: %v", keyID, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, keyID: keyID, aead: aead}, nil
+}
+
+// startSegment prepares the header of a new segment, written with the next
+// chunk.
+func (e *encryptWriter) startSegment() error {
+	if _, err := io.ReadFull(rand.Reader, e.nonce[:8]); err != nil {
+		return err
+	}
+	e.header = append([]byte(encMagic), byte(len(e.keyID)))
+	e.header = append(e.header, e.keyID...)
+	e.header = append(e.header, e.nonce[:8]...)
+	e.n = 0
+	return nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if e.header == nil || e.n == 1<<32-1 {
+			if err := e.startSegment(); err != nil {
+				return written, err
+			}
+		}
+		chunk := p
+		if len(chunk) > encMaxChunk {
+			chunk = chunk[:encMaxChunk]
+		}
+		var out []byte
+		if e.n == 0 {
+			out = append(out, e.header...)
+		}
+		binary.BigEndian.PutUint32(e.nonce[8:], e.n)
+		out = binary.BigEndian.AppendUint32(out, uint32(len(chunk)+e.aead.Overhead()))
+		out = e.aead.Seal(out, e.nonce[:], chunk, e.header)
+		if _, err := e.w.Write(out); err != nil {
+			return written, err
+		}
+		e.n++
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// NewDecryptReader returns a reader of the log lines of r, a log file
+// encrypted as set by SetFileEncryption, with the keys of p, found by the
+// IDs recorded in the file. A chunk cut short, as by a crash while it was
+// written, ends the lines with io.ErrUnexpectedEOF.
+func NewDecryptReader(r io.Reader, p KeyProvider) io.Reader {
+	return &decryptReader{r: r, keys: p}
+}
+
+type decryptReader struct {
+	r      io.Reader
+	keys   KeyProvider
+	aead   cipher.AEAD
+	header []byte
+	nonce  [12]byte
+	n      uint32
+	plain  []byte // Decrypted and not yet read.
+	err    error
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 && d.err == nil {
+		d.err = d.next()
+	}
+	if len(d.plain) > 0 {
+		n := copy(p, d.plain)
+		d.plain = d.plain[n:]
+		return n, nil
+	}
+	return 0, d.err
+}
+
+// next reads a header or a chunk.
+func (d *decryptReader) next() error {
+	var word [4]byte
+	if _, err := io.ReadFull(d.r, word[:]); err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		return io.ErrUnexpectedEOF
+	}
+	if string(word[:]) == encMagic[:4] {
+		return d.readHeader()
+	}
+	if d.aead == nil {
+		return errors.New("log: not an encrypted log file")
+	}
+	size := binary.BigEndian.Uint32(word[:])
+	if size > encMaxChunk+uint32(d.aead.Overhead()) {
+		return fmt.Errorf("log: encrypted chunk of %d bytes", size)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	binary.BigEndian.PutUint32(d.nonce[8:], d.n)
+	plain, err := d.aead.Open(sealed[:0], d.nonce[:], sealed, d.header)
+	if err != nil {
+		return fmt.Errorf("log: encrypted chunk %d: %v", d.n, err)
+	}
+	d.n++
+	d.plain = plain
+	return nil
+}
+
+// readHeader reads the header of a segment, after its first 4 bytes.
+func (d *decryptReader) readHeader() error {
+	rest := make([]byte, len(encMagic)-4+1)
+	if _, err := io.ReadFull(d.r, rest); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if string(rest[:len(rest)-1]) != encMagic[4:] {
+		return errors.New("log: not an encrypted log file, or of an unknown version")
+	}
+	id := make([]byte, int(rest[len(rest)-1])+8)
+	if _, err := io.ReadFull(d.r, id); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	key, err := d.keys.GetKey(string(id[:len(id)-8]))
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	if d.aead, err = cipher.NewGCM(block); err != nil {
+		return err
+	}
+	var header bytes.Buffer
+	header.WriteString(encMagic[:4])
+	header.Write(rest)
+	header.Write(id)
+	d.header = header.Bytes()
+	copy(d.nonce[:8], id[len(id)-8:])
+	d.n = 0
+	return nil
+}
+
+// encryptKeyEnvValue is the -log_encrypt_key_env flag.
+type encryptKeyEnvValue struct{}
+
+// String is part of the flag.Value interface.
+func (encryptKeyEnvValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	return logging.encryptEnv
+}
+
+// Set is part of the flag.Value interface.
+func (encryptKeyEnvValue) Set(value string) error {
+	setEncryptKeyEnv(value)
+	return nil
+}
+
+// setEncryptKeyEnv encrypts log files with the key in the environment
+// variable name, or not at all if name is empty.
+func setEncryptKeyEnv(name string) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.setEncryptKeyEnv(name)
+}
+
+// setEncryptKeyEnv is setEncryptKeyEnv with l.mu held.
+func (l *loggingT) setEncryptKeyEnv(name string) {
+	l.fileKeys = nil
+	if name != "" {
+		l.fileKeys = EnvKeyProvider(name)
+	}
+	l.encryptEnv = name
+}
@@ -0,0 +1,118 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryptRoundTrip(t *testing.T) {
+	ring := KeyRing{Keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 16), "k2": bytes.Repeat([]byte{2}, 32)}}
+	var file bytes.Buffer
+	// Two segments, as when a file is reopened after a key rotation; the
+	// second holds a write of more than one chunk.
+	w1, err := newEncryptWriter(&file, "k1", ring.Keys["k1"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	w1.Write([]byte("first\n"))
+	w1.Write([]byte("second\n"))
+	w2, _ := newEncryptWriter(&file, "k2", ring.Keys["k2"])
+	big := strings.Repeat("x", encMaxChunk+10) + "\n"
+	if n, err := w2.Write([]byte(big)); n != len(big) || err != nil {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if bytes.Contains(file.Bytes(), []byte("first")) {
+		t.Fatal("written in clear")
+	}
+	got, err := io.ReadAll(NewDecryptReader(bytes.NewReader(file.Bytes()), ring))
+	if err != nil || string(got) != "first\nsecond\n"+big {
+		t.Errorf("decrypted %d bytes, %v", len(got), err)
+	}
+
+	// A truncated chunk ends the lines before it.
+	data := file.Bytes()
+	got, err = io.ReadAll(NewDecryptReader(bytes.NewReader(data[:len(data)-1]), ring))
+	if err != io.ErrUnexpectedEOF || !strings.HasPrefix(string(got), "first\nsecond\n") {
+		t.Errorf("truncated: %q, %v", got[:13], err)
+	}
+	// Tampering is detected.
+	data[len(encMagic)+1+2+8+4] ^= 1
+	if _, err := io.ReadAll(NewDecryptReader(bytes.NewReader(data), ring)); err == nil {
+		t.Error("tampered chunk accepted")
+	}
+	if _, err := io.ReadAll(NewDecryptReader(strings.NewReader("Log file created at"), ring)); err == nil {
+		t.Error("clear file accepted")
+	}
+}
+
+func TestEncryptedLogFile(t *testing.T) {
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	logDirs = []string{t.TempDir()}
+	keys := StaticKey("test", bytes.Repeat([]byte{4}, 32))
+	SetFileEncryption(keys)
+	defer SetFileEncryption(nil)
+
+	sb := &syncBuffer{logger: &logging, sev: infoLog}
+	logging.mu.Lock()
+	err := sb.rotateFile(time.Now())
+	if err == nil {
+		sb.Write([]byte("before reopen\n"))
+		err = sb.reopen()
+	}
+	if err == nil {
+		sb.Write([]byte("after reopen\n"))
+		err = sb.Flush()
+	}
+	logging.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb.Close()
+	f, err := os.Open(sb.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := io.ReadAll(NewDecryptReader(f, keys))
+	if err != nil || !strings.HasPrefix(string(got), "Log file created at") || !strings.HasSuffix(string(got), "before reopen\nafter reopen\n") {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	// Without a key, nothing is written in clear.
+	SetFileEncryption(failingKeys{errors.New("KMS down")})
+	sb = &syncBuffer{logger: &logging, sev: infoLog}
+	logging.mu.Lock()
+	err = sb.rotateFile(time.Now().Add(time.Hour))
+	logging.mu.Unlock()
+	if err == nil || err.Error() != "KMS down" {
+		t.Errorf("rotateFile = %v", err)
+	}
+	if sb.file != nil {
+		defer sb.file.Close()
+		if info, _ := sb.file.Stat(); info.Size() != 0 {
+			t.Errorf("%d bytes written without a key", info.Size())
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Entries carrying contextual fields.
+
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field is a key/value pair attached to log lines by WithFields.
+//
+// Fields made by String, Int and the other typed constructors hold their
+// value without storing it in an interface, which saves an allocation per
+// field; their Value is nil. Entry.Fields fills it in.
+type Field struct {
+	Key   string
+	Value interface{}
+
+	kind fieldKind // Where the value is held.
+	num  int64     // The value of int, float and bool fields.
+	str  string    // The value of string fields.
+}
+
+// Entry logs lines carrying a fixed set of fields, written after the message
+// as key=value pairs:
+//
+//	I0102 15:04:05.000000   1234 handler.go:42] charged request_id=abc user_id=7
+//
+// Field values are masked like logged values, with the field key playing the
+// part of a map key: a "mobile" field is masked as a phone number. Entries are
+// immutable and safe for concurrent use; WithFields returns a new Entry.
+//
+// Hooks are passed an Entry describing the line being written, with the
+// exported fields set; see AddHook.
+type Entry struct {
+	name   string
+	fields []Field
+	mask   maskMode // Set by Unmasked and Masked.
+
+	Time     time.Time
+	Severity string // "INFO", "WARNING", "ERROR" or "FATAL".
+	File     string // As selected by the -log_caller flag.
+	Line     int
+	Message  string // Without header, fields or trailing newline.
+}
+
+// WithFields returns an Entry carrying the fields given by kv, which holds
+// alternating keys and values, such as
+//
+//	glog.WithFields("request_id", id, "user_id", uid).Info("charged")
+//
+// A Field, such as one made by String or Int, may also be given in place of
+// a key and its value. Keys that are not
+// strings are formatted with fmt.Sprint, and a key without a value gets the
+// value "(MISSING)".
+func WithFields(kv ...interface{}) *Entry {
+	return (&Entry{}).WithFields(kv...)
+}
+
+// WithFields returns an Entry logging as lg and carrying the fields in kv; see
+// the global WithFields.
+func (lg *Logger) WithFields(kv ...interface{}) *Entry {
+	return (&Entry{name: lg.name}).WithFields(kv...)
+}
+
+// WithFields returns an Entry carrying the fields of e followed by those in
+// kv; see the global WithFields.
+func (e *Entry) WithFields(kv ...interface{}) *Entry {
+	fields := make([]Field, len(e.fields), len(e.fields)+len(kv)/2)
+	copy(fields, e.fields)
+	for i := 0; i < len(kv); i++ {
+		if f, ok := kv[i].(Field); ok {
+			fields = append(fields, f)
+			continue
+		}
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		var value interface{} = "(MISSING)"
+		if i+1 < len(kv) {
+			i++
+			value = kv[i]
+		}
+		fields = append(fields, Field{Key: key, Value: value})
+	}
+	return &Entry{name: e.name, fields: fields, mask: e.mask}
+}
+
+// Fields returns a copy of the fields of e, unmasked, with their Value set.
+func (e *Entry) Fields() []Field {
+	fields := make([]Field, len(e.fields))
+	for i, f := range e.fields {
+		fields[i] = Field{Key: f.Key, Value: f.value()}
+	}
+	return fields
+}
+
+// Name returns the name of the Logger of e, or "" if it has none.
+func (e *Entry) Name() string {
+	return e.name
+}
+
+// SetField sets the value of the field with the given key, adding it if it
+// is missing. It is meant for hooks: it modifies e in place, and the value is
+// not masked.
+func (e *Entry) SetField(key string, value interface{}) {
+	for i := range e.fields {
+		if e.fields[i].Key == key {
+			e.fields[i] = Field{Key: key, Value: value}
+			return
+		}
+	}
+	e.fields = append(e.fields, Field{Key: key, Value: value})
+}
+
+// Info logs to the INFO log, like the global Info.
+func (e *Entry) Info(args ...interface{}) {
+	logging.printEntry(infoLog, e.name, e.fields, e.mask, tprint, "", args)
+}
+
+// Infoln logs to the INFO log, like the global Infoln.
+func (e *Entry) Infoln(args ...interface{}) {
+	logging.printEntry(infoLog, e.name, e.fields, e.mask, tprintln, "", args)
+}
+
+// Infof logs to the INFO log, like the global Infof.
+func (e *Entry) Infof(format string, args ...interface{}) {
+	logging.printEntry(infoLog, e.name, e.fields, e.mask, tprintf, format, args)
+}
+
+// Warning logs to the WARNING and INFO logs, like the global Warning.
+func (e *Entry) Warning(args ...interface{}) {
+	logging.printEntry(warningLog, e.name, e.fields, e.mask, tprint, "", args)
+}
+
+// Warningln logs to the WARNING and INFO logs, like the global Warningln.
+func (e *Entry) Warningln(args ...interface{}) {
+	logging.printEntry(warningLog, e.name, e.fields, e.mask, tprintln, "", args)
+}
+
+// Warningf logs to the WARNING and INFO logs, like the global Warningf.
+func (e *Entry) Warningf(format string, args ...interface{}) {
+	logging.printEntry(warningLog, e.name, e.fields, e.mask, tprintf, format, args)
+}
+
+// Error logs to the ERROR, WARNING, and INFO logs, like the global Error.
+func (e *Entry) Error(args ...interface{}) {
+	logging.printEntry(errorLog, e.name, e.fields, e.mask, tprint, "", args)
+}
+
+// Errorln logs to the ERROR, WARNING, and INFO logs, like the global Errorln.
+func (e *Entry) Errorln(args ...interface{}) {
+	logging.printEntry(errorLog, e.name, e.fields, e.mask, tprintln, "", args)
+}
+
+// Errorf logs to the ERROR, WARNING, and INFO logs, like the global Errorf.
+func (e *Entry) Errorf(format string, args ...interface{}) {
+	logging.printEntry(errorLog, e.name, e.fields, e.mask, tprintf, format, args)
+}
+
+// Fatal logs to the FATAL, ERROR, WARNING, and INFO logs and exits, like the
+// global Fatal.
+func (e *Entry) Fatal(args ...interface{}) {
+	logging.printEntry(fatalLog, e.name, e.fields, e.mask, tprint, "", args)
+}
+
+// Fatalln logs to the FATAL, ERROR, WARNING, and INFO logs and exits, like the
+// global Fatalln.
+func (e *Entry) Fatalln(args ...interface{}) {
+	logging.printEntry(fatalLog, e.name, e.fields, e.mask, tprintln, "", args)
+}
+
+// Fatalf logs to the FATAL, ERROR, WARNING, and INFO logs and exits, like the
+// global Fatalf.
+func (e *Entry) Fatalf(format string, args ...interface{}) {
+	logging.printEntry(fatalLog, e.name, e.fields, e.mask, tprintf, format, args)
+}
+
+// maskFields returns fields with their values masked according to their keys.
+func (l *loggingT) maskFields(fields []Field) []Field {
+	masked := make([]Field, len(fields))
+	for i, f := range fields {
+		switch f.kind {
+		case durationField, bytesField, timeField:
+			// Nothing to mask, and they keep their format.
+			masked[i] = f
+			continue
+		}
+		value := f.value()
+		if _, ok := value.(error); ok {
+			// Errors are written as is, as when they are logged.
+			masked[i] = f
+			continue
+		}
+		m, _ := l.transform(map[string]interface{}{f.Key: value}).(map[string]interface{})
+		v, ok := m[f.Key]
+		if !ok {
+			v = value
+		}
+		masked[i] = Field{Key: f.Key, Value: asMaskedJSON(v)}
+	}
+	return masked
+}
+
+// writeFields appends fields to buf as space-separated key=value pairs,
+// quoting values that would be ambiguous.
+func writeFields(buf *buffer, fields []Field) {
+	for _, f := range fields {
+		buf.WriteByte(' ')
+		buf.WriteString(f.Key)
+		buf.WriteByte('=')
+		var v string
+		switch f.kind {
+		case anyField:
+			v = fmt.Sprint(f.Value)
+		case stringField:
+			v = f.str
+		default:
+			// Numbers and booleans never need quoting.
+			buf.Write(f.appendValue(buf.tmp[:0]))
+			continue
+		}
+		if v == "" || strings.ContainsAny(v, " =\"\n\t") {
+			v = strconv.Quote(v)
+		}
+		buf.WriteString(v)
+	}
+}
+
+// printEntry formats a line for a Logger or Entry: name, if any, is written
+// in brackets after the header, and fields after the message. It must be
+// called directly by the exported methods so that the caller's file and line
+// are found.
+func (l *loggingT) printEntry(s severity, name string, fields []Field, mask maskMode, t printtype, format string, args []interface{}) {
+	l.printEntryV(1, s, vLine{}, name, fields, mask, t, format, args)
+}
+
+// printEntryV is printEntry for a line of V level v, called depth frames
+// below the exported method.
+func (l *loggingT) printEntryV(depth int, s severity, v vLine, name string, fields []Field, mask maskMode, t printtype, format string, args []interface{}) {
+	buf, file, line := l.formatEntry(depth+1, s, v, name, fields, mask, t, format, args)
+	l.output(s, buf, file, line, false)
+}
+
+// formatEntry formats a line of printEntryV, called depth frames below the
+// exported method, and returns it with its source location.
+func (l *loggingT) formatEntry(depth int, s severity, v vLine, name string, fields []Field, mask maskMode, t printtype, format string, args []interface{}) (buf *buffer, file string, line int) {
+	buf, file, line = l.header(s, depth)
+	buf.v = v
+	if len(fields) > 0 {
+		buf.setWorkerID(fields)
+	}
+	if name != "" {
+		buf.WriteByte('[')
+		buf.WriteString(name)
+		buf.WriteString("] ")
+		buf.name = name
+		buf.hdrLen = buf.Len()
+	}
+	unmasked := mask == maskOff && unmaskedAllowed()
+	msgAt := buf.Len()
+	if unmasked {
+		formatPlain(buf, t, format, args)
+	} else {
+		l.formatArgs(buf, t, format, args)
+	}
+	if mask == maskForce {
+		msg := l.scrub(string(buf.Bytes()[msgAt:]))
+		buf.Truncate(msgAt)
+		buf.WriteString(msg)
+	}
+	if len(fields) > 0 {
+		if !unmasked && l.masking() {
+			fields = l.maskFields(fields)
+		}
+		if mask == maskForce {
+			fields = l.scrubFields(fields)
+		}
+		if b := buf.Bytes(); b[len(b)-1] == '\n' {
+			buf.Truncate(len(b) - 1)
+		}
+		buf.fieldsAt = buf.Len()
+		buf.fields = fields
+		writeFields(buf, fields)
+	}
+	if buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	return buf, file, line
+}
+
+// fieldList marshals fields as a JSON object, keeping their order. Errors and
+// values that cannot be marshaled are written as strings.
+type fieldList []Field
+
+// MarshalJSON is part of the json.Marshaler interface.
+func (fl fieldList) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range fl {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.Key)
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(valueJSON(f))
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// valueJSON returns the value of f encoded as JSON. Errors and values that
+// cannot be marshaled are encoded as strings.
+func valueJSON(f Field) []byte {
+	value := f.value()
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+	return v
+}
@@ -0,0 +1,82 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// recordBuffer is a recordWriter collecting records.
+type recordBuffer struct {
+	bytes.Buffer
+	records []*logRecord
+}
+
+func (rb *recordBuffer) writeRecord(r *logRecord) error {
+	rb.records = append(rb.records, r)
+	return nil
+}
+
+// Test that entries write their fields, masked, after the message.
+func TestWithFields(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var rb recordBuffer
+	remove := AddWriter("INFO", &rb)
+	defer remove()
+
+	base := WithFields("request_id", "abc")
+	e := base.WithFields("mobile", "13812345678", "note", "two words", 7)
+	e.Infoln("charged")
+	base.Info("done")
+
+	line := contents(infoLog)
+	want := "] charged request_id=abc mobile=138****5678 note=\"two words\" 7=(MISSING)\n"
+	if !strings.Contains(line, want) {
+		t.Errorf("got %q, want %q", line, want)
+	}
+	if !strings.HasSuffix(line, "] done request_id=abc\n") {
+		t.Errorf("parent entry changed by WithFields: %q", line)
+	}
+	if len(rb.records) != 2 {
+		t.Fatalf("got %d records", len(rb.records))
+	}
+	r := rb.records[0]
+	if r.Message != "charged" || len(r.Fields) != 4 || r.Fields[1].Value != "138****5678" {
+		t.Errorf("bad record: %+v", r)
+	}
+	b, err := json.Marshal(r.Fields)
+	if err != nil || !strings.HasPrefix(string(b), `{"request_id":"abc","mobile":"138****5678",`) {
+		t.Errorf("bad JSON fields: %s, %v", b, err)
+	}
+	if e.Fields()[1].Value != "13812345678" {
+		t.Error("Fields not unmasked")
+	}
+}
+
+// Test that entries of a named logger carry its name.
+func TestLoggerWithFields(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	Named("payments").WithFields("user_id", 7).Errorf("declined %s", "card")
+	if !contains(errorLog, "] [payments] declined card user_id=7\n", t) {
+		t.Errorf("got %q", contents(errorLog))
+	}
+}
@@ -0,0 +1,70 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Reporting of failures to write log output.
+
+package glog
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	errorHandlerMu sync.Mutex
+	errorHandler   func(error)
+)
+
+// SetErrorHandler arranges for f to be called with each failure to write log
+// output: creating, rotating, writing, flushing or syncing a log file, or
+// writing to a writer added with AddWriter, whose failures are reported as a
+// *WriteError. By default a failure with a log file terminates the program
+// and a failing writer is reported once on standard error; with a handler
+// set, the program keeps running and f decides what to do, such as raising
+// an alarm or calling os.Exit. A nil f restores the default. f is called
+// with logging blocked, so it must not log through this package.
+func SetErrorHandler(f func(error)) {
+	errorHandlerMu.Lock()
+	defer errorHandlerMu.Unlock()
+	errorHandler = f
+}
+
+// handleError passes err to the SetErrorHandler function and reports whether
+// there is one.
+func handleError(err error) bool {
+	errorHandlerMu.Lock()
+	f := errorHandler
+	errorHandlerMu.Unlock()
+	if f == nil {
+		return false
+	}
+	f(err)
+	return true
+}
+
+// WriteError is passed to the SetErrorHandler function when a writer added
+// with AddWriter fails.
+type WriteError struct {
+	Writer interface{} // The writer, as passed to AddWriter.
+	Err    error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("log: write to %T failed: %v", e.Writer, e.Err)
+}
+
+// Unwrap returns the error of the writer.
+func (e *WriteError) Unwrap() error { return e.Err }
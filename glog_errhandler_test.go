@@ -0,0 +1,64 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that failures with log files and writers go to the error handler and
+// do not terminate the program.
+func TestSetErrorHandler(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.swap([numSeverity]Sink{}))
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	dir := filepath.Join(t.TempDir(), "later")
+	logDirs = []string{dir}
+	var errs []error
+	SetErrorHandler(func(err error) { errs = append(errs, err) })
+	defer SetErrorHandler(nil)
+
+	capture(t, &os.Stderr, func() { Info("lost") })
+	if len(errs) != 1 {
+		t.Fatalf("handler called with %v, want the error creating the file", errs)
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	Info("found")
+	sb, ok := logging.file[infoLog].(*syncBuffer)
+	if !ok {
+		t.Fatal("log file not created once the directory exists")
+	}
+	defer sb.file.Close()
+	if len(errs) != 1 {
+		t.Errorf("handler called with %v after recovery", errs)
+	}
+
+	remove := AddWriter("INFO", errWriter{})
+	defer remove()
+	Info("x")
+	Info("y")
+	var werr *WriteError
+	if len(errs) != 3 || !errors.As(errs[2], &werr) || werr.Writer != (errWriter{}) {
+		t.Errorf("handler called with %v, want two writer errors", errs)
+	}
+}
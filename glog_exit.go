@@ -0,0 +1,143 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Flushing before the program exits.
+
+package glog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultExitGrace bounds the flush done before exiting, unless changed by
+// SetExitGracePeriod.
+const defaultExitGrace = 10 * time.Second
+
+// exitGrace is the grace period of SetExitGracePeriod, in nanoseconds; zero
+// means defaultExitGrace. Accessed atomically.
+var exitGrace int64
+
+// fatalMu is held by the goroutine that is exiting on a FATAL line.
+var fatalMu sync.Mutex
+
+// contextFlusher is implemented by writers, such as TCPSink, that can wait
+// until what they hold is sent.
+type contextFlusher interface {
+	FlushContext(ctx context.Context) error
+}
+
+// SetExitGracePeriod sets how long Fatal, Exit, FlushAndExit and ExitFunc
+// wait, before the process exits, for the log files to be flushed and for
+// the writers added with AddWriter that have a FlushContext method, such as
+// TCPSink and CloudWatchSink, to send what they hold. The default is 10s.
+func SetExitGracePeriod(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("log: exit grace period %v is not positive", d)
+	}
+	atomic.StoreInt64(&exitGrace, int64(d))
+	return nil
+}
+
+// exitGracePeriod returns the grace period of SetExitGracePeriod.
+func exitGracePeriod() time.Duration {
+	if d := atomic.LoadInt64(&exitGrace); d > 0 {
+		return time.Duration(d)
+	}
+	return defaultExitGrace
+}
+
+// exitFlush flushes the logs and waits for the writers that support it to
+// send what they hold, returning when that completes or after the grace
+// period, whichever happens first. The limit is needed because the hooks
+// invoked by Flush may deadlock when glog.Fatal is called from a hook that
+// holds a lock.
+func exitFlush() {
+	grace := exitGracePeriod()
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := logging.flushContext(ctx); errors.Is(err, context.DeadlineExceeded) {
+		fmt.Fprintln(os.Stderr, "glog: Flush took longer than", grace)
+	}
+}
+
+// contextFlushers returns the writers added with AddWriter that have a
+// FlushContext method.
+func (l *loggingT) contextFlushers() []contextFlusher {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var fs []contextFlusher
+	for _, t := range l.tees {
+		if f, ok := t.w.(contextFlusher); ok {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+// FlushAndExit flushes the logs and terminates the process with the given
+// status code, using os.Exit or the function installed by SetExitFunc. Calling
+// os.Exit directly loses the lines logged since the last periodic flush, up
+// to -flush_interval ago; use FlushAndExit instead:
+//
+//	if err := run(); err != nil {
+//		glog.Error(err)
+//		glog.FlushAndExit(1)
+//	}
+//
+// As for Fatal, the flush is abandoned after the grace period of
+// SetExitGracePeriod.
+func FlushAndExit(code int) {
+	exitFlush()
+	logging.mu.Lock()
+	exit := osExit
+	logging.mu.Unlock()
+	exit(code)
+}
+
+// ExitFunc returns a function that flushes the logs, like FlushAndExit, and
+// then calls exit. It suits libraries that let the program replace the
+// function they exit with, such as
+//
+//	cli.OsExiter = glog.ExitFunc(os.Exit)
+func ExitFunc(exit func(code int)) func(code int) {
+	return func(code int) {
+		exitFlush()
+		exit(code)
+	}
+}
+
+// exitGraceValue implements flag.Value for the -log_exit_grace flag.
+type exitGraceValue struct{}
+
+// String is part of the flag.Value interface.
+func (exitGraceValue) String() string {
+	return exitGracePeriod().String()
+}
+
+// Set is part of the flag.Value interface.
+func (exitGraceValue) Set(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	return SetExitGracePeriod(d)
+}
@@ -0,0 +1,140 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFlushAndExit(t *testing.T) {
+	setFlags()
+	file := new(countingBuffer)
+	defer logging.swap(logging.swap([numSeverity]Sink{file, new(flushBuffer), new(flushBuffer), new(flushBuffer)}))
+	var codes []int
+	exit := func(code int) {
+		if file.flushes == 0 {
+			t.Error("exited before flushing")
+		}
+		codes = append(codes, code)
+	}
+	defer SetExitFunc(nil)
+	SetExitFunc(exit)
+
+	FlushAndExit(3)
+	file.flushes = 0
+	ExitFunc(exit)(4)
+	if len(codes) != 2 || codes[0] != 3 || codes[1] != 4 {
+		t.Errorf("got exit codes %v, want [3 4]", codes)
+	}
+}
+
+func TestExitGracePeriod(t *testing.T) {
+	defer SetExitGracePeriod(exitGracePeriod())
+	if err := SetExitGracePeriod(0); err == nil {
+		t.Error("SetExitGracePeriod(0) succeeded")
+	}
+	if err := SetExitGracePeriod(50 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if d := exitGracePeriod(); d != 50*time.Millisecond {
+		t.Errorf("exitGracePeriod() = %v, want 50ms", d)
+	}
+
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	stuck := stuckFlusher(make(chan struct{}))
+	defer AddWriter("INFO", stuck)()
+	start := time.Now()
+	capture(t, &os.Stderr, exitFlush)
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("exitFlush took %v with a 50ms grace period", d)
+	}
+	select {
+	case <-stuck:
+	default:
+		t.Error("FlushContext was not called")
+	}
+}
+
+// stuckFlusher is a writer whose FlushContext never finishes before the
+// deadline; it closes the channel when called.
+type stuckFlusher chan struct{}
+
+func (stuckFlusher) Write(p []byte) (int, error) { return len(p), nil }
+
+func (s stuckFlusher) FlushContext(ctx context.Context) error {
+	close(s)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// Test that a FATAL line logged while another is exiting waits for the
+// first one instead of racing it to the exit.
+func TestDoubleFatal(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	release := make(chan struct{})
+	exited := make(chan string, 2)
+	defer SetExitFunc(nil)
+	SetExitFunc(func(int) {
+		exited <- "exit"
+		<-release
+	})
+	go Fatal("first")
+	if <-exited != "exit" {
+		t.Fatal("first FATAL did not exit")
+	}
+	second := make(chan struct{})
+	go func() {
+		Fatal("second")
+		close(second)
+	}()
+	select {
+	case <-exited:
+		t.Fatal("second FATAL exited while the first was still exiting")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-exited
+	<-second
+}
+
+// Test that the buffer of a FATAL line returns to the pool when the exit
+// function returns, as stubs do.
+func TestFatalReleasesBuffer(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func(f func(error)) { logExitFunc = f }(logExitFunc)
+	defer SetExitFunc(nil)
+	SetExitFunc(func(int) {})
+	freeCount := func() int {
+		logging.freeListMu.Lock()
+		defer logging.freeListMu.Unlock()
+		return logging.freeCount
+	}
+	Info("fill the pool")
+	for _, f := range []func(...interface{}){Fatal, Exit} {
+		before := freeCount()
+		capture(t, &os.Stderr, func() { f("exiting") })
+		if after := freeCount(); after != before {
+			t.Errorf("%d buffers in the pool after exiting, want %d", after, before)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Failover of log files to other outputs.
+
+package glog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Fallback outputs of SetFailover, in addition to OutputStderr.
+const (
+	OutputStdout  = "stdout"  // Standard output.
+	OutputDiscard = "discard" // Nowhere, like /dev/null.
+)
+
+// failover is the fallback chain of the log file of one severity.
+type failover struct {
+	outputs []string // OutputStderr, OutputStdout or OutputDiscard, in order.
+	at      int      // Number of failed outputs, counting the file; 0 while the file works.
+}
+
+// SetFailover sets the outputs, OutputStderr, OutputStdout or OutputDiscard,
+// tried in order when the log file of the named severity cannot be created
+// or written:
+//
+//	glog.SetFailover("INFO", glog.OutputStderr, glog.OutputDiscard)
+//
+// When the file, or an output of the chain, fails, its lines go to the next
+// output, and the switch is reported once, to the SetErrorHandler function
+// or on standard error, instead of the program exiting. A line already
+// written to an output is not written to it again. The file is not used
+// again until SetFailover is called once more; no outputs removes the chain
+// and restores the default behavior.
+//
+// Valid names are "INFO", "WARNING", "ERROR", and "FATAL".
+func SetFailover(name string, outputs ...string) error {
+	sev, ok := severityByName(name)
+	if !ok {
+		return fmt.Errorf("log: unknown severity %q", name)
+	}
+	for _, o := range outputs {
+		if !validFailoverOutput(o) {
+			return fmt.Errorf("log: unknown failover output %q", o)
+		}
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.failover[sev] = failover{outputs: append([]string(nil), outputs...)}
+	return nil
+}
+
+// validFailoverOutput reports whether o names a fallback output.
+func validFailoverOutput(o string) bool {
+	switch o {
+	case OutputStderr, OutputStdout, OutputDiscard:
+		return true
+	}
+	return false
+}
+
+// failOver switches the log file of severity s, or the output of its chain
+// in use, to the next output after err, and reports whether there is one,
+// or the lines are already going elsewhere.
+// l.mu is held.
+func (l *loggingT) failOver(s severity, err error) bool {
+	fo := &l.failover[s]
+	if fo.at >= len(fo.outputs) {
+		// No chain, or the last output is in use.
+		return len(fo.outputs) > 0
+	}
+	from := "file"
+	if fo.at > 0 {
+		from = fo.outputs[fo.at-1]
+	}
+	fo.at++
+	notice := fmt.Errorf("log: %s log failed over from %s to %s: %v", severityName[s], from, fo.outputs[fo.at-1], err)
+	if !handleError(notice) {
+		fmt.Fprintf(os.Stderr, "%v\n", notice)
+	}
+	return true
+}
+
+// fileError handles err, a failure to create or write the log file of
+// severity s, by failing over or, if there is no chain, exiting.
+// l.mu is held.
+func (l *loggingT) fileError(s severity, err error) {
+	if !l.failOver(s, err) {
+		l.exit(err)
+	}
+}
+
+// failedOver reports whether the log file of severity s has failed over.
+// l.mu is held.
+func (l *loggingT) failedOver(s severity) bool {
+	return l.failover[s].at > 0
+}
+
+// outputSet is a set of fallback outputs, by their bits.
+type outputSet uint8
+
+// outputBit returns the bit of the fallback output o in an outputSet.
+func outputBit(o string) outputSet {
+	switch o {
+	case OutputStderr:
+		return 1
+	case OutputStdout:
+		return 2
+	}
+	return 4
+}
+
+// writeFailover writes data, a line for the log file of severity s, to the
+// output of its chain in use, unless written holds it already, and adds the
+// output to written. If the last output fails, the line is dropped.
+// l.mu is held.
+func (l *loggingT) writeFailover(s severity, data []byte, written *outputSet) {
+	fo := &l.failover[s]
+	for {
+		out := fo.outputs[fo.at-1]
+		if *written&outputBit(out) != 0 {
+			return
+		}
+		var err error
+		switch out {
+		case OutputStderr:
+			_, err = os.Stderr.Write(data)
+		case OutputStdout:
+			_, err = os.Stdout.Write(data)
+		}
+		if err == nil {
+			*written |= outputBit(out)
+			return
+		}
+		if fo.at == len(fo.outputs) {
+			return
+		}
+		l.failOver(s, err)
+	}
+}
+
+// parseFailover parses the value of the -log_failover flag: a
+// semicolon-separated list of SEVERITY:output,..., for instance
+// "INFO:stderr,discard;ERROR:stderr".
+func parseFailover(value string) (map[string][]string, error) {
+	chains := make(map[string][]string)
+	for _, spec := range strings.Split(value, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		colon := strings.Index(spec, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("syntax error in %q: expect SEVERITY:output,...", spec)
+		}
+		name := strings.ToUpper(spec[:colon])
+		if _, ok := severityByName(name); !ok {
+			return nil, fmt.Errorf("unknown severity %q", name)
+		}
+		var outputs []string
+		for _, o := range strings.Split(spec[colon+1:], ",") {
+			o = strings.TrimSpace(o)
+			if !validFailoverOutput(o) {
+				return nil, fmt.Errorf("%s: unknown failover output %q", name, o)
+			}
+			outputs = append(outputs, o)
+		}
+		chains[name] = outputs
+	}
+	return chains, nil
+}
+
+// failoverValue implements flag.Value for the -log_failover flag.
+type failoverValue struct{}
+
+// String is part of the flag.Value interface.
+func (failoverValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	var specs []string
+	for s, fo := range logging.failover {
+		if len(fo.outputs) > 0 {
+			specs = append(specs, severityName[s]+":"+strings.Join(fo.outputs, ","))
+		}
+	}
+	return strings.Join(specs, ";")
+}
+
+// Set is part of the flag.Value interface.
+func (failoverValue) Set(value string) error {
+	chains, err := parseFailover(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	for s := range logging.failover {
+		logging.failover[s] = failover{outputs: chains[severityName[s]]}
+	}
+	return nil
+}
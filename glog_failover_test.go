@@ -0,0 +1,76 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that failing log files fail over along their chains, with one notice
+// per switch, and that a line reaches each fallback output once.
+func TestFailover(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.swap([numSeverity]Sink{}))
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	logDirs = []string{filepath.Join(t.TempDir(), "missing")}
+	var notices []string
+	SetErrorHandler(func(err error) { notices = append(notices, err.Error()) })
+	defer SetErrorHandler(nil)
+	const spec = "INFO:stdout,discard;WARNING:discard;ERROR:stderr"
+	if err := (failoverValue{}).Set(spec); err != nil {
+		t.Fatal(err)
+	}
+	defer failoverValue{}.Set("")
+	if got := (failoverValue{}).String(); got != spec {
+		t.Errorf("-log_failover is %q, want %q", got, spec)
+	}
+
+	out := capture(t, &os.Stdout, func() { Info("info-line") })
+	if strings.Count(out, "info-line") != 1 {
+		t.Errorf("standard output is %q, want the INFO line", out)
+	}
+	if len(notices) != 1 || !strings.Contains(notices[0], "INFO log failed over from file to stdout") {
+		t.Errorf("notices %q, want one for INFO", notices)
+	}
+
+	var errOut string
+	out = capture(t, &os.Stdout, func() {
+		errOut = capture(t, &os.Stderr, func() { Error("error-line") })
+	})
+	if strings.Count(errOut, "error-line") != 1 {
+		t.Errorf("standard error is %q, want the ERROR line once", errOut)
+	}
+	if strings.Count(out, "error-line") != 1 {
+		t.Errorf("standard output is %q, want the ERROR line for the INFO log", out)
+	}
+	if len(notices) != 3 {
+		t.Errorf("notices %q, want one more each for ERROR and WARNING", notices)
+	}
+
+	for _, bad := range []string{"INFO", "LOUD:stderr", "INFO:file"} {
+		if _, err := parseFailover(bad); err == nil {
+			t.Errorf("parseFailover(%q) succeeded", bad)
+		}
+	}
+	if err := SetFailover("INFO", "nowhere"); err == nil {
+		t.Error("SetFailover accepted an unknown output")
+	}
+}
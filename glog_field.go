@@ -0,0 +1,194 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Typed fields and logging with fields.
+
+package glog
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// fieldKind tells where the value of a Field is held.
+type fieldKind uint8
+
+const (
+	anyField      fieldKind = iota // In Value.
+	stringField                    // In str.
+	intField                       // In num.
+	floatField                     // In num, as math.Float64bits.
+	boolField                      // In num, 0 or 1.
+	durationField                  // In num, in nanoseconds.
+	bytesField                     // In num.
+	timeField                      // In Value, a time.Time.
+)
+
+// String returns a Field holding a string.
+func String(key, value string) Field {
+	return Field{Key: key, kind: stringField, str: value}
+}
+
+// Int returns a Field holding an int.
+func Int(key string, value int) Field {
+	return Field{Key: key, kind: intField, num: int64(value)}
+}
+
+// Int64 returns a Field holding an int64.
+func Int64(key string, value int64) Field {
+	return Field{Key: key, kind: intField, num: value}
+}
+
+// Float64 returns a Field holding a float64.
+func Float64(key string, value float64) Field {
+	return Field{Key: key, kind: floatField, num: int64(math.Float64bits(value))}
+}
+
+// Bool returns a Field holding a bool.
+func Bool(key string, value bool) Field {
+	f := Field{Key: key, kind: boolField}
+	if value {
+		f.num = 1
+	}
+	return f
+}
+
+// Duration returns a Field holding a duration, written as by
+// time.Duration.String, such as 1.2s, and as a number of nanoseconds in JSON.
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, kind: durationField, num: int64(value)}
+}
+
+// Bytes returns a Field holding a size in bytes, written in binary units with
+// one decimal, such as 3.4MiB, and as a number of bytes in JSON.
+func Bytes(key string, n int64) Field {
+	return Field{Key: key, kind: bytesField, num: n}
+}
+
+// Time returns a Field holding a time, written in RFC 3339 format, such as
+// 2026-10-16T12:00:00+08:00, and with its nanoseconds in JSON.
+func Time(key string, t time.Time) Field {
+	return Field{Key: key, kind: timeField, Value: t}
+}
+
+// Err returns a Field holding err, with the key "error".
+func Err(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+// Any returns a Field holding a value of any type; it is the same as
+// Field{Key: key, Value: value}.
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// value returns the value of f as an interface.
+func (f Field) value() interface{} {
+	switch f.kind {
+	case stringField:
+		return f.str
+	case intField:
+		return f.num
+	case floatField:
+		return math.Float64frombits(uint64(f.num))
+	case boolField:
+		return f.num != 0
+	case durationField:
+		return time.Duration(f.num)
+	case bytesField:
+		return f.num
+	}
+	return f.Value
+}
+
+// appendValue appends the value of f, a number or bool field, to b as
+// fmt.Sprint would format it, or that of a duration, size or time field as
+// described by Duration, Bytes and Time.
+func (f Field) appendValue(b []byte) []byte {
+	switch f.kind {
+	case durationField:
+		return append(b, time.Duration(f.num).String()...)
+	case bytesField:
+		return appendBytes(b, f.num)
+	case timeField:
+		t, _ := f.Value.(time.Time)
+		return t.AppendFormat(b, time.RFC3339)
+	case intField:
+		return strconv.AppendInt(b, f.num, 10)
+	case floatField:
+		return strconv.AppendFloat(b, math.Float64frombits(uint64(f.num)), 'g', -1, 64)
+	case boolField:
+		return strconv.AppendBool(b, f.num != 0)
+	}
+	return b
+}
+
+// byteUnits are the units of appendBytes.
+var byteUnits = [...]string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// appendBytes appends the size n to b in the largest binary unit in which it
+// is at least 1, with one decimal, or in bytes if it is under 1KiB.
+func appendBytes(b []byte, n int64) []byte {
+	if n > -1024 && n < 1024 {
+		return append(strconv.AppendInt(b, n, 10), 'B')
+	}
+	v := float64(n) / 1024
+	u := 0
+	for math.Abs(v) >= 1024 && u < len(byteUnits)-1 {
+		v /= 1024
+		u++
+	}
+	b = strconv.AppendFloat(b, v, 'f', 1, 64)
+	return append(b, byteUnits[u]...)
+}
+
+// InfoFields logs msg to the INFO log followed by fields, as an Entry would:
+//
+//	glog.InfoFields("charged", glog.String("user", u), glog.Int("cents", n))
+//
+// Built with the typed constructors, the fields are not stored in
+// interfaces, which suits hot paths.
+func InfoFields(msg string, fields ...Field) {
+	logging.printEntry(infoLog, "", fields, maskDefault, tmsg, msg, nil)
+}
+
+// WarningFields logs msg to the WARNING and INFO logs followed by fields; see
+// InfoFields.
+func WarningFields(msg string, fields ...Field) {
+	logging.printEntry(warningLog, "", fields, maskDefault, tmsg, msg, nil)
+}
+
+// ErrorFields logs msg to the ERROR, WARNING, and INFO logs followed by
+// fields; see InfoFields.
+func ErrorFields(msg string, fields ...Field) {
+	logging.printEntry(errorLog, "", fields, maskDefault, tmsg, msg, nil)
+}
+
+// FatalFields logs msg to the FATAL, ERROR, WARNING, and INFO logs followed
+// by fields, including a stack trace of all running goroutines, then calls
+// os.Exit(255) or the function installed by SetExitFunc; see InfoFields.
+func FatalFields(msg string, fields ...Field) {
+	logging.printEntry(fatalLog, "", fields, maskDefault, tmsg, msg, nil)
+}
+
+// InfoFields is equivalent to the global InfoFields function, guarded by the
+// value of v.
+func (v Verbose) InfoFields(msg string, fields ...Field) {
+	if v {
+		logging.printEntryV(0, infoLog, globalV, "", fields, maskDefault, tmsg, msg, nil)
+	}
+}
@@ -0,0 +1,105 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that typed fields are written, masked and encoded like untyped ones.
+func TestTypedFields(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var rb recordBuffer
+	remove := AddWriter("INFO", &rb)
+	defer remove()
+
+	InfoFields("charged", String("mobile", "13812345678"), String("note", "two words"),
+		Int("cents", 1250), Float64("rate", 0.5), Bool("retry", true), Err(errors.New("declined")))
+	WithFields(Int64("user_id", 7)).Warning("done")
+	V(0).InfoFields("verbose", Any("ids", []int{1, 2}))
+
+	line := contents(infoLog)
+	want := "] charged mobile=138****5678 note=\"two words\" cents=1250 rate=0.5 retry=true error=declined\n"
+	if !strings.Contains(line, want) {
+		t.Errorf("got %q, want %q", line, want)
+	}
+	if !contains(warningLog, "] done user_id=7\n", t) {
+		t.Errorf("got %q", contents(warningLog))
+	}
+	if !strings.HasSuffix(line, "] verbose ids=\"[1 2]\"\n") {
+		t.Errorf("got %q", line)
+	}
+	if len(rb.records) != 3 {
+		t.Fatalf("got %d records", len(rb.records))
+	}
+	b, err := json.Marshal(rb.records[0].Fields)
+	if err != nil || string(b) != `{"mobile":"138****5678","note":"two words","cents":1250,"rate":0.5,"retry":true,"error":"declined"}` {
+		t.Errorf("bad JSON fields: %s, %v", b, err)
+	}
+
+	fields := WithFields(String("a", "x"), Int("b", 2)).Fields()
+	if fields[0].Value != "x" || fields[1].Value != int64(2) {
+		t.Errorf("Fields did not fill in Value: %+v", fields)
+	}
+}
+
+// Test that writing typed number fields does not allocate.
+// Test that durations, sizes and times are written for people in lines and
+// for machines in JSON.
+func TestDurationBytesTimeFields(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var rb recordBuffer
+	remove := AddWriter("INFO", &rb)
+	defer remove()
+
+	at := time.Date(2026, 10, 16, 12, 0, 0, 500, time.UTC)
+	InfoFields("copied", Duration("took", 1200*time.Millisecond), Bytes("size", 3565158),
+		Bytes("small", 512), Time("at", at))
+
+	want := "] copied took=1.2s size=3.4MiB small=512B at=2026-10-16T12:00:00Z\n"
+	if line := contents(infoLog); !strings.HasSuffix(line, want) {
+		t.Errorf("got %q, want %q", line, want)
+	}
+	b, err := json.Marshal(rb.records[0].Fields)
+	if err != nil || string(b) != `{"took":1200000000,"size":3565158,"small":512,"at":"2026-10-16T12:00:00.0000005Z"}` {
+		t.Errorf("bad JSON fields: %s, %v", b, err)
+	}
+	for n, want := range map[int64]string{1023: "1023B", 1024: "1.0KiB", -2048: "-2.0KiB", 5 << 40: "5.0TiB"} {
+		if got := string(appendBytes(nil, n)); got != want {
+			t.Errorf("appendBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestTypedFieldsAllocs(t *testing.T) {
+	buf := new(buffer)
+	buf.Grow(256)
+	fields := []Field{Int("a", 12345), Float64("b", 1.5), Bool("c", true), String("d", "text")}
+	allocs := testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		writeFields(buf, fields)
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocations", allocs)
+	}
+}
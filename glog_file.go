@@ -20,11 +20,11 @@ package glog
 
 import (
 	"errors"
-	"flag"
 	"fmt"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -36,20 +36,14 @@ var MaxSize uint64 = 1024 * 1024 * 1800
 // logDirs lists the candidate directories for new log files.
 var logDirs []string
 
-// If non-empty, overrides the choice of directory in which to write logs.
-// See createLogDirs for the full list of possible destinations.
-var logDir = flag.String("log_dir", "", "If non-empty, write log files in this directory")
+// If non-empty, overrides the choice of directory in which to write logs: a
+// comma-separated list of directories tried in order. See createLogDirs.
+// It is the -log_dir flag.
+var logDir = new(string)
 
-var LogRotateInterval = flag.String("rotate_interval", "day",
-	"Set the rolling log intervals to be months, days, hours, and minutes, and values correspond to 'month', 'day', 'hour', 'minute' respectively")
-
-func createLogDirs() {
-	if *logDir != "" {
-		logDirs = append(logDirs, *logDir)
-	} else {
-		logDirs = append(logDirs, os.TempDir())
-	}
-}
+// LogRotateInterval is the -rotate_interval flag: "month", "day", "hour",
+// "minute" or "second".
+var LogRotateInterval = func() *string { s := "day"; return &s }()
 
 var (
 	pid      = os.Getpid()
@@ -69,8 +63,20 @@ func init() {
 		userName = current.Username
 	}
 
-	// Sanitize userName since it may contain filepath separators on Windows.
-	userName = strings.Replace(userName, `\`, "_", -1)
+	// Sanitize the parts of file names, since userName may contain filepath
+	// separators on Windows, which allows even fewer characters in names.
+	program, host, userName = safeName(program), safeName(host), safeName(userName)
+}
+
+// safeName replaces the characters of s that are not allowed in file names
+// on Windows, and path separators everywhere, with underscores.
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, s)
 }
 
 // shortHostname returns its argument, truncating at the first period.
@@ -83,8 +89,9 @@ func shortHostname(hostname string) string {
 }
 
 // logName returns a new log file name containing tag, with start time t, and
-// the name for the symlink for tag.
-func logName(tag string, t time.Time) (name, link string) {
+// the name for the symlink for tag. A positive seq, which numbers the files
+// started within the same second, is appended to the name.
+func logName(tag string, t time.Time, seq int) (name, link string) {
 	name = fmt.Sprintf("%s.%s.%s.log.%s.%04d%02d%02d-%02d%02d%02d.%d",
 		program,
 		host,
@@ -97,32 +104,86 @@ func logName(tag string, t time.Time) (name, link string) {
 		t.Minute(),
 		t.Second(),
 		pid)
+	if seq > 0 {
+		name += "." + strconv.Itoa(seq)
+	}
 	return name, program + "." + tag
 }
 
 var onceLogDirs sync.Once
 
+// maxSeq bounds the sequence numbers tried by create in each directory.
+const maxSeq = 1000
+
 // create creates a new log file and returns the file and its filename, which
-// contains tag ("INFO", "FATAL", etc.) and t.  If the file is created
-// successfully, create also attempts to update the symlink for that tag, ignoring
-// errors.
-func create(tag string, t time.Time) (f *os.File, filename string, err error) {
+// contains tag ("INFO", "FATAL", etc.), t and a sequence number, as in logName.
+// An existing file is never overwritten: the sequence number used, returned as
+// used, is the lowest from seq up whose name is free. If the file is created
+// successfully, create also attempts to update the symlink for that tag,
+// ignoring errors.
+func create(tag string, t time.Time, seq int) (f *os.File, filename string, used int, err error) {
 	onceLogDirs.Do(createLogDirs)
 	if len(logDirs) == 0 {
-		return nil, "", errors.New("log: no log dirs")
+		return nil, "", 0, errors.New("log: no log dirs")
 	}
-	name, link := logName(tag, t)
 	var lastErr error
 	for _, dir := range logDirs {
-		fname := filepath.Join(dir, name)
-		f, err := os.Create(fname)
-		if err == nil {
-			symlink := filepath.Join(dir, link)
-			os.Remove(symlink)        // ignore err
-			os.Symlink(name, symlink) // ignore err
-			return f, fname, nil
+		for n := seq; n < seq+maxSeq; n++ {
+			name, link := logName(tag, t, n)
+			fname := filepath.Join(dir, name)
+			f, err := openLogFile(fname, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+			if err == nil {
+				updateLink(dir, name, link)
+				return f, fname, n, nil
+			}
+			lastErr = err
+			if !os.IsExist(err) {
+				break
+			}
+		}
+	}
+	return nil, "", 0, fmt.Errorf("log: cannot create log: %v", lastErr)
+}
+
+// updateLink points the symlink link in dir at the file name, ignoring errors.
+func updateLink(dir, name, link string) {
+	symlink := filepath.Join(dir, link)
+	os.Remove(symlink)        // ignore err
+	os.Symlink(name, symlink) // ignore err
+}
+
+// moved reports whether the syncBuffer's file has been removed or renamed by
+// another process, so that writes would go to an orphaned inode.
+func (sb *syncBuffer) moved() bool {
+	if sb.file == nil {
+		return false
+	}
+	fi, err := sb.file.Stat()
+	if err != nil {
+		return false
+	}
+	pi, err := os.Stat(sb.name)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	return !os.SameFile(fi, pi)
+}
+
+// checkFiles starts a new file for each log whose file has been removed or
+// renamed since it was opened. It is called periodically by flushDaemon.
+// This is not a rotation: OnRotate functions are not called.
+// l.mu is held.
+func (l *loggingT) checkFiles() {
+	for s := fatalLog; s >= infoLog; s-- {
+		sb, ok := l.file[s].(*syncBuffer)
+		if !ok || l.failedOver(s) || !sb.moved() {
+			continue
+		}
+		sb.Flush()
+		sb.file.Close()
+		sb.file = nil
+		if err := sb.rotateFile(l.now()); err != nil {
+			l.fileError(s, err)
 		}
-		lastErr = err
 	}
-	return nil, "", fmt.Errorf("log: cannot create log: %v", lastErr)
 }
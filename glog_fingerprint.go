@@ -0,0 +1,106 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Fingerprints that group occurrences of the same error.
+
+package glog
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// FingerprintKey is the key of the field that SetFingerprint adds to ERROR
+// and FATAL lines.
+const FingerprintKey = "fingerprint"
+
+// fingerprints is set by SetFingerprint. Accessed atomically.
+var fingerprints uint32
+
+// SetFingerprint controls whether ERROR and FATAL lines end with a
+// fingerprint field, a hash of their source location and message in which
+// words with digits, such as numbers, addresses and IDs, do not count, so
+// that log backends can group occurrences of the same error:
+//
+//	E1016 12:00:00.000000   12345 conn.go:42] dial 10.0.0.7:5432: timeout fingerprint=5b1e7a42c09d3f68
+//
+// A Webhook sends it to Sentry as the fingerprint of the event.
+func SetFingerprint(on bool) {
+	var v uint32
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&fingerprints, v)
+}
+
+// Fingerprint returns the fingerprint of a line logged at file:line with the
+// message msg, as added by SetFingerprint.
+func Fingerprint(file string, line int, msg string) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s:%d\x00", file, line)
+	for _, w := range strings.Fields(msg) {
+		if strings.IndexAny(w, "0123456789") >= 0 {
+			w = "#"
+		}
+		h.Write([]byte(w))
+		h.Write([]byte{' '})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// addFingerprint adds the fingerprint field to buf, a line logged at
+// file:line.
+func (b *buffer) addFingerprint(file string, line int) {
+	data := b.Bytes()
+	end := len(data)
+	if b.fields != nil && b.fieldsAt >= b.hdrLen {
+		end = b.fieldsAt
+	}
+	f := String(FingerprintKey, Fingerprint(file, line, string(data[b.hdrLen:end])))
+	if data[len(data)-1] == '\n' {
+		b.Truncate(len(data) - 1)
+	}
+	if b.fields == nil {
+		b.fieldsAt = b.Len()
+	}
+	b.fields = append(b.fields[:len(b.fields):len(b.fields)], f)
+	writeFields(b, b.fields[len(b.fields)-1:])
+	b.WriteByte('\n')
+}
+
+// fingerprintValue implements flag.Value for the -log_fingerprint flag.
+type fingerprintValue struct{}
+
+// String is part of the flag.Value interface.
+func (fingerprintValue) String() string {
+	return strconv.FormatBool(atomic.LoadUint32(&fingerprints) != 0)
+}
+
+// Set is part of the flag.Value interface.
+func (fingerprintValue) Set(value string) error {
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	SetFingerprint(on)
+	return nil
+}
+
+// IsBoolFlag lets -log_fingerprint be given without a value.
+func (fingerprintValue) IsBoolFlag() bool { return true }
@@ -0,0 +1,91 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var fingerprintRE = regexp.MustCompile(` fingerprint=([0-9a-f]{16})\n`)
+
+// Test that ERROR lines from one call site with messages that differ in
+// numbers share a fingerprint, and other lines have none.
+func TestFingerprint(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetFingerprint(false)
+	SetFingerprint(true)
+
+	for _, id := range []int{42, 1234} {
+		Errorf("user %d not found at 10.0.0.%d", id, id)
+	}
+	ErrorFields("lookup failed", Int("user", 7))
+	Warning("not fingerprinted")
+
+	lines := strings.Split(strings.TrimSuffix(contents(errorLog), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("ERROR log is %q, want 3 lines", contents(errorLog))
+	}
+	var fps []string
+	for _, l := range lines {
+		m := fingerprintRE.FindStringSubmatch(l + "\n")
+		if m == nil {
+			t.Fatalf("no fingerprint in %q", l)
+		}
+		fps = append(fps, m[1])
+	}
+	if fps[0] != fps[1] || fps[0] == fps[2] {
+		t.Errorf("fingerprints %q, want the first two equal", fps)
+	}
+	if !strings.HasSuffix(lines[2], "] lookup failed user=7 fingerprint="+fps[2]) {
+		t.Errorf("fields line is %q", lines[2])
+	}
+	if strings.Contains(contents(warningLog), "not fingerprinted fingerprint=") {
+		t.Errorf("WARNING line fingerprinted: %q", contents(warningLog))
+	}
+	if Fingerprint("a.go", 1, "open x: denied") == Fingerprint("a.go", 1, "open y: denied") {
+		t.Error("messages differing in words share a fingerprint")
+	}
+}
+
+// Test that the Sentry event carries the fingerprint of the entry.
+func TestWebhookFingerprint(t *testing.T) {
+	w := &Webhook{auth: "Sentry sentry_key=k"}
+	e := &Entry{Time: time.Now(), Severity: "ERROR", File: "x.go", Line: 1, Message: "boom",
+		fields: []Field{String(FingerprintKey, "0123456789abcdef")}}
+	body, err := w.encode(e, "stack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ev struct {
+		Fingerprint []string               `json:"fingerprint"`
+		Extra       map[string]interface{} `json:"extra"`
+	}
+	if err := json.Unmarshal(body, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(ev.Fingerprint) != 1 || ev.Fingerprint[0] != "0123456789abcdef" {
+		t.Errorf("event fingerprint %q", ev.Fingerprint)
+	}
+	if _, ok := ev.Extra[FingerprintKey]; ok {
+		t.Error("fingerprint repeated in extra")
+	}
+}
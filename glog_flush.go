@@ -0,0 +1,241 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Flushing and syncing of log files.
+
+package glog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// noFlushSeverity is the flush severity that leaves all lines buffered.
+const noFlushSeverity = numSeverity
+
+// SetFlushSeverity makes lines of the named severity, such as "WARNING", and
+// above flush the log files they are written to as soon as they are written,
+// and also sync them to disk if sync is set. Lower severities remain buffered
+// until the periodic flush. An empty name restores the default, under which
+// all lines are buffered.
+func SetFlushSeverity(name string, sync bool) error {
+	sev, err := parseFlushSeverity(name)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.flushSeverity = sev
+	logging.flushSync = sync
+	return nil
+}
+
+// parseFlushSeverity parses the value of the -flush_severity flag.
+func parseFlushSeverity(name string) (severity, error) {
+	if name == "" || strings.EqualFold(name, "none") {
+		return noFlushSeverity, nil
+	}
+	sev, ok := severityByName(name)
+	if !ok {
+		return 0, fmt.Errorf("log: unknown severity %q", name)
+	}
+	return sev, nil
+}
+
+// flushLine flushes, and syncs if configured, file f after a line of
+// severity s was written to it. l.mu is held.
+func (l *loggingT) flushLine(s, f severity) {
+	sync := l.flushSync && s >= l.flushSeverity ||
+		l.syncPolicy.Mode == SyncSeverity && s >= l.syncSeverity
+	if s < l.flushSeverity && !sync {
+		return
+	}
+	l.file[f].Flush() // ignore error
+	if sync {
+		l.file[f].Sync() // ignore error
+	}
+}
+
+// Modes of a SyncPolicy.
+const (
+	SyncNever    = "never"    // Never sync; the OS writes the data back.
+	SyncFatal    = "fatal"    // Sync when exiting, through Fatal or Exit, or on Flush and Close.
+	SyncInterval = "interval" // Also sync periodically.
+	SyncSeverity = "severity" // Also sync on each line at or above a severity.
+)
+
+// SyncPolicy sets when the log files are synced to disk, which makes lines
+// survive a crash of the machine, not only of the program, at the cost of
+// waiting for the disk.
+type SyncPolicy struct {
+	Mode string // One of the Sync* modes.
+	// Interval is the period of SyncInterval. Files are synced by the first
+	// line written once it has elapsed, or by the next periodic flush. Zero
+	// means at each periodic flush, every -flush_interval, the default.
+	Interval time.Duration
+	// Severity is the lowest severity synced by SyncSeverity, as soon as the
+	// line is written. The default is "ERROR".
+	Severity string
+}
+
+// SetSyncPolicy sets when the log files are synced to disk. The default is
+// SyncPolicy{Mode: SyncInterval}, syncing at each periodic flush.
+func SetSyncPolicy(p SyncPolicy) error {
+	sev, err := p.validate()
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.syncPolicy = p
+	logging.syncSeverity = sev
+	return nil
+}
+
+// validate reports whether p holds valid settings and returns the severity
+// of SyncSeverity.
+func (p SyncPolicy) validate() (severity, error) {
+	switch p.Mode {
+	case SyncNever, SyncFatal, SyncInterval, SyncSeverity:
+	default:
+		return 0, fmt.Errorf("log: unknown sync mode %q", p.Mode)
+	}
+	if p.Interval < 0 {
+		return 0, fmt.Errorf("log: negative sync interval %v", p.Interval)
+	}
+	if p.Severity == "" {
+		return errorLog, nil
+	}
+	sev, ok := severityByName(p.Severity)
+	if !ok {
+		return 0, fmt.Errorf("log: unknown severity %q", p.Severity)
+	}
+	return sev, nil
+}
+
+// syncDue reports whether the periodic flush, or a line written at now,
+// syncs the files, and if so records the sync. l.mu is held.
+func (l *loggingT) syncDue(now time.Time, periodic bool) bool {
+	if l.syncPolicy.Mode != SyncInterval {
+		return false
+	}
+	if l.syncPolicy.Interval == 0 {
+		return periodic
+	}
+	if now.Sub(l.lastSync) < l.syncPolicy.Interval && !now.Before(l.lastSync) {
+		return false
+	}
+	l.lastSync = now
+	return true
+}
+
+// parseSyncPolicy parses the value of the -log_sync flag: a mode, a duration
+// for SyncInterval or a severity name for SyncSeverity.
+func parseSyncPolicy(value string) (SyncPolicy, error) {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case SyncNever, SyncFatal, SyncInterval, SyncSeverity:
+		return SyncPolicy{Mode: strings.ToLower(value)}, nil
+	}
+	if _, ok := severityByName(value); ok {
+		return SyncPolicy{Mode: SyncSeverity, Severity: strings.ToUpper(value)}, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return SyncPolicy{}, fmt.Errorf("log: -log_sync: %q is not a mode, severity or duration", value)
+	}
+	p := SyncPolicy{Mode: SyncInterval, Interval: d}
+	_, err = p.validate()
+	return p, err
+}
+
+// syncValue implements flag.Value for the -log_sync flag.
+type syncValue struct{}
+
+// String is part of the flag.Value interface.
+func (syncValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	p := logging.syncPolicy
+	switch {
+	case p.Mode == SyncInterval && p.Interval > 0:
+		return p.Interval.String()
+	case p.Mode == SyncSeverity:
+		return severityName[logging.syncSeverity]
+	}
+	return p.Mode
+}
+
+// Set is part of the flag.Value interface.
+func (syncValue) Set(value string) error {
+	p, err := parseSyncPolicy(value)
+	if err != nil {
+		return err
+	}
+	return SetSyncPolicy(p)
+}
+
+// flushSeverityValue implements flag.Value for the -flush_severity flag.
+type flushSeverityValue struct{}
+
+// String is part of the flag.Value interface.
+func (flushSeverityValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	if logging.flushSeverity == noFlushSeverity {
+		return ""
+	}
+	return severityName[logging.flushSeverity]
+}
+
+// Set is part of the flag.Value interface.
+func (flushSeverityValue) Set(value string) error {
+	sev, err := parseFlushSeverity(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.flushSeverity = sev
+	return nil
+}
+
+// flushSyncValue implements flag.Value for the -flush_severity_sync flag.
+type flushSyncValue struct{}
+
+// String is part of the flag.Value interface.
+func (flushSyncValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	return strconv.FormatBool(logging.flushSync)
+}
+
+// Set is part of the flag.Value interface.
+func (flushSyncValue) Set(value string) error {
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.flushSync = on
+	return nil
+}
+
+// IsBoolFlag lets the flag be given without a value.
+func (flushSyncValue) IsBoolFlag() bool { return true }
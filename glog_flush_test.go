@@ -0,0 +1,187 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"testing"
+	"time"
+)
+
+// countingBuffer counts the flushes and syncs of a log file.
+type countingBuffer struct {
+	flushBuffer
+	flushes, syncs int
+}
+
+func (c *countingBuffer) Flush() error {
+	c.flushes++
+	return nil
+}
+
+func (c *countingBuffer) Sync() error {
+	c.syncs++
+	return nil
+}
+
+func TestFlushSeverity(t *testing.T) {
+	setFlags()
+	var files [numSeverity]*countingBuffer
+	var writers [numSeverity]Sink
+	for s := range files {
+		files[s] = new(countingBuffer)
+		writers[s] = files[s]
+	}
+	defer logging.swap(logging.swap(writers))
+	defer SetFlushSeverity("", false)
+
+	Warning("buffered")
+	if files[infoLog].flushes != 0 {
+		t.Errorf("flushed with no flush severity")
+	}
+	if err := SetFlushSeverity("warning", false); err != nil {
+		t.Fatal(err)
+	}
+	Info("buffered")
+	Warning("flushed")
+	if f := files[infoLog]; f.flushes != 1 || f.syncs != 0 {
+		t.Errorf("INFO file: %d flushes, %d syncs; want 1, 0", f.flushes, f.syncs)
+	}
+	if f := files[warningLog]; f.flushes != 1 {
+		t.Errorf("WARNING file: %d flushes; want 1", f.flushes)
+	}
+
+	SetFlushSeverity("ERROR", true)
+	Error("synced")
+	if f := files[errorLog]; f.flushes != 1 || f.syncs != 1 {
+		t.Errorf("ERROR file: %d flushes, %d syncs; want 1, 1", f.flushes, f.syncs)
+	}
+	if err := SetFlushSeverity("LOUD", false); err == nil {
+		t.Error("unknown severity accepted")
+	}
+}
+
+func TestSyncPolicy(t *testing.T) {
+	setFlags()
+	var files [numSeverity]*countingBuffer
+	var writers [numSeverity]Sink
+	for s := range files {
+		files[s] = new(countingBuffer)
+		writers[s] = files[s]
+	}
+	defer logging.swap(logging.swap(writers))
+	defer SetSyncPolicy(SyncPolicy{Mode: SyncInterval})
+	syncs := func() int {
+		n := 0
+		for _, f := range files {
+			n += f.syncs
+		}
+		return n
+	}
+
+	// Severity: lines at or above it are synced as they are written.
+	SetSyncPolicy(SyncPolicy{Mode: SyncSeverity, Severity: "WARNING"})
+	Info("not synced")
+	if syncs() != 0 {
+		t.Errorf("INFO line synced")
+	}
+	Warning("synced")
+	if files[warningLog].syncs != 1 || files[infoLog].syncs != 1 {
+		t.Errorf("WARNING line not synced to its files")
+	}
+
+	// Never: not even by Flush.
+	SetSyncPolicy(SyncPolicy{Mode: SyncNever})
+	before := syncs()
+	Error("not synced")
+	Flush()
+	if syncs() != before {
+		t.Errorf("synced under SyncNever")
+	}
+
+	// Interval: the first line after the interval syncs.
+	SetSyncPolicy(SyncPolicy{Mode: SyncInterval, Interval: time.Hour})
+	logging.mu.Lock()
+	logging.lastSync = time.Now().Add(-2 * time.Hour)
+	logging.mu.Unlock()
+	Info("synced")
+	Info("not synced")
+	if files[infoLog].syncs != 2 {
+		t.Errorf("got %d syncs of the INFO file, want 2", files[infoLog].syncs)
+	}
+
+	for _, bad := range []string{"sometimes", "-1s"} {
+		if _, err := parseSyncPolicy(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+	if p, err := parseSyncPolicy("error"); err != nil || p.Mode != SyncSeverity || p.Severity != "ERROR" {
+		t.Errorf("parseSyncPolicy(error) = %+v, %v", p, err)
+	}
+}
+
+func TestFlushDaemonControl(t *testing.T) {
+	defer SetFlushInterval(defaultFlushInterval)
+	defer StartFlushDaemon()
+
+	StopFlushDaemon()
+	if FlushDaemonRunning() {
+		t.Fatal("daemon running after StopFlushDaemon")
+	}
+	ensureFlushDaemon()
+	if FlushDaemonRunning() {
+		t.Fatal("creating a log file restarted a stopped daemon")
+	}
+	SetFlushInterval(time.Minute)
+	if FlushDaemonRunning() {
+		t.Fatal("SetFlushInterval started a stopped daemon")
+	}
+
+	StartFlushDaemon()
+	if !FlushDaemonRunning() {
+		t.Fatal("daemon not running after StartFlushDaemon")
+	}
+	SetFlushInterval(2 * time.Second)
+	if !FlushDaemonRunning() {
+		t.Error("daemon not restarted by SetFlushInterval")
+	}
+	if got := (flushIntervalValue{}).String(); got != "2s" {
+		t.Errorf("-flush_interval = %s, want 2s", got)
+	}
+}
+
+// Test that the flush daemon flushes the log files every interval.
+func TestFlushDaemonFlushes(t *testing.T) {
+	setFlags()
+	c := &countingBuffer{}
+	defer logging.swap(logging.swap([numSeverity]Sink{c}))
+	defer SetFlushInterval(defaultFlushInterval)
+	defer StartFlushDaemon()
+
+	StartFlushDaemon()
+	SetFlushInterval(time.Second)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		logging.mu.Lock()
+		n := c.flushes
+		logging.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Error("log file not flushed by the daemon")
+}
@@ -0,0 +1,119 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Flushing with a deadline and error reporting.
+
+package glog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SinkFlushError reports a sink that FlushWithContext could not flush.
+type SinkFlushError struct {
+	// Sink names the sink: "INFO log file" for the log file of a severity,
+	// or the type of a writer added with AddWriter, such as "*glog.TCPSink".
+	Sink string
+	Err  error
+}
+
+func (e SinkFlushError) Error() string {
+	return e.Sink + ": " + e.Err.Error()
+}
+
+// FlushError is returned by FlushWithContext when some sinks failed to flush.
+type FlushError struct {
+	Failed []SinkFlushError
+}
+
+func (e *FlushError) Error() string {
+	msgs := make([]string, len(e.Failed))
+	for i, f := range e.Failed {
+		msgs[i] = f.Error()
+	}
+	return fmt.Sprintf("log: flush failed: %s", strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the failed sinks, so that errors.Is reports
+// whether any of them is, for example, context.DeadlineExceeded.
+func (e *FlushError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, f := range e.Failed {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// FlushWithContext is like Flush, but returns when ctx is done if the
+// flush has not finished by then, and reports the sinks that failed. Besides
+// the log files and the writers added with AddWriter, it waits for the
+// writers with a FlushContext method, such as TCPSink and CloudWatchSink, to
+// send what they hold. Log files still being flushed when ctx is done are
+// reported with the error of ctx, as "log files"; the flush continues in the
+// background.
+func FlushWithContext(ctx context.Context) error {
+	return logging.flushContext(ctx)
+}
+
+// flushContext implements FlushWithContext. Nothing is waited for, l.mu
+// included, except in a goroutine or until ctx is done, so that it returns in
+// time even if a flush hook deadlocks.
+func (l *loggingT) flushContext(ctx context.Context) error {
+	flushers := make(chan []contextFlusher, 1)
+	files := make(chan []SinkFlushError, 1)
+	go func() {
+		flushers <- l.contextFlushers()
+		files <- l.lockAndFlushAll()
+	}()
+	var fs []contextFlusher
+	select {
+	case fs = <-flushers:
+	case <-ctx.Done():
+		return &FlushError{Failed: []SinkFlushError{{"log files", ctx.Err()}}}
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		sinks []SinkFlushError
+	)
+	for _, f := range fs {
+		wg.Add(1)
+		go func(f contextFlusher) {
+			defer wg.Done()
+			if err := f.FlushContext(ctx); err != nil {
+				mu.Lock()
+				sinks = append(sinks, SinkFlushError{fmt.Sprintf("%T", f), err})
+				mu.Unlock()
+			}
+		}(f)
+	}
+	var failed []SinkFlushError
+	select {
+	case failed = <-files:
+	case <-ctx.Done():
+		failed = []SinkFlushError{{"log files", ctx.Err()}}
+	}
+	wg.Wait() // The writers return once ctx is done.
+	failed = append(failed, sinks...)
+	if len(failed) > 0 {
+		return &FlushError{Failed: failed}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flushFailer is a writer whose Flush fails.
+type flushFailer struct{}
+
+func (flushFailer) Write(p []byte) (int, error) { return len(p), nil }
+func (flushFailer) Flush() error                { return errors.New("flush failed") }
+
+func TestFlushWithContext(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	Info("flushed")
+	if err := FlushWithContext(context.Background()); err != nil {
+		t.Fatalf("FlushWithContext: %v", err)
+	}
+
+	defer AddWriter("INFO", flushFailer{})()
+	stuck := stuckFlusher(make(chan struct{}))
+	defer AddWriter("INFO", stuck)()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := FlushWithContext(ctx)
+	var ferr *FlushError
+	if !errors.As(err, &ferr) {
+		t.Fatalf("FlushWithContext returned %v, want a *FlushError", err)
+	}
+	var sinks []string
+	for _, f := range ferr.Failed {
+		sinks = append(sinks, f.Sink)
+	}
+	if len(sinks) != 2 || sinks[0] != "glog.flushFailer" || sinks[1] != "glog.stuckFlusher" {
+		t.Errorf("failed sinks %q, want [glog.flushFailer glog.stuckFlusher]", sinks)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("%v does not wrap the deadline error", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Control of the flush daemon.
+
+package glog
+
+import (
+	"sync"
+	"time"
+)
+
+// flushd is the state of the flush daemon, which periodically flushes the log
+// files. It is started with the first log file, so that programs that import
+// the package but never write a log file run no daemon.
+var flushd struct {
+	mu      sync.Mutex
+	stop    chan struct{} // Closed to stop the running daemon; nil if none.
+	stopped bool          // Set by StopFlushDaemon, until StartFlushDaemon.
+}
+
+// StartFlushDaemon starts the daemon that flushes the log files every flush
+// interval, if it is not running. It is started automatically when the first
+// log file is created, so it is only needed after StopFlushDaemon or Close.
+func StartFlushDaemon() {
+	flushd.mu.Lock()
+	defer flushd.mu.Unlock()
+	flushd.stopped = false
+	startFlushDaemonLocked()
+}
+
+// StopFlushDaemon stops the flush daemon, if running, and keeps it from being
+// started automatically. The log files are then flushed only by Flush, lines
+// of the flush severity, or a full buffer.
+func StopFlushDaemon() {
+	flushd.mu.Lock()
+	defer flushd.mu.Unlock()
+	flushd.stopped = true
+	stopFlushDaemonLocked()
+}
+
+// SetFlushInterval sets how often the flush daemon flushes the log files, as
+// does the -flush_interval flag. Intervals under a second are rounded up. A
+// running daemon is restarted with the new interval.
+func SetFlushInterval(d time.Duration) {
+	flushd.mu.Lock()
+	defer flushd.mu.Unlock()
+	logging.flushInterval = d
+	if flushd.stop != nil {
+		stopFlushDaemonLocked()
+		startFlushDaemonLocked()
+	}
+}
+
+// FlushDaemonRunning reports whether the flush daemon is running.
+func FlushDaemonRunning() bool {
+	flushd.mu.Lock()
+	defer flushd.mu.Unlock()
+	return flushd.stop != nil
+}
+
+// ensureFlushDaemon starts the flush daemon unless it is running or was
+// stopped. It is called when a log file is created.
+func ensureFlushDaemon() {
+	flushd.mu.Lock()
+	defer flushd.mu.Unlock()
+	if !flushd.stopped {
+		startFlushDaemonLocked()
+	}
+}
+
+// startFlushDaemonLocked starts the flush daemon if it is not running.
+// flushd.mu is held.
+func startFlushDaemonLocked() {
+	if flushd.stop != nil {
+		return
+	}
+	flushd.stop = make(chan struct{})
+	go logging.flushDaemon(logging.flushInterval, flushd.stop)
+}
+
+// stopFlushDaemonLocked stops the flush daemon if it is running. It does not
+// wait for a flush in progress. flushd.mu is held.
+func stopFlushDaemonLocked() {
+	if flushd.stop == nil {
+		return
+	}
+	close(flushd.stop)
+	flushd.stop = nil
+}
+
+// flushIntervalValue implements flag.Value for the -flush_interval flag.
+type flushIntervalValue struct{}
+
+// String is part of the flag.Value interface.
+func (flushIntervalValue) String() string {
+	flushd.mu.Lock()
+	defer flushd.mu.Unlock()
+	return logging.flushInterval.String()
+}
+
+// Set is part of the flag.Value interface.
+func (flushIntervalValue) Set(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	SetFlushInterval(d)
+	return nil
+}
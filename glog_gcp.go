@@ -0,0 +1,224 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Google Cloud credentials, for the Cloud Logging sink.
+
+package glog
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GCPToken is an OAuth 2.0 access token for Google Cloud APIs.
+type GCPToken struct {
+	AccessToken string
+	Expires     time.Time // Zero if the token does not expire.
+}
+
+// gcpLoggingScope is the OAuth scope needed to write log entries.
+const gcpLoggingScope = "https://www.googleapis.com/auth/logging.write"
+
+// gcpMetadataURL is the address of the metadata server of GCE, GKE, Cloud
+// Run and Cloud Functions.
+var gcpMetadataURL = "http://metadata.google.internal"
+
+// gcpCredentialsFile is a service account key or user credentials file, as
+// written by "gcloud auth application-default login".
+type gcpCredentialsFile struct {
+	Type string `json:"type"`
+	// Service account keys.
+	ProjectID    string `json:"project_id"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+	// User credentials.
+	ClientID       string `json:"client_id"`
+	ClientSecret   string `json:"client_secret"`
+	RefreshToken   string `json:"refresh_token"`
+	QuotaProjectID string `json:"quota_project_id"`
+}
+
+// readGCPCredentialsFile reads the application default credentials file:
+// $GOOGLE_APPLICATION_CREDENTIALS, or else the file written by gcloud. It
+// returns nil if there is none.
+func readGCPCredentialsFile() (*gcpCredentialsFile, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		dir := os.Getenv("CLOUDSDK_CONFIG")
+		if dir == "" {
+			if d, err := os.UserConfigDir(); err == nil {
+				dir = filepath.Join(d, "gcloud")
+			}
+		}
+		path = filepath.Join(dir, "application_default_credentials.json")
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := new(gcpCredentialsFile)
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("log: %s: %v", path, err)
+	}
+	if f.TokenURI == "" {
+		f.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return f, nil
+}
+
+// DefaultGCPToken returns an access token for writing logs from the
+// application default credentials: the service account key or user
+// credentials file named by $GOOGLE_APPLICATION_CREDENTIALS or written by
+// gcloud, or else the service account of the metadata server.
+func DefaultGCPToken() (GCPToken, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	f, err := readGCPCredentialsFile()
+	if err != nil {
+		return GCPToken{}, err
+	}
+	if f == nil {
+		req, _ := http.NewRequest("GET", gcpMetadataURL+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+		req.Header.Set("Metadata-Flavor", "Google")
+		return fetchGCPToken(client, req)
+	}
+	form := url.Values{}
+	switch f.Type {
+	case "service_account":
+		assertion, err := f.jwt(time.Now())
+		if err != nil {
+			return GCPToken{}, err
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	case "authorized_user":
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", f.ClientID)
+		form.Set("client_secret", f.ClientSecret)
+		form.Set("refresh_token", f.RefreshToken)
+	default:
+		return GCPToken{}, fmt.Errorf("log: unsupported credentials type %q", f.Type)
+	}
+	req, err := http.NewRequest("POST", f.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return GCPToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return fetchGCPToken(client, req)
+}
+
+// jwt returns the signed assertion exchanged for an access token of the
+// service account.
+func (f *gcpCredentialsFile) jwt(now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(f.PrivateKey))
+	if block == nil {
+		return "", errors.New("log: service account key: no PEM private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", fmt.Errorf("log: service account key: %v", err)
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("log: service account key is not an RSA key")
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": f.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   f.ClientEmail,
+		"scope": gcpLoggingScope,
+		"aud":   f.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// fetchGCPToken sends req and decodes the token in the response.
+func fetchGCPToken(client *http.Client, req *http.Request) (GCPToken, error) {
+	body, err := readHTTPResponse(client, req)
+	if err != nil {
+		return GCPToken{}, fmt.Errorf("log: GCP token: %v", err)
+	}
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return GCPToken{}, fmt.Errorf("log: GCP token: %v", err)
+	}
+	t := GCPToken{AccessToken: resp.AccessToken}
+	if resp.ExpiresIn > 0 {
+		t.Expires = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
+
+// defaultGCPProject returns the project of $GOOGLE_CLOUD_PROJECT, of the
+// credentials file, or else of the metadata server.
+func defaultGCPProject() (string, error) {
+	if p := os.Getenv("GOOGLE_CLOUD_PROJECT"); p != "" {
+		return p, nil
+	}
+	if f, _ := readGCPCredentialsFile(); f != nil {
+		if f.ProjectID != "" {
+			return f.ProjectID, nil
+		}
+		if f.QuotaProjectID != "" {
+			return f.QuotaProjectID, nil
+		}
+	}
+	p, err := gcpMetadata("project/project-id")
+	if err != nil {
+		return "", fmt.Errorf("log: no GCP project: %v", err)
+	}
+	return p, nil
+}
+
+// gcpMetadata returns a value of the metadata server, such as
+// "instance/zone".
+func gcpMetadata(path string) (string, error) {
+	req, _ := http.NewRequest("GET", gcpMetadataURL+"/computeMetadata/v1/"+path, nil)
+	req.Header.Set("Metadata-Flavor", "Google")
+	body, err := readHTTPResponse(&http.Client{Timeout: 2 * time.Second}, req)
+	return strings.TrimSpace(string(body)), err
+}
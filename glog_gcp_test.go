@@ -0,0 +1,142 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearGCPEnv unsets the variables read by DefaultGCPToken for the duration
+// of the test and points the metadata server at srv.
+func clearGCPEnv(t *testing.T, srv *httptest.Server) {
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	u := gcpMetadataURL
+	t.Cleanup(func() { gcpMetadataURL = u })
+	gcpMetadataURL = srv.URL
+}
+
+func TestGCPServiceAccountToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		parts := strings.Split(r.Form.Get("assertion"), ".")
+		if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(parts) != 3 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var c map[string]interface{}
+		json.Unmarshal(claims, &c)
+		if c["iss"] != "sa@p.iam.gserviceaccount.com" || c["scope"] != gcpLoggingScope {
+			http.Error(w, "bad claims", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"access_token":"ya29.sa","expires_in":3600,"token_type":"Bearer"}`))
+	}))
+	defer srv.Close()
+	clearGCPEnv(t, srv)
+
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	file := filepath.Join(t.TempDir(), "key.json")
+	data, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"project_id":   "p",
+		"client_email": "sa@p.iam.gserviceaccount.com",
+		"private_key":  string(pemKey),
+		"token_uri":    srv.URL + "/token",
+	})
+	os.WriteFile(file, data, 0600)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", file)
+
+	tok, err := DefaultGCPToken()
+	if err != nil || tok.AccessToken != "ya29.sa" || tok.Expires.IsZero() {
+		t.Errorf("got %+v, %v", tok, err)
+	}
+	if p, err := defaultGCPProject(); p != "p" || err != nil {
+		t.Errorf("defaultGCPProject() = %q, %v", p, err)
+	}
+}
+
+func TestGCPUserToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "rt" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token":"ya29.user","expires_in":3600}`))
+	}))
+	defer srv.Close()
+	clearGCPEnv(t, srv)
+	// The file written by gcloud, found without GOOGLE_APPLICATION_CREDENTIALS.
+	data := fmt.Sprintf(`{"type":"authorized_user","client_id":"c","client_secret":"s","refresh_token":"rt","token_uri":%q}`, srv.URL)
+	os.WriteFile(filepath.Join(os.Getenv("CLOUDSDK_CONFIG"), "application_default_credentials.json"), []byte(data), 0600)
+	if tok, err := DefaultGCPToken(); err != nil || tok.AccessToken != "ya29.user" {
+		t.Errorf("got %+v, %v", tok, err)
+	}
+}
+
+func TestGCPMetadataToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing header", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/service-accounts/default/token":
+			w.Write([]byte(`{"access_token":"ya29.md","expires_in":100}`))
+		case "/computeMetadata/v1/project/project-id":
+			w.Write([]byte("md-project"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	clearGCPEnv(t, srv)
+	if tok, err := DefaultGCPToken(); err != nil || tok.AccessToken != "ya29.md" {
+		t.Errorf("got %+v, %v", tok, err)
+	}
+	if p, err := defaultGCPProject(); p != "md-project" || err != nil {
+		t.Errorf("defaultGCPProject() = %q, %v", p, err)
+	}
+}
@@ -0,0 +1,364 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The GELF encoding of log lines, and a sink sending it to Graylog over UDP.
+
+package glog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// GELFEncoder writes lines as GELF 1.1 messages, as read by Graylog, each
+// terminated by a NUL byte as GELF over TCP requires. Send them with a
+// TCPSink:
+//
+//	glog.AddWriter("INFO", glog.NewTCPSink(glog.TCPSinkConfig{Addr: "graylog:12201", Encoder: glog.GELFEncoder}))
+//
+// or over UDP with a GELFUDPSink. The first line of the message, cut to 250
+// bytes, is the short_message; the whole message is the full_message if it
+// differs. The severity is a syslog level, and the file, line, logger, pid,
+// labels and fields are additional fields, with the characters of their
+// names that GELF does not allow replaced with underscores. Numeric fields
+// stay numbers; other values are sent as strings.
+var GELFEncoder Encoder = gelfEncoder{}
+
+// gelfShortMax is the longest short_message, in bytes.
+const gelfShortMax = 250
+
+// gelfLevel maps severities to syslog levels.
+var gelfLevel = map[string]int{
+	"INFO":    6,
+	"WARNING": 4,
+	"ERROR":   3,
+	"FATAL":   2,
+}
+
+type gelfEncoder struct{}
+
+func (gelfEncoder) Encode(r *Record, text []byte) []byte {
+	return gelfEncoder{}.encodeRecord(recordOf(r))
+}
+
+func (gelfEncoder) encodeRecord(r *logRecord) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString(`{"version":"1.1","host":`)
+	gelfString(buf, r.Host)
+	full := withStack(r.Message, r.Stack)
+	short := full
+	if i := strings.IndexByte(short, '\n'); i >= 0 {
+		short = short[:i]
+	}
+	if len(short) > gelfShortMax {
+		n := gelfShortMax
+		for n > 0 && !utf8.RuneStart(short[n]) {
+			n--
+		}
+		short = short[:n]
+	}
+	if short == "" {
+		// short_message is required and may not be empty.
+		short = "-"
+	}
+	buf.WriteString(`,"short_message":`)
+	gelfString(buf, short)
+	if short != full && full != "" {
+		buf.WriteString(`,"full_message":`)
+		gelfString(buf, full)
+	}
+	buf.WriteString(`,"timestamp":`)
+	ts := float64(r.Time.Unix()) + float64(r.Time.Nanosecond()/1e3)/1e6
+	buf.WriteString(strconv.FormatFloat(ts, 'f', 6, 64))
+	if level, ok := gelfLevel[r.Severity]; ok {
+		fmt.Fprintf(buf, `,"level":%d`, level)
+	}
+	if r.File != "" {
+		buf.WriteString(`,"_file":`)
+		gelfString(buf, r.File)
+		fmt.Fprintf(buf, `,"_line":%d`, r.Line)
+	}
+	if r.Logger != "" {
+		buf.WriteString(`,"_logger":`)
+		gelfString(buf, r.Logger)
+	}
+	fmt.Fprintf(buf, `,"_pid":%d`, r.PID)
+	seen := map[string]bool{"_file": r.File != "", "_line": r.File != "", "_logger": r.Logger != "", "_pid": true}
+	for _, f := range r.Fields {
+		key := gelfKey(f.Key)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		buf.WriteString(`,"` + key + `":`)
+		switch f.kind {
+		case intField:
+			buf.WriteString(strconv.FormatInt(f.num, 10))
+		case floatField:
+			if b, err := json.Marshal(f.value()); err == nil {
+				buf.Write(b)
+			} else {
+				// NaN and infinities are not JSON numbers.
+				gelfString(buf, fmt.Sprint(f.value()))
+			}
+		case stringField:
+			gelfString(buf, f.str)
+		case anyField:
+			gelfString(buf, fmt.Sprint(f.Value))
+		default:
+			gelfString(buf, string(f.appendValue(nil)))
+		}
+	}
+	keys := make([]string, 0, len(r.Labels))
+	for k := range r.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key := gelfKey(k)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		buf.WriteString(`,"` + key + `":`)
+		gelfString(buf, r.Labels[k])
+	}
+	if r.Truncated && !seen["_truncated"] {
+		buf.WriteString(`,"_truncated":1`)
+	}
+	buf.WriteString("}\x00")
+	return buf.Bytes()
+}
+
+// gelfString writes s to buf as a JSON string.
+func gelfString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	buf.Write(b)
+}
+
+// gelfKey returns the name of the additional field for key: key prefixed
+// with an underscore, with the characters GELF does not allow replaced with
+// underscores. "_id", which GELF reserves, becomes "_id_".
+func gelfKey(key string) string {
+	key = strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, key)
+	if key == "id" {
+		key = "id_"
+	}
+	return "_" + key
+}
+
+// GELFUDPConfig configures a GELFUDPSink.
+type GELFUDPConfig struct {
+	// Addr is the host:port of the Graylog GELF UDP input.
+	Addr string
+	// Compression is "gzip", "zlib" or "none". The default is "gzip".
+	Compression string
+	// ChunkSize is the largest datagram sent; longer messages are split into
+	// chunks. The default is 1420, which fits the MTU of most networks; 8154
+	// suits local networks.
+	ChunkSize int
+	// BufferSize is the number of messages waiting to be sent before further
+	// messages are dropped. The default is 1000.
+	BufferSize int
+}
+
+// GELFUDPSink sends log records as GELF messages to a Graylog UDP input,
+// compressed and split into chunks as needed. Register it with AddWriter:
+//
+//	g, err := glog.NewGELFUDPSink(glog.GELFUDPConfig{Addr: "graylog:12201"})
+//	...
+//	glog.AddWriter("INFO", g)
+//	defer g.Close()
+//
+// Messages are sent by a background goroutine, so logging never waits on
+// the network. Messages that need more than 128 chunks, the limit of GELF,
+// are dropped.
+type GELFUDPSink struct {
+	cfg     GELFUDPConfig
+	conn    net.Conn
+	dropped int64 // Updated atomically.
+	unsent  int64 // Messages queued and not yet sent; updated atomically.
+
+	mu     sync.Mutex
+	closed bool
+
+	queue chan []byte
+	done  chan struct{} // Closed when the sender goroutine exits.
+}
+
+// gelfMaxChunks is the largest number of chunks of a message.
+const gelfMaxChunks = 128
+
+// gelfChunkHeader is the length of the header of a chunk: magic bytes,
+// message ID, sequence number and count.
+const gelfChunkHeader = 2 + 8 + 1 + 1
+
+// NewGELFUDPSink returns a GELFUDPSink for cfg and starts its sender
+// goroutine.
+func NewGELFUDPSink(cfg GELFUDPConfig) (*GELFUDPSink, error) {
+	switch cfg.Compression {
+	case "":
+		cfg.Compression = "gzip"
+	case "gzip", "zlib", "none":
+	default:
+		return nil, fmt.Errorf("log: gelf: unknown compression %q", cfg.Compression)
+	}
+	if cfg.ChunkSize <= gelfChunkHeader {
+		cfg.ChunkSize = 1420
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 1000
+	}
+	conn, err := net.Dial("udp", cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("log: gelf: %v", err)
+	}
+	s := &GELFUDPSink{
+		cfg:   cfg,
+		conn:  conn,
+		queue: make(chan []byte, cfg.BufferSize),
+		done:  make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Write queues p, a formatted log line, as the message of a record.
+// AddWriter does not call it; it sends fully structured records instead.
+func (s *GELFUDPSink) Write(p []byte) (int, error) {
+	rec := &logRecord{Time: currentClock().Now(), Host: host, PID: pid, Message: string(p), Labels: globalLabels.Load().(*labelSet).labels}
+	return len(p), s.writeRecord(rec)
+}
+
+func (s *GELFUDPSink) writeRecord(r *logRecord) error {
+	// The NUL terminator frames TCP streams only.
+	msg := bytes.TrimSuffix(gelfEncoder{}.encodeRecord(r), []byte{0})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errSinkClosed
+	}
+	select {
+	case s.queue <- msg:
+		atomic.AddInt64(&s.unsent, 1)
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+	return nil
+}
+
+// FlushContext waits until the queued messages are sent, or until ctx is
+// done, in which case it returns an error wrapping that of ctx.
+func (s *GELFUDPSink) FlushContext(ctx context.Context) error {
+	return waitSent(ctx, "gelf "+s.cfg.Addr, func() int { return int(atomic.LoadInt64(&s.unsent)) })
+}
+
+// Dropped returns the number of messages not sent because the queue was
+// full, they were too long or sending failed.
+func (s *GELFUDPSink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Close sends the queued messages, stops the sender goroutine and closes the
+// socket.
+func (s *GELFUDPSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errSinkClosed
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+	<-s.done
+	return s.conn.Close()
+}
+
+// run sends queued messages until the queue is closed.
+func (s *GELFUDPSink) run() {
+	defer close(s.done)
+	for msg := range s.queue {
+		if err := s.send(msg); err != nil {
+			atomic.AddInt64(&s.dropped, 1)
+		}
+		atomic.AddInt64(&s.unsent, -1)
+	}
+}
+
+var errGELFTooLong = errors.New("log: gelf: message needs more than 128 chunks")
+
+// send compresses msg and sends it, in chunks if it does not fit in one
+// datagram.
+func (s *GELFUDPSink) send(msg []byte) error {
+	if s.cfg.Compression != "none" {
+		var buf bytes.Buffer
+		if s.cfg.Compression == "gzip" {
+			zw := gzip.NewWriter(&buf)
+			zw.Write(msg)
+			zw.Close()
+		} else {
+			zw := zlib.NewWriter(&buf)
+			zw.Write(msg)
+			zw.Close()
+		}
+		msg = buf.Bytes()
+	}
+	if len(msg) <= s.cfg.ChunkSize {
+		_, err := s.conn.Write(msg)
+		return err
+	}
+	size := s.cfg.ChunkSize - gelfChunkHeader
+	n := (len(msg) + size - 1) / size
+	if n > gelfMaxChunks {
+		return errGELFTooLong
+	}
+	chunk := make([]byte, s.cfg.ChunkSize)
+	chunk[0], chunk[1] = 0x1e, 0x0f
+	if _, err := rand.Read(chunk[2:10]); err != nil {
+		return err
+	}
+	chunk[11] = byte(n)
+	for i := 0; i < n; i++ {
+		chunk[10] = byte(i)
+		data := msg[i*size:]
+		if len(data) > size {
+			data = data[:size]
+		}
+		m := copy(chunk[gelfChunkHeader:], data)
+		if _, err := s.conn.Write(chunk[:gelfChunkHeader+m]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
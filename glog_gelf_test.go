@@ -0,0 +1,175 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGELFEncoder(t *testing.T) {
+	r := &logRecord{
+		Time:     time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC),
+		Severity: "WARNING",
+		Host:     "web-1",
+		PID:      42,
+		File:     "pay.go",
+		Line:     7,
+		Logger:   "payments",
+		Message:  "card declined\ndetails follow",
+		Fields:   fieldList{Int("order", 12), String("user id", "u1"), String("id", "x"), Bool("retry", true), Int("pid", 1)},
+		Labels:   map[string]string{"region": "eu"},
+	}
+	out := GELFEncoder.(recordEncoder).encodeRecord(r)
+	if !bytes.HasSuffix(out, []byte{0}) {
+		t.Fatalf("%q is not NUL-terminated", out)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(out[:len(out)-1], &m); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"version":       "1.1",
+		"host":          "web-1",
+		"short_message": "card declined",
+		"full_message":  "card declined\ndetails follow",
+		"timestamp":     1714564800.123456,
+		"level":         4.0,
+		"_file":         "pay.go",
+		"_line":         7.0,
+		"_logger":       "payments",
+		"_pid":          42.0, // Not overridden by the field.
+		"_order":        12.0,
+		"_user_id":      "u1",
+		"_id_":          "x",
+		"_retry":        "true",
+		"_region":       "eu",
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, m[k], v)
+		}
+	}
+	if len(m) != len(want) {
+		t.Errorf("got %d keys, want %d: %s", len(m), len(want), out)
+	}
+}
+
+func TestGELFShortMessage(t *testing.T) {
+	for _, tc := range []struct{ msg, short, full string }{
+		{"one line", "one line", ""},
+		{"", "-", ""},
+		{strings.Repeat("é", 200), strings.Repeat("é", 125), strings.Repeat("é", 200)},
+	} {
+		var m map[string]string
+		out := GELFEncoder.Encode(&Record{Message: tc.msg}, nil)
+		// Numbers do not decode into strings; only keep the messages.
+		json.Unmarshal(bytes.TrimSuffix(out, []byte{0}), &m)
+		if m["short_message"] != tc.short || m["full_message"] != tc.full {
+			t.Errorf("%q: short %q, full %q", tc.msg, m["short_message"], m["full_message"])
+		}
+	}
+}
+
+// readGELF reads a message from conn, reassembling chunks and decompressing.
+func readGELF(t *testing.T, conn net.PacketConn) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var chunks [][]byte
+	var msg []byte
+	for msg == nil {
+		buf := make([]byte, 65536)
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf = buf[:n]
+		if len(buf) < 2 || buf[0] != 0x1e || buf[1] != 0x0f {
+			msg = buf
+			break
+		}
+		if chunks == nil {
+			chunks = make([][]byte, buf[11])
+		}
+		chunks[buf[10]] = buf[gelfChunkHeader:]
+		complete := true
+		for _, c := range chunks {
+			complete = complete && c != nil
+		}
+		if complete {
+			msg = bytes.Join(chunks, nil)
+		}
+	}
+	var r io.Reader = bytes.NewReader(msg)
+	switch {
+	case bytes.HasPrefix(msg, []byte{0x1f, 0x8b}):
+		r, _ = gzip.NewReader(r)
+	case msg[0] == 0x78:
+		r, _ = zlib.NewReader(r)
+	}
+	var m map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		t.Fatalf("decoding %q: %v", msg, err)
+	}
+	return m
+}
+
+func TestGELFUDPSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	long := strings.Repeat("x", 1000)
+	for _, tc := range []struct {
+		compression string
+		chunkSize   int
+		msg         string
+	}{
+		{"", 0, "gzip"},
+		{"zlib", 0, "zlib"},
+		{"none", 100, long}, // In 12 chunks.
+	} {
+		s, err := NewGELFUDPSink(GELFUDPConfig{Addr: conn.LocalAddr().String(), Compression: tc.compression, ChunkSize: tc.chunkSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.writeRecord(&logRecord{Time: time.Now(), Severity: "ERROR", Message: tc.msg})
+		m := readGELF(t, conn)
+		if m["full_message"] != nil && m["full_message"] != tc.msg || m["full_message"] == nil && m["short_message"] != tc.msg || m["level"] != 3.0 {
+			t.Errorf("%s: got %v", tc.msg[:4], m)
+		}
+		s.Close()
+	}
+
+	s, _ := NewGELFUDPSink(GELFUDPConfig{Addr: conn.LocalAddr().String(), Compression: "none", ChunkSize: 100})
+	s.writeRecord(&logRecord{Message: strings.Repeat("x", 128*88)})
+	s.Close()
+	if d := s.Dropped(); d != 1 {
+		t.Errorf("Dropped() = %d for a message over 128 chunks", d)
+	}
+	if _, err := NewGELFUDPSink(GELFUDPConfig{Addr: "127.0.0.1:1", Compression: "lz4"}); err == nil {
+		t.Error("no error for unknown compression")
+	}
+}
@@ -0,0 +1,122 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Goroutine and worker IDs in log headers.
+
+package glog
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// goroutineIDs is the -log_goroutine_id flag. Accessed atomically.
+var goroutineIDs uint32
+
+// WorkerIDKey is the key of the field naming the worker that logs a line.
+// With -log_goroutine_id, its value replaces the goroutine ID in the header,
+// which helps tell apart the lines of the workers of a pool:
+//
+//	ctx = glog.NewContext(ctx, glog.WorkerIDKey, "fetch-3")
+//	glog.FromContext(ctx).Info("fetched") // I0102 15:04:05.000000   1234 fetch-3 fetch.go:42] fetched worker_id=fetch-3
+//
+// Worker IDs should not contain spaces.
+const WorkerIDKey = "worker_id"
+
+// SetGoroutineID controls whether log headers include the ID of the logging
+// goroutine, as "g17", after the thread ID; it is the -log_goroutine_id flag.
+// Lines carrying a WorkerIDKey field show the worker ID instead. Finding the
+// goroutine ID costs about a microsecond per line.
+func SetGoroutineID(on bool) {
+	var v uint32
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&goroutineIDs, v)
+}
+
+// goroutineID returns the ID of the calling goroutine, parsed from the first
+// line of its stack trace, "goroutine 17 [running]:".
+func goroutineID() int {
+	var b [64]byte
+	n := runtime.Stack(b[:], false)
+	id := 0
+	for _, c := range b[len("goroutine "):n] {
+		if c < '0' || c > '9' {
+			break
+		}
+		id = id*10 + int(c-'0')
+	}
+	return id
+}
+
+// writeGoroutineID writes the goroutine ID to the header being formatted in
+// buf, if -log_goroutine_id is set, and records where it is.
+func (buf *buffer) writeGoroutineID() {
+	if atomic.LoadUint32(&goroutineIDs) == 0 {
+		return
+	}
+	buf.tmp[0] = ' '
+	buf.tmp[1] = 'g'
+	n := buf.someDigits(2, goroutineID())
+	buf.idAt = buf.Len() + 1
+	buf.Write(buf.tmp[:n+2])
+	buf.idEnd = buf.Len()
+}
+
+// setWorkerID replaces the goroutine ID in the header of buf with the value
+// of the WorkerIDKey field in fields, if there is one.
+func (buf *buffer) setWorkerID(fields []Field) {
+	if buf.idAt == 0 {
+		return
+	}
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Key != WorkerIDKey {
+			continue
+		}
+		id := fmt.Sprint(fields[i].value())
+		tail := append([]byte(nil), buf.Bytes()[buf.idEnd:]...)
+		buf.Truncate(buf.idAt)
+		buf.WriteString(id)
+		buf.Write(tail)
+		buf.hdrLen += len(id) - (buf.idEnd - buf.idAt)
+		buf.idEnd = buf.idAt + len(id)
+		return
+	}
+}
+
+// goroutineIDValue implements flag.Value for the -log_goroutine_id flag.
+type goroutineIDValue struct{}
+
+// String is part of the flag.Value interface.
+func (goroutineIDValue) String() string {
+	return strconv.FormatBool(atomic.LoadUint32(&goroutineIDs) != 0)
+}
+
+// Set is part of the flag.Value interface.
+func (goroutineIDValue) Set(value string) error {
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	SetGoroutineID(on)
+	return nil
+}
+
+// IsBoolFlag lets -log_goroutine_id be given without a value.
+func (goroutineIDValue) IsBoolFlag() bool { return true }
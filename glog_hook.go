@@ -0,0 +1,123 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Hooks called for every line before it is written.
+
+package glog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrDropEntry is returned by a hook to discard the line it was passed.
+var ErrDropEntry = errors.New("log: entry dropped by hook")
+
+// hook is a function registered with AddHook.
+type hook struct {
+	fn     func(e *Entry) error
+	failed int32 // The last call returned an error; accessed atomically.
+}
+
+var (
+	hooksMu sync.Mutex // Serializes changes to hooks.
+	// hooks holds the registered []*hook. It is replaced, never modified, so
+	// that logging calls can read it without locking.
+	hooks atomic.Value
+)
+
+// AddHook arranges for fn to be called for every line logged, before it is
+// written. fn is passed an Entry describing the line, after masking, with
+// its Time, Severity, File, Line and Message set. It may change Message or
+// the fields, with SetField, to change what is written, count or mirror
+// lines elsewhere, or return ErrDropEntry to discard the line; FATAL lines
+// cannot be discarded. Other errors are reported once on standard error and
+// otherwise ignored. Hooks run in the order they were added, in the logging
+// goroutine, and must not log themselves. The returned function removes fn.
+func AddHook(fn func(e *Entry) error) (remove func()) {
+	h := &hook{fn: fn}
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	old, _ := hooks.Load().([]*hook)
+	hooks.Store(append(old[:len(old):len(old)], h))
+	return func() {
+		hooksMu.Lock()
+		defer hooksMu.Unlock()
+		old, _ := hooks.Load().([]*hook)
+		for i, other := range old {
+			if other == h {
+				hooks.Store(append(old[:i:i], old[i+1:]...))
+				return
+			}
+		}
+	}
+}
+
+// runHooks passes the line of severity s formatted in buf to the registered
+// hooks and applies their changes to buf. It reports false if the line is to
+// be dropped.
+func (l *loggingT) runHooks(s severity, buf *buffer, file string, line int) bool {
+	hs, _ := hooks.Load().([]*hook)
+	if len(hs) == 0 {
+		return true
+	}
+	data := buf.Bytes()
+	end := len(data)
+	if buf.fields != nil && buf.fieldsAt >= buf.hdrLen {
+		end = buf.fieldsAt
+	}
+	msg := strings.TrimSuffix(string(data[buf.hdrLen:end]), "\n")
+	e := &Entry{
+		name:     buf.name,
+		fields:   append([]Field(nil), buf.fields...),
+		Time:     buf.when,
+		Severity: severityName[s],
+		File:     file,
+		Line:     line,
+		Message:  msg,
+	}
+	for _, h := range hs {
+		err := h.fn(e)
+		if err == ErrDropEntry {
+			if s < fatalLog {
+				return false
+			}
+			continue
+		}
+		if err != nil {
+			if atomic.SwapInt32(&h.failed, 1) == 0 {
+				fmt.Fprintf(os.Stderr, "log: hook failed: %v\n", err)
+			}
+		} else {
+			atomic.StoreInt32(&h.failed, 0)
+		}
+	}
+	// Rewrite the line after the header.
+	buf.Truncate(buf.hdrLen)
+	buf.WriteString(strings.TrimSuffix(e.Message, "\n"))
+	buf.fields, buf.fieldsAt = nil, 0
+	if len(e.fields) > 0 {
+		buf.fieldsAt = buf.Len()
+		buf.fields = e.fields
+		writeFields(buf, e.fields)
+	}
+	buf.WriteByte('\n')
+	return true
+}
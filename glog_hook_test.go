@@ -0,0 +1,71 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Test that hooks see masked entries and can change or drop them.
+func TestAddHook(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var seen []Entry
+	removeSeen := AddHook(func(e *Entry) error {
+		seen = append(seen, *e)
+		return nil
+	})
+	defer removeSeen()
+	removeEdit := AddHook(func(e *Entry) error {
+		switch {
+		case strings.Contains(e.Message, "noisy"):
+			return ErrDropEntry
+		case e.Severity == "ERROR":
+			e.Message = "[alert] " + e.Message
+			e.SetField("hooked", true)
+		}
+		return errors.New("hook trouble")
+	})
+
+	Info("noisy line")
+	Infof("mobile %v", map[string]interface{}{"mobile": "13812345678"})
+	Named("db").WithFields("table", "users").Error("query failed")
+	removeEdit()
+	Info("noisy again")
+
+	if contains(infoLog, "noisy line", t) {
+		t.Errorf("dropped line written: %q", contents(infoLog))
+	}
+	if !contains(infoLog, "noisy again", t) {
+		t.Error("hook still active after removal")
+	}
+	want := "[db] [alert] query failed table=users hooked=true\n"
+	if !contains(errorLog, want, t) {
+		t.Errorf("got %q, want it to contain %q", contents(errorLog), want)
+	}
+	if len(seen) != 4 {
+		t.Fatalf("hook saw %d entries, want 4", len(seen))
+	}
+	if e := seen[1]; e.Severity != "INFO" || e.File != "glog_hook_test.go" || e.Line == 0 || !strings.Contains(e.Message, "138****5678") {
+		t.Errorf("got entry %+v", e)
+	}
+	if e := seen[2]; e.Name() != "db" || e.Message != "query failed" || len(e.Fields()) != 1 {
+		t.Errorf("got entry %+v", e)
+	}
+}
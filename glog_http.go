@@ -0,0 +1,220 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Masking of net/http and net/url values.
+
+package glog
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maskHTTP returns the masked copy of v and true if v is a request, URL,
+// header or set of query or form values.
+func (l *loggingT) maskHTTP(v interface{}) (interface{}, bool) {
+	switch r := v.(type) {
+	case url.Values:
+		return l.maskValues(r), true
+	case http.Header:
+		return l.maskHeader(r), true
+	case *url.URL:
+		if r == nil {
+			return v, true
+		}
+		return l.maskURL(r), true
+	case url.URL:
+		return l.maskURL(&r), true
+	case *http.Request:
+		if r == nil {
+			return v, true
+		}
+		return l.maskRequest(r), true
+	case http.Request:
+		return l.maskRequest(&r), true
+	}
+	return nil, false
+}
+
+// maskRequest returns the parts of r worth logging, masked. The body is
+// left out.
+func (l *loggingT) maskRequest(r *http.Request) map[string]interface{} {
+	ret := map[string]interface{}{
+		"Method":        r.Method,
+		"Proto":         r.Proto,
+		"Host":          r.Host,
+		"RemoteAddr":    r.RemoteAddr,
+		"RequestURI":    l.maskRequestURI(r.RequestURI),
+		"ContentLength": r.ContentLength,
+		"Header":        l.maskHeader(r.Header),
+	}
+	if r.URL != nil {
+		ret["URL"] = l.maskURL(r.URL)
+	}
+	if r.Form != nil {
+		ret["Form"] = l.maskValues(r.Form)
+	}
+	if r.PostForm != nil {
+		ret["PostForm"] = l.maskValues(r.PostForm)
+	}
+	return ret
+}
+
+// maskURL returns a copy of u with its query parameters masked and its
+// password redacted.
+func (l *loggingT) maskURL(u *url.URL) *url.URL {
+	c := *u
+	c.RawQuery = l.maskQuery(u.RawQuery)
+	if _, ok := u.User.Password(); ok && l.filterPwd {
+		c.User = url.UserPassword(u.User.Username(), ShrinePwdStr())
+	}
+	return &c
+}
+
+// maskRequestURI masks the query of a request URI, as found in the
+// RequestURI field of a server request.
+func (l *loggingT) maskRequestURI(uri string) string {
+	i := strings.IndexByte(uri, '?')
+	if i < 0 {
+		return uri
+	}
+	return uri[:i+1] + l.maskQuery(uri[i+1:])
+}
+
+// maskQuery masks the values in the URL-encoded query q, keeping the order
+// and encoding of the parameters it leaves alone.
+func (l *loggingT) maskQuery(q string) string {
+	if q == "" {
+		return q
+	}
+	params := strings.Split(q, "&")
+	for i, p := range params {
+		j := strings.IndexByte(p, '=')
+		if j < 0 {
+			continue
+		}
+		k, v := p[:j], p[j+1:]
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			continue
+		}
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			continue
+		}
+		if masked := l.maskParam(key, value); masked != value {
+			params[i] = k + "=" + url.QueryEscape(masked)
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// maskValues returns a copy of the query or form values vs with the values
+// of sensitive keys masked.
+func (l *loggingT) maskValues(vs url.Values) url.Values {
+	if vs == nil {
+		return nil
+	}
+	ret := make(url.Values, len(vs))
+	for key, values := range vs {
+		masked := make([]string, len(values))
+		for i, v := range values {
+			masked[i] = l.maskParam(key, v)
+		}
+		ret[key] = masked
+	}
+	return ret
+}
+
+// maskHeader returns a copy of h with the values of sensitive headers and
+// cookies redacted and those of other headers masked by name.
+func (l *loggingT) maskHeader(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	ret := make(http.Header, len(h))
+	for key, values := range h {
+		masked := make([]string, len(values))
+		for i, v := range values {
+			switch canonical := http.CanonicalHeaderKey(key); {
+			case isSensitiveHeader(canonical):
+				masked[i] = l.maskCredential(v)
+			case canonical == "Cookie":
+				masked[i] = l.maskCookies(v)
+			case canonical == "Set-Cookie":
+				masked[i] = l.maskSetCookie(v)
+			default:
+				masked[i] = l.maskParam(key, v)
+			}
+		}
+		ret[key] = masked
+	}
+	return ret
+}
+
+// maskCredential redacts the credentials in the value of a sensitive header,
+// keeping the scheme of an Authorization value, as in "Bearer ******".
+func (l *loggingT) maskCredential(v string) string {
+	if !l.filterPwd {
+		return v
+	}
+	if i := strings.IndexByte(v, ' '); i >= 0 {
+		return v[:i+1] + ShrinePwdStr()
+	}
+	return ShrinePwdStr()
+}
+
+// maskCookies redacts the values in a Cookie header value, keeping the
+// cookie names.
+func (l *loggingT) maskCookies(v string) string {
+	if !l.filterPwd {
+		return v
+	}
+	cookies := strings.Split(v, ";")
+	for i, c := range cookies {
+		if j := strings.IndexByte(c, '='); j >= 0 {
+			cookies[i] = c[:j+1] + ShrinePwdStr()
+		}
+	}
+	return strings.Join(cookies, ";")
+}
+
+// maskSetCookie redacts the cookie value in a Set-Cookie header value,
+// keeping its name and attributes.
+func (l *loggingT) maskSetCookie(v string) string {
+	if !l.filterPwd {
+		return v
+	}
+	i := strings.IndexByte(v, '=')
+	if i < 0 {
+		return v
+	}
+	masked := v[:i+1] + ShrinePwdStr()
+	if j := strings.IndexByte(v, ';'); j > i {
+		masked += v[j:]
+	}
+	return masked
+}
+
+// maskParam masks value as transform masks the value of key in a map.
+func (l *loggingT) maskParam(key, value string) string {
+	m, _ := l.transform(map[string]interface{}{key: value}).(map[string]interface{})
+	if masked, ok := m[key].(string); ok {
+		return masked
+	}
+	return value
+}
@@ -0,0 +1,178 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Structured log records and their JSON encoding.
+
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SchemaVersion is the version of the layout of the JSON records written by
+// AddJSONWriter and the sinks that send records, in their "schema_version"
+// field. It changes only when a field is removed or changes meaning; new
+// fields may be added within a version.
+const SchemaVersion = 1
+
+// logRecord is the structured form of a log line.
+type logRecord struct {
+	SchemaVersion int               `json:"schema_version"`
+	Time          time.Time         `json:"time"`
+	Severity      string            `json:"severity"`
+	Host          string            `json:"host"`
+	PID           int               `json:"pid"`
+	File          string            `json:"file"`
+	Line          int               `json:"line"`
+	Logger        string            `json:"logger,omitempty"`
+	Message       string            `json:"message"`
+	Fields        fieldList         `json:"fields,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Build         *BuildInfo        `json:"build,omitempty"` // See SetBuildInfoInRecords.
+	Stack         []StackFrame      `json:"stack,omitempty"`
+	// Truncated is set if fields were dropped or the message shortened to
+	// keep the record within the length limit; see fitRecord.
+	Truncated bool `json:"truncated,omitempty"`
+
+	encoded []byte // Set by fitRecord.
+	text    []byte // The line as written to the log files, if any.
+}
+
+// newLogRecord builds the record for data, a line of severity s formatted in
+// buf by formatHeader. data may differ from the contents of buf if the line
+// was truncated.
+func newLogRecord(s severity, buf *buffer, file string, line int, data []byte) *logRecord {
+	var msg []byte
+	if buf.hdrLen <= len(data) {
+		msg = data[buf.hdrLen:]
+		if buf.stackAt > buf.hdrLen && buf.stackAt-buf.hdrLen <= len(msg) {
+			msg = msg[:buf.stackAt-buf.hdrLen]
+		}
+		msg = bytes.TrimSuffix(msg, []byte{'\n'})
+		if buf.fieldsAt >= buf.hdrLen && buf.fieldsAt-buf.hdrLen <= len(msg) && buf.fields != nil {
+			msg = msg[:buf.fieldsAt-buf.hdrLen]
+		}
+	}
+	return &logRecord{
+		SchemaVersion: SchemaVersion,
+		Time:          buf.when,
+		Severity:      severityName[s],
+		Host:          host,
+		PID:           pid,
+		File:          file,
+		Line:          line,
+		Logger:        buf.name,
+		Message:       string(msg),
+		Fields:        buf.fields,
+		Labels:        globalLabels.Load().(*labelSet).labels,
+		Build:         recordBuild(),
+		Stack:         parseStack(buf.stack),
+	}
+}
+
+// encodeJSON returns r as a single line of JSON, terminated by a newline.
+func (r *logRecord) encodeJSON() []byte {
+	if r.encoded != nil {
+		return r.encoded
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		// Only possible for times outside years 0-9999; keep the message.
+		b, _ = json.Marshal(&logRecord{SchemaVersion: SchemaVersion, Severity: r.Severity, Message: r.Message})
+	}
+	return append(b, '\n')
+}
+
+// Record is a log line decoded from its JSON record by ParseRecord.
+type Record struct {
+	SchemaVersion int               `json:"schema_version"`
+	Time          time.Time         `json:"time"`
+	Severity      string            `json:"severity"` // "INFO", "WARNING", "ERROR" or "FATAL".
+	Host          string            `json:"host"`
+	PID           int               `json:"pid"`
+	File          string            `json:"file"` // As selected by the -log_caller flag.
+	Line          int               `json:"line"`
+	Logger        string            `json:"logger,omitempty"` // The name of the Logger, if any.
+	Message       string            `json:"message"`          // Without header, fields or trailing newline.
+	Labels        map[string]string `json:"labels,omitempty"`
+	Build         *BuildInfo        `json:"build,omitempty"` // The build of the program, if recorded.
+	// Stack holds the goroutine stack attached to the line, if any; it is
+	// not part of Message.
+	Stack []StackFrame `json:"stack,omitempty"`
+	// Fields holds the fields of the line, as decoded from JSON: numbers
+	// are float64 and structs are maps.
+	Fields map[string]interface{} `json:"fields,omitempty"`
+	// Truncated is set if fields were dropped or the message shortened to
+	// keep the record within the length limit.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ParseRecord decodes a JSON record, as written by AddJSONWriter. Records
+// without a schema version, written before it was added, are read as
+// version 1. Records of a newer version than SchemaVersion are rejected.
+func ParseRecord(data []byte) (*Record, error) {
+	r := new(Record)
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("log: bad record: %v", err)
+	}
+	if r.SchemaVersion == 0 {
+		r.SchemaVersion = 1
+	}
+	if r.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("log: record schema version %d is newer than %d", r.SchemaVersion, SchemaVersion)
+	}
+	return r, nil
+}
+
+// Text returns r as a line of text in the format of the log files, with a
+// trailing newline. Fields are written in key order, since records do not
+// keep their order.
+func (r *Record) Text() string {
+	buf := new(buffer)
+	sev, _ := severityByName(r.Severity)
+	t := r.Time
+	fmt.Fprintf(buf, "%c%02d%02d %02d:%02d:%02d.%06d %7d", severityChar[sev], int(t.Month()), t.Day(),
+		t.Hour(), t.Minute(), t.Second(), t.Nanosecond()/1000, r.PID)
+	if ls, err := newLabelSet(r.Labels); err == nil {
+		buf.WriteString(ls.text)
+	}
+	if r.File == "" {
+		buf.WriteString("] ")
+	} else {
+		fmt.Fprintf(buf, " %s:%d] ", r.File, r.Line)
+	}
+	if r.Logger != "" {
+		fmt.Fprintf(buf, "[%s] ", r.Logger)
+	}
+	buf.WriteString(r.Message)
+	keys := make([]string, 0, len(r.Fields))
+	for k := range r.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([]Field, len(keys))
+	for i, k := range keys {
+		fields[i] = Field{Key: k, Value: r.Fields[k]}
+	}
+	writeFields(buf, fields)
+	buf.WriteString(withStack("", r.Stack))
+	buf.WriteByte('\n')
+	return buf.String()
+}
@@ -0,0 +1,50 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Test that records written by AddJSONWriter are decoded by ParseRecord.
+func TestParseRecord(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var buf bytes.Buffer
+	remove := AddJSONWriter("INFO", &buf)
+	defer remove()
+
+	Named("payments").WithFields("order", 42).Warning("declined")
+	r, err := ParseRecord(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SchemaVersion != SchemaVersion || r.Severity != "WARNING" || r.Logger != "payments" || r.Message != "declined" ||
+		r.File != "glog_json_test.go" || r.Fields["order"] != float64(42) || r.PID != pid || r.Time.IsZero() {
+		t.Errorf("got %+v", r)
+	}
+
+	if r, err := ParseRecord([]byte(`{"severity":"INFO","message":"old"}`)); err != nil || r.SchemaVersion != 1 {
+		t.Errorf("record without version: %+v, %v", r, err)
+	}
+	for _, bad := range []string{`{"schema_version":99}`, `not json`} {
+		if _, err := ParseRecord([]byte(bad)); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Key management for the encryption of log files and values.
+
+package glog
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeyProvider supplies the AES keys, 16, 24 or 32 bytes long, of
+// SetFileEncryption and SetShrineKeyProvider, so that keys can come from a
+// KMS, Vault or an HSM rather than being fixed. Each key has an ID,
+// recorded next to what it encrypts so that the key can be found again to
+// decrypt, which lets keys be rotated: CurrentKey may return a new key at
+// any time while GetKey still returns the old ones.
+//
+// CurrentKey is called for each value encrypted and each log file opened,
+// with logging blocked; implementations backed by a remote service should
+// cache the key and refresh it in the background. Methods may be called
+// concurrently.
+type KeyProvider interface {
+	// CurrentKey returns the key that encrypts from now on, and its ID.
+	CurrentKey() (keyID string, key []byte, err error)
+	// GetKey returns the key with the given ID, to decrypt.
+	GetKey(keyID string) ([]byte, error)
+}
+
+// KeyRing is a KeyProvider holding its keys in memory: Keys maps IDs to
+// keys, and Current is the ID of the key that encrypts. Retired keys stay in
+// Keys as long as what they encrypted must be read.
+type KeyRing struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+// StaticKey returns a KeyRing of the single key with the given ID.
+func StaticKey(keyID string, key []byte) KeyRing {
+	return KeyRing{Current: keyID, Keys: map[string][]byte{keyID: key}}
+}
+
+// CurrentKey returns the key named by Current.
+func (r KeyRing) CurrentKey() (string, []byte, error) {
+	key, err := r.GetKey(r.Current)
+	return r.Current, key, err
+}
+
+// GetKey returns the key with the given ID.
+func (r KeyRing) GetKey(keyID string) ([]byte, error) {
+	key, ok := r.Keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("log: no key %q", keyID)
+	}
+	return key, nil
+}
+
+// EnvKeyProvider returns a KeyProvider of the base64-encoded key in the
+// environment variable name, read on each use. The ID of the key is "env:"
+// followed by name.
+func EnvKeyProvider(name string) KeyProvider {
+	return envKey(name)
+}
+
+type envKey string
+
+func (e envKey) CurrentKey() (string, []byte, error) {
+	key, err := decodeKey(os.Getenv(string(e)))
+	if err != nil {
+		return "", nil, fmt.Errorf("log: key in $%s: %v", string(e), err)
+	}
+	return "env:" + string(e), key, nil
+}
+
+func (e envKey) GetKey(keyID string) ([]byte, error) {
+	id, key, err := e.CurrentKey()
+	if err == nil && keyID != id {
+		err = fmt.Errorf("log: no key %q in $%s", keyID, string(e))
+	}
+	return key, err
+}
+
+// decodeKey decodes a base64-encoded AES key.
+func decodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	case 0:
+		return nil, errors.New("no key")
+	}
+	return nil, fmt.Errorf("key of %d bytes, want 16, 24 or 32", len(key))
+}
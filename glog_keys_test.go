@@ -0,0 +1,67 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+// failingKeys is a KeyProvider whose service is down.
+type failingKeys struct{ err error }
+
+func (f failingKeys) CurrentKey() (string, []byte, error) { return "", nil, f.err }
+func (f failingKeys) GetKey(string) ([]byte, error)       { return nil, f.err }
+
+func TestKeyRing(t *testing.T) {
+	ring := KeyRing{Current: "2024-06", Keys: map[string][]byte{"2024-05": []byte("old"), "2024-06": []byte("new")}}
+	if id, key, err := ring.CurrentKey(); id != "2024-06" || string(key) != "new" || err != nil {
+		t.Errorf("CurrentKey() = %q, %q, %v", id, key, err)
+	}
+	if key, err := ring.GetKey("2024-05"); string(key) != "old" || err != nil {
+		t.Errorf("GetKey(2024-05) = %q, %v", key, err)
+	}
+	if _, err := ring.GetKey("2024-04"); err == nil {
+		t.Error("GetKey of an unknown ID succeeded")
+	}
+	ring.Current = "2024-07"
+	if _, _, err := ring.CurrentKey(); err == nil {
+		t.Error("CurrentKey without the current key succeeded")
+	}
+}
+
+func TestEnvKeyProvider(t *testing.T) {
+	key := bytes.Repeat([]byte{3}, 24)
+	t.Setenv("TEST_LOG_KEY", base64.StdEncoding.EncodeToString(key))
+	p := EnvKeyProvider("TEST_LOG_KEY")
+	if id, k, err := p.CurrentKey(); err != nil || id != "env:TEST_LOG_KEY" || !bytes.Equal(k, key) {
+		t.Errorf("CurrentKey() = %q, %v, %v", id, k, err)
+	}
+	if k, err := p.GetKey("env:TEST_LOG_KEY"); err != nil || !bytes.Equal(k, key) {
+		t.Errorf("GetKey() = %v, %v", k, err)
+	}
+	if _, err := p.GetKey("env:OTHER"); err == nil {
+		t.Error("GetKey of another ID succeeded")
+	}
+	for _, bad := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		t.Setenv("TEST_LOG_KEY", bad)
+		if _, _, err := p.CurrentKey(); err == nil {
+			t.Errorf("no error for %q", bad)
+		}
+	}
+}
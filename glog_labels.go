@@ -0,0 +1,146 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Static labels written in every log line.
+
+package glog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// labelSet is an immutable set of global labels with its header form
+// precomputed.
+type labelSet struct {
+	labels map[string]string
+	text   string // " key=value ..." in key order, or "" if there are none.
+}
+
+// globalLabels holds the current *labelSet.
+var globalLabels atomic.Value
+
+func init() {
+	globalLabels.Store(&labelSet{})
+}
+
+// newLabelSet validates labels and returns their set. Keys must be non-empty
+// and may not contain spaces, '=' or ']', which would make headers ambiguous.
+func newLabelSet(labels map[string]string) (*labelSet, error) {
+	if len(labels) == 0 {
+		return &labelSet{}, nil
+	}
+	keys := make([]string, 0, len(labels))
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if k == "" || strings.ContainsAny(k, " =]\"\n\t") {
+			return nil, fmt.Errorf("log: invalid label name %q", k)
+		}
+		keys = append(keys, k)
+		copied[k] = v
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		v := labels[k]
+		if v == "" || strings.ContainsAny(v, " =]\"\n\t") {
+			v = strconv.Quote(v)
+		}
+		b.WriteByte(' ')
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(v)
+	}
+	return &labelSet{labels: copied, text: b.String()}, nil
+}
+
+// SetGlobalLabels sets static labels, such as the service, environment or
+// data center, written in the header of every line after the thread ID:
+//
+//	I1016 12:00:00.000000   12345 env=prod service=api file.go:10] msg
+//
+// and as the "labels" object of JSON records. Labels are written in key
+// order. A nil or empty map removes them. It may be called at any time.
+func SetGlobalLabels(labels map[string]string) error {
+	ls, err := newLabelSet(labels)
+	if err != nil {
+		return err
+	}
+	globalLabels.Store(ls)
+	return nil
+}
+
+// GlobalLabels returns a copy of the labels set by SetGlobalLabels.
+func GlobalLabels() map[string]string {
+	ls := globalLabels.Load().(*labelSet)
+	if len(ls.labels) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(ls.labels))
+	for k, v := range ls.labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// parseLabels parses a comma-separated list of key=value labels, the syntax
+// of the -log_labels flag.
+func parseLabels(value string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("log: label %q is not of the form key=value", pair)
+		}
+		labels[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return labels, nil
+}
+
+// labelsValue implements flag.Value for the -log_labels flag.
+type labelsValue struct{}
+
+// String is part of the flag.Value interface.
+func (labelsValue) String() string {
+	ls, _ := globalLabels.Load().(*labelSet)
+	if ls == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(ls.labels))
+	for k := range ls.labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + ls.labels[k]
+	}
+	return strings.Join(keys, ",")
+}
+
+// Set is part of the flag.Value interface.
+func (labelsValue) Set(value string) error {
+	labels, err := parseLabels(value)
+	if err != nil {
+		return err
+	}
+	return SetGlobalLabels(labels)
+}
@@ -0,0 +1,93 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Test that global labels appear in text headers and JSON records.
+func TestGlobalLabels(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var rb recordBuffer
+	remove := AddWriter("INFO", &rb)
+	defer remove()
+
+	err := SetGlobalLabels(map[string]string{"service": "api", "env": "prod", "dc": "us east"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer SetGlobalLabels(nil)
+	Info("hello")
+
+	line := contents(infoLog)
+	want := " dc=\"us east\" env=prod service=api glog_labels_test.go:"
+	if !strings.Contains(line, want) {
+		t.Errorf("got %q, want it to contain %q", line, want)
+	}
+	if len(rb.records) != 1 {
+		t.Fatalf("got %d records, want 1", len(rb.records))
+	}
+	var rec struct {
+		Message string
+		Labels  map[string]string
+	}
+	if err := json.Unmarshal(rb.records[0].encodeJSON(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Message != "hello" || rec.Labels["service"] != "api" || rec.Labels["dc"] != "us east" {
+		t.Errorf("got record %+v", rec)
+	}
+
+	SetGlobalLabels(nil)
+	logging.newBuffers()
+	Info("bare")
+	if line := contents(infoLog); strings.Contains(line, "service=") {
+		t.Errorf("labels not removed: %q", line)
+	}
+	if GlobalLabels() != nil {
+		t.Errorf("GlobalLabels() = %v, want nil", GlobalLabels())
+	}
+}
+
+func TestGlobalLabelsInvalid(t *testing.T) {
+	for _, key := range []string{"", "a b", "a=b", "a]"} {
+		if err := SetGlobalLabels(map[string]string{key: "v"}); err == nil {
+			t.Errorf("SetGlobalLabels with key %q succeeded", key)
+		}
+	}
+	if GlobalLabels() != nil {
+		t.Errorf("invalid labels were installed: %v", GlobalLabels())
+	}
+}
+
+func TestLabelsFlag(t *testing.T) {
+	var v labelsValue
+	if err := v.Set("service=api, env=prod"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetGlobalLabels(nil)
+	if got, want := v.String(), "env=prod,service=api"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if err := v.Set("nokey"); err == nil {
+		t.Error("Set accepted a label without a value")
+	}
+}
@@ -0,0 +1,131 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Detection of sensitive values that escape masking.
+
+package glog
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// LeakStats counts the lines in which leak detection found values that look
+// like unmasked card numbers, identity card numbers or mobile phone numbers;
+// see SetLeakDetection. A line with several kinds of values counts once for
+// each kind.
+type LeakStats struct {
+	cards      int64
+	identities int64
+	phones     int64
+}
+
+// Cards returns the number of lines found with Luhn-valid card numbers.
+func (s *LeakStats) Cards() int64 {
+	return atomic.LoadInt64(&s.cards)
+}
+
+// Identities returns the number of lines found with 18-digit identity card
+// numbers.
+func (s *LeakStats) Identities() int64 {
+	return atomic.LoadInt64(&s.identities)
+}
+
+// Phones returns the number of lines found with mobile phone numbers.
+func (s *LeakStats) Phones() int64 {
+	return atomic.LoadInt64(&s.phones)
+}
+
+// Leaks counts the lines in which leak detection found unmasked values.
+var Leaks LeakStats
+
+// leakDetection is set by SetLeakDetection. Accessed atomically.
+var leakDetection uint32
+
+// leakSites holds the call sites, as "file:line", already reported by a
+// warning.
+var leakSites sync.Map
+
+// SetLeakDetection turns on an audit mode in which every line logged is
+// scanned, after masking, for values that look like unmasked card numbers
+// that pass the Luhn check, 18-digit identity card numbers and mobile phone
+// numbers. Each such line is counted in Leaks and, the first time a call site
+// leaks, a WARNING naming the kind of value is logged at that call site; the
+// values themselves are never repeated. Masking is unaffected: with the
+// filters off, the detector finds the places that bypass tagging without
+// changing the logs. It is off by default.
+func SetLeakDetection(on bool) {
+	var v uint32
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&leakDetection, v)
+}
+
+// detectLeaks scans the message in buf, logged at file:line, for unmasked
+// values and counts and reports them.
+func (l *loggingT) detectLeaks(buf *buffer, file string, line int) {
+	msg := buf.Bytes()[buf.hdrLen:]
+	var kinds []string
+	if scrubCardRe.Match(msg) {
+		for _, m := range scrubCardRe.FindAll(msg, -1) {
+			if luhnValid(string(m)) {
+				atomic.AddInt64(&Leaks.cards, 1)
+				kinds = append(kinds, "card number")
+				break
+			}
+		}
+	}
+	if scrubIdentityRe.Match(msg) {
+		atomic.AddInt64(&Leaks.identities, 1)
+		kinds = append(kinds, "identity card number")
+	}
+	if scrubPhoneRe.Match(msg) {
+		atomic.AddInt64(&Leaks.phones, 1)
+		kinds = append(kinds, "mobile phone number")
+	}
+	if len(kinds) == 0 {
+		return
+	}
+	if _, reported := leakSites.LoadOrStore(file+":"+strconv.Itoa(line), true); reported {
+		return
+	}
+	for _, kind := range kinds {
+		l.printWithFileLine(warningLog, file, line, false, "log: possible unmasked ", kind, " logged here")
+	}
+}
+
+// leakDetectionValue implements flag.Value for the -leak_detection flag.
+type leakDetectionValue struct{}
+
+// String is part of the flag.Value interface.
+func (leakDetectionValue) String() string {
+	return strconv.FormatBool(atomic.LoadUint32(&leakDetection) != 0)
+}
+
+// Set is part of the flag.Value interface.
+func (leakDetectionValue) Set(value string) error {
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	SetLeakDetection(on)
+	return nil
+}
+
+// IsBoolFlag lets the flag be given without a value.
+func (leakDetectionValue) IsBoolFlag() bool { return true }
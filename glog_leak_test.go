@@ -0,0 +1,75 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLeakDetection(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetLeakDetection(false)
+	defer logging.SetFilter(true, true, true, true, true, true, true)
+	SetLeakDetection(true)
+	leakSites = sync.Map{}
+
+	logging.SetFilter(false, false, false, false, false, false, false)
+	cards, phones := Leaks.Cards(), Leaks.Phones()
+	for i := 0; i < 2; i++ {
+		Info(map[string]string{"card_no": "4111111111111111"})
+	}
+	Info("call 13812345678")
+	if got := Leaks.Cards() - cards; got != 2 {
+		t.Errorf("counted %d card leaks, want 2", got)
+	}
+	if got := Leaks.Phones() - phones; got != 1 {
+		t.Errorf("counted %d phone leaks, want 1", got)
+	}
+	warnings := contents(warningLog)
+	if n := strings.Count(warnings, "possible unmasked card number"); n != 1 {
+		t.Errorf("%d card warnings, want 1: %q", n, warnings)
+	}
+	if !strings.Contains(warnings, "glog_leak_test.go:") || !strings.Contains(warnings, "possible unmasked mobile phone number") {
+		t.Errorf("warnings lack the call site or kind: %q", warnings)
+	}
+	if strings.Contains(warnings, "4111111111111111") || strings.Contains(warnings, "13812345678") {
+		t.Errorf("warnings repeat the values: %q", warnings)
+	}
+
+	logging.SetFilter(true, true, true, true, true, true, true)
+	cards = Leaks.Cards()
+	Info(map[string]string{"card_no": "4111111111111111"})
+	if Leaks.Cards() != cards {
+		t.Error("masked card number counted as a leak")
+	}
+}
+
+func TestLeakDetectionLuhn(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetLeakDetection(false)
+	SetLeakDetection(true)
+
+	cards := Leaks.Cards()
+	Info("order 4111111111111112")
+	if Leaks.Cards() != cards {
+		t.Error("number failing the Luhn check counted as a card leak")
+	}
+}
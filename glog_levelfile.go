@@ -0,0 +1,146 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Changing the V logging levels at run time from a control file.
+
+package glog
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WatchLevelFile polls the file at path every interval (one second if
+// interval is not positive) and applies the V logging settings it holds, so
+// that verbosity can be raised on a running process by editing the file. The
+// file holds one setting per line, named after the flags; blank lines and
+// lines starting with # are ignored:
+//
+//	v=2
+//	vmodule=gopher*=3,net*=1
+//
+// Settings missing from the file, or the whole file being removed, restore the
+// levels in effect when WatchLevelFile was called. A file that cannot be parsed
+// is reported on standard error and leaves the levels unchanged. The returned
+// function stops watching; it does not restore the levels.
+func WatchLevelFile(path string, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	logging.mu.Lock()
+	w := &levelWatcher{
+		path:      path,
+		verbosity: logging.verbosity.get(),
+		filter:    logging.vmodule.filter,
+	}
+	logging.mu.Unlock()
+	w.check()
+	done := make(chan struct{})
+	go func() {
+		ticker := currentClock().NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				w.check()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// levelWatcher tracks the state of a control file watched by WatchLevelFile.
+type levelWatcher struct {
+	path      string
+	verbosity Level       // The levels to restore when the file is removed.
+	filter    []modulePat //
+	exists    bool        // The file existed at the last check.
+	modTime   time.Time   // Its modification time and size at the last check.
+	size      int64       //
+}
+
+// check applies the contents of the file if it has changed since the last
+// check.
+func (w *levelWatcher) check() {
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		if w.exists {
+			w.exists = false
+			w.apply(w.verbosity, w.filter)
+		}
+		return
+	}
+	if w.exists && fi.ModTime().Equal(w.modTime) && fi.Size() == w.size {
+		return
+	}
+	w.exists, w.modTime, w.size = true, fi.ModTime(), fi.Size()
+	data, err := os.ReadFile(w.path)
+	if err == nil {
+		var v Level
+		var filter []modulePat
+		v, filter, err = w.parse(string(data))
+		if err == nil {
+			w.apply(v, filter)
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "log: ignoring level file %s: %v\n", w.path, err)
+}
+
+// parse returns the levels set by the contents of a control file.
+func (w *levelWatcher) parse(data string) (Level, []modulePat, error) {
+	v, filter := w.verbosity, w.filter
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return 0, nil, fmt.Errorf("line %d: expected name=value", n+1)
+		}
+		name, value := strings.TrimSpace(line[:eq]), strings.TrimSpace(line[eq+1:])
+		switch name {
+		case "v":
+			i, err := strconv.Atoi(value)
+			if err != nil || i < 0 {
+				return 0, nil, fmt.Errorf("line %d: bad verbosity %q", n+1, value)
+			}
+			v = Level(i)
+		case "vmodule":
+			f, err := parseVModule(value)
+			if err != nil {
+				return 0, nil, fmt.Errorf("line %d: %v", n+1, err)
+			}
+			filter = f
+		default:
+			return 0, nil, fmt.Errorf("line %d: unknown setting %q", n+1, name)
+		}
+	}
+	return v, filter, nil
+}
+
+// apply installs the levels.
+func (w *levelWatcher) apply(v Level, filter []modulePat) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.setVState(v, filter, true)
+}
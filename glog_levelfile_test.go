@@ -0,0 +1,79 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond for up to two seconds.
+func waitFor(cond func() bool) bool {
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+// Test that changes to the level file are applied and reverted.
+func TestWatchLevelFile(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func() {
+		logging.mu.Lock()
+		logging.setVState(0, nil, true)
+		logging.mu.Unlock()
+	}()
+	path := filepath.Join(t.TempDir(), "glog.level")
+	write := func(data string, age time.Duration) {
+		// Replace the file by renaming so that the watcher never sees it
+		// half written.
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Make sure the change is seen even on coarse file system clocks.
+		mtime := time.Now().Add(-age)
+		os.Chtimes(tmp, mtime, mtime)
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("# debugging\nv=1\n", time.Hour)
+	stop := WatchLevelFile(path, 5*time.Millisecond)
+	defer stop()
+	if !V(1) || V(2) {
+		t.Fatal("initial level file not applied")
+	}
+	write("vmodule=glog_levelfile_test=3\n", time.Minute)
+	if !waitFor(func() bool { return bool(V(3)) }) {
+		t.Error("vmodule change not applied")
+	}
+	write("v=oops\n", time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if !V(3) {
+		t.Error("invalid level file applied")
+	}
+	os.Remove(path)
+	if !waitFor(func() bool { return !bool(V(1)) }) {
+		t.Error("levels not restored after removal")
+	}
+}
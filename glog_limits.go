@@ -0,0 +1,158 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Limits on the size of logged structs, maps and slices.
+
+package glog
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// maskMore is the key of the entry that replaces the entries of a map beyond
+// the -mask_max_elements limit, and the prefix of the element that replaces
+// those of a slice.
+const maskMore = "..."
+
+// moreMarker holds the markers that replace elements beyond the limit, which
+// are never cut themselves.
+type moreMarker string
+
+// SetMaskMaxElements limits the number of elements of the maps and slices
+// within logged values that are written. The remaining elements of a slice
+// are replaced by a single "...(N more)" element, and those of a map, whose
+// first keys in sorted order are kept, by a "..." key holding their number.
+// Zero, the default, removes the limit.
+func SetMaskMaxElements(n int) error {
+	if n < 0 {
+		return fmt.Errorf("log: negative element limit %d", n)
+	}
+	atomic.StoreInt32(&logging.maskMaxElements, int32(n))
+	return nil
+}
+
+// SetMaskMaxString limits the length in bytes of the strings within logged
+// structs, maps and slices. Longer strings are cut, at a character boundary,
+// and end with "...". Zero, the default, removes the limit.
+func SetMaskMaxString(n int) error {
+	if n < 0 {
+		return fmt.Errorf("log: negative string limit %d", n)
+	}
+	atomic.StoreInt32(&logging.maskMaxString, int32(n))
+	return nil
+}
+
+// limited reports whether any of the size limits is set, in which case
+// logged values go through transform even with the filters off.
+func (l *loggingT) limited() bool {
+	return atomic.LoadInt32(&l.maskMaxElements) > 0 || atomic.LoadInt32(&l.maskMaxString) > 0
+}
+
+// elements returns how many of n elements are written.
+func (st *maskState) elements(n int) int {
+	if st.maxElements > 0 && n > st.maxElements {
+		return st.maxElements
+	}
+	return n
+}
+
+// moreElements returns the element that replaces the n elements of a slice
+// beyond the limit.
+func moreElements(n int) moreMarker {
+	return moreMarker(maskMore + "(" + strconv.Itoa(n) + " more)")
+}
+
+// limitKeys returns the keys of a map that are written: all of them, or the
+// first in sorted order if there are too many.
+func (st *maskState) limitKeys(keys []reflect.Value) []reflect.Value {
+	n := st.elements(len(keys))
+	if n == len(keys) {
+		return keys
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys[:n]
+}
+
+// limitStrings returns v, as returned by transform, with its strings cut to
+// max bytes. Maps and slices are copied rather than modified, since some are
+// the logged values themselves.
+func limitStrings(v interface{}, max int) interface{} {
+	switch v := v.(type) {
+	case string:
+		if len(v) <= max {
+			return v
+		}
+		n := max
+		for n > 0 && !utf8.RuneStart(v[n]) {
+			n--
+		}
+		return v[:n] + maskMore
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			ret[key] = limitStrings(elem, max)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(v))
+		for i, elem := range v {
+			ret[i] = limitStrings(elem, max)
+		}
+		return ret
+	}
+	return v
+}
+
+// maskElementsValue implements flag.Value for the -mask_max_elements flag.
+type maskElementsValue struct{}
+
+// String is part of the flag.Value interface.
+func (maskElementsValue) String() string {
+	return strconv.Itoa(int(atomic.LoadInt32(&logging.maskMaxElements)))
+}
+
+// Set is part of the flag.Value interface.
+func (maskElementsValue) Set(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	return SetMaskMaxElements(n)
+}
+
+// maskStringValue implements flag.Value for the -mask_max_string flag.
+type maskStringValue struct{}
+
+// String is part of the flag.Value interface.
+func (maskStringValue) String() string {
+	return strconv.Itoa(int(atomic.LoadInt32(&logging.maskMaxString)))
+}
+
+// Set is part of the flag.Value interface.
+func (maskStringValue) Set(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	return SetMaskMaxString(n)
+}
@@ -0,0 +1,81 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"strings"
+	"testing"
+)
+
+type limitsTest struct {
+	Names []string
+	Notes string
+	Items []int
+	Attrs map[string]int
+}
+
+func TestMaskLimits(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetMaskMaxElements(0)
+	defer SetMaskMaxString(0)
+	defer logging.SetFilter(true, true, true, true, true, true, true)
+	// The limits apply with the filters off.
+	logging.SetFilter(false, false, false, false, false, false, false)
+	SetMaskMaxElements(2)
+	SetMaskMaxString(5)
+
+	Info(limitsTest{
+		Names: []string{"a", "b", "c"},
+		Notes: "héllo world",
+		Items: []int{1, 2, 3, 4},
+		Attrs: map[string]int{"x": 1, "y": 2, "z": 3},
+	})
+	Info(map[string][]string{"k": {"1", "2", "3"}})
+
+	got := contents(infoLog)
+	for _, want := range []string{
+		"Names:[a b ...(1 more)]",
+		"Notes:héll...",
+		"Items:[1 2 ...(2 more)]",
+		"Attrs:map[...:1 more x:1 y:2]",
+		"map[k:[1 2 ...(1 more)]]",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("log lacks %q: %q", want, got)
+		}
+	}
+}
+
+func TestMaskLimitsOff(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+
+	Info(limitsTest{Names: []string{"a", "b", "c"}, Notes: strings.Repeat("x", 100)})
+	if got := contents(infoLog); strings.Contains(got, maskMore) {
+		t.Errorf("value limited without limits: %q", got)
+	}
+}
+
+func TestSetMaskLimitsNegative(t *testing.T) {
+	if err := SetMaskMaxElements(-1); err == nil {
+		t.Error("SetMaskMaxElements(-1) succeeded")
+	}
+	if err := SetMaskMaxString(-1); err == nil {
+		t.Error("SetMaskMaxString(-1) succeeded")
+	}
+}
@@ -0,0 +1,138 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Choice and validation of the log directories.
+
+package glog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// LogDirMinFree is the free space, in bytes, that a directory of -log_dir
+// needs to receive log files. It is only checked on systems that report free
+// space, currently Linux and macOS.
+var LogDirMinFree uint64 = 16 << 20
+
+// ErrNoLogDir is reported to the OnLogDirError function, with the temporary
+// directory, when no directory of -log_dir is usable.
+var ErrNoLogDir = errors.New("log: no usable log directory; using the temporary directory")
+
+var (
+	logDirErrorMu sync.Mutex
+	logDirErrorFn func(dir string, err error)
+)
+
+// OnLogDirError arranges for fn to be called with each directory of -log_dir
+// that cannot receive log files and the reason, when the directories are
+// chosen on the creation of the first log file, and with ErrNoLogDir if log
+// files go to the temporary directory as a result. By default the problems
+// are reported on standard error; a nil fn restores that. fn is called with
+// logging blocked, so it must not log through this package.
+func OnLogDirError(fn func(dir string, err error)) {
+	logDirErrorMu.Lock()
+	defer logDirErrorMu.Unlock()
+	logDirErrorFn = fn
+}
+
+// reportLogDirError passes the problem with dir to the OnLogDirError function.
+func reportLogDirError(dir string, err error) {
+	logDirErrorMu.Lock()
+	fn := logDirErrorFn
+	logDirErrorMu.Unlock()
+	if fn == nil {
+		if err == ErrNoLogDir {
+			fmt.Fprintf(os.Stderr, "log: no usable log directory; writing log files to %s\n", dir)
+		} else {
+			fmt.Fprintf(os.Stderr, "log: cannot use log directory %s: %v\n", dir, err)
+		}
+		return
+	}
+	fn(dir, err)
+}
+
+// ValidateLogDirs checks that the directories of -log_dir exist, are writable
+// and have LogDirMinFree bytes free, and returns an error describing those
+// that do not. It is meant to be called at startup, to fail fast rather than
+// have log files go elsewhere; the directories are checked again when the
+// first log file is created.
+func ValidateLogDirs() error {
+	var problems []string
+	for _, dir := range splitLogDirs(*logDir) {
+		if err := checkLogDir(dir); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", dir, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("log: unusable log directories: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// splitLogDirs returns the directories of a -log_dir value, a comma-separated
+// list.
+func splitLogDirs(value string) []string {
+	var dirs []string
+	for _, dir := range strings.Split(value, ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// checkLogDir reports why dir cannot receive log files, if it cannot.
+func checkLogDir(dir string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return errors.New("not a directory")
+	}
+	f, err := os.CreateTemp(dir, ".glog-check-")
+	if err != nil {
+		return fmt.Errorf("not writable: %v", err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	if free, ok := freeSpace(dir); ok && free < LogDirMinFree {
+		return fmt.Errorf("%d bytes free, need %d", free, LogDirMinFree)
+	}
+	return nil
+}
+
+// createLogDirs sets logDirs to the usable directories of -log_dir, in
+// order, or to the temporary directory if there are none.
+func createLogDirs() {
+	dirs := splitLogDirs(*logDir)
+	for _, dir := range dirs {
+		if err := checkLogDir(dir); err != nil {
+			reportLogDirError(dir, err)
+			continue
+		}
+		logDirs = append(logDirs, dir)
+	}
+	if len(logDirs) == 0 {
+		if len(dirs) > 0 {
+			reportLogDirError(tempDir(), ErrNoLogDir)
+		}
+		logDirs = append(logDirs, tempDir())
+	}
+}
@@ -0,0 +1,86 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test that unusable directories of -log_dir are skipped and reported.
+func TestLogDirs(t *testing.T) {
+	defer func(dirs []string, value string) { logDirs, *logDir = dirs, value }(logDirs, *logDir)
+	defer OnLogDirError(nil)
+	type report struct {
+		dir string
+		err error
+	}
+	var reports []report
+	OnLogDirError(func(dir string, err error) {
+		reports = append(reports, report{dir, err})
+	})
+
+	tmp := t.TempDir()
+	missing := filepath.Join(tmp, "missing")
+	file := filepath.Join(tmp, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	good := filepath.Join(tmp, "good")
+	if err := os.Mkdir(good, 0755); err != nil {
+		t.Fatal(err)
+	}
+	*logDir = missing + ", " + file + "," + good
+	logDirs = nil
+	createLogDirs()
+	if want := []string{good}; !reflect.DeepEqual(logDirs, want) {
+		t.Errorf("log dirs %q, want %q", logDirs, want)
+	}
+	if len(reports) != 2 || reports[0].dir != missing || reports[1].dir != file {
+		t.Errorf("reports %v, want %s and %s", reports, missing, file)
+	}
+	err := ValidateLogDirs()
+	if err == nil || !strings.Contains(err.Error(), missing) || strings.Contains(err.Error(), good) {
+		t.Errorf("ValidateLogDirs() = %v, want an error about %s only", err, missing)
+	}
+
+	reports = nil
+	*logDir = missing
+	logDirs = nil
+	createLogDirs()
+	if want := []string{os.TempDir()}; !reflect.DeepEqual(logDirs, want) {
+		t.Errorf("log dirs %q, want %q", logDirs, want)
+	}
+	if len(reports) != 2 || reports[1].err != ErrNoLogDir {
+		t.Errorf("reports %v, want the missing directory and ErrNoLogDir", reports)
+	}
+
+	*logDir = good
+	if err := ValidateLogDirs(); err != nil {
+		t.Errorf("ValidateLogDirs() = %v", err)
+	}
+	if _, ok := freeSpace(good); ok {
+		defer func(n uint64) { LogDirMinFree = n }(LogDirMinFree)
+		LogDirMinFree = 1 << 62
+		if err := ValidateLogDirs(); err == nil || !strings.Contains(err.Error(), "free") {
+			t.Errorf("ValidateLogDirs() = %v, want an error about free space", err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The logfmt encoding of log lines.
+
+package glog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogfmtEncoder writes lines in logfmt, as read by Grafana Loki and other
+// log pipelines, with the fields and labels of each line after its message:
+//
+//	severity=WARNING ts=2026-10-16T12:00:00.123456+08:00 caller=pay.go:42 logger=payments msg="card declined" order=42
+//
+// Values are quoted when they are empty or hold spaces, quotes, equals
+// signs or control characters; characters of keys that would need quoting
+// are replaced with underscores.
+var LogfmtEncoder Encoder = logfmtEncoder{}
+
+type logfmtEncoder struct{}
+
+func (logfmtEncoder) Encode(r *Record, text []byte) []byte {
+	return logfmtEncoder{}.encodeRecord(recordOf(r))
+}
+
+func (logfmtEncoder) encodeRecord(r *logRecord) []byte {
+	buf := new(buffer)
+	buf.WriteString("severity=")
+	buf.WriteString(r.Severity)
+	buf.WriteString(" ts=")
+	buf.Write(r.Time.AppendFormat(buf.tmp[:0], time.RFC3339Nano))
+	if r.File != "" {
+		buf.WriteString(" caller=")
+		buf.WriteString(logfmtValue(r.File + ":" + strconv.Itoa(r.Line)))
+	}
+	if r.Logger != "" {
+		buf.WriteString(" logger=")
+		buf.WriteString(logfmtValue(r.Logger))
+	}
+	buf.WriteString(" msg=")
+	buf.WriteString(logfmtValue(withStack(r.Message, r.Stack)))
+	for _, f := range r.Fields {
+		buf.WriteByte(' ')
+		buf.WriteString(logfmtKey(f.Key))
+		buf.WriteByte('=')
+		switch f.kind {
+		case anyField:
+			buf.WriteString(logfmtValue(fmt.Sprint(f.Value)))
+		case stringField:
+			buf.WriteString(logfmtValue(f.str))
+		default:
+			buf.Write(f.appendValue(buf.tmp[:0]))
+		}
+	}
+	keys := make([]string, 0, len(r.Labels))
+	for k := range r.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteByte(' ')
+		buf.WriteString(logfmtKey(k))
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(r.Labels[k]))
+	}
+	if r.Truncated {
+		buf.WriteString(" truncated=true")
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// logfmtValue returns s, quoted if it is empty or holds characters that end
+// or confuse a value.
+func logfmtValue(s string) string {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool { return r <= ' ' || r == '=' || r == '"' || r == 0x7f }) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// logfmtKey returns key with the characters that would need quoting replaced
+// with underscores.
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, key)
+}
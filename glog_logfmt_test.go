@@ -0,0 +1,51 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// Test the logfmt encoding of lines and of records.
+func TestLogfmtEncoder(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var buf bytes.Buffer
+	defer AddEncodedWriter("INFO", LogfmtEncoder, &buf)()
+
+	Named("payments").WithFields("order", 42, "note", "two words", "bad key", "", "took", 1500*time.Millisecond).Warning(`card "x" declined`)
+	want := regexp.MustCompile(`^severity=WARNING ts=\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?(Z|[+-]\d\d:\d\d) caller=glog_logfmt_test.go:\d+ logger=payments ` +
+		`msg="card \\"x\\" declined" order=42 note="two words" bad_key="" took=1.5s\n$`)
+	if !want.Match(buf.Bytes()) {
+		t.Errorf("got %q", buf.String())
+	}
+
+	r := &Record{
+		Time:      time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+		Severity:  "ERROR",
+		Message:   "line one\nline two",
+		Labels:    map[string]string{"zone": "b", "app": "shop"},
+		Truncated: true,
+	}
+	const wantRecord = `severity=ERROR ts=2026-10-16T12:00:00Z msg="line one\nline two" app=shop zone=b truncated=true` + "\n"
+	if got := string(LogfmtEncoder.Encode(r, nil)); got != wantRecord {
+		t.Errorf("got %q, want %q", got, wantRecord)
+	}
+}
@@ -0,0 +1,105 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Per-call control of masking.
+
+package glog
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// maskMode selects how an Entry masks its lines.
+type maskMode uint8
+
+const (
+	maskDefault maskMode = iota // Mask as configured.
+	maskOff                     // Unmasked: no masking, if allowed.
+	maskForce                   // Masked: also scrub the rendered text.
+)
+
+// allowUnmasked is set if Unmasked may turn masking off: by SetAllowUnmasked,
+// or in programs built with the glog_unmasked tag. Accessed atomically.
+var allowUnmasked uint32
+
+// unmaskedBuild is set in programs built with the glog_unmasked tag.
+var unmaskedBuild bool
+
+// SetAllowUnmasked controls whether Unmasked turns masking off. It is meant
+// for development and test environments; in production, leave it off, its
+// default, and lines logged through Unmasked are masked as usual. Building
+// with the glog_unmasked tag turns it on from the start, and Init leaves it
+// on.
+func SetAllowUnmasked(on bool) {
+	var v uint32
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&allowUnmasked, v)
+}
+
+// unmaskedAllowed reports whether Unmasked turns masking off.
+func unmaskedAllowed() bool {
+	return atomic.LoadUint32(&allowUnmasked) != 0
+}
+
+// Unmasked returns an Entry whose lines are not masked, for debugging:
+//
+//	glog.Unmasked().Info("raw request: ", req)
+//
+// It only takes effect where allowed by SetAllowUnmasked or in programs built
+// with the glog_unmasked tag, which is meant for development builds only;
+// elsewhere, lines are masked as usual.
+func Unmasked() *Entry {
+	return &Entry{mask: maskOff}
+}
+
+// Masked returns an Entry whose lines go through the whole masking pipeline,
+// for high-assurance paths: besides the usual masking of logged values, the
+// rendered message and field values are scrubbed as by SetMaskRendered, which
+// also catches sensitive values inside plain strings, such as
+//
+//	glog.Masked().Infof("sent code to %s", phone)
+func Masked() *Entry {
+	return &Entry{mask: maskForce}
+}
+
+// Unmasked returns a copy of e whose lines are not masked; see the global
+// Unmasked.
+func (e *Entry) Unmasked() *Entry {
+	return &Entry{name: e.name, fields: e.fields, mask: maskOff}
+}
+
+// Masked returns a copy of e whose lines go through the whole masking
+// pipeline; see the global Masked.
+func (e *Entry) Masked() *Entry {
+	return &Entry{name: e.name, fields: e.fields, mask: maskForce}
+}
+
+// scrubFields returns fields with their rendered values scrubbed. Values that
+// scrubbing changes are replaced by the scrubbed string.
+func (l *loggingT) scrubFields(fields []Field) []Field {
+	scrubbed := make([]Field, len(fields))
+	for i, f := range fields {
+		scrubbed[i] = f
+		s := fmt.Sprint(f.value())
+		if masked := l.scrub(s); masked != s {
+			scrubbed[i] = Field{Key: f.Key, Value: masked}
+		}
+	}
+	return scrubbed
+}
@@ -0,0 +1,61 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnmasked(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	m := map[string]interface{}{"mobile": "13812345678"}
+
+	// Without permission, Unmasked lines are masked.
+	defer SetAllowUnmasked(unmaskedBuild)
+	SetAllowUnmasked(false)
+	Unmasked().Info("raw ", m)
+	if contains(infoLog, "13812345678", t) || !contains(infoLog, "138****5678", t) {
+		t.Errorf("unmasked without permission: %q", contents(infoLog))
+	}
+
+	SetAllowUnmasked(true)
+	Unmasked().WithFields("phone", "13912345678").Info("raw ", m)
+	if !contains(infoLog, "raw map[mobile:13812345678] phone=13912345678", t) {
+		t.Errorf("masked with permission: %q", contents(infoLog))
+	}
+}
+
+func TestMasked(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	Masked().WithFields("note", "email zhangsan@example.com").Infof("sent code to %s", "13812345678")
+	got := contents(infoLog)
+	if strings.Contains(got, "13812345678") || strings.Contains(got, "zhangsan@") {
+		t.Errorf("not masked: %q", got)
+	}
+	if !strings.Contains(got, "sent code to 138****5678") {
+		t.Errorf("got %q", got)
+	}
+
+	// Plain strings are not scrubbed by default.
+	Infof("sent code to %s", "13912345678")
+	if !contains(infoLog, "13912345678", t) {
+		t.Errorf("scrubbed by default: %q", contents(infoLog))
+	}
+}
@@ -0,0 +1,103 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Rendering of masked values as JSON.
+
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+)
+
+// maskJSON is set by SetMaskJSON. Accessed atomically.
+var maskJSON uint32
+
+// SetMaskJSON controls how masked structs, maps and slices are written. By
+// default they are written as fmt writes the masked copy, as in
+// map[CardNo:6222****0123 Name:Aline]; when on, they are written as compact
+// JSON, with map keys and struct fields sorted, as in
+// {"CardNo":"6222****0123","Name":"Aline"}, which tools can parse from the
+// message, or from field values, of text and JSON records alike. Values that
+// cannot be marshaled are written as by fmt. It is off by default.
+func SetMaskJSON(on bool) {
+	var v uint32
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&maskJSON, v)
+}
+
+// maskedJSON is a masked value rendered as JSON. Format writes it as is for
+// every verb; unlike a string, it keeps the spaces that Print adds between
+// operands that are not strings.
+type maskedJSON []byte
+
+// Format is part of the fmt.Formatter interface.
+func (j maskedJSON) Format(f fmt.State, _ rune) {
+	f.Write(j)
+}
+
+// MarshalJSON is part of the json.Marshaler interface.
+func (j maskedJSON) MarshalJSON() ([]byte, error) {
+	return j, nil
+}
+
+// asMaskedJSON returns v, a value masked by transform, rendered as JSON if
+// SetMaskJSON is on and v is a struct, map, slice or array. Otherwise, or if v
+// cannot be marshaled, it returns v.
+func asMaskedJSON(v interface{}) interface{} {
+	if atomic.LoadUint32(&maskJSON) == 0 || v == nil {
+		return v
+	}
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+	default:
+		return v
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return v
+	}
+	return maskedJSON(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+}
+
+// maskJSONValue implements flag.Value for the -mask_json flag.
+type maskJSONValue struct{}
+
+// String is part of the flag.Value interface.
+func (maskJSONValue) String() string {
+	return strconv.FormatBool(atomic.LoadUint32(&maskJSON) != 0)
+}
+
+// Set is part of the flag.Value interface.
+func (maskJSONValue) Set(value string) error {
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	SetMaskJSON(on)
+	return nil
+}
+
+// IsBoolFlag lets the flag be given without a value.
+func (maskJSONValue) IsBoolFlag() bool { return true }
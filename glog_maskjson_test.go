@@ -0,0 +1,83 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type maskJSONTest struct {
+	Name   string
+	CardNo string `filter:"card"`
+	Tags   []string
+}
+
+func TestMaskJSON(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetMaskJSON(false)
+	SetMaskJSON(true)
+
+	v := maskJSONTest{Name: "<Aline>", CardNo: "6222021234567890123", Tags: []string{"a"}}
+	card := ShrineAlipayAccountNumber(v.CardNo)
+	Info(v, v)
+	Infof("order %v", map[string]string{"mobile": "13812345678"})
+
+	want := `] {"CardNo":"` + card + `","Name":"<Aline>","Tags":["a"]} {"CardNo":"` + card + `","Name":"<Aline>","Tags":["a"]}` + "\n"
+	if !contains(infoLog, want, t) {
+		t.Errorf("got %q, want it to contain %q", contents(infoLog), want)
+	}
+	if want := `] order {"mobile":"138****5678"}`; !contains(infoLog, want, t) {
+		t.Errorf("got %q, want it to contain %q", contents(infoLog), want)
+	}
+}
+
+func TestMaskJSONFields(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetMaskJSON(false)
+	SetMaskJSON(true)
+	var buf bytes.Buffer
+	remove := AddJSONWriter("INFO", &buf)
+	defer remove()
+
+	InfoFields("paid", Any("user", map[string]string{"mobile": "13812345678"}))
+
+	if want := `paid user="{\"mobile\":\"138****5678\"}"`; !contains(infoLog, want, t) {
+		t.Errorf("got %q, want it to contain %q", contents(infoLog), want)
+	}
+	rec, err := ParseRecord(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, ok := rec.Fields["user"].(map[string]interface{})
+	if !ok || user["mobile"] != "138****5678" {
+		t.Errorf("record field user = %#v, want a JSON object", rec.Fields["user"])
+	}
+}
+
+func TestMaskJSONOff(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+
+	Info(map[string]string{"mobile": "13812345678"})
+	if got := contents(infoLog); !strings.Contains(got, "map[mobile:138****5678]") {
+		t.Errorf("got %q, want the fmt format", got)
+	}
+}
@@ -0,0 +1,291 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Named loggers with their own V levels.
+
+package glog
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Logger writes to the same logs as the package-level functions, adding its
+// name to each line after the header:
+//
+//	I0102 15:04:05.000000   1234 pay.go:42] [payments.gateway] charged
+//
+// Names are hierarchical, with dots separating the levels. The V level of a
+// logger is set by name with the -vlogger flag or SetLoggerVerbosity, and a
+// setting for a logger also applies to its descendants. Loggers are cheap and
+// safe for concurrent use.
+type Logger struct {
+	name string
+}
+
+// Named returns the logger with the given name, such as "payments.gateway".
+func Named(name string) *Logger {
+	return &Logger{name: name}
+}
+
+// Named returns a child of lg: its name is lg's name followed by a dot and
+// name.
+func (lg *Logger) Named(name string) *Logger {
+	if lg.name == "" {
+		return Named(name)
+	}
+	return &Logger{name: lg.name + "." + name}
+}
+
+// Name returns the name of lg.
+func (lg *Logger) Name() string {
+	return lg.name
+}
+
+// loggerLevels holds the V levels of named loggers set by -vlogger.
+var loggerLevels struct {
+	sync.RWMutex
+	filter []modulePat
+	cache  map[string]Level // Levels resolved so far, by logger name.
+}
+
+// maxLoggerCache bounds loggerLevels.cache; it is emptied when full.
+const maxLoggerCache = 4096
+
+// numLoggerPats is the length of loggerLevels.filter. It may be read safely
+// using atomic.LoadInt32 to skip the lock when no levels are set.
+var numLoggerPats int32
+
+// SetLoggerVerbosity sets the V levels of named loggers, replacing earlier
+// settings. The syntax is that of the -vlogger flag: a comma-separated list of
+// name=N, where name is a logger name or "glob" pattern, for instance
+// "payments.*=3,auth=1". Wildcards do not match dots. A logger takes the level
+// of the first setting that matches its name or, failing that, the name of its
+// nearest ancestor, so "payments.*=3,payments.gateway.noisy=0" silences one
+// branch of the tree. The effective V level of a logger is the larger of that
+// and -v.
+//
+// The levels of the names looked up are cached until the next call. The cache
+// holds a few thousand names and is emptied when full, so names built from
+// request data cost matching time rather than unbounded memory.
+func SetLoggerVerbosity(spec string) error {
+	filter, err := parsePatterns(spec, true)
+	if err != nil {
+		return err
+	}
+	setLoggerFilter(filter)
+	return nil
+}
+
+// setLoggerFilter installs the V levels of named loggers.
+func setLoggerFilter(filter []modulePat) {
+	loggerLevels.Lock()
+	defer loggerLevels.Unlock()
+	loggerLevels.filter = filter
+	loggerLevels.cache = make(map[string]Level)
+	atomic.StoreInt32(&numLoggerPats, int32(len(filter)))
+}
+
+// loggerLevel returns the V level set for the logger with the given name.
+func loggerLevel(name string) Level {
+	loggerLevels.RLock()
+	v, ok := loggerLevels.cache[name]
+	loggerLevels.RUnlock()
+	if ok {
+		return v
+	}
+	loggerLevels.Lock()
+	defer loggerLevels.Unlock()
+	for n := name; ; {
+		for _, f := range loggerLevels.filter {
+			if matchLoggerName(&f, n) {
+				cacheLoggerLevel(name, f.level)
+				return f.level
+			}
+		}
+		dot := strings.LastIndex(n, ".")
+		if dot < 0 {
+			break
+		}
+		n = n[:dot]
+	}
+	cacheLoggerLevel(name, 0)
+	return 0
+}
+
+// cacheLoggerLevel records the level of the named logger, emptying the cache
+// first if it is full.
+// loggerLevels is locked.
+func cacheLoggerLevel(name string, v Level) {
+	if len(loggerLevels.cache) >= maxLoggerCache {
+		loggerLevels.cache = make(map[string]Level)
+	}
+	loggerLevels.cache[name] = v
+}
+
+// matchLoggerName reports whether the logger name matches the pattern of f.
+// Wildcards do not match dots, so that "payments.*" matches the children of
+// "payments" and reaches further descendants only through them.
+func matchLoggerName(f *modulePat, name string) bool {
+	if f.literal {
+		return name == f.pattern
+	}
+	match, _ := path.Match(strings.Replace(f.pattern, ".", "/", -1), strings.Replace(name, ".", "/", -1))
+	return match
+}
+
+// loggerSpecValue implements flag.Value for the -vlogger flag.
+type loggerSpecValue struct{}
+
+// String is part of the flag.Value interface.
+func (loggerSpecValue) String() string {
+	loggerLevels.RLock()
+	defer loggerLevels.RUnlock()
+	var b bytes.Buffer
+	for i, f := range loggerLevels.filter {
+		if i > 0 {
+			b.WriteRune(',')
+		}
+		fmt.Fprintf(&b, "%s=%d", f.pattern, f.level)
+	}
+	return b.String()
+}
+
+// Set is part of the flag.Value interface.
+func (loggerSpecValue) Set(value string) error {
+	return SetLoggerVerbosity(value)
+}
+
+// LoggerVerbose is the result of Logger.V. Its methods log if the V level of
+// the logger is at least the requested level, or if the V threshold of an
+// output is (see SetVThreshold).
+type LoggerVerbose struct {
+	lg *Logger
+	on bool
+	v  vLine
+}
+
+// V reports whether the V level of lg is at least level. As with the global
+// V, either
+//
+//	if lg.V(2).Enabled() { lg.Info("log this") }
+//
+// or
+//
+//	lg.V(2).Info("log this")
+//
+// may be written.
+func (lg *Logger) V(level Level) LoggerVerbose {
+	gated := logging.verbosity.get() >= level
+	if !gated && atomic.LoadInt32(&numLoggerPats) > 0 {
+		gated = loggerLevel(lg.name) >= level
+	}
+	on := gated || int32(level) < atomic.LoadInt32(&logging.maxV)
+	return LoggerVerbose{lg, on, vLine{level: level, gated: gated}}
+}
+
+// Enabled reports whether v logs.
+func (v LoggerVerbose) Enabled() bool {
+	return v.on
+}
+
+// Info is equivalent to lg.Info, guarded by the value of v.
+func (v LoggerVerbose) Info(args ...interface{}) {
+	if v.on {
+		logging.printEntryV(0, infoLog, v.v, v.lg.name, nil, maskDefault, tprint, "", args)
+	}
+}
+
+// Infoln is equivalent to lg.Infoln, guarded by the value of v.
+func (v LoggerVerbose) Infoln(args ...interface{}) {
+	if v.on {
+		logging.printEntryV(0, infoLog, v.v, v.lg.name, nil, maskDefault, tprintln, "", args)
+	}
+}
+
+// Infof is equivalent to lg.Infof, guarded by the value of v.
+func (v LoggerVerbose) Infof(format string, args ...interface{}) {
+	if v.on {
+		logging.printEntryV(0, infoLog, v.v, v.lg.name, nil, maskDefault, tprintf, format, args)
+	}
+}
+
+// Info logs to the INFO log, like the global Info.
+func (lg *Logger) Info(args ...interface{}) {
+	logging.printEntry(infoLog, lg.name, nil, maskDefault, tprint, "", args)
+}
+
+// Infoln logs to the INFO log, like the global Infoln.
+func (lg *Logger) Infoln(args ...interface{}) {
+	logging.printEntry(infoLog, lg.name, nil, maskDefault, tprintln, "", args)
+}
+
+// Infof logs to the INFO log, like the global Infof.
+func (lg *Logger) Infof(format string, args ...interface{}) {
+	logging.printEntry(infoLog, lg.name, nil, maskDefault, tprintf, format, args)
+}
+
+// Warning logs to the WARNING and INFO logs, like the global Warning.
+func (lg *Logger) Warning(args ...interface{}) {
+	logging.printEntry(warningLog, lg.name, nil, maskDefault, tprint, "", args)
+}
+
+// Warningln logs to the WARNING and INFO logs, like the global Warningln.
+func (lg *Logger) Warningln(args ...interface{}) {
+	logging.printEntry(warningLog, lg.name, nil, maskDefault, tprintln, "", args)
+}
+
+// Warningf logs to the WARNING and INFO logs, like the global Warningf.
+func (lg *Logger) Warningf(format string, args ...interface{}) {
+	logging.printEntry(warningLog, lg.name, nil, maskDefault, tprintf, format, args)
+}
+
+// Error logs to the ERROR, WARNING, and INFO logs, like the global Error.
+func (lg *Logger) Error(args ...interface{}) {
+	logging.printEntry(errorLog, lg.name, nil, maskDefault, tprint, "", args)
+}
+
+// Errorln logs to the ERROR, WARNING, and INFO logs, like the global Errorln.
+func (lg *Logger) Errorln(args ...interface{}) {
+	logging.printEntry(errorLog, lg.name, nil, maskDefault, tprintln, "", args)
+}
+
+// Errorf logs to the ERROR, WARNING, and INFO logs, like the global Errorf.
+func (lg *Logger) Errorf(format string, args ...interface{}) {
+	logging.printEntry(errorLog, lg.name, nil, maskDefault, tprintf, format, args)
+}
+
+// Fatal logs to the FATAL, ERROR, WARNING, and INFO logs and exits, like the
+// global Fatal.
+func (lg *Logger) Fatal(args ...interface{}) {
+	logging.printEntry(fatalLog, lg.name, nil, maskDefault, tprint, "", args)
+}
+
+// Fatalln logs to the FATAL, ERROR, WARNING, and INFO logs and exits, like the
+// global Fatalln.
+func (lg *Logger) Fatalln(args ...interface{}) {
+	logging.printEntry(fatalLog, lg.name, nil, maskDefault, tprintln, "", args)
+}
+
+// Fatalf logs to the FATAL, ERROR, WARNING, and INFO logs and exits, like the
+// global Fatalf.
+func (lg *Logger) Fatalf(format string, args ...interface{}) {
+	logging.printEntry(fatalLog, lg.name, nil, maskDefault, tprintf, format, args)
+}
@@ -0,0 +1,101 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Test that a named logger writes its name after the header.
+func TestNamedHeader(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	Named("payments").Named("gateway").Warningf("charged %d", 42)
+	line := contents(infoLog)
+	if !strings.HasPrefix(line, "W") || !strings.Contains(line, "glog_named_test.go:") {
+		t.Errorf("bad header: %q", line)
+	}
+	if !strings.HasSuffix(line, "] [payments.gateway] charged 42\n") {
+		t.Errorf("name missing: %q", line)
+	}
+	r := newLogRecord(warningLog, &buffer{hdrLen: strings.Index(line, "charged"), name: "payments.gateway"}, "f.go", 1, []byte(line))
+	if r.Logger != "payments.gateway" || r.Message != "charged 42" {
+		t.Errorf("bad record: %+v", r)
+	}
+}
+
+// Test that V levels set by name apply to descendants, most specific first.
+func TestLoggerVerbosity(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetLoggerVerbosity("")
+	if err := SetLoggerVerbosity("payments.*=3,payments.gateway.noisy=0,auth=1"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		level Level
+	}{
+		{"payments", 0},
+		{"payments.gateway", 3},
+		{"payments.gateway.stripe", 3},
+		{"payments.gateway.noisy", 0},
+		{"payments.gateway.noisy.deeper", 0},
+		{"auth", 1},
+		{"auth.oauth", 1},
+		{"authz", 0},
+	}
+	for _, test := range tests {
+		lg := Named(test.name)
+		if !lg.V(test.level).Enabled() || lg.V(test.level+1).Enabled() {
+			t.Errorf("%s: V level is not %d", test.name, test.level)
+		}
+	}
+	Named("payments.gateway").V(3).Info("verbose")
+	Named("payments").V(3).Info("quiet")
+	if !contains(infoLog, "[payments.gateway] verbose", t) || contains(infoLog, "quiet", t) {
+		t.Errorf("V logging not applied: %q", contents(infoLog))
+	}
+	if err := SetLoggerVerbosity("payments=x"); err == nil {
+		t.Error("bad spec accepted")
+	}
+}
+
+// Test that the cache of logger levels stays bounded with dynamic names.
+func TestLoggerLevelCache(t *testing.T) {
+	defer SetLoggerVerbosity("")
+	if err := SetLoggerVerbosity("req.*=2"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3*maxLoggerCache; i++ {
+		if v := loggerLevel(fmt.Sprint("req.", i)); v != 2 {
+			t.Fatalf("level of req.%d is %d, want 2", i, v)
+		}
+	}
+	loggerLevels.RLock()
+	n := len(loggerLevels.cache)
+	loggerLevels.RUnlock()
+	if n > maxLoggerCache {
+		t.Errorf("cache holds %d names, want at most %d", n, maxLoggerCache)
+	}
+	SetLoggerVerbosity("req.*=1")
+	if v := loggerLevel("req.1"); v != 1 {
+		t.Errorf("level after SetLoggerVerbosity is %d, want 1", v)
+	}
+}
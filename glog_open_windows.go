@@ -0,0 +1,76 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build windows
+// +build windows
+
+package glog
+
+import (
+	"os"
+	"syscall"
+)
+
+// openLogFile opens a log file like os.OpenFile, but shares it for deletion
+// as well as reading and writing, so that other processes tailing it do not
+// stop it from being rotated, pruned or renamed by external tools, and the
+// file being written does not stop them either.
+func openLogFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	path, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	var access uint32 = syscall.GENERIC_WRITE
+	if flag&os.O_RDWR != 0 {
+		access |= syscall.GENERIC_READ
+	}
+	if flag&os.O_APPEND != 0 {
+		access &^= syscall.GENERIC_WRITE
+		access |= syscall.FILE_APPEND_DATA
+	}
+	var mode uint32
+	switch {
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		mode = syscall.CREATE_NEW
+	case flag&(os.O_CREATE|os.O_TRUNC) == os.O_CREATE|os.O_TRUNC:
+		mode = syscall.CREATE_ALWAYS
+	case flag&os.O_CREATE != 0:
+		mode = syscall.OPEN_ALWAYS
+	case flag&os.O_TRUNC != 0:
+		mode = syscall.TRUNCATE_EXISTING
+	default:
+		mode = syscall.OPEN_EXISTING
+	}
+	attrs := uint32(syscall.FILE_ATTRIBUTE_NORMAL)
+	if perm&0200 == 0 {
+		attrs = syscall.FILE_ATTRIBUTE_READONLY
+	}
+	share := uint32(syscall.FILE_SHARE_READ | syscall.FILE_SHARE_WRITE | syscall.FILE_SHARE_DELETE)
+	h, err := syscall.CreateFile(path, access, share, nil, mode, attrs, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return os.NewFile(uintptr(h), name), nil
+}
+
+// tempDir returns the default directory for log files: %TEMP%, if set, as
+// the documented home of temporary files of the user, or else the directory
+// of os.TempDir, which prefers %TMP%.
+func tempDir() string {
+	if dir := os.Getenv("TEMP"); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
@@ -0,0 +1,64 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//go:build windows
+// +build windows
+
+package glog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that log files can be renamed and deleted while open, as by external
+// rotation tools, and opened by readers that tail them.
+func TestOpenLogFileShared(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "INFO")
+	f, err := openLogFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := openLogFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666); !os.IsExist(err) {
+		t.Errorf("exclusive open of an existing file: %v", err)
+	}
+	if _, err := f.WriteString("line\n"); err != nil {
+		t.Fatal(err)
+	}
+	tail, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open for tailing: %v", err)
+	}
+	defer tail.Close()
+	renamed := filepath.Join(dir, "INFO.1")
+	if err := os.Rename(path, renamed); err != nil {
+		t.Errorf("rename while open: %v", err)
+	}
+	if err := os.Remove(renamed); err != nil {
+		t.Errorf("remove while open: %v", err)
+	}
+}
+
+// Test that log files go to %TEMP% by default.
+func TestTempDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TEMP", dir)
+	if got := tempDir(); got != dir {
+		t.Errorf("tempDir() = %q, want %q", got, dir)
+	}
+}
@@ -0,0 +1,273 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Length-prefixed protocol buffer records.
+
+package glog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// AddProtoWriter is like AddJSONWriter, but each line is passed to w as a
+// Record message of glog.proto, in the protocol buffer binary format,
+// preceded by its length as a varint. It is more compact and cheaper to
+// produce than JSON, for shipping high volumes of logs between machines.
+// NewProtoReader reads the stream back.
+func AddProtoWriter(name string, w io.Writer) (remove func()) {
+	return AddEncodedWriter(name, ProtoEncoder, w)
+}
+
+// Protocol buffer wire types.
+const (
+	wireVarint = 0
+	wire64     = 1
+	wireBytes  = 2
+	wire32     = 5
+)
+
+// appendTag appends the key of field number n of wire type t.
+func appendTag(b []byte, n, t int) []byte {
+	return binary.AppendUvarint(b, uint64(n<<3|t))
+}
+
+// appendVarintField appends field n holding v, unless v is zero.
+func appendVarintField(b []byte, n int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.AppendUvarint(appendTag(b, n, wireVarint), v)
+}
+
+// appendBytesField appends field n holding s, unless s is empty.
+func appendBytesField(b []byte, n int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = binary.AppendUvarint(appendTag(b, n, wireBytes), uint64(len(s)))
+	return append(b, s...)
+}
+
+// encodeProto returns r as a Record message.
+func (r *logRecord) encodeProto() []byte {
+	var b []byte
+	b = appendVarintField(b, 1, uint64(r.SchemaVersion))
+	if !r.Time.IsZero() {
+		b = appendVarintField(b, 2, uint64(r.Time.UnixNano()))
+	}
+	if sev, ok := severityByName(r.Severity); ok {
+		b = appendVarintField(b, 3, uint64(sev))
+	}
+	b = appendBytesField(b, 4, r.Host)
+	b = appendVarintField(b, 5, uint64(int64(r.PID)))
+	b = appendBytesField(b, 6, r.File)
+	b = appendVarintField(b, 7, uint64(int64(r.Line)))
+	b = appendBytesField(b, 8, r.Logger)
+	b = appendBytesField(b, 9, withStack(r.Message, r.Stack))
+	for _, f := range r.Fields {
+		var m []byte
+		m = appendBytesField(m, 1, f.Key)
+		m = appendBytesField(m, 2, string(valueJSON(f)))
+		b = appendBytesField(b, 10, string(m))
+	}
+	keys := make([]string, 0, len(r.Labels))
+	for k := range r.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var m []byte
+		m = appendBytesField(m, 1, k)
+		m = appendBytesField(m, 2, r.Labels[k])
+		b = appendBytesField(b, 11, string(m))
+	}
+	if r.Truncated {
+		b = appendVarintField(b, 12, 1)
+	}
+	return b
+}
+
+// A ProtoReader reads the records written by AddProtoWriter.
+type ProtoReader struct {
+	r *bufio.Reader
+}
+
+// NewProtoReader returns a ProtoReader reading from r.
+func NewProtoReader(r io.Reader) *ProtoReader {
+	return &ProtoReader{r: bufio.NewReader(r)}
+}
+
+// maxProtoRecord bounds the length of the records read, against corrupt
+// length prefixes.
+const maxProtoRecord = 64 << 20
+
+// Next returns the next record, or io.EOF at the end of the stream. A stream
+// ending within a record returns io.ErrUnexpectedEOF.
+func (pr *ProtoReader) Next() (*Record, error) {
+	n, err := binary.ReadUvarint(pr.r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxProtoRecord {
+		return nil, fmt.Errorf("log: record of %d bytes", n)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(pr.r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return parseProto(msg)
+}
+
+var errBadProto = errors.New("log: malformed protocol buffer record")
+
+// protoField is a field of a message, as read by nextProtoField.
+type protoField struct {
+	num   int
+	value uint64 // For varint fields.
+	bytes []byte // For length-delimited fields.
+}
+
+// nextProtoField reads the field at the start of b and returns it with the
+// rest of b. Fixed-width fields are skipped over.
+func nextProtoField(b []byte) (protoField, []byte, error) {
+	key, n := binary.Uvarint(b)
+	if n <= 0 {
+		return protoField{}, nil, errBadProto
+	}
+	b = b[n:]
+	f := protoField{num: int(key >> 3)}
+	switch key & 7 {
+	case wireVarint:
+		if f.value, n = binary.Uvarint(b); n <= 0 {
+			return f, nil, errBadProto
+		}
+		return f, b[n:], nil
+	case wireBytes:
+		size, n := binary.Uvarint(b)
+		if n <= 0 || size > uint64(len(b)-n) {
+			return f, nil, errBadProto
+		}
+		f.bytes = b[n : n+int(size)]
+		return f, b[n+int(size):], nil
+	case wire64:
+		if len(b) < 8 {
+			return f, nil, errBadProto
+		}
+		return f, b[8:], nil
+	case wire32:
+		if len(b) < 4 {
+			return f, nil, errBadProto
+		}
+		return f, b[4:], nil
+	}
+	return f, nil, errBadProto
+}
+
+// parseKeyValue parses a Field message or a map entry.
+func parseKeyValue(b []byte) (key, value string, err error) {
+	for len(b) > 0 {
+		var f protoField
+		if f, b, err = nextProtoField(b); err != nil {
+			return "", "", err
+		}
+		switch f.num {
+		case 1:
+			key = string(f.bytes)
+		case 2:
+			value = string(f.bytes)
+		}
+	}
+	return key, value, nil
+}
+
+// parseProto parses a Record message. Unknown fields are ignored.
+func parseProto(b []byte) (*Record, error) {
+	r := new(Record)
+	for len(b) > 0 {
+		f, rest, err := nextProtoField(b)
+		if err != nil {
+			return nil, err
+		}
+		b = rest
+		switch f.num {
+		case 1:
+			r.SchemaVersion = int(f.value)
+		case 2:
+			r.Time = time.Unix(0, int64(f.value))
+		case 3:
+			if f.value >= uint64(numSeverity) {
+				return nil, fmt.Errorf("log: bad severity %d in record", f.value)
+			}
+			r.Severity = severityName[f.value]
+		case 4:
+			r.Host = string(f.bytes)
+		case 5:
+			r.PID = int(int32(f.value))
+		case 6:
+			r.File = string(f.bytes)
+		case 7:
+			r.Line = int(int32(f.value))
+		case 8:
+			r.Logger = string(f.bytes)
+		case 9:
+			r.Message = string(f.bytes)
+		case 10:
+			key, value, err := parseKeyValue(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			if r.Fields == nil {
+				r.Fields = make(map[string]interface{})
+			}
+			var v interface{}
+			if err := json.Unmarshal([]byte(value), &v); err != nil {
+				v = value
+			}
+			r.Fields[key] = v
+		case 11:
+			key, value, err := parseKeyValue(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			if r.Labels == nil {
+				r.Labels = make(map[string]string)
+			}
+			r.Labels[key] = value
+		case 12:
+			r.Truncated = f.value != 0
+		}
+	}
+	if r.Severity == "" {
+		r.Severity = severityName[infoLog]
+	}
+	if r.SchemaVersion == 0 {
+		r.SchemaVersion = 1
+	}
+	if r.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("log: record schema version %d is newer than %d", r.SchemaVersion, SchemaVersion)
+	}
+	return r, nil
+}
@@ -0,0 +1,64 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// Test that records written by AddProtoWriter are read back by ProtoReader
+// and converted to the text of the log files.
+func TestProtoWriter(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var buf bytes.Buffer
+	remove := AddProtoWriter("INFO", &buf)
+	defer remove()
+
+	Named("payments").WithFields("order", 42).Warning("declined")
+	Info("second")
+	want := contents(infoLog)
+
+	pr := NewProtoReader(&buf)
+	r, err := pr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SchemaVersion != SchemaVersion || r.Severity != "WARNING" || r.Logger != "payments" || r.Message != "declined" ||
+		r.File != "glog_proto_test.go" || r.Fields["order"] != float64(42) || r.PID != pid {
+		t.Errorf("got %+v", r)
+	}
+	text := r.Text()
+	r, err = pr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text += r.Text(); text != want {
+		t.Errorf("got text %q, want %q", text, want)
+	}
+	if _, err := pr.Next(); err != io.EOF {
+		t.Errorf("got %v at the end, want EOF", err)
+	}
+
+	for _, bad := range [][]byte{{5, 0x0a}, {2, 0x18, 9}, {1, 0x07}} {
+		if r, err := NewProtoReader(bytes.NewReader(bad)).Next(); err == nil {
+			t.Errorf("% x accepted: %+v", bad, r)
+		}
+	}
+}
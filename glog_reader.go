@@ -0,0 +1,82 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Logging of large payloads in parts.
+
+package glog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"unicode/utf8"
+)
+
+// readerPart is the size in bytes of the parts logged by InfoReader.
+const readerPart = 4096
+
+// InfoReader logs the payload read from r, such as a response body, to the
+// INFO log without holding it whole in memory: it is logged in parts of up
+// to 4KB, each a line made of header, ": " and the part, with the fields
+// "payload", an ID shared by the parts of the payload, and "part", their
+// number from 1. The last part, which is empty if the payload fills the
+// others exactly, also has the field "last". Parts end between UTF-8 runes,
+// and are masked and truncated like other lines.
+//
+//	defer resp.Body.Close()
+//	err := glog.InfoReader("GET "+url+" response", resp.Body)
+//
+// InfoReader returns the error of r, other than io.EOF, after logging what
+// was read before it.
+func InfoReader(header string, r io.Reader) error {
+	var id [8]byte
+	if _, err := io.ReadFull(rand.Reader, id[:]); err != nil {
+		return err
+	}
+	payload := String("payload", hex.EncodeToString(id[:]))
+	buf := make([]byte, readerPart+utf8.UTFMax)
+	have, part := 0, 1
+	for {
+		n, err := io.ReadFull(r, buf[have:readerPart])
+		have += n
+		last := err != nil
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = nil
+		}
+		// Keep an incomplete rune at the end for the next part.
+		cut := have
+		if !last {
+			for i := have - 1; i >= 0 && i >= have-utf8.UTFMax; i-- {
+				if utf8.RuneStart(buf[i]) {
+					if !utf8.FullRune(buf[i:have]) {
+						cut = i
+					}
+					break
+				}
+			}
+		}
+		fields := []Field{payload, Int("part", part)}
+		if last {
+			fields = append(fields, Bool("last", true))
+		}
+		logging.printEntryV(0, infoLog, vLine{}, "", fields, maskDefault, tprint, "", []interface{}{header, ": ", string(buf[:cut])})
+		if last {
+			return err
+		}
+		have = copy(buf, buf[cut:have])
+		part++
+	}
+}
@@ -0,0 +1,79 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestInfoReader(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var out bytes.Buffer
+	defer AddJSONWriter("INFO", &out)()
+
+	// Two-byte runes straddle the part boundaries.
+	payload := "x" + strings.Repeat("é", 5000)
+	if err := InfoReader("body", strings.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+	var got strings.Builder
+	var id interface{}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d parts, want 3", len(lines))
+	}
+	for i, line := range lines {
+		r, err := ParseRecord([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(r.Message, "body: ") || r.File != "glog_reader_test.go" {
+			t.Errorf("part %d: unexpected record %+v", i+1, r)
+		}
+		got.WriteString(strings.TrimPrefix(r.Message, "body: "))
+		if i == 0 {
+			id = r.Fields["payload"]
+		} else if r.Fields["payload"] != id {
+			t.Errorf("part %d: payload %v, want %v", i+1, r.Fields["payload"], id)
+		}
+		if part := r.Fields["part"]; part != float64(i+1) {
+			t.Errorf("part %d: numbered %v", i+1, part)
+		}
+		if last := r.Fields["last"] == true; last != (i == len(lines)-1) {
+			t.Errorf("part %d: last %t", i+1, last)
+		}
+	}
+	if got.String() != payload {
+		t.Errorf("parts do not make up the payload")
+	}
+
+	out.Reset()
+	boom := errors.New("boom")
+	err := InfoReader("broken", io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(boom)))
+	if err != boom {
+		t.Errorf("InfoReader returned %v, want %v", err, boom)
+	}
+	if !strings.Contains(out.String(), `"message":"broken: partial"`) || !strings.Contains(out.String(), `"last":true`) {
+		t.Errorf("got %s", out.String())
+	}
+}
@@ -0,0 +1,101 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Logging of recovered panics.
+
+package glog
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// RecoverAndLog recovers a panic and logs it to the ERROR, WARNING, and INFO
+// logs with the stack of the panicking goroutine. It must be deferred
+// directly:
+//
+//	go func() {
+//		defer glog.RecoverAndLog()
+//		...
+//	}()
+//
+// The panic value is masked like other logged values, and the line is
+// attributed to the statement that panicked. The goroutine then returns
+// normally from the deferring function.
+func RecoverAndLog() {
+	if r := recover(); r != nil {
+		logPanic(r, "")
+	}
+}
+
+// LogAndRepanic is like RecoverAndLog, but panics again with the same value
+// once it is logged, so that the program still crashes.
+func LogAndRepanic() {
+	if r := recover(); r != nil {
+		logPanic(r, "")
+		panic(r)
+	}
+}
+
+// RecoverHandler returns a handler that serves h, logging the panics of h
+// like RecoverAndLog along with the request method and URL, and replying to
+// the request with status 500. http.ErrAbortHandler, used to abort a
+// response, is passed on without logging.
+func RecoverHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+			logPanic(r, req.Method+" "+req.URL.Path+": ")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		h.ServeHTTP(w, req)
+	})
+}
+
+// logPanic logs r, a value recovered by a function deferred by the caller of
+// logPanic, at the statement that panicked.
+func logPanic(r interface{}, prefix string) {
+	logging.printfStackDepth(errorLog, panicDepth(), debug.Stack(), "%spanic: %v\n", prefix, r)
+}
+
+// panicDepth returns the depth, relative to the caller of logPanic, of the
+// function that panicked, or 0 if it cannot be found.
+func panicDepth() int {
+	var pcs [64]uintptr
+	n := runtime.Callers(3, pcs[:]) // Skip Callers, panicDepth and logPanic.
+	frames := runtime.CallersFrames(pcs[:n])
+	panicking := false
+	for depth := 0; ; depth++ {
+		f, more := frames.Next()
+		if panicking && !strings.HasPrefix(f.Function, "runtime.") {
+			return depth
+		}
+		if f.Function == "runtime.gopanic" {
+			panicking = true
+		}
+		if !more {
+			return 0
+		}
+	}
+}
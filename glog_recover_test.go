@@ -0,0 +1,67 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func panicky(v interface{}) {
+	defer RecoverAndLog()
+	panic(v)
+}
+
+func TestRecoverAndLog(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	panicky(map[string]interface{}{"mobile": "13812345678"})
+	if !contains(errorLog, "glog_recover_test.go:27] panic: map[mobile:138****5678]", t) {
+		t.Errorf("got %q", contents(errorLog))
+	}
+	if !contains(errorLog, "glog.panicky", t) {
+		t.Errorf("stack missing: %q", contents(errorLog))
+	}
+
+	defer func() {
+		if r := recover(); r != "again" {
+			t.Errorf("recovered %v, want again", r)
+		}
+	}()
+	func() {
+		defer LogAndRepanic()
+		panic("again")
+	}()
+}
+
+func TestRecoverHandler(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	h := RecoverHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		var m map[string]int
+		m["boom"]++
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/users", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d", rec.Code)
+	}
+	if !contains(errorLog, "glog_recover_test.go:57] GET /users: panic: assignment to entry in nil map", t) {
+		t.Errorf("got %q", contents(errorLog))
+	}
+}
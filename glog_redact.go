@@ -0,0 +1,124 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Redaction of credentials: sensitive headers, API keys and bearer tokens.
+
+package glog
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// sensitiveHeaders holds the canonical names of the headers whose values are
+// redacted. Cookie and Set-Cookie, whose values are redacted keeping the
+// cookie names, are handled separately.
+var (
+	sensitiveHeadersMu sync.RWMutex
+	sensitiveHeaders   = map[string]bool{
+		"Authorization":        true,
+		"Proxy-Authorization":  true,
+		"X-Api-Key":            true,
+		"X-Auth-Token":         true,
+		"X-Amz-Security-Token": true,
+	}
+)
+
+// RegisterSensitiveHeader adds headers whose values are redacted when an
+// http.Header or *http.Request is logged, besides the built-in Authorization,
+// Proxy-Authorization, Cookie, Set-Cookie, X-Api-Key, X-Auth-Token and
+// X-Amz-Security-Token. Names are case-insensitive. It may be called at any
+// time.
+func RegisterSensitiveHeader(names ...string) {
+	sensitiveHeadersMu.Lock()
+	defer sensitiveHeadersMu.Unlock()
+	for _, name := range names {
+		sensitiveHeaders[http.CanonicalHeaderKey(name)] = true
+	}
+}
+
+// isSensitiveHeader reports whether the values of the header with the
+// canonical name are redacted.
+func isSensitiveHeader(canonical string) bool {
+	sensitiveHeadersMu.RLock()
+	defer sensitiveHeadersMu.RUnlock()
+	return sensitiveHeaders[canonical]
+}
+
+// keySeparators removes the separators from keys compared by isTokenKey.
+var keySeparators = strings.NewReplacer("_", "", "-", "")
+
+// isTokenKey reports whether key, a map key or parameter name, names a
+// credential such as "access_token", "apiKey" or "client_secret".
+func isTokenKey(key string) bool {
+	k := keySeparators.Replace(strings.ToUpper(key))
+	return strings.Contains(k, "TOKEN") || strings.Contains(k, "APIKEY") ||
+		strings.Contains(k, "SECRET") || strings.Contains(k, "ACCESSKEY")
+}
+
+var (
+	// tokenRe matches bearer tokens, JWTs and API keys of well-known formats:
+	// AWS access key IDs, GitHub, Slack and OpenAI-style tokens, and Google
+	// API keys.
+	tokenRe = regexp.MustCompile(`\b[Bb]earer\s+[A-Za-z0-9._~+/-]+=*` +
+		`|\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*` +
+		`|\bAKIA[0-9A-Z]{16}\b` +
+		`|\bgh[pousr]_[A-Za-z0-9]{36,}\b` +
+		`|\bxox[abprs]-[A-Za-z0-9-]{10,}` +
+		`|\bAIza[0-9A-Za-z_-]{35}` +
+		`|\bsk-[A-Za-z0-9_-]{20,}`)
+
+	// tokenMarks are substrings of every match of tokenRe, so that the
+	// common case of text without tokens skips the regexp.
+	tokenMarks = []string{"earer", "eyJ", "AKIA", "ghp_", "gho_", "ghu_", "ghs_", "ghr_", "xox", "AIza", "sk-"}
+)
+
+// hasToken reports whether s may contain a token matched by tokenRe.
+func hasToken(s string) bool {
+	for _, m := range tokenMarks {
+		if strings.Contains(s, m) {
+			return tokenRe.MatchString(s)
+		}
+	}
+	return false
+}
+
+// redactTokens replaces the bearer tokens, JWTs and API keys in s, if the
+// pwd filter is on. Bearer tokens keep their scheme.
+func (l *loggingT) redactTokens(s string) string {
+	if !l.filterPwd || !hasToken(s) {
+		return s
+	}
+	return tokenRe.ReplaceAllStringFunc(s, func(tok string) string {
+		if i := strings.IndexAny(tok, " \t"); i >= 0 && strings.EqualFold(tok[:i], "bearer") {
+			return tok[:i+1] + ShrinePwdStr()
+		}
+		return ShrinePwdStr()
+	})
+}
+
+// redactArgs redacts the tokens in the string arguments in args.
+func (l *loggingT) redactArgs(args []interface{}) {
+	for i, arg := range args {
+		if s, ok := arg.(string); ok {
+			if r := l.redactTokens(s); r != s {
+				args[i] = r
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Reopening log files for external rotation tools such as logrotate.
+
+package glog
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// reopen flushes and closes the syncBuffer's file and opens the same path
+// again for appending, creating it if an external tool has moved it away.
+// l.mu is held.
+func (sb *syncBuffer) reopen() error {
+	if sb.file == nil {
+		return nil
+	}
+	sb.Flush()
+	sb.file.Close()
+	f, err := openLogFile(sb.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		sb.file = nil
+		return err
+	}
+	sb.file = f
+	if err := sb.startFile(); err != nil {
+		f.Close()
+		sb.file = nil
+		return err
+	}
+	sb.nbytes = 0
+	if fi, err := f.Stat(); err == nil {
+		sb.nbytes = uint64(fi.Size())
+	}
+	return nil
+}
+
+// ReopenLogFiles flushes and closes the current log files and opens them
+// again by name. After an external tool renames a log file, writes that
+// would otherwise go to the renamed file go to a fresh file at the original
+// path. It returns the first error encountered; files that fail to reopen
+// are recreated on the next write.
+func ReopenLogFiles() error {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	var first error
+	for s := fatalLog; s >= infoLog; s-- {
+		sb, ok := logging.file[s].(*syncBuffer)
+		if !ok {
+			continue
+		}
+		if err := sb.reopen(); err != nil {
+			logging.file[s] = nil
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// ReopenOnSignal calls ReopenLogFiles whenever the process receives one of
+// sigs, SIGHUP if none are given. Errors are reported on standard error.
+// The returned function stops the signal handling.
+func ReopenOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, sigs...)
+	go func() {
+		for {
+			select {
+			case <-c:
+				if err := ReopenLogFiles(); err != nil {
+					os.Stderr.Write([]byte("log: cannot reopen log files: " + err.Error() + "\n"))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(c)
+		close(done)
+	}
+}
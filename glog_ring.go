@@ -0,0 +1,194 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// In-memory buffers holding the most recent log output.
+
+package glog
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// ringBuffer holds the most recent bytes written to it.
+type ringBuffer struct {
+	buf  []byte
+	pos  int  // Next write position in buf.
+	full bool // buf has wrapped at least once.
+}
+
+// newRingBuffer returns a buffer keeping the last size bytes. One extra byte
+// is kept so that Bytes can tell whether the oldest line is whole.
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{buf: make([]byte, size+1)}
+}
+
+// Write appends p, overwriting the oldest data if necessary. It never fails.
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if n >= len(r.buf) {
+		copy(r.buf, p[n-len(r.buf):])
+		r.pos, r.full = 0, true
+		return n, nil
+	}
+	if r.pos+n >= len(r.buf) {
+		r.full = true
+	}
+	c := copy(r.buf[r.pos:], p)
+	copy(r.buf, p[c:])
+	r.pos = (r.pos + n) % len(r.buf)
+	return n, nil
+}
+
+// Bytes returns a copy of the buffered data, oldest first. Once the buffer has
+// wrapped, the partial line at the start is dropped.
+func (r *ringBuffer) Bytes() []byte {
+	if !r.full {
+		return append([]byte(nil), r.buf[:r.pos]...)
+	}
+	out := make([]byte, 0, len(r.buf))
+	out = append(out, r.buf[r.pos:]...)
+	out = append(out, r.buf[:r.pos]...)
+	// The extra byte is either the end of the previous line or part of a
+	// line that was cut.
+	if i := bytes.IndexByte(out, '\n'); i >= 0 {
+		out = out[i+1:]
+	}
+	return out
+}
+
+// SetRecentLogSize keeps the last kb kilobytes of output of each severity in
+// memory, for retrieval with RecentLogs. Zero, the default, disables the
+// buffers. Changing the size discards what has been kept so far.
+func SetRecentLogSize(kb int) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.recentKB = kb
+	for s := range logging.recent {
+		logging.recent[s] = nil
+		if kb > 0 {
+			logging.recent[s] = newRingBuffer(kb * 1024)
+		}
+	}
+}
+
+// writeRecent records data, a line of severity s, in the in-memory buffers.
+// As with the log files, a line is kept for its own and, unless disabled, all
+// lower severities. l.mu is held.
+func (l *loggingT) writeRecent(s severity, data []byte) {
+	lowest := s
+	if l.alsoToLower {
+		lowest = infoLog
+	}
+	for ; s >= lowest; s-- {
+		if r := l.recent[s]; r != nil {
+			r.Write(data)
+		}
+	}
+}
+
+// crashContext returns the last l.crashLines lines kept in memory before the
+// FATAL line just written, introduced by a heading, or nil if there are none.
+// They are taken from the buffer of the lowest severity that is kept, which
+// holds the lines of all goroutines and, with -alsologtolower, of all
+// severities.
+// l.mu is held.
+func (l *loggingT) crashContext() []byte {
+	if l.crashLines <= 0 {
+		return nil
+	}
+	for s := infoLog; s <= fatalLog; s++ {
+		r := l.recent[s]
+		if r == nil {
+			continue
+		}
+		data := r.Bytes()
+		if len(data) > 0 && (s == fatalLog || l.alsoToLower) {
+			// Drop the FATAL line itself, which precedes the stacks anyway.
+			data = data[:bytes.LastIndexByte(data[:len(data)-1], '\n')+1]
+		}
+		n, start := 0, len(data)
+		for n < l.crashLines && start > 0 {
+			start = bytes.LastIndexByte(data[:start-1], '\n') + 1
+			n++
+		}
+		if n == 0 {
+			return nil
+		}
+		head := fmt.Sprintf("\nLast %d log lines before the crash:\n", n)
+		return append([]byte(head), data[start:]...)
+	}
+	return nil
+}
+
+// RecentLogs returns the most recent output of the named severity kept in
+// memory, oldest line first. It returns nil unless SetRecentLogSize or the
+// -recent_log_kb flag has enabled the buffers.
+//
+// Valid names are "INFO", "WARNING", "ERROR", and "FATAL".  If the name is not
+// recognized, RecentLogs panics.
+func RecentLogs(name string) []byte {
+	sev, ok := severityByName(name)
+	if !ok {
+		panic(fmt.Sprintf("log.RecentLogs(%q): unrecognized severity name", name))
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	if r := logging.recent[sev]; r != nil {
+		return r.Bytes()
+	}
+	return nil
+}
+
+// RecentLogsHandler returns an HTTP handler that serves the output kept by
+// RecentLogs as plain text. The severity is chosen with the "severity" query
+// parameter and defaults to INFO, e.g. /debug/logs?severity=ERROR.
+func RecentLogsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.FormValue("severity")
+		if name == "" {
+			name = severityName[infoLog]
+		}
+		if _, ok := severityByName(name); !ok {
+			http.Error(w, fmt.Sprintf("unrecognized severity %q", name), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(RecentLogs(name))
+	})
+}
+
+// recentSizeValue implements flag.Value for the -recent_log_kb flag.
+type recentSizeValue struct{}
+
+// String is part of the flag.Value interface.
+func (recentSizeValue) String() string {
+	return fmt.Sprint(logging.recentKB)
+}
+
+// Set is part of the flag.Value interface.
+func (recentSizeValue) Set(value string) error {
+	var kb int
+	if _, err := fmt.Sscan(value, &kb); err != nil {
+		return err
+	}
+	if kb < 0 {
+		return fmt.Errorf("negative value for recent_log_kb: %d", kb)
+	}
+	SetRecentLogSize(kb)
+	return nil
+}
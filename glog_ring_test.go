@@ -0,0 +1,106 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Test that the ring buffer keeps only the newest whole lines.
+func TestRingBuffer(t *testing.T) {
+	r := newRingBuffer(16)
+	r.Write([]byte("one\n"))
+	r.Write([]byte("two\n"))
+	if got := string(r.Bytes()); got != "one\ntwo\n" {
+		t.Errorf("before wrap: got %q", got)
+	}
+	r.Write([]byte("three\n"))
+	r.Write([]byte("four\n"))
+	if got := string(r.Bytes()); got != "two\nthree\nfour\n" {
+		t.Errorf("after wrap: got %q", got)
+	}
+	r.Write([]byte("a very long line indeed\n"))
+	if got := string(r.Bytes()); got != "" {
+		t.Errorf("oversized line: got %q", got)
+	}
+}
+
+// Test that RecentLogs and its handler return recent lines per severity.
+func TestRecentLogs(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	if RecentLogs("INFO") != nil {
+		t.Fatal("recent logs kept while disabled")
+	}
+	SetRecentLogSize(1)
+	defer SetRecentLogSize(0)
+
+	Info("recent-info")
+	Error("recent-error")
+	if got := string(RecentLogs("INFO")); !strings.Contains(got, "recent-info") || !strings.Contains(got, "recent-error") {
+		t.Errorf("INFO recent logs: %q", got)
+	}
+	if got := string(RecentLogs("WARNING")); strings.Contains(got, "recent-info") || !strings.Contains(got, "recent-error") {
+		t.Errorf("WARNING recent logs: %q", got)
+	}
+
+	w := httptest.NewRecorder()
+	RecentLogsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/debug/logs?severity=error", nil))
+	if body := w.Body.String(); !strings.Contains(body, "recent-error") || strings.Contains(body, "recent-info") {
+		t.Errorf("handler body: %q", body)
+	}
+	w = httptest.NewRecorder()
+	RecentLogsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/debug/logs?severity=LOG", nil))
+	if w.Code != 400 {
+		t.Errorf("bad severity: got status %d", w.Code)
+	}
+}
+
+// Test that Fatal writes the last lines kept in memory before the stacks.
+func TestCrashContext(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func(previous func(error)) { logExitFunc = previous }(logExitFunc)
+	defer SetExitFunc(nil)
+	defer func() { logging.crashLines = 0 }()
+	SetExitFunc(func(int) {})
+	SetRecentLogSize(4)
+	defer SetRecentLogSize(0)
+	logging.crashLines = 2
+
+	Info("lead-up one")
+	Warning("lead-up two")
+	Info("lead-up three")
+	stderr := capture(t, &os.Stderr, func() { Fatal("crashed") })
+	for _, out := range []string{contents(fatalLog), stderr} {
+		i := strings.Index(out, "Last 2 log lines before the crash:\n")
+		if i < 0 || strings.Contains(out[i:], "lead-up one") || !strings.Contains(out[i:], "lead-up two") ||
+			!strings.Contains(out[i:], "lead-up three\ngoroutine ") {
+			t.Errorf("no crash context before the stacks: %q", out)
+		}
+		if strings.Count(out, "crashed") > 1 {
+			t.Errorf("FATAL line repeated: %q", out)
+		}
+	}
+
+	if err := Init(WithCrashLines(2)); err == nil {
+		t.Error("crash lines accepted without recent logs")
+	}
+}
@@ -0,0 +1,396 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Rotation and retention settings, globally and per severity.
+
+package glog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RotationPolicy overrides the rotation and retention of the log files of one
+// severity. Zero fields take the global settings: MaxSize, -rotate_interval,
+// -log_max_age and -log_max_files.
+type RotationPolicy struct {
+	MaxSize  uint64        // Size in bytes at which files are rotated.
+	Interval string        // "month", "day", "hour", "minute" or "second".
+	MaxAge   time.Duration // Older files are deleted when a file is created.
+	MaxFiles int           // At most this many files, including the current one, are kept.
+}
+
+// validate reports whether p holds valid settings.
+func (p RotationPolicy) validate() error {
+	if p.Interval != "" && !validRotateInterval(p.Interval) {
+		return fmt.Errorf("unknown rotate interval %q", p.Interval)
+	}
+	if p.MaxAge < 0 || p.MaxFiles < 0 {
+		return fmt.Errorf("negative retention")
+	}
+	return nil
+}
+
+// SetRotationPolicy sets the rotation and retention of the files of the named
+// severity, such as "ERROR". The zero RotationPolicy restores the global
+// settings. The new interval takes effect from the next rotation.
+func SetRotationPolicy(name string, p RotationPolicy) error {
+	sev, ok := severityByName(name)
+	if !ok {
+		return fmt.Errorf("log: unknown severity %q", name)
+	}
+	if err := p.validate(); err != nil {
+		return fmt.Errorf("log: %s: %v", name, err)
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.rotation[sev] = p
+	return nil
+}
+
+// SetRotateUTC controls whether rotation intervals start at the boundaries
+// of UTC, as by the -log_rotate_utc flag, so that daily files cover the same
+// 24 hours everywhere and never change length with daylight saving time.
+// By default, they start at the boundaries of the time zone of timestamps.
+// The setting takes effect from the next rotation.
+func SetRotateUTC(on bool) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.rotateUTC = on
+}
+
+// maxSize returns the size at which files of severity s are rotated.
+// l.mu is held.
+func (l *loggingT) maxSize(s severity) uint64 {
+	if n := l.rotation[s].MaxSize; n > 0 {
+		return n
+	}
+	return MaxSize
+}
+
+// rotateInterval returns the rotation interval of files of severity s.
+// l.mu is held.
+func (l *loggingT) rotateInterval(s severity) string {
+	if i := l.rotation[s].Interval; i != "" {
+		return i
+	}
+	return *LogRotateInterval
+}
+
+// scheduleRotation sets a timer for the start of the rotation interval
+// following now, which marks sb's file due for rotation. The file is rotated
+// by the next line written to it, so an interval without lines leaves no
+// file behind. Timers run on monotonic time: one that fires before the
+// boundary by the clock, which has been set back, waits for the rest.
+// l.mu is held.
+func (sb *syncBuffer) scheduleRotation(now time.Time) {
+	if sb.rotateTimer != nil {
+		sb.rotateTimer.Stop()
+	}
+	sb.rotateDue = false
+	sb.rotateGen++
+	sb.nextRotateTime = sb.logger.nextRotation(sb.sev, now)
+	sb.armRotation(now)
+}
+
+// nextRotation returns the start of the rotation interval of files of
+// severity s that follows t.
+// l.mu is held.
+func (l *loggingT) nextRotation(s severity, t time.Time) time.Time {
+	if l.rotateUTC {
+		t = t.In(time.UTC)
+	}
+	return getStartOfNextInterval(l.rotateInterval(s), t)
+}
+
+// armRotation sets sb.rotateTimer to fire at sb.nextRotateTime.
+// l.mu is held.
+func (sb *syncBuffer) armRotation(now time.Time) {
+	gen := sb.rotateGen
+	sb.rotateTimer = currentClock().AfterFunc(sb.nextRotateTime.Sub(now), func() {
+		l := sb.logger
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if sb.rotateGen != gen {
+			return // Replaced by a later rotation.
+		}
+		if now := l.now(); now.Before(sb.nextRotateTime) {
+			sb.armRotation(now)
+			return
+		}
+		sb.rotateDue = true
+	})
+}
+
+// retention returns the age and count limits of files of severity s.
+// l.mu is held.
+func (l *loggingT) retention(s severity) (maxAge time.Duration, maxFiles int) {
+	maxAge, maxFiles = l.maxAge, l.maxFiles
+	if p := l.rotation[s]; p.MaxAge > 0 {
+		maxAge = p.MaxAge
+	}
+	if p := l.rotation[s]; p.MaxFiles > 0 {
+		maxFiles = p.MaxFiles
+	}
+	return maxAge, maxFiles
+}
+
+// pruneLogs deletes the files of sb's severity, written by any run of the
+// program, that exceed its retention limits. Only the directory of the
+// current file is searched.
+// l.mu is held.
+func (sb *syncBuffer) pruneLogs(now time.Time) {
+	maxAge, maxFiles := sb.logger.retention(sb.sev)
+	if maxAge <= 0 && maxFiles <= 0 || sb.name == "" {
+		return
+	}
+	dir := filepath.Dir(sb.name)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	prefix := fmt.Sprintf("%s.%s.%s.log.%s.", program, host, userName, severityName[sb.sev])
+	type oldFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []oldFile
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if !strings.HasPrefix(e.Name(), prefix) || !e.Type().IsRegular() || path == sb.name {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			files = append(files, oldFile{path, info.ModTime()})
+		}
+	}
+	// Newest first.
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for i, f := range files {
+		if maxFiles > 0 && i+1 >= maxFiles || maxAge > 0 && now.Sub(f.modTime) > maxAge {
+			os.Remove(f.path)
+		}
+	}
+}
+
+// rotateHook is a function registered with OnRotate.
+type rotateHook struct {
+	fn func(oldPath, newPath string, severity Severity)
+}
+
+var (
+	rotateHooksMu sync.Mutex // Serializes changes to rotateHooks.
+	// rotateHooks holds the registered []*rotateHook. It is replaced, never
+	// modified, so that rotation can read it without locking.
+	rotateHooks atomic.Value
+)
+
+// OnRotate arranges for fn to be called each time a log file is rotated,
+// by size or time, with the path of the file just closed and that of the
+// file replacing it, for example to upload the closed file. With
+// SetCompression, the closed file is compressed first and oldPath is the
+// compressed file.
+// Calls run on a separate goroutine, one rotation at a time and in the order
+// the functions were added, so that fn may take its time and log; by then
+// the closed file is complete.
+// A file recreated because another process removed or renamed it is not a
+// rotation and is not reported.
+// The returned function removes fn.
+func OnRotate(fn func(oldPath, newPath string, severity Severity)) (remove func()) {
+	h := &rotateHook{fn: fn}
+	rotateHooksMu.Lock()
+	defer rotateHooksMu.Unlock()
+	old, _ := rotateHooks.Load().([]*rotateHook)
+	rotateHooks.Store(append(old[:len(old):len(old)], h))
+	return func() {
+		rotateHooksMu.Lock()
+		defer rotateHooksMu.Unlock()
+		old, _ := rotateHooks.Load().([]*rotateHook)
+		for i, other := range old {
+			if other == h {
+				rotateHooks.Store(append(old[:i:i], old[i+1:]...))
+				return
+			}
+		}
+	}
+}
+
+// rotation is a rotation waiting for its closed file to be compressed and
+// its OnRotate functions to be called.
+type rotation struct {
+	hooks            []*rotateHook
+	oldPath, newPath string
+	sev              severity
+	codec            string // Compresses oldPath, if set.
+}
+
+// rotations queues rotations for a single goroutine, which runs while the
+// queue is not empty, so that the functions of a burst of rotations run one
+// after the other instead of competing for the same files.
+var rotations struct {
+	mu      sync.Mutex
+	queue   []rotation
+	running bool // A goroutine is draining queue.
+}
+
+// rotated compresses oldPath with codec, if set, and calls the OnRotate
+// functions for the rotation of the file of severity s at oldPath to
+// newPath.
+func rotated(oldPath, newPath string, s severity, codec string) {
+	hs, _ := rotateHooks.Load().([]*rotateHook)
+	if len(hs) == 0 && codec == "" {
+		return
+	}
+	rotations.mu.Lock()
+	defer rotations.mu.Unlock()
+	rotations.queue = append(rotations.queue, rotation{hs, oldPath, newPath, s, codec})
+	if !rotations.running {
+		rotations.running = true
+		go runRotations()
+	}
+}
+
+// runRotations compresses the files and calls the OnRotate functions of the
+// queued rotations in order until the queue is empty.
+func runRotations() {
+	for {
+		rotations.mu.Lock()
+		if len(rotations.queue) == 0 {
+			rotations.running = false
+			rotations.mu.Unlock()
+			return
+		}
+		r := rotations.queue[0]
+		rotations.queue = rotations.queue[1:]
+		rotations.mu.Unlock()
+		if r.codec != "" {
+			if path, err := compressFile(r.oldPath, r.codec); err != nil {
+				if !handleError(err) {
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+				}
+			} else {
+				r.oldPath = path
+			}
+		}
+		for _, h := range r.hooks {
+			h.fn(r.oldPath, r.newPath, r.sev)
+		}
+	}
+}
+
+// parseRotation parses the value of the -log_rotation flag: a
+// semicolon-separated list of SEVERITY:field=value,..., for instance
+// "ERROR:max_size=104857600,max_age=720h;WARNING:interval=hour".
+func parseRotation(value string) (map[string]RotationPolicy, error) {
+	policies := make(map[string]RotationPolicy)
+	for _, spec := range strings.Split(value, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		colon := strings.Index(spec, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("syntax error in %q: expect SEVERITY:field=value,...", spec)
+		}
+		name := strings.ToUpper(spec[:colon])
+		if _, ok := severityByName(name); !ok {
+			return nil, fmt.Errorf("unknown severity %q", name)
+		}
+		p := policies[name]
+		for _, kv := range strings.Split(spec[colon+1:], ",") {
+			eq := strings.Index(kv, "=")
+			if eq < 0 {
+				return nil, fmt.Errorf("syntax error in %q: expect field=value", kv)
+			}
+			if err := p.set(strings.TrimSpace(kv[:eq]), strings.TrimSpace(kv[eq+1:])); err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		policies[name] = p
+	}
+	return policies, nil
+}
+
+// set sets the field of p with the given -log_rotation name.
+func (p *RotationPolicy) set(field, value string) error {
+	var err error
+	switch field {
+	case "max_size":
+		p.MaxSize, err = strconv.ParseUint(value, 10, 64)
+	case "interval":
+		p.Interval = value
+	case "max_age":
+		p.MaxAge, err = time.ParseDuration(value)
+	case "max_files":
+		p.MaxFiles, err = strconv.Atoi(value)
+	default:
+		return fmt.Errorf("unknown field %q", field)
+	}
+	return err
+}
+
+// rotationValue implements flag.Value for the -log_rotation flag.
+type rotationValue struct{}
+
+// String is part of the flag.Value interface.
+func (rotationValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	var specs []string
+	for s, p := range logging.rotation {
+		var fields []string
+		if p.MaxSize > 0 {
+			fields = append(fields, fmt.Sprintf("max_size=%d", p.MaxSize))
+		}
+		if p.Interval != "" {
+			fields = append(fields, "interval="+p.Interval)
+		}
+		if p.MaxAge > 0 {
+			fields = append(fields, "max_age="+p.MaxAge.String())
+		}
+		if p.MaxFiles > 0 {
+			fields = append(fields, fmt.Sprintf("max_files=%d", p.MaxFiles))
+		}
+		if len(fields) > 0 {
+			specs = append(specs, severityName[s]+":"+strings.Join(fields, ","))
+		}
+	}
+	return strings.Join(specs, ";")
+}
+
+// Set is part of the flag.Value interface.
+func (rotationValue) Set(value string) error {
+	policies, err := parseRotation(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	for s := range logging.rotation {
+		logging.rotation[s] = policies[severityName[s]]
+	}
+	return nil
+}
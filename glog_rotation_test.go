@@ -0,0 +1,484 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test that per-severity policies override the global settings.
+func TestRotationPolicy(t *testing.T) {
+	defer SetRotationPolicy("ERROR", RotationPolicy{})
+	if err := SetRotationPolicy("ERROR", RotationPolicy{MaxSize: 512, Interval: "hour", MaxFiles: 3}); err != nil {
+		t.Fatal(err)
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	if logging.maxSize(errorLog) != 512 || logging.maxSize(infoLog) != MaxSize {
+		t.Error("max size not overridden")
+	}
+	if logging.rotateInterval(errorLog) != "hour" || logging.rotateInterval(warningLog) != *LogRotateInterval {
+		t.Error("interval not overridden")
+	}
+	if _, n := logging.retention(errorLog); n != 3 {
+		t.Errorf("got max files %d", n)
+	}
+	for _, bad := range []struct {
+		name string
+		p    RotationPolicy
+	}{
+		{"LOUD", RotationPolicy{}},
+		{"ERROR", RotationPolicy{Interval: "week"}},
+		{"ERROR", RotationPolicy{MaxFiles: -1}},
+	} {
+		if err := SetRotationPolicy(bad.name, bad.p); err == nil {
+			t.Errorf("%s %+v accepted", bad.name, bad.p)
+		}
+	}
+}
+
+func TestParseRotation(t *testing.T) {
+	got, err := parseRotation("ERROR:max_size=1024,max_age=720h; warning:interval=hour,max_files=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]RotationPolicy{
+		"ERROR":   {MaxSize: 1024, MaxAge: 720 * time.Hour},
+		"WARNING": {Interval: "hour", MaxFiles: 5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	for _, bad := range []string{"ERROR", "LOUD:max_files=1", "ERROR:size=1", "ERROR:interval=week", "ERROR:max_age=soon"} {
+		if _, err := parseRotation(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+// Test that rotation deletes the files exceeding the retention limits.
+func TestPruneLogs(t *testing.T) {
+	defer SetRotationPolicy("ERROR", RotationPolicy{})
+	if err := SetRotationPolicy("ERROR", RotationPolicy{MaxAge: 48 * time.Hour, MaxFiles: 3}); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	now := time.Now()
+	prefix := fmt.Sprintf("%s.%s.%s.log.", program, host, userName)
+	touch := func(name string, age time.Duration) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, now.Add(-age), now.Add(-age))
+	}
+	touch(prefix+"ERROR.current", 0)
+	touch(prefix+"ERROR.1h", time.Hour)
+	touch(prefix+"ERROR.2h", 2*time.Hour)
+	touch(prefix+"ERROR.3h", 3*time.Hour) // Over the count.
+	touch(prefix+"ERROR.72h", 72*time.Hour)
+	touch(prefix+"INFO.72h", 72*time.Hour) // Another severity.
+	touch("unrelated.72h", 72*time.Hour)
+
+	sb := &syncBuffer{logger: &logging, sev: errorLog, name: filepath.Join(dir, prefix+"ERROR.current")}
+	logging.mu.Lock()
+	sb.pruneLogs(now)
+	logging.mu.Unlock()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{prefix + "ERROR.1h", prefix + "ERROR.2h", prefix + "ERROR.current", prefix + "INFO.72h", "unrelated.72h"}
+	sort.Strings(want)
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
+
+// Test that OnRotate functions are called with the closed and new files.
+func TestOnRotate(t *testing.T) {
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	dir := t.TempDir()
+	logDirs = []string{dir}
+
+	type rotation struct {
+		oldPath, newPath string
+		sev              Severity
+	}
+	got := make(chan rotation, 1)
+	remove := OnRotate(func(oldPath, newPath string, sev Severity) {
+		got <- rotation{oldPath, newPath, sev}
+	})
+	defer remove()
+
+	now := time.Now()
+	sb := &syncBuffer{logger: &logging, sev: warningLog}
+	logging.mu.Lock()
+	err := sb.rotateFile(now)
+	first := sb.name
+	if err == nil {
+		err = sb.rotateFile(now.Add(time.Second))
+	}
+	logging.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Close()
+
+	select {
+	case r := <-got:
+		if r.oldPath != first || r.newPath != sb.name || r.sev != SeverityWarning {
+			t.Errorf("got %+v, want %s to %s", r, first, sb.name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("rotation not reported")
+	}
+	select {
+	case r := <-got:
+		t.Errorf("creation of the first file reported: %+v", r)
+	default:
+	}
+}
+
+// Test that the OnRotate functions of a burst of rotations run one at a time,
+// in order.
+func TestOnRotateSerialized(t *testing.T) {
+	var mu sync.Mutex
+	var running, overlaps int
+	var got []string
+	done := make(chan bool)
+	remove := OnRotate(func(oldPath, newPath string, sev Severity) {
+		mu.Lock()
+		running++
+		if running > 1 {
+			overlaps++
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running--
+		got = append(got, oldPath)
+		n := len(got)
+		mu.Unlock()
+		if n == 10 {
+			done <- true
+		}
+	})
+	defer remove()
+	var want []string
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprint("old", i)
+		want = append(want, name)
+		rotated(name, "new", infoLog, "")
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rotations not reported")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if overlaps > 0 {
+		t.Errorf("%d OnRotate calls overlapped", overlaps)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rotations reported as %q, want %q", got, want)
+	}
+}
+
+// Test that a rotated file names its continuation, and the new file names the
+// rotated one.
+func TestRotationHeaderFooter(t *testing.T) {
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	logDirs = []string{t.TempDir()}
+
+	now := time.Now()
+	sb := &syncBuffer{logger: &logging, sev: infoLog}
+	logging.mu.Lock()
+	err := sb.rotateFile(now)
+	first := sb.name
+	if err == nil {
+		sb.Write([]byte("a line\n"))
+		err = sb.rotateFile(now.Add(time.Second))
+	}
+	logging.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb.Flush()
+	sb.Close()
+
+	old, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a line\nLog file continues in: " + sb.name + "\n"; !strings.HasSuffix(string(old), want) {
+		t.Errorf("rotated file ends with %q, want %q", old, want)
+	}
+	if strings.Contains(string(old), "Previous log file") {
+		t.Errorf("first file names a previous one: %q", old)
+	}
+	cur, err := os.ReadFile(sb.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{fmt.Sprintf("\nProcess ID: %d\n", pid), "\nPrevious log file: " + first + "\n"} {
+		if !strings.Contains(string(cur), want) {
+			t.Errorf("header of the new file %q lacks %q", cur, want)
+		}
+	}
+}
+
+// manualClock is a Clock set by hand, whose AfterFunc timers run when it is
+// set past their time.
+type manualClock struct {
+	systemClock
+	mu     sync.Mutex
+	now    time.Time
+	timers []manualTimer
+}
+
+type manualTimer struct {
+	at time.Time
+	f  func()
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc returns a Timer whose Stop has no effect.
+func (c *manualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timers = append(c.timers, manualTimer{c.now.Add(d), f})
+	return systemTimer{time.NewTimer(time.Hour)}
+}
+
+// set sets the clock to now and runs the timers due.
+func (c *manualClock) set(now time.Time) {
+	c.mu.Lock()
+	c.now = now
+	var due []func()
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(now) {
+			pending = append(pending, timer)
+		} else {
+			due = append(due, timer.f)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+	for _, f := range due {
+		f()
+	}
+}
+
+// Test that files are rotated after the timer for the interval boundary
+// fires, by the next line, and that intervals without lines leave no files.
+func TestRotationTimer(t *testing.T) {
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	dir := t.TempDir()
+	logDirs = []string{dir}
+	defer func(previous string) { *LogRotateInterval = previous }(*LogRotateInterval)
+	*LogRotateInterval = "minute"
+	setFlags()
+	start := time.Date(2026, 10, 16, 12, 0, 30, 0, time.Local)
+	c := &manualClock{now: start}
+	SetClock(c)
+	defer SetClock(nil)
+	defer logging.swap(logging.swap([numSeverity]Sink{}))
+
+	Info("first")
+	sb, ok := logging.file[infoLog].(*syncBuffer)
+	if !ok {
+		t.Fatal("info wasn't created")
+	}
+	first := sb.name
+	c.set(start.Add(29 * time.Second))
+	Info("second")
+	if sb.name != first {
+		t.Errorf("rotated before the boundary to %s", sb.name)
+	}
+	c.set(start.Add(30 * time.Second))
+	logging.mu.Lock()
+	due, name := sb.rotateDue, sb.name
+	logging.mu.Unlock()
+	if !due || name != first {
+		t.Errorf("at the boundary: due %v, file %s; want due, %s", due, name, first)
+	}
+	c.set(start.Add(3 * time.Minute))
+	Info("third")
+	if sb.name == first {
+		t.Error("not rotated after the boundary")
+	}
+	logging.flushAll()
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.log.INFO.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 {
+		t.Errorf("got files %v, want 2", matches)
+	}
+}
+
+// Test rotation boundaries across daylight saving time changes, and in UTC.
+func TestRotationBoundaryDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database unavailable: ", err)
+	}
+	utc := func(s string) time.Time {
+		u, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	tests := []struct {
+		interval string
+		t        time.Time
+		want     time.Time
+	}{
+		// Clocks go forward at 2:00 EST on March 8th, 2026.
+		{"hour", time.Date(2026, 3, 8, 1, 30, 0, 0, ny), utc("2026-03-08 07:00")}, // 3:00 EDT.
+		{"day", time.Date(2026, 3, 8, 1, 0, 0, 0, ny), utc("2026-03-09 04:00")},   // 23 hours later.
+		// Clocks go back at 2:00 EDT on November 1st, 2026.
+		{"hour", time.Date(2026, 11, 1, 0, 30, 0, 0, ny), utc("2026-11-01 05:00")},   // 1:00 EDT.
+		{"hour", utc("2026-11-01 05:30").In(ny), utc("2026-11-01 06:00")},            // 1:00 EST.
+		{"minute", utc("2026-11-01 05:59").In(ny), utc("2026-11-01 06:00")},          // 1:00 EST.
+		{"hour", utc("2026-11-01 06:30").In(ny), utc("2026-11-01 07:00")},            // 2:00 EST.
+		{"day", time.Date(2026, 10, 31, 12, 0, 0, 0, ny), utc("2026-11-01 04:00")},   // Midnight EDT.
+		{"day", time.Date(2026, 11, 1, 12, 0, 0, 0, ny), utc("2026-11-02 05:00")},    // Midnight EST.
+		{"month", time.Date(2026, 10, 31, 12, 0, 0, 0, ny), utc("2026-11-01 04:00")}, // Midnight EDT.
+	}
+	if kolkata, err := time.LoadLocation("Asia/Kolkata"); err == nil {
+		// A half-hour offset.
+		tests = append(tests, struct {
+			interval string
+			t        time.Time
+			want     time.Time
+		}{"hour", time.Date(2026, 1, 1, 10, 15, 0, 0, kolkata), utc("2026-01-01 05:30")})
+	}
+	for _, tt := range tests {
+		if got := getStartOfNextInterval(tt.interval, tt.t); !got.Equal(tt.want) {
+			t.Errorf("next %s after %v = %v, want %v", tt.interval, tt.t, got.UTC(), tt.want)
+		}
+	}
+
+	defer SetRotateUTC(false)
+	SetRotateUTC(true)
+	sb := &syncBuffer{logger: &logging, sev: infoLog}
+	logging.mu.Lock()
+	sb.scheduleRotation(time.Date(2026, 3, 7, 18, 0, 0, 0, ny))
+	sb.rotateTimer.Stop()
+	next := sb.nextRotateTime
+	logging.mu.Unlock()
+	if want := utc("2026-03-08 00:00"); !next.Equal(want) {
+		t.Errorf("UTC boundary = %v, want %v", next, want)
+	}
+}
+
+// Test that files started within the same second are numbered rather than
+// overwritten, and that rotation can be every second.
+func TestRotationSequence(t *testing.T) {
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	logDirs = []string{t.TempDir()}
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 250e6, time.Local)
+	sb := &syncBuffer{logger: &logging, sev: infoLog}
+	var names []string
+	logging.mu.Lock()
+	for i, at := range []time.Time{now, now, now.Add(500 * time.Millisecond), now.Add(time.Second)} {
+		if err := sb.rotateFile(at); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(sb, "file %d\n", i)
+		names = append(names, sb.name)
+	}
+	sb.Flush()
+	sb.Close()
+	logging.mu.Unlock()
+
+	for i, suffix := range []string{"", ".1", ".2", ""} {
+		if !strings.HasSuffix(names[i], fmt.Sprint(pid)+suffix) {
+			t.Errorf("file %d is %s, want suffix %q", i, names[i], suffix)
+		}
+		if data, err := os.ReadFile(names[i]); err != nil || !strings.Contains(string(data), fmt.Sprintf("file %d\n", i)) {
+			t.Errorf("file %d lost its line: %q, %v", i, data, err)
+		}
+	}
+	if names[3] == names[0] {
+		t.Errorf("file of the next second reuses %s", names[0])
+	}
+
+	if got, want := getStartOfNextInterval("second", now), now.Truncate(time.Second).Add(time.Second); !got.Equal(want) {
+		t.Errorf("next second after %v = %v, want %v", now, got, want)
+	}
+}
+
+// Test that create numbers a file whose name is taken by another, as left by
+// a previous process of the same ID, rather than overwriting it.
+func TestCreateExisting(t *testing.T) {
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	dir := t.TempDir()
+	logDirs = []string{dir}
+
+	now := time.Now()
+	for seq := 0; seq < 2; seq++ {
+		name, _ := logName("INFO", now, seq)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("previous\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, fname, seq, err := create("INFO", now, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if want, _ := logName("INFO", now, 2); seq != 2 || fname != filepath.Join(dir, want) {
+		t.Errorf("created %s with sequence number %d, want %s and 2", fname, seq, want)
+	}
+	for seq := 0; seq < 2; seq++ {
+		name, _ := logName("INFO", now, seq)
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != "previous\n" {
+			t.Errorf("%s changed: %q, %v", name, data, err)
+		}
+	}
+}
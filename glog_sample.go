@@ -0,0 +1,208 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Sampling of INFO lines adapted to the error rate.
+
+package glog
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AdaptiveSampling configures the sampling of INFO lines by the recent rate
+// of ERROR lines: few INFO lines are written while the program is healthy,
+// and more, for context, while errors are frequent.
+type AdaptiveSampling struct {
+	// Healthy is the fraction of INFO lines written while the error rate is
+	// low, between 0 and 1.
+	Healthy float64
+	// Degraded is the fraction written while the error rate is high; 1 if
+	// zero.
+	Degraded float64
+	// ErrorRate is the number of ERROR and FATAL lines per second at which
+	// sampling switches to Degraded. It must be positive.
+	ErrorRate float64
+	// RecoverRate is the error rate at or below which sampling switches back
+	// to Healthy; half ErrorRate if zero. Keeping it below ErrorRate stops a
+	// rate close to the threshold from switching back and forth.
+	RecoverRate float64
+	// Window is the period over which the error rate is measured; ten
+	// seconds if zero. Sampling degrades as soon as the errors in a window
+	// reach the rate, and recovers only at the end of a window.
+	Window time.Duration
+}
+
+// SamplingStats reports the activity of adaptive sampling; see
+// SetAdaptiveSampling.
+type SamplingStats struct {
+	kept        int64
+	dropped     int64
+	transitions int64
+	errorRate   uint64 // math.Float64bits of the rate of the last window.
+	degraded    uint32
+}
+
+// Kept returns the number of INFO lines written while sampling.
+func (s *SamplingStats) Kept() int64 {
+	return atomic.LoadInt64(&s.kept)
+}
+
+// Dropped returns the number of INFO lines dropped by sampling.
+func (s *SamplingStats) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Transitions returns the number of switches between the healthy and
+// degraded states.
+func (s *SamplingStats) Transitions() int64 {
+	return atomic.LoadInt64(&s.transitions)
+}
+
+// Degraded reports whether the error rate is high, so that INFO lines are
+// sampled at the Degraded fraction.
+func (s *SamplingStats) Degraded() bool {
+	return atomic.LoadUint32(&s.degraded) != 0
+}
+
+// ErrorRate returns the error rate, in lines per second, measured over the
+// last complete window.
+func (s *SamplingStats) ErrorRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.errorRate))
+}
+
+// Sampling reports the activity of adaptive sampling.
+var Sampling SamplingStats
+
+// sampler holds the state of adaptive sampling.
+type sampler struct {
+	cfg AdaptiveSampling
+
+	mu       sync.Mutex
+	start    time.Time // Of the current window; zero before the first line.
+	errors   float64   // In the current window.
+	degraded bool
+	credit   float64 // Fraction of a line owed to the output.
+}
+
+// samplerPtr holds the *sampler installed by SetAdaptiveSampling, or nil.
+var samplerPtr atomic.Value
+
+// SetAdaptiveSampling samples INFO lines, including V lines, as configured
+// by s, and counts them in Sampling. WARNING and higher lines are always
+// written. A nil s turns sampling off, which is the default.
+//
+// Lines are sampled evenly rather than at random: at a fraction of 0.1,
+// every tenth INFO line is written.
+func SetAdaptiveSampling(s *AdaptiveSampling) error {
+	if s == nil {
+		samplerPtr.Store((*sampler)(nil))
+		return nil
+	}
+	cfg := *s
+	if cfg.Degraded == 0 {
+		cfg.Degraded = 1
+	}
+	if cfg.RecoverRate == 0 {
+		cfg.RecoverRate = cfg.ErrorRate / 2
+	}
+	if cfg.Window == 0 {
+		cfg.Window = 10 * time.Second
+	}
+	switch {
+	case cfg.Healthy < 0 || cfg.Healthy > 1 || cfg.Degraded < 0 || cfg.Degraded > 1:
+		return errors.New("log: sampling fractions must be between 0 and 1")
+	case cfg.ErrorRate <= 0:
+		return errors.New("log: sampling error rate must be positive")
+	case cfg.RecoverRate < 0 || cfg.RecoverRate > cfg.ErrorRate:
+		return errors.New("log: sampling recover rate must be between 0 and the error rate")
+	case cfg.Window < 0:
+		return errors.New("log: negative sampling window")
+	}
+	atomic.StoreUint32(&Sampling.degraded, 0)
+	samplerPtr.Store(&sampler{cfg: cfg})
+	return nil
+}
+
+// sampledOut counts a line of severity s logged at t and reports whether
+// sampling drops it.
+func (l *loggingT) sampledOut(s severity, t time.Time) bool {
+	sp, _ := samplerPtr.Load().(*sampler)
+	if sp == nil {
+		return false
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.advance(t)
+	if s >= errorLog {
+		sp.errors++
+		if !sp.degraded && sp.errors >= sp.cfg.ErrorRate*sp.cfg.Window.Seconds() {
+			sp.setDegraded(true)
+		}
+	}
+	if s != infoLog {
+		return false
+	}
+	fraction := sp.cfg.Healthy
+	if sp.degraded {
+		fraction = sp.cfg.Degraded
+	}
+	sp.credit += fraction
+	if sp.credit >= 1 {
+		sp.credit--
+		atomic.AddInt64(&Sampling.kept, 1)
+		return false
+	}
+	atomic.AddInt64(&Sampling.dropped, 1)
+	return true
+}
+
+// advance ends the current window if t is past it, measuring its error rate.
+// sp.mu is held.
+func (sp *sampler) advance(t time.Time) {
+	if sp.start.IsZero() {
+		sp.start = t
+		return
+	}
+	elapsed := t.Sub(sp.start)
+	if elapsed < sp.cfg.Window {
+		return
+	}
+	rate := sp.errors / elapsed.Seconds()
+	atomic.StoreUint64(&Sampling.errorRate, math.Float64bits(rate))
+	switch {
+	case !sp.degraded && rate >= sp.cfg.ErrorRate:
+		sp.setDegraded(true)
+	case sp.degraded && rate <= sp.cfg.RecoverRate:
+		sp.setDegraded(false)
+	}
+	sp.start, sp.errors = t, 0
+}
+
+// setDegraded switches between the healthy and degraded states.
+// sp.mu is held.
+func (sp *sampler) setDegraded(on bool) {
+	sp.degraded = on
+	var v uint32
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&Sampling.degraded, v)
+	atomic.AddInt64(&Sampling.transitions, 1)
+}
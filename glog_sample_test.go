@@ -0,0 +1,129 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveSampling(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetClock(nil)
+	defer SetAdaptiveSampling(nil)
+	now := time.Date(2030, 5, 6, 7, 8, 9, 0, time.Local)
+	at := func(d time.Duration) { SetClock(fixedClock{t: now.Add(d), pending: new([]func())}) }
+	at(0)
+	if err := SetAdaptiveSampling(&AdaptiveSampling{Healthy: 0.25, ErrorRate: 1, Window: 4 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	kept, dropped, transitions := Sampling.Kept(), Sampling.Dropped(), Sampling.Transitions()
+	for i := 0; i < 8; i++ {
+		Info("healthy")
+	}
+	Warning("warned")
+	if n := strings.Count(contents(infoLog), "healthy"); n != 2 {
+		t.Errorf("wrote %d of 8 healthy lines, want 2", n)
+	}
+	if !contains(warningLog, "warned", t) {
+		t.Error("WARNING line sampled")
+	}
+
+	// Four errors in a 4s window reach the rate of 1/s at once.
+	for i := 0; i < 3; i++ {
+		Error("failed")
+	}
+	if Sampling.Degraded() {
+		t.Error("degraded below the error rate")
+	}
+	Error("failed")
+	if !Sampling.Degraded() {
+		t.Fatal("not degraded at the error rate")
+	}
+	for i := 0; i < 4; i++ {
+		Info("degraded")
+	}
+	if n := strings.Count(contents(infoLog), "degraded"); n != 4 {
+		t.Errorf("wrote %d of 4 degraded lines, want 4", n)
+	}
+
+	// A rate of 0.75/s, above the recover rate of 0.5/s, stays degraded.
+	at(4 * time.Second)
+	Error("failed")
+	Error("failed")
+	Error("failed")
+	at(8 * time.Second)
+	Info("still degraded")
+	if !Sampling.Degraded() || Sampling.ErrorRate() != 0.75 {
+		t.Errorf("degraded %t at rate %v, want true at 0.75", Sampling.Degraded(), Sampling.ErrorRate())
+	}
+	at(12 * time.Second)
+	Info("recovered")
+	if Sampling.Degraded() || Sampling.ErrorRate() != 0 {
+		t.Errorf("degraded %t at rate %v, want false at 0", Sampling.Degraded(), Sampling.ErrorRate())
+	}
+	if got := Sampling.Transitions() - transitions; got != 2 {
+		t.Errorf("%d transitions", got)
+	}
+	if k, d := Sampling.Kept()-kept, Sampling.Dropped()-dropped; k != 7 || d != 7 {
+		t.Errorf("kept %d and dropped %d, want 7 and 7", k, d)
+	}
+}
+
+func TestAdaptiveSamplingErrors(t *testing.T) {
+	defer SetAdaptiveSampling(nil)
+	for _, s := range []AdaptiveSampling{
+		{Healthy: 2, ErrorRate: 1},
+		{Healthy: 0.5, Degraded: -1, ErrorRate: 1},
+		{Healthy: 0.5},
+		{Healthy: 0.5, ErrorRate: 1, RecoverRate: 2},
+		{Healthy: 0.5, ErrorRate: 1, Window: -time.Second},
+	} {
+		if err := SetAdaptiveSampling(&s); err == nil {
+			t.Errorf("SetAdaptiveSampling(%+v) succeeded", s)
+		}
+	}
+}
+
+// Test that the lines of a LogBlock are sampled, and their errors counted,
+// like others.
+func TestAdaptiveSamplingBlock(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetClock(nil)
+	defer SetAdaptiveSampling(nil)
+	SetClock(fixedClock{t: time.Date(2030, 5, 6, 7, 8, 9, 0, time.Local), pending: new([]func())})
+	if err := SetAdaptiveSampling(&AdaptiveSampling{Healthy: 0.5, Degraded: 0.25, ErrorRate: 1, Window: 2 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	b := Block()
+	for i := 0; i < 4; i++ {
+		b.Info("healthy")
+	}
+	b.Commit()
+	if n := strings.Count(contents(infoLog), "healthy"); n != 2 {
+		t.Errorf("wrote %d of 4 healthy block lines, want 2", n)
+	}
+	b.Error("failed")
+	b.Error("failed")
+	b.Commit()
+	if !Sampling.Degraded() {
+		t.Error("block errors not counted toward the error rate")
+	}
+}
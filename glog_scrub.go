@@ -0,0 +1,147 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Masking of text rendered by String and MarshalJSON methods.
+
+package glog
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+)
+
+var (
+	// scrubKeyValueRe matches key=value and "key": "value" pairs.
+	scrubKeyValueRe = regexp.MustCompile(`("?)([A-Za-z_][A-Za-z0-9_]*)("?\s*[:=]\s*"?)([^",&;\s}\]]+)`)
+	scrubEmailRe    = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	scrubIdentityRe = regexp.MustCompile(`\b\d{17}[\dXx]\b`)
+	scrubPhoneRe    = regexp.MustCompile(`\b1[3-9]\d{9}\b`)
+	scrubCardRe     = regexp.MustCompile(`\b\d{13,19}\b`)
+)
+
+// SetMaskRendered controls whether values that implement fmt.Stringer or
+// json.Marshaler are masked. Their fields cannot be inspected, since they
+// control their own rendering, so when on, the output of their String or
+// MarshalJSON method is scrubbed instead: values of sensitive keys in
+// key=value and "key": "value" pairs are masked as for maps, as are email
+// addresses, identity card numbers, mobile phone numbers, card numbers that
+// pass the Luhn check, bearer tokens and API keys. Such values are then
+// logged as strings. It is off by default.
+func SetMaskRendered(on bool) {
+	var v uint32
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&logging.maskRendered, v)
+}
+
+// renderMasked returns the scrubbed rendering of v and true if v is to be
+// masked that way.
+func (l *loggingT) renderMasked(v interface{}) (string, bool) {
+	if atomic.LoadUint32(&l.maskRendered) == 0 {
+		return "", false
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return "", false
+	}
+	var text string
+	switch r := v.(type) {
+	case fmt.Stringer:
+		text = r.String()
+	case json.Marshaler:
+		b, err := r.MarshalJSON()
+		if err != nil {
+			return "", false
+		}
+		text = string(b)
+	default:
+		return "", false
+	}
+	return l.scrub(text), true
+}
+
+// scrub masks the sensitive values found in text.
+func (l *loggingT) scrub(text string) string {
+	text = scrubKeyValueRe.ReplaceAllStringFunc(text, func(pair string) string {
+		m := scrubKeyValueRe.FindStringSubmatch(pair)
+		masked, _ := l.transform(map[string]interface{}{m[2]: m[4]}).(map[string]interface{})
+		value, ok := masked[m[2]].(string)
+		if !ok {
+			return pair
+		}
+		return m[1] + m[2] + m[3] + value
+	})
+	text = l.redactTokens(text)
+	if l.filterEmail {
+		text = scrubEmailRe.ReplaceAllStringFunc(text, ShrineEmail)
+	}
+	if l.filterIdentity {
+		text = scrubIdentityRe.ReplaceAllStringFunc(text, ShrineIdentity)
+	}
+	if l.filterPhone {
+		text = scrubPhoneRe.ReplaceAllStringFunc(text, ShrinePhoneNumber)
+	}
+	if l.filterCard {
+		text = scrubCardRe.ReplaceAllStringFunc(text, func(s string) string {
+			if !luhnValid(s) {
+				return s
+			}
+			return ShrineCardNo(s)
+		})
+	}
+	return text
+}
+
+// luhnValid reports whether the digits in s pass the Luhn check used by card
+// numbers.
+func luhnValid(s string) bool {
+	sum := 0
+	for i := 0; i < len(s); i++ {
+		d := int(s[len(s)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// maskRenderedValue implements flag.Value for the -mask_rendered flag.
+type maskRenderedValue struct{}
+
+// String is part of the flag.Value interface.
+func (maskRenderedValue) String() string {
+	return strconv.FormatBool(atomic.LoadUint32(&logging.maskRendered) != 0)
+}
+
+// Set is part of the flag.Value interface.
+func (maskRenderedValue) Set(value string) error {
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	SetMaskRendered(on)
+	return nil
+}
+
+// IsBoolFlag lets the flag be given without a value.
+func (maskRenderedValue) IsBoolFlag() bool { return true }
@@ -0,0 +1,135 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Queries of the settings in effect.
+
+package glog
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// VerbosityFor returns the V level in effect for the source file, given as a
+// path such as "/src/github.com/org/repo/db/conn.go": the higher of -v and
+// the -vmodule level of the file. V(n) is true in the file for n at or below
+// it.
+func VerbosityFor(file string) Level {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	v := logging.verbosity.get()
+	if m := logging.moduleLevel(file); m > v {
+		v = m
+	}
+	return v
+}
+
+// Settings returns the configuration in effect, whether it was set by flags,
+// Init, LoadConfig or the Set functions, so that programs can show it on a
+// status page. Init(WithConfig(Settings())) leaves the configuration as it
+// is.
+func Settings() Config {
+	c := Config{
+		VModule:         logging.vmodule.String(),
+		VLogger:         loggerSpecValue{}.String(),
+		TimingLevel:     timingLevel.get(),
+		AlsoToStderrV:   Level(logging.alsoStderrV.get() - 1),
+		TimeZone:        locationValue{}.String(),
+		Caller:          CallerMode(atomic.LoadInt32(&logging.callerMode)).String(),
+		CallerTrim:      append([]string(nil), callerTrimPrefixes()...),
+		CallerFunc:      atomic.LoadUint32(&logging.callerFunc) != 0,
+		GoroutineID:     atomic.LoadUint32(&goroutineIDs) != 0,
+		Fingerprint:     atomic.LoadUint32(&fingerprints) != 0,
+		MaskMaxDepth:    int(atomic.LoadInt32(&logging.maskMaxDepth)),
+		MaskMaxElements: int(atomic.LoadInt32(&logging.maskMaxElements)),
+		MaskMaxString:   int(atomic.LoadInt32(&logging.maskMaxString)),
+		MaskRendered:    atomic.LoadUint32(&logging.maskRendered) != 0,
+		MaskJSON:        atomic.LoadUint32(&maskJSON) != 0,
+		LeakDetection:   atomic.LoadUint32(&leakDetection) != 0,
+		AllowUnmasked:   atomic.LoadUint32(&allowUnmasked) != 0,
+		Labels:          GlobalLabels(),
+	}
+	flushd.mu.Lock()
+	c.FlushInterval = logging.flushInterval
+	flushd.mu.Unlock()
+	logging.freeListMu.Lock()
+	c.BufferPool = logging.bufPool
+	logging.freeListMu.Unlock()
+
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	c.LogDir = *logDir
+	c.ToStderr = logging.toStderr
+	c.ToStdout = logging.toStdout
+	c.ContainerMode = logging.container
+	c.AlsoToStderr = logging.alsoToStderr
+	c.AlsoToLower = logging.alsoToLower
+	c.PrecreateFiles = logging.precreate
+	c.AppendOnRestart = logging.appendFile
+	c.StderrThreshold = severityName[logging.stderrThreshold.get()]
+	c.FileThreshold = severityName[logging.fileThreshold.get()]
+	c.Verbosity = logging.verbosity.get()
+	if logging.traceLocation.isSet() {
+		c.BacktraceAt = fmt.Sprintf("%s:%d", logging.traceLocation.file, logging.traceLocation.line)
+	}
+	c.RotateInterval = *LogRotateInterval
+	c.RotateUTC = logging.rotateUTC
+	c.MaxSize = MaxSize
+	c.MaxAge = logging.maxAge
+	c.MaxFiles = logging.maxFiles
+	c.Compression = logging.compress
+	c.EncryptKeyEnv = logging.encryptEnv
+	for s, p := range logging.rotation {
+		if p != (RotationPolicy{}) {
+			if c.Rotation == nil {
+				c.Rotation = make(map[string]RotationPolicy)
+			}
+			c.Rotation[severityName[s]] = p
+		}
+	}
+	for s, fo := range logging.failover {
+		if len(fo.outputs) > 0 {
+			if c.Failover == nil {
+				c.Failover = make(map[string][]string)
+			}
+			c.Failover[severityName[s]] = append([]string(nil), fo.outputs...)
+		}
+	}
+	if logging.flushSeverity != noFlushSeverity {
+		c.FlushSeverity = severityName[logging.flushSeverity]
+	}
+	c.FlushSync = logging.flushSync
+	c.BatchLatency = logging.batchLatency
+	c.Sync = logging.syncPolicy
+	c.MaxLogMessageLen = logging.maxLogMessageLen
+	for s, n := range logging.maxLen {
+		if n != 0 {
+			if c.MaxMessageLen == nil {
+				c.MaxMessageLen = make(map[string]int)
+			}
+			c.MaxMessageLen[severityName[s]] = n
+		}
+	}
+	c.TruncateBytes = logging.truncateBytes
+	c.TruncateSuffix = logging.truncateSuffix
+	c.RecentLogKB = logging.recentKB
+	c.CrashLines = logging.crashLines
+	c.ExitGrace = exitGracePeriod()
+	c.FatalStacks = logging.fatalStacks.String()
+	c.FatalStacksMax = logging.stacksMax
+	c.CrashFile = logging.crashFile
+	return c
+}
@@ -0,0 +1,64 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSettings(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	saved := Settings()
+	defer func() {
+		if err := Init(WithConfig(saved)); err != nil {
+			t.Error(err)
+		}
+		logging.mu.Lock()
+		logging.needFlagParse = true
+		logging.mu.Unlock()
+	}()
+	err := Init(WithVerbosity(1), WithVModule("db/conn=3,glog_settings_test=2"),
+		WithStderrThreshold("WARNING"), WithRotationPolicy("ERROR", RotationPolicy{Interval: "hour"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]Level{
+		"/src/repo/db/conn.go":       3,
+		"/src/repo/cache/conn.go":    1,
+		"glog/glog_settings_test.go": 2,
+		"main.go":                    1,
+	} {
+		if got := VerbosityFor(file); got != want {
+			t.Errorf("VerbosityFor(%q) = %d, want %d", file, got, want)
+		}
+	}
+	c := Settings()
+	if c.Verbosity != 1 || c.VModule != "db/conn=3,glog_settings_test=2" || c.StderrThreshold != "WARNING" {
+		t.Errorf("Settings() = %+v", c)
+	}
+	if p := c.Rotation["ERROR"]; p.Interval != "hour" || len(c.Rotation) != 1 {
+		t.Errorf("Settings().Rotation = %v", c.Rotation)
+	}
+	if err := Init(WithConfig(c)); err != nil {
+		t.Fatal(err)
+	}
+	if again := Settings(); !reflect.DeepEqual(again, c) {
+		t.Errorf("settings changed by Init(WithConfig(Settings())):\n%+v\n%+v", c, again)
+	}
+}
@@ -0,0 +1,82 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Severities chosen at run time.
+
+package glog
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Severity is the severity of a log line: SeverityInfo, SeverityWarning,
+// SeverityError or SeverityFatal. It is also a flag.Value, for the
+// -stderrthreshold flag.
+type Severity int32
+
+// The severities, in increasing order.
+const (
+	SeverityInfo    = infoLog
+	SeverityWarning = warningLog
+	SeverityError   = errorLog
+	SeverityFatal   = fatalLog
+)
+
+// ParseSeverity returns the severity with the given name, such as "warning",
+// ignoring case.
+func ParseSeverity(name string) (Severity, error) {
+	s, ok := severityByName(name)
+	if !ok {
+		return 0, fmt.Errorf("log: unknown severity %q", name)
+	}
+	return s, nil
+}
+
+// String returns the name of s, such as "WARNING".
+func (s Severity) String() string {
+	if s < infoLog || s >= numSeverity {
+		return "Severity(" + strconv.Itoa(int(s)) + ")"
+	}
+	return severityName[s]
+}
+
+// Log logs to the log of severity s and those below it, like Info, Warning,
+// Error or Fatal, which makes it possible to choose the severity at run time:
+//
+//	sev := glog.SeverityInfo
+//	if resp.StatusCode >= 500 {
+//		sev = glog.SeverityError
+//	}
+//	glog.Log(sev, "request served: ", resp.Status)
+//
+// Unknown severities log to INFO. SeverityFatal exits, like Fatal.
+func Log(s Severity, args ...interface{}) {
+	logging.print(validSeverity(s), args...)
+}
+
+// Logf is like Log, but formats its arguments like Infof.
+func Logf(s Severity, format string, args ...interface{}) {
+	logging.printf(validSeverity(s), format, args...)
+}
+
+// validSeverity returns s, or infoLog if s is unknown.
+func validSeverity(s Severity) Severity {
+	if s < infoLog || s >= numSeverity {
+		return infoLog
+	}
+	return s
+}
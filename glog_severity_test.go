@@ -0,0 +1,51 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import "testing"
+
+func TestParseSeverity(t *testing.T) {
+	for name, want := range map[string]Severity{"info": SeverityInfo, "Warning": SeverityWarning, "ERROR": SeverityError} {
+		s, err := ParseSeverity(name)
+		if err != nil || s != want {
+			t.Errorf("ParseSeverity(%q) = %v, %v; want %v", name, s, err, want)
+		}
+	}
+	if _, err := ParseSeverity("loud"); err == nil {
+		t.Error("unknown severity accepted")
+	}
+	if SeverityWarning.String() != "WARNING" || Severity(9).String() != "Severity(9)" {
+		t.Errorf("got %q, %q", SeverityWarning.String(), Severity(9).String())
+	}
+}
+
+func TestLog(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	Log(SeverityWarning, "dynamic ", 1)
+	Logf(SeverityError, "status %d", 503)
+	Log(Severity(-1), "unknown")
+	if !contains(warningLog, "glog_severity_test.go:39] dynamic 1", t) {
+		t.Errorf("got %q", contents(warningLog))
+	}
+	if !contains(errorLog, "glog_severity_test.go:40] status 503", t) {
+		t.Errorf("got %q", contents(errorLog))
+	}
+	if !contains(infoLog, "unknown", t) {
+		t.Errorf("got %q", contents(infoLog))
+	}
+}
@@ -0,0 +1,194 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Sharded queueing of output, to reduce lock contention between goroutines.
+
+package glog
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// maxShardLines is the number of lines a shard holds before the goroutine
+// filling it writes the queued lines itself.
+const maxShardLines = 1024
+
+// queuedLine is a formatted line waiting in a shard.
+type queuedLine struct {
+	seq          uint64 // Order in which the line was logged.
+	s            severity
+	buf          *buffer
+	file         string
+	line         int
+	alsoToStderr bool
+	n            int // Bytes written, set when the line is written.
+}
+
+// outputShard is one of the independently locked queues of a shardedOutput.
+type outputShard struct {
+	mu     sync.Mutex
+	lines  []queuedLine
+	closed bool     // The shardedOutput has been replaced.
+	_      [32]byte // Keep shards on separate cache lines.
+}
+
+// shardedOutput queues lines in several shards, so that goroutines logging
+// concurrently contend only on a shard rather than on logging.mu, and writes
+// them from a single goroutine in the order they were logged.
+type shardedOutput struct {
+	shards  []outputShard
+	next    uint32        // Round-robin choice of shard.
+	seq     uint64        // Last sequence number handed out.
+	wake    chan struct{} // Wakes the writer goroutine.
+	quit    chan struct{}
+	stopped chan struct{}
+
+	drainMu sync.Mutex   // Serializes drain.
+	held    []queuedLine // Lines drained before earlier ones were queued.
+}
+
+var shardsMu sync.Mutex // Serializes SetOutputShards.
+
+// SetOutputShards makes logging calls queue their lines in n independently
+// locked shards instead of writing them, and has a single goroutine write the
+// queued lines in the order they were logged. Many goroutines logging at once
+// then no longer serialize on the lock guarding the log files. Lines are
+// written shortly after they are logged; Flush writes all queued lines, so
+// programs should call Flush before exiting, as they already must to see
+// the end of the log files. FATAL lines, and lines hitting -log_backtrace_at,
+// are written synchronously after the queued lines.
+//
+// n <= 0, the default, restores synchronous writing after writing the queued
+// lines.
+func SetOutputShards(n int) {
+	shardsMu.Lock()
+	defer shardsMu.Unlock()
+	var o *shardedOutput
+	if n > 0 {
+		o = &shardedOutput{
+			shards:  make([]outputShard, n),
+			wake:    make(chan struct{}, 1),
+			quit:    make(chan struct{}),
+			stopped: make(chan struct{}),
+		}
+		go o.run()
+	}
+	if old := logging.sharded(); old != nil {
+		logging.shards.Store(o)
+		old.stop()
+	} else {
+		logging.shards.Store(o)
+	}
+}
+
+// sharded returns the installed shardedOutput, or nil.
+func (l *loggingT) sharded() *shardedOutput {
+	o, _ := l.shards.Load().(*shardedOutput)
+	return o
+}
+
+// enqueue queues a line formatted in buf. It returns false if o has been
+// replaced, in which case the caller must write the line itself.
+func (o *shardedOutput) enqueue(s severity, buf *buffer, file string, line int, alsoToStderr bool) bool {
+	sh := &o.shards[atomic.AddUint32(&o.next, 1)%uint32(len(o.shards))]
+	sh.mu.Lock()
+	if sh.closed {
+		sh.mu.Unlock()
+		return false
+	}
+	// Taking the sequence number under the shard lock lets drain know which
+	// lines have been queued.
+	seq := atomic.AddUint64(&o.seq, 1)
+	sh.lines = append(sh.lines, queuedLine{seq: seq, s: s, buf: buf, file: file, line: line, alsoToStderr: alsoToStderr})
+	full := len(sh.lines) >= maxShardLines
+	sh.mu.Unlock()
+	if full {
+		o.drain()
+		return true
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// run is the writer goroutine.
+func (o *shardedOutput) run() {
+	defer close(o.stopped)
+	for {
+		select {
+		case <-o.wake:
+			o.drain()
+		case <-o.quit:
+			return
+		}
+	}
+}
+
+// stop shuts down o after it has been replaced, writing the lines it holds.
+func (o *shardedOutput) stop() {
+	close(o.quit)
+	<-o.stopped
+	for i := range o.shards {
+		sh := &o.shards[i]
+		sh.mu.Lock()
+		sh.closed = true
+		sh.mu.Unlock()
+	}
+	o.drain()
+}
+
+// drain writes the queued lines in order.
+func (o *shardedOutput) drain() {
+	o.drainMu.Lock()
+	defer o.drainMu.Unlock()
+	// Every line numbered up to mark is in a shard by the time its lock is
+	// taken below; later lines are held back for the next drain, as lines
+	// numbered before them may not be queued yet.
+	mark := atomic.LoadUint64(&o.seq)
+	lines := o.held
+	for i := range o.shards {
+		sh := &o.shards[i]
+		sh.mu.Lock()
+		lines = append(lines, sh.lines...)
+		sh.lines = sh.lines[:0]
+		sh.mu.Unlock()
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].seq < lines[j].seq })
+	n := sort.Search(len(lines), func(i int) bool { return lines[i].seq > mark })
+	ready, rest := lines[:n], lines[n:]
+	if len(ready) > 0 {
+		logging.mu.Lock()
+		for i := range ready {
+			q := &ready[i]
+			q.n = logging.writeLine(q.s, q.buf, q.file, q.line, q.alsoToStderr)
+			logging.putBuffer(q.buf)
+			q.buf = nil
+		}
+		logging.mu.Unlock()
+		for i := range ready {
+			countLine(ready[i].s, ready[i].n)
+		}
+	}
+	if len(rest) == 0 {
+		o.held = lines[:0]
+	} else {
+		o.held = append([]queuedLine(nil), rest...)
+	}
+}
@@ -0,0 +1,96 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Test that sharded output keeps every line, in order for each goroutine and
+// across Flush.
+func TestOutputShards(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	SetOutputShards(4)
+	defer SetOutputShards(0)
+
+	const goroutines, lines = 16, 200
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				Infof("g%d-%d", g, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	Info("last")
+	Flush()
+
+	out := strings.Split(strings.TrimSuffix(contents(infoLog), "\n"), "\n")
+	if len(out) != goroutines*lines+1 {
+		t.Fatalf("got %d lines, want %d", len(out), goroutines*lines+1)
+	}
+	next := make([]int, goroutines)
+	for _, l := range out[:len(out)-1] {
+		var g, i int
+		if _, err := fmt.Sscanf(l[strings.Index(l, "] ")+2:], "g%d-%d", &g, &i); err != nil {
+			t.Fatalf("bad line %q", l)
+		}
+		if i != next[g] {
+			t.Fatalf("goroutine %d: got line %d, want %d", g, i, next[g])
+		}
+		next[g]++
+	}
+	if !strings.HasSuffix(out[len(out)-1], "] last") {
+		t.Errorf("last line out of order: %q", out[len(out)-1])
+	}
+
+	SetOutputShards(0)
+	Info("sync")
+	if !contains(infoLog, "] sync\n", t) {
+		t.Error("synchronous output not restored")
+	}
+}
+
+func benchmarkParallel(b *testing.B, shards int) {
+	setFlags()
+	defer logging.swap(logging.swap([numSeverity]Sink{discardWriter{}, discardWriter{}, discardWriter{}, discardWriter{}}))
+	SetOutputShards(shards)
+	defer SetOutputShards(0)
+	b.SetParallelism(32)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			Infof("request %s took %d ms", "GET /", 12)
+		}
+	})
+	Flush()
+}
+
+func BenchmarkInfoParallel(b *testing.B) {
+	benchmarkParallel(b, 0)
+}
+
+func BenchmarkInfoParallelSharded(b *testing.B) {
+	benchmarkParallel(b, 16)
+}
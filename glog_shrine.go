@@ -0,0 +1,473 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Runtime configuration of the masking (shrine) rules.
+
+package glog
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+)
+
+// shrineRules lists the masking rules, named after their filter tags.
+var shrineRules = []string{
+	"card", "identity", "phone", "realname", "email", "pwd", "company",
+	"bankacct", "iban", "passport",
+}
+
+// ShrineMode selects how a masking rule protects sensitive values.
+type ShrineMode int
+
+const (
+	// ShrineMask hides characters of the value. It is the default.
+	ShrineMask ShrineMode = iota
+	// ShrineEncrypt replaces the value with "{enc:BASE64}", its AES-GCM
+	// encryption under the key set by SetShrineKey or SetShrineKeyProvider
+	// ("{enc:ID:BASE64}" if the key has an ID), so that authorized
+	// operators can recover it with DecryptShrined.
+	ShrineEncrypt
+	// ShrineTokenize replaces the value with "{tok:HEX}", a keyed HMAC-SHA256
+	// of it under the key set by SetShrineTokenKey. Equal values yield equal
+	// tokens, so log lines can be correlated without exposing the value.
+	ShrineTokenize
+)
+
+// ShrinePolicy controls how a masking rule renders sensitive values. It
+// replaces the rule's built-in choice of visible characters once installed
+// with SetShrinePolicy.
+type ShrinePolicy struct {
+	// Mode selects masking (ShrineMask), encryption (ShrineEncrypt) or
+	// tokenization (ShrineTokenize). The fields other than MaskRune only
+	// apply to ShrineMask.
+	Mode ShrineMode
+	// MaskRune replaces hidden characters. The zero value means '*'. It is
+	// also used by ShrineEncrypt and ShrineTokenize, which write six mask
+	// runes in place of values they cannot encrypt or tokenize for want of
+	// a key.
+	MaskRune rune
+	// KeepPrefix and KeepSuffix are the number of characters left visible
+	// at the start and end of the value. Values too short to hide anything
+	// are masked entirely.
+	KeepPrefix int
+	KeepSuffix int
+	// Redact replaces the whole value with six mask runes, hiding its length.
+	Redact bool
+}
+
+var (
+	shrinePoliciesMu sync.RWMutex
+	shrinePolicies   map[string]ShrinePolicy
+	// numShrinePolicies lets the shrine functions skip the lock when no
+	// policy is installed. It may be read using atomic.LoadInt32, but is only
+	// modified under shrinePoliciesMu.
+	numShrinePolicies int32
+)
+
+// defaultMaskMaxDepth is the default of the -mask_max_depth flag.
+const defaultMaskMaxDepth = 20
+
+// SetMaskMaxDepth sets the depth to which the nested structs, maps and slices
+// of logged values are masked. Values nested deeper are replaced by
+// "<max depth>" rather than written unmasked. Zero removes the limit; values
+// that contain themselves are replaced by "<cycle>" in any case.
+func SetMaskMaxDepth(n int) error {
+	if n < 0 {
+		return fmt.Errorf("log: negative mask depth %d", n)
+	}
+	atomic.StoreInt32(&logging.maskMaxDepth, int32(n))
+	return nil
+}
+
+// maskDepthValue implements flag.Value for the -mask_max_depth flag.
+type maskDepthValue struct{}
+
+// String is part of the flag.Value interface.
+func (maskDepthValue) String() string {
+	return strconv.Itoa(int(atomic.LoadInt32(&logging.maskMaxDepth)))
+}
+
+// Set is part of the flag.Value interface.
+func (maskDepthValue) Set(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	return SetMaskMaxDepth(n)
+}
+
+// SetShrinePolicy installs p for the named rule. Rule names are the filter
+// tags: "card", "identity", "phone", "realname", "email", "pwd", "company",
+// "bankacct", "iban" and "passport". It may be called at any time.
+func SetShrinePolicy(rule string, p ShrinePolicy) error {
+	if !isShrineRule(rule) {
+		return fmt.Errorf("log: unknown shrine rule %q", rule)
+	}
+	if p.KeepPrefix < 0 || p.KeepSuffix < 0 {
+		return fmt.Errorf("log: negative keep count in shrine policy for %q", rule)
+	}
+	shrinePoliciesMu.Lock()
+	defer shrinePoliciesMu.Unlock()
+	if shrinePolicies == nil {
+		shrinePolicies = make(map[string]ShrinePolicy)
+	}
+	shrinePolicies[rule] = p
+	atomic.StoreInt32(&numShrinePolicies, int32(len(shrinePolicies)))
+	return nil
+}
+
+// ClearShrinePolicy restores the built-in behavior of the named rule.
+func ClearShrinePolicy(rule string) {
+	shrinePoliciesMu.Lock()
+	defer shrinePoliciesMu.Unlock()
+	delete(shrinePolicies, rule)
+	atomic.StoreInt32(&numShrinePolicies, int32(len(shrinePolicies)))
+}
+
+// GetShrinePolicy returns the policy installed for the named rule, if any.
+func GetShrinePolicy(rule string) (ShrinePolicy, bool) {
+	if atomic.LoadInt32(&numShrinePolicies) == 0 {
+		return ShrinePolicy{}, false
+	}
+	shrinePoliciesMu.RLock()
+	defer shrinePoliciesMu.RUnlock()
+	p, ok := shrinePolicies[rule]
+	return p, ok
+}
+
+func isShrineRule(rule string) bool {
+	for _, r := range shrineRules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// shrineWithPolicy masks str according to the policy installed for rule. It
+// reports false if there is none, in which case the caller applies its
+// built-in masking.
+func shrineWithPolicy(rule, str string) (string, bool) {
+	p, ok := GetShrinePolicy(rule)
+	if !ok {
+		return "", false
+	}
+	return p.apply(str), true
+}
+
+// apply masks str according to p.
+func (p ShrinePolicy) apply(str string) string {
+	mask := p.MaskRune
+	if mask == 0 {
+		mask = '*'
+	}
+	if p.Mode == ShrineEncrypt {
+		if enc, err := shrineEncrypt(str); err == nil {
+			return enc
+		}
+		// Without a usable key, never fall back to the plain value.
+		return strings.Repeat(string(mask), 6)
+	}
+	if p.Mode == ShrineTokenize {
+		if tok, ok := shrineToken(str); ok {
+			return tok
+		}
+		return strings.Repeat(string(mask), 6)
+	}
+	if p.Redact {
+		return strings.Repeat(string(mask), 6)
+	}
+	runes := []rune(str)
+	n := len(runes)
+	if n <= p.KeepPrefix+p.KeepSuffix {
+		return strings.Repeat(string(mask), n)
+	}
+	return string(runes[:p.KeepPrefix]) +
+		strings.Repeat(string(mask), n-p.KeepPrefix-p.KeepSuffix) +
+		string(runes[n-p.KeepSuffix:])
+}
+
+// shrineKeys holds a shrineKeyProvider wrapping the KeyProvider set by
+// SetShrineKeyProvider or SetShrineKey.
+var shrineKeys atomic.Value
+
+// shrineKeyProvider wraps the provider so that atomic.Value can also hold its
+// absence.
+type shrineKeyProvider struct {
+	p KeyProvider
+}
+
+// shrineAEAD caches a *shrineCipher for the last key returned by the
+// provider, so that values are not each paying for the key schedule.
+var shrineAEAD atomic.Value
+
+// shrineCipher is the AEAD of key.
+type shrineCipher struct {
+	key  []byte
+	aead cipher.AEAD
+}
+
+const (
+	encPrefix = "{enc:"
+	encSuffix = "}"
+)
+
+// SetShrineKey sets the AES key, 16, 24 or 32 bytes long, used by rules whose
+// policy has Mode ShrineEncrypt. A nil key removes it; values are then fully
+// redacted instead.
+func SetShrineKey(key []byte) error {
+	if key == nil {
+		SetShrineKeyProvider(nil)
+		return nil
+	}
+	if _, err := newShrineAEAD(key); err != nil {
+		return err
+	}
+	SetShrineKeyProvider(StaticKey("", append([]byte(nil), key...)))
+	return nil
+}
+
+// SetShrineKeyProvider makes rules whose policy has Mode ShrineEncrypt
+// encrypt under the current key of p, and write its ID with the value as
+// "{enc:ID:BASE64}" so that DecryptShrinedWith can find the key again after
+// a rotation. An empty ID gives the "{enc:BASE64}" form of SetShrineKey. If
+// p fails, values are fully redacted; a nil p removes the provider.
+func SetShrineKeyProvider(p KeyProvider) {
+	shrineKeys.Store(shrineKeyProvider{p})
+}
+
+func newShrineAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// cachedShrineAEAD returns the AEAD of key, reusing the last one built.
+func cachedShrineAEAD(key []byte) (cipher.AEAD, error) {
+	if c, _ := shrineAEAD.Load().(*shrineCipher); c != nil && bytes.Equal(c.key, key) {
+		return c.aead, nil
+	}
+	aead, err := newShrineAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	shrineAEAD.Store(&shrineCipher{append([]byte(nil), key...), aead})
+	return aead, nil
+}
+
+// shrineEncrypt encrypts str under the current shrine key.
+func shrineEncrypt(str string) (string, error) {
+	k, _ := shrineKeys.Load().(shrineKeyProvider)
+	if k.p == nil {
+		return "", errors.New("log: no shrine key")
+	}
+	id, key, err := k.p.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+	aead, err := cachedShrineAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(str), nil)
+	if id != "" {
+		id += ":"
+	}
+	return encPrefix + id + base64.StdEncoding.EncodeToString(sealed) + encSuffix, nil
+}
+
+// DecryptShrined recovers a value encrypted by a ShrineEncrypt policy. enc is
+// the "{enc:BASE64}" or "{enc:ID:BASE64}" text found in the log and key the
+// AES key it was encrypted under.
+func DecryptShrined(enc string, key []byte) (string, error) {
+	return decryptShrined(enc, func(string) ([]byte, error) { return key, nil })
+}
+
+// DecryptShrinedWith is like DecryptShrined but takes the key from p by the
+// ID recorded in enc, the empty ID for the "{enc:BASE64}" form.
+func DecryptShrinedWith(enc string, p KeyProvider) (string, error) {
+	return decryptShrined(enc, p.GetKey)
+}
+
+func decryptShrined(enc string, getKey func(keyID string) ([]byte, error)) (string, error) {
+	if !strings.HasPrefix(enc, encPrefix) || !strings.HasSuffix(enc, encSuffix) {
+		return "", errors.New("log: not an encrypted value")
+	}
+	body := enc[len(encPrefix) : len(enc)-len(encSuffix)]
+	// Base64 has no colon, so the ID is whatever precedes the last one.
+	var id string
+	if i := strings.LastIndexByte(body, ':'); i >= 0 {
+		id, body = body[:i], body[i+1:]
+	}
+	sealed, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return "", err
+	}
+	key, err := getKey(id)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newShrineAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("log: encrypted value too short")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// shrineTokenKey holds the []byte HMAC key set by SetShrineTokenKey.
+var shrineTokenKey atomic.Value
+
+// tokenLen is the number of hex digits of the HMAC kept in a token. 64 bits
+// make accidental collisions between distinct values negligible.
+const tokenLen = 16
+
+// SetShrineTokenKey sets the secret key used by rules whose policy has Mode
+// ShrineTokenize. Tokens are only comparable between processes that share the
+// key. A nil key removes it; values are then fully redacted instead.
+func SetShrineTokenKey(key []byte) {
+	shrineTokenKey.Store(append([]byte(nil), key...))
+}
+
+// shrineToken returns the token for str, or false if no key is set.
+func shrineToken(str string) (string, bool) {
+	key, _ := shrineTokenKey.Load().([]byte)
+	if len(key) == 0 {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(str))
+	return "{tok:" + hex.EncodeToString(mac.Sum(nil))[:tokenLen] + "}", true
+}
+
+// shrineSamples holds, for each rule, a typical value and the function that
+// masks it, for ValidateShrineRules.
+var shrineSamples = []struct {
+	rule, sample string
+	shrine       func(string) string
+}{
+	{"card", "6225880137706868", ShrineCardNo},
+	{"identity", "110101199003074578", ShrineIdentity},
+	{"phone", "13812345678", ShrinePhoneNumber},
+	{"realname", "Zhang Sanfeng", ShrineRealName},
+	{"email", "zhangsan@example.com", ShrineEmail},
+	{"pwd", "correct horse", func(string) string { return ShrinePwdStr() }},
+	{"company", "Acme Widgets Limited", ShrineCompanyName},
+	{"bankacct", "6225880137706868", ShrineBankAccount},
+	{"iban", "GB82WEST12345698765432", ShrineIBAN},
+	{"passport", "E12345678", ShrinePassport},
+}
+
+// ValidateShrineRules checks the masking rules as currently configured. It
+// reports policies that cannot work as intended, such as encryption without a
+// key or a mask rune that could be mistaken for data, and rules that hide
+// fewer than three characters of a typical value, such as a 16-digit card
+// number, e.g. because of large KeepPrefix and KeepSuffix counts. It is meant
+// to be run in tests and at startup, before sensitive data is logged.
+func ValidateShrineRules() error {
+	var problems []string
+	for _, rule := range shrineRules {
+		p, ok := GetShrinePolicy(rule)
+		if !ok {
+			continue
+		}
+		switch p.Mode {
+		case ShrineEncrypt:
+			k, _ := shrineKeys.Load().(shrineKeyProvider)
+			if k.p == nil {
+				problems = append(problems, fmt.Sprintf("%s: encryption policy without a key; see SetShrineKey", rule))
+			} else if _, _, err := k.p.CurrentKey(); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: encryption key: %v", rule, err))
+			}
+		case ShrineTokenize:
+			if key, _ := shrineTokenKey.Load().([]byte); len(key) == 0 {
+				problems = append(problems, fmt.Sprintf("%s: tokenization policy without a key; see SetShrineTokenKey", rule))
+			}
+		case ShrineMask:
+			if p.MaskRune != 0 && (unicode.IsLetter(p.MaskRune) || unicode.IsDigit(p.MaskRune)) {
+				problems = append(problems, fmt.Sprintf("%s: mask rune %q could be mistaken for data", rule, p.MaskRune))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("%s: unknown mode %d", rule, p.Mode))
+		}
+	}
+	for _, s := range shrineSamples {
+		if out := s.shrine(s.sample); hiddenRunes(s.sample, out) < minHiddenRunes {
+			problems = append(problems, fmt.Sprintf("%s: %q is masked as %q", s.rule, s.sample, out))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("log: shrine rules: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// minHiddenRunes is the number of characters of a sample value that a rule
+// must hide to pass ValidateShrineRules.
+const minHiddenRunes = 3
+
+// hiddenRunes returns the number of characters of value that differ in its
+// masked form. Values masked to another length, e.g. by encryption, count as
+// wholly hidden.
+func hiddenRunes(value, masked string) int {
+	v, m := []rune(value), []rune(masked)
+	if len(v) != len(m) {
+		return len(v)
+	}
+	n := 0
+	for i := range v {
+		if v[i] != m[i] {
+			n++
+		}
+	}
+	return n
+}
+
+// PreviewMasking returns args as they would be written in the message of a log
+// line, formatted in the manner of fmt.Print and masked by the current rules,
+// without logging them. It lets tests check that sensitive values are masked.
+func PreviewMasking(args ...interface{}) string {
+	buf := logging.getBuffer()
+	defer logging.putBuffer(buf)
+	logging.formatArgs(buf, tprint, "", args)
+	return buf.String()
+}
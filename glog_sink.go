@@ -0,0 +1,56 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Sinks that replace the log files.
+
+package glog
+
+import (
+	"fmt"
+	"io"
+)
+
+// Sink is a destination that replaces the log file of a severity, set with
+// SetSink. It receives the same lines, each in a single Write call with its
+// header and trailing newline, and is flushed, synced and closed when the
+// log files would be. Its methods are called with the logging lock held.
+type Sink interface {
+	io.Writer
+	Flush() error
+	Sync() error
+	Close() error
+}
+
+// SetSink makes sink the destination of the lines otherwise written to the
+// log file of severity s, including the lines of higher severities copied
+// to it. A nil sink restores the log file, which is created with its next
+// line. The previous sink or log file is flushed and closed, and a failover
+// of s ends. A Write error of sink is handled like that of a log file: it
+// fails over as set by SetFailover or else, without an error handler, exits.
+func SetSink(s Severity, sink Sink) error {
+	if s < infoLog || s >= numSeverity {
+		return fmt.Errorf("log: unknown severity %v", s)
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	if old := logging.file[s]; old != nil {
+		old.Flush()
+		old.Close()
+	}
+	logging.file[s] = sink
+	logging.failover[s].at = 0
+	return nil
+}
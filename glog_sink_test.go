@@ -0,0 +1,82 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testSink is a Sink that records its lines and calls.
+type testSink struct {
+	flushBuffer
+	err    error // Returned by Write.
+	closed int
+}
+
+func (s *testSink) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.flushBuffer.Write(p)
+}
+
+func (s *testSink) Close() error {
+	s.closed++
+	return nil
+}
+
+// Test that a sink receives the lines of its severity and those copied to
+// it, and is closed when replaced.
+func TestSetSink(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	sink := new(testSink)
+	if err := SetSink(SeverityWarning, sink); err != nil {
+		t.Fatal(err)
+	}
+	Info("info")
+	Warning("warning")
+	Error("error")
+	if got := sink.String(); strings.Contains(got, "info") || !strings.Contains(got, "] warning\n") || !strings.Contains(got, "] error\n") {
+		t.Errorf("sink got %q", got)
+	}
+	if !contains(infoLog, "] warning\n", t) {
+		t.Error("line not copied to INFO")
+	}
+	if err := SetSink(SeverityWarning, new(flushBuffer)); err != nil {
+		t.Fatal(err)
+	}
+	if sink.closed != 1 {
+		t.Errorf("replaced sink closed %d times", sink.closed)
+	}
+	if err := SetSink(Severity(7), sink); err == nil {
+		t.Error("no error for an unknown severity")
+	}
+
+	var errs []error
+	SetErrorHandler(func(err error) { errs = append(errs, err) })
+	defer SetErrorHandler(nil)
+	failing := &testSink{err: errors.New("sink down")}
+	SetSink(SeverityError, failing)
+	capture(t, &os.Stderr, func() { Error("lost") })
+	if len(errs) != 1 || errs[0] != failing.err {
+		t.Errorf("handler called with %v, want the error of the sink", errs)
+	}
+}
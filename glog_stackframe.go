@@ -0,0 +1,103 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Goroutine stacks attached to log lines, as frames in records.
+
+package glog
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// StackFrame is a frame of the goroutine stack attached to a record: by
+// -log_backtrace_at, by RecoverAndLog and the like, or to a FATAL line. The
+// frames are listed innermost first.
+type StackFrame struct {
+	Func string `json:"func"` // Package-qualified, without arguments.
+	File string `json:"file"` // Full path, less SetCallerTrimPrefixes.
+	Line int    `json:"line"`
+}
+
+// attachStack appends stack, as returned by runtime.Stack, to the line in b
+// and marks it, so that records hold it as frames rather than in the message.
+func (b *buffer) attachStack(stack []byte) {
+	b.stackAt = b.Len()
+	b.stack = stack
+	b.Write(stack)
+}
+
+// parseStack returns the frames of the first goroutine in stack, as returned
+// by runtime.Stack or debug.Stack.
+func parseStack(stack []byte) []StackFrame {
+	var frames []StackFrame
+	lines := bytes.Split(stack, []byte{'\n'})
+	for i := 0; i < len(lines); i++ {
+		fn := string(lines[i])
+		if strings.HasPrefix(fn, "goroutine ") {
+			if frames != nil {
+				break // The next goroutine.
+			}
+			continue
+		}
+		if fn == "" || fn[0] == '\t' || fn[0] == '.' || i+1 == len(lines) {
+			continue // Blank, or "...additional frames elided...".
+		}
+		fn = strings.TrimPrefix(fn, "created by ")
+		if j := strings.Index(fn, " in goroutine "); j >= 0 {
+			fn = fn[:j]
+		}
+		if strings.HasSuffix(fn, ")") {
+			if j := strings.LastIndexByte(fn, '('); j > 0 {
+				fn = fn[:j]
+			}
+		}
+		loc := strings.TrimPrefix(string(lines[i+1]), "\t")
+		if j := strings.LastIndex(loc, " +0x"); j >= 0 {
+			loc = loc[:j]
+		}
+		f := StackFrame{Func: fn, File: loc}
+		if j := strings.LastIndexByte(loc, ':'); j >= 0 {
+			if n, err := strconv.Atoi(loc[j+1:]); err == nil {
+				f.File, f.Line = loc[:j], n
+			}
+		}
+		f.File = trimCaller(f.File)
+		frames = append(frames, f)
+		i++
+	}
+	return frames
+}
+
+// withStack returns msg followed by the frames of stack, one per pair of
+// lines as in the log files, for the encodings that have no stack field.
+func withStack(msg string, stack []StackFrame) string {
+	if len(stack) == 0 {
+		return msg
+	}
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, f := range stack {
+		b.WriteString("\n")
+		b.WriteString(f.Func)
+		b.WriteString("(...)\n\t")
+		b.WriteString(f.File)
+		b.WriteString(":")
+		b.WriteString(strconv.Itoa(f.Line))
+	}
+	return b.String()
+}
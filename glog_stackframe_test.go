@@ -0,0 +1,100 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseStack(t *testing.T) {
+	stack := []byte(`goroutine 7 [running]:
+main.(*server).handle(0xc000010000, {0x1, 0x2})
+	/src/main/server.go:42 +0x1d
+main.main.func1()
+	/src/main/main.go:10 +0x25
+...additional frames elided...
+created by main.main in goroutine 1
+	/src/main/main.go:9 +0x3b
+
+goroutine 1 [chan receive]:
+main.main()
+	/src/main/main.go:12 +0x4c
+`)
+	want := []StackFrame{
+		{"main.(*server).handle", "/src/main/server.go", 42},
+		{"main.main.func1", "/src/main/main.go", 10},
+		{"main.main", "/src/main/main.go", 9},
+	}
+	if got := parseStack(stack); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStack = %+v, want %+v", got, want)
+	}
+	if got := parseStack(nil); got != nil {
+		t.Errorf("parseStack(nil) = %+v", got)
+	}
+}
+
+// Test that the stacks of -log_backtrace_at and RecoverAndLog are frames in
+// JSON records, not part of the message, and stay in the text.
+func TestJSONStack(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var buf bytes.Buffer
+	defer AddJSONWriter("INFO", &buf)()
+	defer logging.traceLocation.Set("")
+	_, file, line, _ := runtime.Caller(0)
+	logging.traceLocation.Set(fmt.Sprintf("%s:%d", filepath.Base(file), line+2))
+	Info("traced")
+	panicky("boom")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d records, want 2:\n%s", len(lines), buf.String())
+	}
+	for i, want := range []struct{ msg, fn string }{
+		{"traced", "glog.TestJSONStack"},
+		{"panic: boom", "glog.panicky"},
+	} {
+		r, err := ParseRecord([]byte(lines[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Message != want.msg {
+			t.Errorf("message %q, want %q", r.Message, want.msg)
+		}
+		found := false
+		for _, f := range r.Stack {
+			if strings.HasSuffix(f.Func, want.fn) && strings.HasSuffix(f.File, "_test.go") && f.Line > 0 {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("no frame of %s in %+v", want.fn, r.Stack)
+		}
+		if text := r.Text(); !strings.Contains(text, want.fn+"(...)\n\t") {
+			t.Errorf("Text() = %q, want the frames", text)
+		}
+	}
+	if !contains(infoLog, "goroutine ", t) {
+		t.Errorf("stack missing from the log file: %q", contents(infoLog))
+	}
+}
@@ -0,0 +1,193 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Suppression of known noisy lines.
+
+package glog
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SuppressRule describes lines to drop, such as the known noise of a vendored
+// library. A line is dropped if it matches both Pattern and Location, of which
+// at least one must be set.
+type SuppressRule struct {
+	// Name identifies the rule in Suppressions and RemoveSuppression. It
+	// defaults to Location, or Pattern if Location is empty.
+	Name string
+	// Pattern is a regular expression matched against the message of a
+	// line, without its header.
+	Pattern string
+	// Location is the source location of the logging call, "file.go:42", or
+	// "file.go" for every line of the file. It is matched against the end
+	// of the path in the header, so "pkg/file.go" also works when the
+	// -log_caller mode writes full paths.
+	Location string
+	// Until, if not zero, is when the rule expires; lines are no longer
+	// dropped after it, though its count is still reported.
+	Until time.Time
+}
+
+// suppression is a rule added with AddSuppression.
+type suppression struct {
+	dropped int64 // Lines dropped; first for the alignment of atomic access.
+	name    string
+	re      *regexp.Regexp
+	file    string
+	line    int // Zero for every line of file.
+	until   time.Time
+}
+
+var (
+	suppressionsMu sync.Mutex // Serializes changes to suppressions.
+	// suppressions holds the []*suppression added. It is replaced, never
+	// modified, so that logging calls can read it without locking.
+	suppressions atomic.Value
+)
+
+// AddSuppression drops the lines that match r from now on, replacing any
+// rule of the same name. FATAL lines are never dropped. Suppressed lines are
+// discarded before hooks, sinks and outputs see them, and counted by rule.
+func AddSuppression(r SuppressRule) error {
+	if r.Pattern == "" && r.Location == "" {
+		return errors.New("log: suppression rule without pattern or location")
+	}
+	s := &suppression{name: r.Name, until: r.Until}
+	if s.name == "" {
+		s.name = r.Location
+		if s.name == "" {
+			s.name = r.Pattern
+		}
+	}
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("log: suppression rule %q: %v", s.name, err)
+		}
+		s.re = re
+	}
+	if r.Location != "" {
+		s.file = r.Location
+		if i := strings.LastIndexByte(r.Location, ':'); i >= 0 {
+			line, err := strconv.Atoi(r.Location[i+1:])
+			if err != nil || line <= 0 {
+				return fmt.Errorf("log: suppression rule %q: bad location %q, want file.go or file.go:42", s.name, r.Location)
+			}
+			s.file, s.line = r.Location[:i], line
+		}
+	}
+	suppressionsMu.Lock()
+	defer suppressionsMu.Unlock()
+	old, _ := suppressions.Load().([]*suppression)
+	rules := make([]*suppression, 0, len(old)+1)
+	for _, other := range old {
+		if other.name != s.name {
+			rules = append(rules, other)
+		}
+	}
+	suppressions.Store(append(rules, s))
+	return nil
+}
+
+// RemoveSuppression removes the rule with the given name, and its count.
+func RemoveSuppression(name string) {
+	suppressionsMu.Lock()
+	defer suppressionsMu.Unlock()
+	old, _ := suppressions.Load().([]*suppression)
+	for i, s := range old {
+		if s.name == name {
+			suppressions.Store(append(old[:i:i], old[i+1:]...))
+			return
+		}
+	}
+}
+
+// SuppressionStat reports the activity of a suppression rule.
+type SuppressionStat struct {
+	Name       string
+	Suppressed int64 // Lines dropped by the rule.
+	Expired    bool  // The Until time of the rule has passed.
+}
+
+// Suppressions returns the rules added with AddSuppression, sorted by
+// name, with the number of lines each has dropped.
+func Suppressions() []SuppressionStat {
+	rules, _ := suppressions.Load().([]*suppression)
+	now := logging.now()
+	stats := make([]SuppressionStat, len(rules))
+	for i, s := range rules {
+		stats[i] = SuppressionStat{
+			Name:       s.name,
+			Suppressed: atomic.LoadInt64(&s.dropped),
+			Expired:    s.expired(now),
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// expired reports whether the rule no longer applies at t.
+func (s *suppression) expired(t time.Time) bool {
+	return !s.until.IsZero() && !t.Before(s.until)
+}
+
+// match reports whether the rule drops the line logged at file:line with
+// message msg.
+func (s *suppression) match(file string, line int, msg string) bool {
+	if s.file != "" {
+		if s.line != 0 && s.line != line {
+			return false
+		}
+		if file != s.file && !strings.HasSuffix(file, "/"+s.file) {
+			return false
+		}
+	}
+	return s.re == nil || s.re.MatchString(msg)
+}
+
+// suppressed reports whether a rule drops the line of severity s formatted
+// in buf, and counts it against the first such rule.
+func (l *loggingT) suppressed(s severity, buf *buffer, file string, line int) bool {
+	rules, _ := suppressions.Load().([]*suppression)
+	if len(rules) == 0 || s >= fatalLog {
+		return false
+	}
+	var msg string
+	data := buf.Bytes()
+	end := len(data)
+	if buf.fields != nil && buf.fieldsAt >= buf.hdrLen {
+		end = buf.fieldsAt
+	}
+	if end > buf.hdrLen {
+		msg = strings.TrimSuffix(string(data[buf.hdrLen:end]), "\n")
+	}
+	for _, r := range rules {
+		if !r.expired(buf.when) && r.match(file, line, msg) {
+			atomic.AddInt64(&r.dropped, 1)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSuppression(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetClock(nil)
+	defer RemoveSuppression("retries")
+	defer RemoveSuppression("vendored")
+	now := time.Date(2030, 5, 6, 7, 8, 9, 0, time.Local)
+	SetClock(fixedClock{t: now, pending: new([]func())})
+
+	_, _, line, _ := runtime.Caller(0) // The Warning call below is 9 lines down.
+	loc := "glog_suppress_test.go:" + strconv.Itoa(line+9)
+	if err := AddSuppression(SuppressRule{Name: "vendored", Location: loc}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddSuppression(SuppressRule{Name: "retries", Pattern: `^retrying \d+`, Until: now.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		Warning("pool exhausted")
+		Info("retrying ", i)
+	}
+	Info("retrying later")
+	Warning("pool exhausted elsewhere")
+	if contains(warningLog, "pool exhausted\n", t) || contains(infoLog, "retrying 0", t) {
+		t.Errorf("suppressed lines written: %q", contents(infoLog))
+	}
+	if !contains(infoLog, "retrying later", t) || !contains(warningLog, "pool exhausted elsewhere", t) {
+		t.Errorf("unmatched lines dropped: %q", contents(infoLog))
+	}
+
+	SetClock(fixedClock{t: now.Add(time.Minute), pending: new([]func())})
+	Info("retrying 4")
+	if !contains(infoLog, "retrying 4", t) {
+		t.Error("expired rule still applies")
+	}
+	want := []SuppressionStat{{"retries", 3, true}, {"vendored", 3, false}}
+	if got := Suppressions(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Suppressions() = %+v, want %+v", got, want)
+	}
+
+	// Replacing a rule resets its count; removing it stops it.
+	AddSuppression(SuppressRule{Name: "retries", Pattern: "retrying"})
+	RemoveSuppression("vendored")
+	if got := Suppressions(); len(got) != 1 || got[0] != (SuppressionStat{Name: "retries"}) {
+		t.Errorf("Suppressions() = %+v", got)
+	}
+}
+
+func TestSuppressionErrors(t *testing.T) {
+	for _, r := range []SuppressRule{
+		{},
+		{Pattern: "("},
+		{Location: "file.go:x"},
+		{Location: "file.go:0"},
+	} {
+		if err := AddSuppression(r); err == nil {
+			t.Errorf("AddSuppression(%+v) succeeded", r)
+			RemoveSuppression(r.Location)
+		}
+	}
+	defer RemoveSuppression("glog_suppress_test.go")
+	if err := AddSuppression(SuppressRule{Location: "glog_suppress_test.go"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := Suppressions(); len(got) != 1 || got[0].Name != "glog_suppress_test.go" {
+		t.Errorf("default name: %+v", got)
+	}
+}
+
+// Test that FATAL lines are never suppressed.
+func TestSuppressionFatal(t *testing.T) {
+	defer RemoveSuppression("all")
+	AddSuppression(SuppressRule{Name: "all", Pattern: ""})
+	buf := logging.getBuffer()
+	if logging.suppressed(fatalLog, buf, "x.go", 1) {
+		t.Error("FATAL line suppressed")
+	}
+	logging.putBuffer(buf)
+}
+
+// Test that the lines of a LogBlock are suppressed like others.
+func TestSuppressionBlock(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer RemoveSuppression("noisy")
+	if err := AddSuppression(SuppressRule{Name: "noisy", Pattern: "^noisy"}); err != nil {
+		t.Fatal(err)
+	}
+	b := Block()
+	b.Info("noisy row")
+	b.Info("quiet row")
+	b.Commit()
+	if contains(infoLog, "noisy row", t) || !contains(infoLog, "quiet row", t) {
+		t.Errorf("unexpected INFO log %q", contents(infoLog))
+	}
+	if got := Suppressions(); len(got) != 1 || got[0].Suppressed != 1 {
+		t.Errorf("Suppressions() = %+v, want 1 line suppressed", got)
+	}
+}
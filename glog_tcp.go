@@ -0,0 +1,439 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Network sink sending newline-delimited JSON records, e.g. to Logstash.
+
+package glog
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TCPSinkConfig configures a TCPSink.
+type TCPSinkConfig struct {
+	// Addr is the host:port of the endpoint.
+	Addr string
+	// TLS, if non-nil, makes the sink connect with TLS.
+	TLS *tls.Config
+	// BufferSize is the number of records held in memory while the endpoint
+	// is unreachable. The default is 10000.
+	BufferSize int
+	// SpillDir, if set, is a directory where records that do not fit in the
+	// buffer are written until the endpoint comes back. Otherwise the oldest
+	// buffered records are dropped.
+	SpillDir string
+	// MaxSpillBytes, if positive, bounds the size of the spill files, so
+	// that an endpoint down for long cannot fill the disk. Records that
+	// would exceed it are dropped.
+	MaxSpillBytes int64
+	// DialTimeout bounds each connection attempt. The default is 5s.
+	DialTimeout time.Duration
+	// WriteTimeout bounds each write to the endpoint; a stalled endpoint is
+	// treated as a broken connection. The default is 10s.
+	WriteTimeout time.Duration
+	// MinBackoff and MaxBackoff bound the exponential delay between
+	// connection attempts. The defaults are 100ms and 30s.
+	MinBackoff, MaxBackoff time.Duration
+	// Encoder encodes the records sent. The default is JSONEncoder.
+	Encoder Encoder
+}
+
+// TCPSink sends log records, by default as newline-delimited JSON, to a TCP
+// or TLS endpoint such as a Logstash tcp input with the json_lines codec.
+// Register it with AddWriter:
+//
+//	glog.AddWriter("INFO", glog.NewTCPSink(glog.TCPSinkConfig{Addr: "logstash:5000"}))
+//
+// Logging never blocks on the network: records are queued and sent by a
+// background goroutine that reconnects with exponential backoff.
+type TCPSink struct {
+	cfg     TCPSinkConfig
+	dropped int64 // Updated atomically.
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   [][]byte
+	spill   *os.File // Receives records while the queue is full.
+	spilled int      // Number of records in the spill file.
+	nbytes  int64    // Size of the spill file.
+	pending string   // Spill file waiting to be replayed.
+	npend   int      // Number of records in the pending file.
+	pbytes  int64    // Size of the pending file.
+	sent    int64    // Bytes of the pending file already sent.
+	nspill  int      // Number of spill files created, for naming.
+	closed  bool
+	sending int           // Records taken by the sender goroutine, not yet written.
+	conn    net.Conn      // Connection of the sender goroutine, if any.
+	quit    chan struct{} // Closed by Close to interrupt reconnection delays.
+	done    chan struct{} // Closed when the sender goroutine exits.
+}
+
+// NewTCPSink returns a TCPSink for cfg and starts its sender goroutine.
+func NewTCPSink(cfg TCPSinkConfig) *TCPSink {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 10000
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 10 * time.Second
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = 30 * time.Second
+	}
+	if cfg.Encoder == nil {
+		cfg.Encoder = JSONEncoder
+	}
+	s := &TCPSink{cfg: cfg, quit: make(chan struct{}), done: make(chan struct{})}
+	s.cond = sync.NewCond(&s.mu)
+	go s.run()
+	return s
+}
+
+// Write queues p, a formatted log line, as the message of a record.
+// AddWriter does not call it; it sends fully structured records instead.
+func (s *TCPSink) Write(p []byte) (int, error) {
+	rec := &logRecord{Time: currentClock().Now(), Host: host, PID: pid, Message: string(p), Labels: globalLabels.Load().(*labelSet).labels}
+	return len(p), s.writeRecord(rec)
+}
+
+func (s *TCPSink) writeRecord(r *logRecord) error {
+	return s.enqueue(encode(s.cfg.Encoder, r))
+}
+
+// Dropped returns the number of records discarded because the buffer was
+// full and no spill directory was configured, spilling failed or would
+// exceed MaxSpillBytes, or the spill file was gone when the endpoint came
+// back.
+func (s *TCPSink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Close sends what is queued if the endpoint is reachable, stops the sender
+// goroutine and closes the connection. It waits at most DialTimeout plus
+// WriteTimeout for the queue to drain before closing the connection. Records
+// still buffered in memory are lost; spilled records stay on disk.
+func (s *TCPSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errSinkClosed
+	}
+	s.closed = true
+	close(s.quit)
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+	case <-time.After(s.cfg.DialTimeout + s.cfg.WriteTimeout):
+		// Interrupt the sender; it returns once its write fails.
+		s.mu.Lock()
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		s.mu.Unlock()
+		<-s.done
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.spill != nil {
+		s.spill.Close()
+		s.spill = nil
+	}
+	return nil
+}
+
+var (
+	errSinkClosed = errors.New("log: sink is closed")
+	errSpillFull  = errors.New("log: spill files are full")
+)
+
+// enqueue adds a JSON line to the queue, spilling or dropping if it is full.
+func (s *TCPSink) enqueue(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errSinkClosed
+	}
+	defer s.cond.Signal()
+	if s.spill == nil && len(s.queue) < s.cfg.BufferSize {
+		s.queue = append(s.queue, line)
+		return nil
+	}
+	if s.cfg.SpillDir != "" {
+		err := s.spillLine(line)
+		if err == nil {
+			return nil
+		}
+		if s.spill != nil {
+			// Keep ordering: nothing may overtake the spill file.
+			atomic.AddInt64(&s.dropped, 1)
+			if err == errSpillFull {
+				return nil
+			}
+			return err
+		}
+	}
+	s.queue = append(s.queue[1:], line)
+	atomic.AddInt64(&s.dropped, 1)
+	return nil
+}
+
+// spillLine appends line to the spill file, creating it if needed.
+// s.mu is held.
+func (s *TCPSink) spillLine(line []byte) error {
+	if max := s.cfg.MaxSpillBytes; max > 0 && s.nbytes+s.pbytes+int64(len(line)) > max {
+		return errSpillFull
+	}
+	if s.spill == nil {
+		s.nspill++
+		name := filepath.Join(s.cfg.SpillDir, fmt.Sprintf("%s.%d.spill.%d", program, pid, s.nspill))
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		s.spill = f
+	}
+	n, err := s.spill.Write(line)
+	s.nbytes += int64(n)
+	if err == nil {
+		s.spilled++
+	}
+	return err
+}
+
+// take waits for queued records and removes them from the queue. Once the
+// queue is empty, a spill file becomes pending for replay. It returns false
+// when the sink is closed and nothing is left to send.
+func (s *TCPSink) take() ([][]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.queue) == 0 && s.spill == nil && !s.closed {
+		s.cond.Wait()
+	}
+	if len(s.queue) == 0 && s.spill != nil {
+		s.spill.Close()
+		s.pending, s.npend, s.pbytes = s.spill.Name(), s.spilled, s.nbytes
+		s.spill, s.spilled, s.nbytes = nil, 0, 0
+		return nil, true
+	}
+	q := s.queue
+	s.queue = nil
+	s.sending = len(q)
+	return q, len(q) > 0 || !s.closed
+}
+
+// unsent returns the number of records not yet sent, counting a spill file
+// as one.
+func (s *TCPSink) unsent() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.queue) + s.sending
+	if s.spill != nil || s.pending != "" {
+		n++
+	}
+	return n
+}
+
+// FlushContext sends the queued records now and waits until they are sent,
+// or until ctx is done, in which case it returns an error wrapping that of
+// ctx. Logging is not blocked while it waits.
+func (s *TCPSink) FlushContext(ctx context.Context) error {
+	s.mu.Lock()
+	s.cond.Signal()
+	s.mu.Unlock()
+	return waitSent(ctx, "tcp "+s.cfg.Addr, s.unsent)
+}
+
+// waitSent polls unsent until it returns zero or ctx is done, in which case
+// it returns an error naming the sink and the records left.
+func waitSent(ctx context.Context, sink string, unsent func() int) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		n := unsent()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("log: %s: %d records unsent: %w", sink, n, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// requeue puts unsent records back at the front of the queue.
+func (s *TCPSink) requeue(lines [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(lines, s.queue...)
+	s.sending = 0
+	if over := len(s.queue) - s.cfg.BufferSize; over > 0 {
+		s.queue = s.queue[over:]
+		atomic.AddInt64(&s.dropped, int64(over))
+	}
+}
+
+func (s *TCPSink) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// setConn records the connection of the sender goroutine for Close.
+func (s *TCPSink) setConn(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn = conn
+}
+
+// deadlineWriter sets a write deadline on conn before each Write, so that a
+// stalled endpoint cannot block the sender goroutine.
+type deadlineWriter struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (w deadlineWriter) Write(p []byte) (int, error) {
+	w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	return w.conn.Write(p)
+}
+
+func (s *TCPSink) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: s.cfg.DialTimeout}
+	if s.cfg.TLS != nil {
+		return tls.DialWithDialer(d, "tcp", s.cfg.Addr, s.cfg.TLS)
+	}
+	return d.Dial("tcp", s.cfg.Addr)
+}
+
+// replay sends the pending spill file, removing it once sent. If sending
+// fails, the next replay resumes after the bytes already sent, so that they
+// are not sent twice. A spill file that cannot be opened, such as one removed
+// by a cleaner of temporary files, is counted as dropped.
+func (s *TCPSink) replay(w io.Writer) error {
+	s.mu.Lock()
+	name, sent := s.pending, s.sent
+	s.mu.Unlock()
+	if name == "" {
+		return nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		s.mu.Lock()
+		atomic.AddInt64(&s.dropped, int64(s.npend))
+		s.pending, s.npend, s.pbytes, s.sent = "", 0, 0, 0
+		s.mu.Unlock()
+		return nil
+	}
+	if _, err = f.Seek(sent, io.SeekStart); err == nil {
+		_, err = io.Copy(&replayWriter{s, w}, bufio.NewReader(f))
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+	os.Remove(name)
+	s.mu.Lock()
+	s.pending, s.npend, s.pbytes, s.sent = "", 0, 0, 0
+	s.mu.Unlock()
+	return nil
+}
+
+// replayWriter writes to w, adding the bytes written to the sent count of
+// the pending spill file.
+type replayWriter struct {
+	s *TCPSink
+	w io.Writer
+}
+
+func (rw *replayWriter) Write(p []byte) (int, error) {
+	n, err := rw.w.Write(p)
+	rw.s.mu.Lock()
+	rw.s.sent += int64(n)
+	rw.s.mu.Unlock()
+	return n, err
+}
+
+// run is the sender goroutine.
+func (s *TCPSink) run() {
+	defer close(s.done)
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+		s.setConn(nil)
+	}()
+	backoff := s.cfg.MinBackoff
+	for {
+		if conn == nil {
+			if s.isClosed() {
+				return
+			}
+			c, err := s.dial()
+			if err != nil {
+				select {
+				case <-time.After(backoff):
+				case <-s.quit:
+				}
+				if backoff *= 2; backoff > s.cfg.MaxBackoff {
+					backoff = s.cfg.MaxBackoff
+				}
+				continue
+			}
+			conn, backoff = c, s.cfg.MinBackoff
+			s.setConn(conn)
+		}
+		w := deadlineWriter{conn, s.cfg.WriteTimeout}
+		if err := s.replay(w); err != nil {
+			conn.Close()
+			conn = nil
+			continue
+		}
+		lines, ok := s.take()
+		if !ok {
+			return
+		}
+		for i, line := range lines {
+			if _, err := w.Write(line); err != nil {
+				s.requeue(lines[i:])
+				conn.Close()
+				conn = nil
+				break
+			}
+		}
+		s.mu.Lock()
+		s.sending = 0
+		s.mu.Unlock()
+	}
+}
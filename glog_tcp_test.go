@@ -0,0 +1,305 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordServer accepts connections on l and decodes the JSON records sent.
+type recordServer struct {
+	l       net.Listener
+	records chan logRecord
+	mu      sync.Mutex
+	conns   []net.Conn
+}
+
+func newRecordServer(t *testing.T, l net.Listener) *recordServer {
+	srv := &recordServer{l: l, records: make(chan logRecord, 100)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			srv.mu.Lock()
+			srv.conns = append(srv.conns, conn)
+			srv.mu.Unlock()
+			go func() {
+				sc := bufio.NewScanner(conn)
+				for sc.Scan() {
+					var r logRecord
+					if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+						t.Errorf("bad record %q: %v", sc.Text(), err)
+					}
+					srv.records <- r
+				}
+			}()
+		}
+	}()
+	return srv
+}
+
+// close stops the server, dropping its connections.
+func (srv *recordServer) close() {
+	srv.l.Close()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, c := range srv.conns {
+		c.Close()
+	}
+}
+
+func nextRecord(t *testing.T, c <-chan logRecord) logRecord {
+	select {
+	case r := <-c:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for record")
+	}
+	return logRecord{}
+}
+
+// Test that TCPSink sends JSON records, and spills and replays them in order
+// while the endpoint is down.
+func TestTCPSink(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	srv := newRecordServer(t, l)
+
+	sink := NewTCPSink(TCPSinkConfig{Addr: addr, BufferSize: 1, SpillDir: t.TempDir(), MinBackoff: 10 * time.Millisecond})
+	defer sink.Close()
+	defer AddWriter("WARNING", sink)()
+
+	Info("not sent")
+	Warning("tcp-one")
+	r := nextRecord(t, srv.records)
+	if r.Severity != "WARNING" || r.Message != "tcp-one" || r.File != "glog_tcp_test.go" || r.Line == 0 {
+		t.Errorf("unexpected record %+v", r)
+	}
+
+	// The first write after the peer goes away may be lost in the kernel;
+	// the second fails and makes the sink reconnect.
+	srv.close()
+	Error("probe")
+	time.Sleep(50 * time.Millisecond)
+	Error("probe")
+	time.Sleep(50 * time.Millisecond)
+	for _, m := range []string{"tcp-two", "tcp-three", "tcp-four"} {
+		Error(m)
+	}
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip("cannot listen again on ", addr, ": ", err)
+	}
+	srv = newRecordServer(t, l)
+	defer srv.close()
+	Error("tcp-five")
+	var got []string
+	for len(got) == 0 || got[len(got)-1] != "tcp-five" {
+		if m := nextRecord(t, srv.records).Message; m != "probe" {
+			got = append(got, m)
+		}
+	}
+	if want := []string{"tcp-two", "tcp-three", "tcp-four", "tcp-five"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got records %q, want %q", got, want)
+	}
+	if sink.Dropped() != 0 {
+		t.Errorf("dropped %d records", sink.Dropped())
+	}
+}
+
+// Test that Close returns promptly when the endpoint stops reading, and
+// closes the spill file.
+func TestTCPSinkStalled(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			accepted <- conn // Never read from.
+		}
+	}()
+	sink := NewTCPSink(TCPSinkConfig{Addr: l.Addr().String(), BufferSize: 1, SpillDir: t.TempDir(), DialTimeout: 50 * time.Millisecond, WriteTimeout: 50 * time.Millisecond})
+	big := make([]byte, 1<<16)
+	for i := range big {
+		big[i] = 'x'
+	}
+	for i := 0; i < 200; i++ {
+		sink.Write(big)
+	}
+	start := time.Now()
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Close took %v", d)
+	}
+	if sink.spill != nil {
+		t.Errorf("spill file %s left open", sink.spill.Name())
+	}
+	select {
+	case conn := <-accepted:
+		conn.Close()
+	default:
+	}
+}
+
+// Test that FlushContext waits for queued records to be sent, and gives up
+// at the context's deadline when they cannot be.
+func TestTCPSinkFlushContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newRecordServer(t, l)
+	sink := NewTCPSink(TCPSinkConfig{Addr: l.Addr().String(), SpillDir: t.TempDir(), MinBackoff: 10 * time.Millisecond})
+	defer sink.Close()
+	for i := 0; i < 10; i++ {
+		sink.Write([]byte("flushed\n"))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sink.FlushContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := sink.unsent(); n != 0 {
+		t.Errorf("%d records unsent after FlushContext", n)
+	}
+
+	srv.close()
+	sink.Write([]byte("probe\n"))
+	time.Sleep(50 * time.Millisecond)
+	sink.Write([]byte("probe\n"))
+	time.Sleep(50 * time.Millisecond)
+	sink.Write([]byte("stuck\n"))
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sink.FlushContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("FlushContext with the endpoint down returned %v, want a deadline error", err)
+	}
+}
+
+// failAfter accepts n bytes, then fails.
+type failAfter struct {
+	n   int
+	buf bytes.Buffer
+}
+
+func (w *failAfter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		w.buf.Write(p[:w.n])
+		n := w.n
+		w.n = 0
+		return n, errors.New("connection reset")
+	}
+	w.n -= len(p)
+	return w.buf.Write(p)
+}
+
+// Test that a replay interrupted by a failed write resumes where it stopped.
+func TestTCPSinkReplayResumes(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "spill")
+	data := []byte("{\"message\":\"one\"}\n{\"message\":\"two\"}\n{\"message\":\"three\"}\n")
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := &TCPSink{pending: name}
+	w := &failAfter{n: 25}
+	if err := s.replay(w); err == nil {
+		t.Fatal("replay succeeded through a failing writer")
+	}
+	if err := s.replay(w); err == nil {
+		t.Fatal("replay succeeded through a failing writer")
+	}
+	w.n = len(data)
+	if err := s.replay(w); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.buf.String(); got != string(data) {
+		t.Errorf("replayed %q, want %q", got, data)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("spill file left after replay: %v", err)
+	}
+}
+
+// Test that a spill file removed before its replay is counted as dropped
+// rather than stopping the sink.
+func TestTCPSinkReplayMissing(t *testing.T) {
+	s := &TCPSink{pending: filepath.Join(t.TempDir(), "gone"), npend: 3}
+	var buf bytes.Buffer
+	if err := s.replay(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if s.pending != "" || s.Dropped() != 3 {
+		t.Errorf("pending %q, dropped %d; want none pending and 3 dropped", s.pending, s.Dropped())
+	}
+}
+
+// Test that the spill files stay within MaxSpillBytes while the endpoint is
+// down, dropping the records beyond.
+func TestTCPSinkMaxSpill(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close() // Nothing listens there now.
+	dir := t.TempDir()
+	sink := NewTCPSink(TCPSinkConfig{Addr: addr, BufferSize: 1, SpillDir: dir, MaxSpillBytes: 1000, MinBackoff: time.Hour})
+	defer sink.Close()
+	line := []byte("spilled\n") // Some 150 bytes as a record.
+	for i := 0; i < 20; i++ {
+		if _, err := sink.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.spill.*"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("spill files %q, %v", files, err)
+	}
+	fi, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() > 1000 || fi.Size() == 0 {
+		t.Errorf("spill file of %d bytes, want at most 1000", fi.Size())
+	}
+	if sink.Dropped() == 0 {
+		t.Error("no records dropped beyond MaxSpillBytes")
+	}
+}
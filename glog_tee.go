@@ -0,0 +1,161 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Additional writers that receive a copy of the log output.
+
+package glog
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// teeWriter is an additional log destination registered with AddWriter.
+type teeWriter struct {
+	w      io.Writer
+	sev    severity   // Lowest severity written to w.
+	v      vThreshold // V threshold of w, set by AddWriterV.
+	failed bool       // The last write to w returned an error.
+}
+
+// AddWriter arranges for log lines of the named severity and above to also be
+// written to w, in addition to the log files or standard error. Each line is
+// passed to w in a single Write call, with its header and trailing newline.
+// If w has a Flush() error method it is called whenever the logs are flushed.
+//
+// Errors from w are reported once on standard error, or each to the
+// SetErrorHandler function, and otherwise ignored; they never affect the log
+// files or other writers. The returned function
+// removes w.
+//
+// w is called with the logging lock held, so every log call waits for it. It
+// must return quickly: put a queue in front of a slow destination, as TCPSink
+// does. It must not log through this package on its own goroutine, which
+// deadlocks; it may hand the line to another goroutine that logs it.
+//
+// Valid names are "INFO", "WARNING", "ERROR", and "FATAL".  If the name is not
+// recognized, AddWriter panics.
+func AddWriter(name string, w io.Writer) (remove func()) {
+	sev, ok := severityByName(name)
+	if !ok {
+		panic(fmt.Sprintf("log.AddWriter(%q): unrecognized severity name", name))
+	}
+	return addTee(sev, -1, w)
+}
+
+// AddWriterV is like AddWriter, but w also receives the lines of Logger.V up
+// to V level level, whatever -v and -vlogger are set to, and no lines above
+// it. See SetVThreshold.
+func AddWriterV(name string, level Level, w io.Writer) (remove func()) {
+	sev, ok := severityByName(name)
+	if !ok {
+		panic(fmt.Sprintf("log.AddWriterV(%q): unrecognized severity name", name))
+	}
+	return addTee(sev, level, w)
+}
+
+// addTee registers w for lines of severity sev and above, with V threshold
+// level if it is not negative.
+func addTee(sev severity, level Level, w io.Writer) (remove func()) {
+	t := &teeWriter{w: w, sev: sev}
+	t.v.set(level)
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.tees = append(logging.tees, t)
+	logging.updateMaxV()
+	return func() {
+		logging.mu.Lock()
+		defer logging.mu.Unlock()
+		for i, other := range logging.tees {
+			if other == t {
+				logging.tees = append(logging.tees[:i:i], logging.tees[i+1:]...)
+				logging.updateMaxV()
+				return
+			}
+		}
+	}
+}
+
+// AddJSONWriter is like AddWriter, but each line is passed to w as a single
+// JSON object, terminated by a newline, with the schema version, time,
+// severity, host, pid, file, line, logger name, message, fields and labels of
+// the line. It is meant for consumers that parse log output, such as the
+// glogtest package; ParseRecord decodes the records.
+// A goroutine stack attached to the line, by -log_backtrace_at,
+// RecoverAndLog or a FATAL line, is in the "stack" field as an array of
+// frames with "func", "file" and "line", rather than in the message.
+// Records over the -maxlogmessagelen limit stay valid JSON: fields are
+// dropped, then the message is shortened, and "truncated": true is added.
+func AddJSONWriter(name string, w io.Writer) (remove func()) {
+	return AddEncodedWriter(name, JSONEncoder, w)
+}
+
+// recordWriter is implemented by writers that consume log lines in
+// structured form. writeTees passes them records instead of text.
+type recordWriter interface {
+	writeRecord(r *logRecord) error
+}
+
+// writeTees writes data, a line of severity s formatted in buf, to the
+// registered writers.
+// l.mu is held.
+func (l *loggingT) writeTees(s severity, buf *buffer, file string, line int, data []byte) {
+	var rec *logRecord
+	for _, t := range l.tees {
+		if s < t.sev || !t.v.allows(buf.v) {
+			continue
+		}
+		var err error
+		if rw, ok := t.w.(recordWriter); ok {
+			if rec == nil {
+				// From the whole line: records are truncated by fitRecord.
+				rec = newLogRecord(s, buf, file, line, buf.Bytes())
+				l.fitRecord(s, rec)
+				rec.text = data
+			}
+			err = rw.writeRecord(rec)
+		} else {
+			_, err = t.w.Write(data)
+		}
+		if err != nil {
+			werr := &WriteError{t.w, err}
+			if !handleError(werr) && !t.failed {
+				fmt.Fprintf(os.Stderr, "%v\n", werr)
+			}
+			t.failed = true
+		} else {
+			t.failed = false
+		}
+	}
+}
+
+// flushTees flushes the registered writers that support it and returns
+// their failures.
+// l.mu is held.
+func (l *loggingT) flushTees() []SinkFlushError {
+	var failed []SinkFlushError
+	for _, t := range l.tees {
+		if f, ok := t.w.(interface {
+			Flush() error
+		}); ok {
+			if err := f.Flush(); err != nil {
+				failed = append(failed, SinkFlushError{fmt.Sprintf("%T", t.w), err})
+			}
+		}
+	}
+	return failed
+}
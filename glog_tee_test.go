@@ -0,0 +1,163 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errWriter is an io.Writer that always fails.
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+// Test that AddWriter tees lines at or above its severity, independently of
+// failing writers, until removed.
+func TestAddWriter(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var buf bytes.Buffer
+	removeBad := AddWriter("INFO", errWriter{})
+	defer removeBad()
+	remove := AddWriter("WARNING", &buf)
+
+	Info("info-line")
+	Warning("warning-line")
+	remove()
+	Error("error-line")
+
+	if strings.Contains(buf.String(), "info-line") {
+		t.Errorf("INFO line written to WARNING writer: %q", buf.String())
+	}
+	if !strings.HasPrefix(buf.String(), "W") || !strings.Contains(buf.String(), "warning-line") {
+		t.Errorf("WARNING line missing from writer: %q", buf.String())
+	}
+	if strings.Contains(buf.String(), "error-line") {
+		t.Errorf("line written after remove: %q", buf.String())
+	}
+	if !contains(infoLog, "error-line", t) {
+		t.Errorf("failing writer affected the log file: %q", contents(infoLog))
+	}
+}
+
+// Test that AddJSONWriter passes each line as a JSON object.
+func TestAddJSONWriter(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var buf bytes.Buffer
+	remove := AddJSONWriter("WARNING", &buf)
+	defer remove()
+
+	Info("info-line")
+	WithFields("user_id", 7).Warning("json-line")
+
+	var rec struct {
+		Severity string
+		File     string
+		Message  string
+		Fields   map[string]int
+	}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("%v: %q", err, buf.String())
+	}
+	if rec.Severity != "WARNING" || rec.File != "glog_tee_test.go" || rec.Message != "json-line" || rec.Fields["user_id"] != 7 {
+		t.Errorf("got %+v", rec)
+	}
+}
+
+// Test that truncated records remain valid JSON within the limit.
+func TestJSONTruncation(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var buf bytes.Buffer
+	remove := AddJSONWriter("INFO", &buf)
+	defer remove()
+	defer SetTruncation(false, defaultTruncateSuffix)
+	defer func() { logging.maxLogMessageLen = -1 }()
+	logging.maxLogMessageLen = 300
+	SetTruncation(true, defaultTruncateSuffix)
+
+	WithFields("a", 1, "b", strings.Repeat("x", 250)).Info("fields-dropped")
+	Info(strings.Repeat("测", 200))
+	Info("short")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %q", buf.String())
+	}
+	for i, want := range []string{"fields-dropped", "测", "short"} {
+		var rec struct {
+			Message   string
+			Fields    map[string]interface{}
+			Truncated bool
+		}
+		if len(lines[i]) > 300 {
+			t.Errorf("record %d is %d bytes", i, len(lines[i]))
+		}
+		if err := json.Unmarshal([]byte(lines[i]), &rec); err != nil {
+			t.Fatalf("%v: %q", err, lines[i])
+		}
+		if !strings.HasPrefix(rec.Message, want) || rec.Truncated != (i < 2) {
+			t.Errorf("record %d: got %+v", i, rec)
+		}
+		if i == 0 && (rec.Fields["a"] != float64(1) || rec.Fields["b"] != nil) {
+			t.Errorf("fields not dropped from the end: %v", rec.Fields)
+		}
+	}
+}
+
+// loggingWriter is a writer that logs about the first line it receives from
+// another goroutine, as AddWriter requires.
+type loggingWriter struct {
+	once   sync.Once
+	logged chan struct{}
+}
+
+func (w *loggingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		go func() {
+			Warning("writer saw a line")
+			close(w.logged)
+		}()
+	})
+	return len(p), nil
+}
+
+// Test that a writer can log through another goroutine without deadlocking.
+func TestAddWriterReentrant(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	w := &loggingWriter{logged: make(chan struct{})}
+	defer AddWriter("INFO", w)()
+	Info("first")
+	select {
+	case <-w.logged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging from the writer deadlocked")
+	}
+	if !contains(warningLog, "writer saw a line", t) {
+		t.Errorf("line logged by the writer missing: %q", contents(warningLog))
+	}
+}
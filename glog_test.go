@@ -17,15 +17,19 @@
 package glog
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	stdLog "log"
+	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -70,7 +74,20 @@ func TestShortHostname(t *testing.T) {
 	}
 }
 
-// flushBuffer wraps a bytes.Buffer to satisfy flushSyncWriter.
+func TestSafeName(t *testing.T) {
+	for name, expect := range map[string]string{
+		"server":          "server",
+		`CORP\alice`:      "CORP_alice",
+		`a:b<c>d|e?f*g"/`: "a_b_c_d_e_f_g__",
+		"tab\tname":       "tab_name",
+	} {
+		if got := safeName(name); expect != got {
+			t.Errorf("safeName(%q): expected %q, got %q", name, expect, got)
+		}
+	}
+}
+
+// flushBuffer wraps a bytes.Buffer to satisfy Sink.
 type flushBuffer struct {
 	bytes.Buffer
 }
@@ -83,8 +100,12 @@ func (f *flushBuffer) Sync() error {
 	return nil
 }
 
+func (f *flushBuffer) Close() error {
+	return nil
+}
+
 // swap sets the log writers and returns the old array.
-func (l *loggingT) swap(writers [numSeverity]flushSyncWriter) (old [numSeverity]flushSyncWriter) {
+func (l *loggingT) swap(writers [numSeverity]Sink) (old [numSeverity]Sink) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	old = l.file
@@ -95,8 +116,8 @@ func (l *loggingT) swap(writers [numSeverity]flushSyncWriter) (old [numSeverity]
 }
 
 // newBuffers sets the log writers to all new byte buffers and returns the old array.
-func (l *loggingT) newBuffers() [numSeverity]flushSyncWriter {
-	return l.swap([numSeverity]flushSyncWriter{new(flushBuffer), new(flushBuffer), new(flushBuffer), new(flushBuffer)})
+func (l *loggingT) newBuffers() [numSeverity]Sink {
+	return l.swap([numSeverity]Sink{new(flushBuffer), new(flushBuffer), new(flushBuffer), new(flushBuffer)})
 }
 
 // contents returns the specified log value as a string.
@@ -299,14 +320,33 @@ func TestStandardLog(t *testing.T) {
 	}
 }
 
+// Test that standard log messages with a severity prefix are routed to it.
+func TestStandardLogPrefix(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	stdLog.Print("ERROR: prefixed")
+	if !contains(errorLog, "prefixed", t) {
+		t.Errorf("ERROR: prefix not routed to ERROR log: %q", contents(errorLog))
+	}
+	if contains(errorLog, "ERROR:", t) {
+		t.Errorf("prefix not removed: %q", contents(errorLog))
+	}
+	stdLog.Print("WARN: warned")
+	if !contains(warningLog, "warned", t) || contains(errorLog, "warned", t) {
+		t.Errorf("WARN: prefix not routed to WARNING log: %q", contents(warningLog))
+	}
+	stdLog.Print("DEBUG: debugged")
+	if !contains(infoLog, "debugged", t) || contains(warningLog, "debugged", t) {
+		t.Errorf("DEBUG: prefix not routed to INFO log: %q", contents(infoLog))
+	}
+}
+
 // Test that the header has the correct format.
 func TestHeader(t *testing.T) {
 	setFlags()
 	defer logging.swap(logging.newBuffers())
-	defer func(previous func() time.Time) { timeNow = previous }(timeNow)
-	timeNow = func() time.Time {
-		return time.Date(2006, 1, 2, 15, 4, 5, .067890e9, time.Local)
-	}
+	defer SetClock(nil)
+	SetClock(fixedClock{t: time.Date(2006, 1, 2, 15, 4, 5, .067890e9, time.Local)})
 	pid = 1234
 	Info("test")
 	var line int
@@ -323,6 +363,32 @@ func TestHeader(t *testing.T) {
 	}
 }
 
+// Test that the header honors the configured time zone.
+func TestHeaderLocation(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetClock(nil)
+	SetClock(fixedClock{t: time.Date(2006, 1, 2, 23, 4, 5, .067890e9, time.UTC)})
+	defer SetLocation(nil)
+	var tz locationValue
+	if err := tz.Set("Asia/Shanghai"); err != nil {
+		t.Skip("time zone database unavailable: ", err)
+	}
+	if tz.String() != "Asia/Shanghai" {
+		t.Errorf("log_timezone = %q, want Asia/Shanghai", tz.String())
+	}
+	Info("test")
+	if !strings.HasPrefix(contents(infoLog), "I0103 07:04:05.067890") {
+		t.Errorf("header not in configured zone: %q", contents(infoLog))
+	}
+	defer func(previous string) { *LogRotateInterval = previous }(*LogRotateInterval)
+	*LogRotateInterval = "day"
+	next := getStartOfNextTime(logging.now())
+	if next.Location().String() != "Asia/Shanghai" || next.Hour() != 0 {
+		t.Errorf("rotation boundary not in configured zone: %v", next)
+	}
+}
+
 // Test that an Error log goes to Warning and Info.
 // Even in the Info log, the source character will be E, so the data should
 // all be identical.
@@ -364,6 +430,130 @@ func TestWarning(t *testing.T) {
 	}
 }
 
+// Test that Close flushes and closes the log files and stops the daemon.
+func TestClose(t *testing.T) {
+	setFlags()
+	path := filepath.Join(t.TempDir(), "INFO")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb := &syncBuffer{logger: &logging, sev: infoLog, file: f, name: path, Writer: bufio.NewWriterSize(f, bufferSize),
+		nextRotateTime: time.Now().Add(time.Hour)}
+	defer logging.swap(logging.swap([numSeverity]Sink{sb}))
+
+	Info("before close")
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "before close") {
+		t.Errorf("line not flushed: %q", data)
+	}
+	if logging.file[infoLog] != nil {
+		t.Error("closed file still installed")
+	}
+	if _, err := f.Write([]byte("x")); err == nil {
+		t.Error("file not closed")
+	}
+	if FlushDaemonRunning() {
+		t.Error("flush daemon not stopped")
+	}
+	if err := Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+// Test that lines can be kept out of the files of lower severities.
+func TestNoDuplicateToLower(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	SetDuplicateToLowerSeverity(false)
+	defer SetDuplicateToLowerSeverity(true)
+	Error("only-error")
+	Warning("only-warning")
+	if !contains(errorLog, "only-error", t) {
+		t.Errorf("error line missing from ERROR: %q", contents(errorLog))
+	}
+	if contains(warningLog, "only-error", t) || contains(infoLog, "only-error", t) {
+		t.Errorf("error line copied to lower severities: %q", contents(infoLog))
+	}
+	if !contains(warningLog, "only-warning", t) || contains(infoLog, "only-warning", t) {
+		t.Errorf("warning line misplaced: WARNING %q, INFO %q", contents(warningLog), contents(infoLog))
+	}
+}
+
+// logFiles logs lines of severity s to log files in a new directory and
+// returns the severities of the files created.
+func logFiles(t *testing.T, s severity) []string {
+	onceLogDirs.Do(createLogDirs)
+	defer func(dirs []string) { logDirs = dirs }(logDirs)
+	dir := t.TempDir()
+	logDirs = []string{dir}
+	defer logging.swap(logging.swap([numSeverity]Sink{}))
+
+	logging.printDepth(s, 0, "to a file")
+	if err := logging.closeFiles(); err != nil {
+		t.Fatal(err)
+	}
+	var sevs []string
+	for sev := infoLog; sev <= fatalLog; sev++ {
+		if m, _ := filepath.Glob(filepath.Join(dir, "*.log."+severityName[sev]+".*")); len(m) > 0 {
+			sevs = append(sevs, severityName[sev])
+		}
+	}
+	return sevs
+}
+
+// Test that log files are created when the first line is written to them,
+// unless -log_precreate is set.
+func TestLazyFileCreation(t *testing.T) {
+	setFlags()
+	if got := fmt.Sprint(logFiles(t, warningLog)); got != "[INFO WARNING]" {
+		t.Errorf("created files %s, want [INFO WARNING]", got)
+	}
+
+	SetPrecreateFiles(true)
+	defer SetPrecreateFiles(false)
+	if got := fmt.Sprint(logFiles(t, infoLog)); got != "[INFO WARNING ERROR FATAL]" {
+		t.Errorf("created files %s, want all four", got)
+	}
+	logging.fileThreshold.set(warningLog)
+	defer logging.fileThreshold.set(infoLog)
+	SetDuplicateToLowerSeverity(false)
+	defer SetDuplicateToLowerSeverity(true)
+	if got := fmt.Sprint(logFiles(t, warningLog)); got != "[WARNING ERROR FATAL]" {
+		t.Errorf("created files %s with -file_threshold=WARNING -alsologtolower=false", got)
+	}
+}
+
+type embeddedCard struct {
+	CardNo string `filter:"card"`
+	Name   string
+}
+
+type withEmbedded struct {
+	embeddedCard
+	Name string
+}
+
+// Test that the fields promoted from an unexported embedded struct are masked
+// and written, and that the struct's own fields take precedence.
+func TestUnexportedEmbedded(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+
+	card := "6222021234567890123"
+	Info(withEmbedded{embeddedCard: embeddedCard{CardNo: card, Name: "x"}, Name: "y"})
+	want := "map[CardNo:" + ShrineAlipayAccountNumber(card) + " Name:y]"
+	if !contains(infoLog, want, t) {
+		t.Errorf("got %q, want %q", contents(infoLog), want)
+	}
+}
+
 // Test that a V log goes to Info.
 func TestV(t *testing.T) {
 	setFlags()
@@ -379,6 +569,26 @@ func TestV(t *testing.T) {
 	}
 }
 
+// Test that lazy arguments are only evaluated when the line is written.
+func TestVLazy(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	logging.verbosity.Set("1")
+	defer logging.verbosity.Set("0")
+	calls := 0
+	dump := func() string { calls++; return "dumped" }
+	V(2).InfoLazy(dump)
+	V(2).Info("state: ", func() interface{} { calls++; return 1 })
+	if calls != 0 || contents(infoLog) != "" {
+		t.Errorf("evaluated with V off: %d calls, %q", calls, contents(infoLog))
+	}
+	V(1).InfoLazy(dump)
+	V(1).Infof("state: %v %s", func() interface{} { return map[string]int{"n": 1} }, dump)
+	if !contains(infoLog, "] dumped\n", t) || !contains(infoLog, "state: map[n:1] dumped", t) {
+		t.Errorf("got %q", contents(infoLog))
+	}
+}
+
 // Test that a vmodule enables a log in this file.
 func TestVmoduleOn(t *testing.T) {
 	setFlags()
@@ -456,6 +666,35 @@ func TestVmoduleGlob(t *testing.T) {
 	}
 }
 
+// Test that vmodule patterns with a slash match the trailing path elements.
+func TestVmodulePath(t *testing.T) {
+	for pat, match := range map[string]bool{
+		"*/glog_test=2":         true,
+		"*/glog_t*=2":           true,
+		"nosuchdir/glog_test=2": false,
+		"*/*/*/*/*/*/*/*/x=2":   false,
+	} {
+		testVmoduleGlob(pat, match, t)
+	}
+}
+
+func TestPathTail(t *testing.T) {
+	for _, tc := range []struct {
+		file string
+		n    int
+		want string
+	}{
+		{"/src/repo/db/conn", 2, "db/conn"},
+		{"db/conn", 2, "db/conn"},
+		{"conn", 2, ""},
+		{"/go/pkg/mod/github.com/org/repo@v1.2.0/db/conn", 4, "org/repo/db/conn"},
+	} {
+		if got := pathTail(tc.file, tc.n); got != tc.want {
+			t.Errorf("pathTail(%q, %d) = %q, want %q", tc.file, tc.n, got, tc.want)
+		}
+	}
+}
+
 func TestRollover(t *testing.T) {
 	setFlags()
 	var err error
@@ -480,14 +719,8 @@ func TestRollover(t *testing.T) {
 		t.Fatalf("info has error after big write: %v", err)
 	}
 
-	// Make sure the next log file gets a file name with a different
-	// time stamp.
-	//
-	// TODO: determine whether we need to support subsecond log
-	// rotation.  C++ does not appear to handle this case (nor does it
-	// handle Daylight Savings Time properly).
-	time.Sleep(1 * time.Second)
-
+	// Files started within the same second as the last one get a sequence
+	// number, so the next file needs no different time stamp.
 	Info("x") // create a new file
 	if err != nil {
 		t.Fatalf("error after rotation: %v", err)
@@ -501,6 +734,128 @@ func TestRollover(t *testing.T) {
 	}
 }
 
+// Test that Fatal and Exit use the configured exit function and codes.
+func TestExitFunc(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func(previous func(error)) { logExitFunc = previous }(logExitFunc)
+	defer SetExitFunc(nil)
+	defer SetExitCodes(255, 1)
+	var codes []int
+	SetExitFunc(func(code int) { codes = append(codes, code) })
+	SetExitCodes(3, 4)
+
+	Exit("exit-test")
+	Fatal("fatal-test")
+	if len(codes) != 2 || codes[0] != 4 || codes[1] != 3 {
+		t.Fatalf("exit codes = %v, want [4 3]", codes)
+	}
+	if !contains(fatalLog, "exit-test", t) || !contains(fatalLog, "fatal-test", t) {
+		t.Errorf("fatal log missing messages: %q", contents(fatalLog))
+	}
+	if !contains(fatalLog, "goroutine ", t) {
+		t.Errorf("Fatal did not dump stacks after Exit: %q", contents(fatalLog))
+	}
+}
+
+// Test that ReopenLogFiles recreates a log file moved away by another process.
+func TestReopenLogFiles(t *testing.T) {
+	setFlags()
+	Info("x") // Be sure we have a file.
+	info, ok := logging.file[infoLog].(*syncBuffer)
+	if !ok {
+		t.Fatal("info wasn't created")
+	}
+	name := info.name
+	Flush()
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(name + ".1")
+	if err := ReopenLogFiles(); err != nil {
+		t.Fatal(err)
+	}
+	Info("after reopen")
+	Flush()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("log file not recreated: %v", err)
+	}
+	if !strings.Contains(string(data), "after reopen") {
+		t.Errorf("reopened file missing message: %q", data)
+	}
+}
+
+// Test that a log file deleted by another process is replaced.
+func TestCheckFiles(t *testing.T) {
+	setFlags()
+	Info("x") // Be sure we have a file.
+	info, ok := logging.file[infoLog].(*syncBuffer)
+	if !ok {
+		t.Fatal("info wasn't created")
+	}
+	logging.mu.Lock()
+	logging.checkFiles()
+	logging.mu.Unlock()
+	rotations := make(chan string, 1)
+	defer OnRotate(func(oldPath, newPath string, sev Severity) {
+		rotations <- oldPath
+	})()
+	name := info.name
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	logging.mu.Lock()
+	logging.checkFiles()
+	logging.mu.Unlock()
+	Info("after check")
+	Flush()
+	data, err := os.ReadFile(info.name)
+	if err != nil {
+		t.Fatalf("log file not replaced: %v", err)
+	}
+	if !strings.Contains(string(data), "after check") {
+		t.Errorf("new file missing message: %q", data)
+	}
+	select {
+	case old := <-rotations:
+		t.Errorf("replacement of removed file %s reported as a rotation", old)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Test that Init applies a valid configuration and rejects an invalid one.
+func TestInit(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func() {
+		logging.mu.Lock()
+		logging.needFlagParse = true
+		logging.setVState(0, nil, true)
+		logging.mu.Unlock()
+		logging.stderrThreshold.set(errorLog)
+		*LogRotateInterval = "day"
+	}()
+	err := Init(WithVerbosity(1), WithVModule("glog_test=3"), WithStderrThreshold("FATAL"), WithRotateInterval("hour"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !V(3) || V(4) {
+		t.Error("vmodule not applied")
+	}
+	if logging.stderrThreshold.get() != fatalLog || *LogRotateInterval != "hour" {
+		t.Error("threshold or rotate interval not applied")
+	}
+	for _, opt := range []Option{WithStderrThreshold("LOUD"), WithVModule("x=y"), WithRotateInterval("week"), WithTimeZone("Nowhere/Nothing")} {
+		if err := Init(WithVerbosity(2), opt); err == nil {
+			t.Error("invalid configuration accepted")
+		}
+	}
+	if logging.verbosity.get() != 1 {
+		t.Error("invalid configuration partially applied")
+	}
+}
+
 func TestLogBacktraceAt(t *testing.T) {
 	setFlags()
 	defer logging.swap(logging.newBuffers())
@@ -544,6 +899,64 @@ func BenchmarkHeader(b *testing.B) {
 	}
 }
 
+// discardWriter is a Sink that drops everything, so that
+// benchmarks measure formatting rather than I/O.
+type discardWriter struct{}
+
+func (discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (discardWriter) Flush() error                { return nil }
+func (discardWriter) Sync() error                 { return nil }
+func (discardWriter) Close() error                { return nil }
+
+func benchmarkOutput(b *testing.B, log func()) {
+	setFlags()
+	defer logging.swap(logging.swap([numSeverity]Sink{discardWriter{}, discardWriter{}, discardWriter{}, discardWriter{}}))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log()
+	}
+}
+
+func BenchmarkInfoLiteral(b *testing.B) {
+	benchmarkOutput(b, func() { Info("a literal message") })
+}
+
+func BenchmarkInfofBasic(b *testing.B) {
+	benchmarkOutput(b, func() { Infof("request %s took %d ms", "GET /", 12) })
+}
+
+func BenchmarkInfoStruct(b *testing.B) {
+	type user struct {
+		Name  string
+		Phone string `filter:"phone"`
+	}
+	u := &user{"Jone", "13812345678"}
+	benchmarkOutput(b, func() { Info("user ", u) })
+}
+
+// Test that the fast path formats like fmt.Print.
+func TestAppendPrint(t *testing.T) {
+	tests := [][]interface{}{
+		{"a", "b"},
+		{1, 2, "x", 3, true, -4},
+		{"n=", int64(-5), uint(6), uint64(7), int32(8), uint32(9)},
+		{},
+	}
+	for _, args := range tests {
+		var buf buffer
+		if !appendPrint(&buf, args) {
+			t.Errorf("%v: fast path not taken", args)
+			continue
+		}
+		if got, want := buf.String(), fmt.Sprint(args...); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	if appendPrint(new(buffer), []interface{}{1.5}) {
+		t.Error("fast path taken for a float")
+	}
+}
+
 func TestTruncate(t *testing.T) {
 	setFlags()
 	defer logging.swap(logging.newBuffers())
@@ -551,8 +964,8 @@ func TestTruncate(t *testing.T) {
 	Info("testmaxlogmessagelen1234567890测试中文哈哈哈哈哈哈哈哈哈哈哈")
 	a := assert.New(t)
 	message := contents(infoLog)
-	a.Equal(len([]rune(message)), 90)
-	a.True(strings.HasSuffix(message, "..."))
+	a.Equal(90, len([]rune(strings.TrimSuffix(message, "\n"))))
+	a.True(strings.HasSuffix(message, "...\n"))
 	a.Contains(message, "testmaxlogmessagelen1234567890测试中文哈哈哈哈哈...")
 	logging.maxLogMessageLen = -1
 	Info("testmaxlogmessagelen1234567890测试中文哈哈哈哈哈哈哈哈哈哈哈")
@@ -566,6 +979,44 @@ func TestTruncate(t *testing.T) {
 	a.False(strings.HasSuffix(message, "..."))
 }
 
+func TestTruncation(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func() {
+		logging.maxLogMessageLen = -1
+		SetMaxMessageLen("ERROR", 0)
+		SetTruncation(false, defaultTruncateSuffix)
+	}()
+	msg := strings.Repeat("测", 100)
+
+	// Bytes, with the count of removed bytes in the suffix.
+	logging.maxLogMessageLen = 100
+	SetTruncation(true, "...[truncated %d bytes]")
+	Info(msg)
+	line := strings.TrimSuffix(contents(infoLog), "\n")
+	if len(line) > 100 || !utf8.ValidString(line) {
+		t.Errorf("got %d bytes: %q", len(line), line)
+	}
+	i := strings.Index(line, "] ") + 2
+	removed := len(msg) - len(line[i:strings.Index(line, "...[")])
+	if want := fmt.Sprintf("...[truncated %d bytes]", removed); !strings.HasSuffix(line, want) {
+		t.Errorf("got %q, want suffix %q", line, want)
+	}
+
+	// Per-severity limits override the global one.
+	logging.newBuffers()
+	if err := SetMaxMessageLen("ERROR", 1000); err != nil {
+		t.Fatal(err)
+	}
+	Error(msg)
+	if !contains(errorLog, msg, t) {
+		t.Errorf("ERROR line truncated: %q", contents(errorLog))
+	}
+	if err := SetMaxMessageLen("LOUD", 1); err == nil {
+		t.Error("unknown severity accepted")
+	}
+}
+
 type T struct {
 	SliceIfWithRealNameTag []interface{} `filter:"realname"`
 	SliceIfWithoutRealNameTag []interface{}
@@ -778,4 +1229,412 @@ func TestShrineEmail(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestShrineBankAccount(t *testing.T) {
+	tests := []struct {
+		name    string
+		account string
+		want    string
+	}{
+		{"digits", "6222021234567890", "************7890"},
+		{"separators", "6222-0212 3456", "********3456"},
+		{"too short", "12345", ""},
+		{"letters", "ABC123456", ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShrineBankAccount(tt.account); got != tt.want {
+				t.Errorf("ShrineBankAccount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShrineIBAN(t *testing.T) {
+	tests := []struct {
+		name string
+		iban string
+		want string
+	}{
+		{"compact", "DE89370400440532013000", "DE89**************3000"},
+		{"spaced lower case", "gb82 west 1234 5698 7654 32", "GB82**************5432"},
+		{"bad checksum", "DE88370400440532013000", ""},
+		{"bad format", "1234", ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShrineIBAN(tt.iban); got != tt.want {
+				t.Errorf("ShrineIBAN() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShrinePassport(t *testing.T) {
+	tests := []struct {
+		name     string
+		passport string
+		want     string
+	}{
+		{"passport", "E12345678", "E*****678"},
+		{"too short", "E123", ""},
+		{"punctuation", "E1234-5678", ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShrinePassport(tt.passport); got != tt.want {
+				t.Errorf("ShrinePassport() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterBankTags(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	type Payee struct {
+		Account  string `filter:"bankacct"`
+		IBAN     string `filter:"iban"`
+		Passport string `filter:"passport"`
+	}
+	Info(Payee{"6222021234567890", "DE89370400440532013000", "E12345678"})
+	for _, want := range []string{ShrineBankAccount("6222021234567890"), ShrineIBAN("DE89370400440532013000"), ShrinePassport("E12345678")} {
+		if !contains(infoLog, want, t) {
+			t.Errorf("missing %q in %q", want, contents(infoLog))
+		}
+	}
+}
+
+// Test that masking follows pointers, embedded structs, interfaces and nested
+// containers, and stops at cycles and the depth limit.
+func TestMaskNested(t *testing.T) {
+	type Contact struct {
+		Phone string `filter:"phone"`
+	}
+	type Base struct {
+		ID string `filter:"identity"`
+	}
+	type Node struct {
+		*Base
+		Contact **Contact
+		Extra   interface{}
+		Items   [1]map[string]interface{}
+		Next    *Node
+	}
+	c := &Contact{"13812345678"}
+	n := &Node{Base: &Base{"110101199003074578"}, Contact: &c}
+	n.Extra = []interface{}{map[string]interface{}{"mobile": "13912345678"}}
+	n.Items[0] = map[string]interface{}{"contact": Contact{"13712345678"}}
+	n.Next = n
+	got := PreviewMasking(n)
+	for _, leak := range []string{"13812345678", "110101199003074578", "13912345678", "13712345678"} {
+		if strings.Contains(got, leak) {
+			t.Errorf("%q not masked in %q", leak, got)
+		}
+	}
+	if !strings.Contains(got, "Next:"+maskCycle) || !strings.Contains(got, "138****5678") {
+		t.Errorf("got %q", got)
+	}
+
+	// A map containing itself.
+	m := map[string]interface{}{"phone": "13812345678"}
+	m["self"] = m
+	if got := PreviewMasking(m); !strings.Contains(got, "self:"+maskCycle) {
+		t.Errorf("got %q", got)
+	}
+
+	// Shared values are not cycles.
+	shared := &Contact{"13812345678"}
+	pair := struct{ A, B *Contact }{shared, shared}
+	if got := PreviewMasking(pair); strings.Contains(got, maskCycle) {
+		t.Errorf("shared value reported as cycle: %q", got)
+	}
+
+	defer SetMaskMaxDepth(defaultMaskMaxDepth)
+	SetMaskMaxDepth(1)
+	deep := map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"mobile": "13812345678"}}}
+	if got := PreviewMasking(deep); got != "map[a:map[b:"+maskTooDeep+"]]" {
+		t.Errorf("got %q", got)
+	}
+	if err := SetMaskMaxDepth(-1); err == nil {
+		t.Error("negative depth accepted")
+	}
+}
+
+// renderedUser implements fmt.Stringer with a pointer receiver.
+type renderedUser struct {
+	Name, Phone, Email string
+}
+
+func (u *renderedUser) String() string {
+	return fmt.Sprintf("user{name=%s phone=%s email=%s}", u.Name, u.Phone, u.Email)
+}
+
+// renderedCard implements json.Marshaler.
+type renderedCard struct{ no string }
+
+func (c renderedCard) MarshalJSON() ([]byte, error) {
+	return []byte(`{"ref":"` + c.no + `","id_card":"110101199003074578"}`), nil
+}
+
+func TestMaskRendered(t *testing.T) {
+	u := &renderedUser{"zhangsan", "13812345678", "zhangsan@example.com"}
+	if got := PreviewMasking(u); !strings.Contains(got, "13812345678") {
+		t.Errorf("masked while off: %q", got)
+	}
+	defer SetMaskRendered(false)
+	SetMaskRendered(true)
+	got := PreviewMasking(u, renderedCard{"4111111111111111"}, map[string]interface{}{"user": u})
+	for _, leak := range []string{"13812345678", "zhangsan@", "4111111111111111", "110101199003074578"} {
+		if strings.Contains(got, leak) {
+			t.Errorf("%q not masked in %q", leak, got)
+		}
+	}
+	if !strings.Contains(got, "phone=138****5678") || !strings.Contains(got, `"id_card":"`) {
+		t.Errorf("got %q", got)
+	}
+	// Numbers that fail the Luhn check are not card numbers.
+	if got := PreviewMasking(renderedCard{"1234567890123456"}); !strings.Contains(got, "1234567890123456") {
+		t.Errorf("got %q", got)
+	}
+	var nilUser *renderedUser
+	PreviewMasking(nilUser)
+}
+
+func TestShrinePolicy(t *testing.T) {
+	defer ClearShrinePolicy("phone")
+	defer ClearShrinePolicy("pwd")
+	if err := SetShrinePolicy("nosuchrule", ShrinePolicy{}); err == nil {
+		t.Error("unknown rule accepted")
+	}
+	if err := SetShrinePolicy("phone", ShrinePolicy{KeepPrefix: -1}); err == nil {
+		t.Error("negative keep count accepted")
+	}
+	if err := SetShrinePolicy("phone", ShrinePolicy{MaskRune: '#', KeepPrefix: 2, KeepSuffix: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ShrinePhoneNumber("13812345678"), "13#######78"; got != want {
+		t.Errorf("ShrinePhoneNumber() = %v, want %v", got, want)
+	}
+	if got, want := ShrinePhoneNumber("138"), "###"; got != want {
+		t.Errorf("short ShrinePhoneNumber() = %v, want %v", got, want)
+	}
+	if err := SetShrinePolicy("pwd", ShrinePolicy{MaskRune: 'x', Redact: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ShrinePwdStr(), "xxxxxx"; got != want {
+		t.Errorf("ShrinePwdStr() = %v, want %v", got, want)
+	}
+	ClearShrinePolicy("phone")
+	if got, want := ShrinePhoneNumber("13812345678"), "138****5678"; got != want {
+		t.Errorf("cleared ShrinePhoneNumber() = %v, want %v", got, want)
+	}
+}
+
+func TestValidateShrineRules(t *testing.T) {
+	defer ClearShrinePolicy("card")
+	defer ClearShrinePolicy("identity")
+	if err := ValidateShrineRules(); err != nil {
+		t.Fatalf("built-in rules: %v", err)
+	}
+	SetShrinePolicy("card", ShrinePolicy{KeepPrefix: 7, KeepSuffix: 8})
+	SetShrinePolicy("identity", ShrinePolicy{Mode: ShrineEncrypt})
+	err := ValidateShrineRules()
+	if err == nil || !strings.Contains(err.Error(), "card: \"6225880137706868\" is masked as \"6225880*37706868\"") ||
+		!strings.Contains(err.Error(), "identity: encryption policy without a key") {
+		t.Errorf("got %v", err)
+	}
+	SetShrinePolicy("card", ShrinePolicy{MaskRune: '0'})
+	ClearShrinePolicy("identity")
+	if err := ValidateShrineRules(); err == nil || !strings.Contains(err.Error(), "mask rune") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestPreviewMasking(t *testing.T) {
+	type Person struct {
+		Name  string
+		Phone string `filter:"phone"`
+	}
+	got := PreviewMasking("user", Person{"ann", "13812345678"})
+	if want := "usermap[Name:ann Phone:138****5678]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestShrineEncrypt(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer ClearShrinePolicy("identity")
+	defer SetShrineKey(nil)
+	key := []byte("0123456789abcdef")
+	if err := SetShrinePolicy("identity", ShrinePolicy{Mode: ShrineEncrypt}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ShrineIdentity("110101199003074514"); got != "******" {
+		t.Errorf("encrypted without key: %q", got)
+	}
+	if err := SetShrineKey([]byte("short")); err == nil {
+		t.Error("invalid key accepted")
+	}
+	if err := SetShrineKey(key); err != nil {
+		t.Fatal(err)
+	}
+	type Person struct {
+		ID string `filter:"identity"`
+	}
+	Info(Person{"110101199003074514"})
+	msg := contents(infoLog)
+	start := strings.Index(msg, encPrefix)
+	if start < 0 || strings.Contains(msg, "110101199003074514") {
+		t.Fatalf("identity not encrypted: %q", msg)
+	}
+	enc := msg[start : start+strings.Index(msg[start:], encSuffix)+1]
+	plain, err := DecryptShrined(enc, key)
+	if err != nil || plain != "110101199003074514" {
+		t.Errorf("DecryptShrined() = %q, %v", plain, err)
+	}
+	if _, err := DecryptShrined(enc, []byte("fedcba9876543210")); err == nil {
+		t.Error("decrypted with wrong key")
+	}
+}
+
+func TestShrineKeyProvider(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer ClearShrinePolicy("identity")
+	defer SetShrineKey(nil)
+	if err := SetShrinePolicy("identity", ShrinePolicy{Mode: ShrineEncrypt}); err != nil {
+		t.Fatal(err)
+	}
+	ring := KeyRing{Current: "k1", Keys: map[string][]byte{
+		"k1": []byte("0123456789abcdef"),
+		"k2": []byte("fedcba9876543210fedcba9876543210"),
+	}}
+	SetShrineKeyProvider(ring)
+	enc1 := ShrineIdentity("110101199003074514")
+	ring.Current = "k2"
+	SetShrineKeyProvider(ring)
+	enc2 := ShrineIdentity("110101199003074514")
+	if !strings.HasPrefix(enc1, encPrefix+"k1:") || !strings.HasPrefix(enc2, encPrefix+"k2:") {
+		t.Fatalf("got %q and %q, want key IDs k1 and k2", enc1, enc2)
+	}
+	for _, enc := range []string{enc1, enc2} {
+		if plain, err := DecryptShrinedWith(enc, ring); err != nil || plain != "110101199003074514" {
+			t.Errorf("DecryptShrinedWith(%q) = %q, %v", enc, plain, err)
+		}
+	}
+	if plain, err := DecryptShrined(enc2, ring.Keys["k2"]); err != nil || plain != "110101199003074514" {
+		t.Errorf("DecryptShrined(%q) = %q, %v", enc2, plain, err)
+	}
+	delete(ring.Keys, "k1")
+	if _, err := DecryptShrinedWith(enc1, ring); err == nil {
+		t.Error("decrypted with a retired key")
+	}
+
+	SetShrineKeyProvider(failingKeys{errors.New("KMS down")})
+	if got := ShrineIdentity("110101199003074514"); got != "******" {
+		t.Errorf("encrypted without key: %q", got)
+	}
+	if err := ValidateShrineRules(); err == nil || !strings.Contains(err.Error(), "KMS down") {
+		t.Errorf("ValidateShrineRules() = %v", err)
+	}
+}
+
+func TestShrineTokenize(t *testing.T) {
+	defer ClearShrinePolicy("card")
+	defer SetShrineTokenKey(nil)
+	if err := SetShrinePolicy("card", ShrinePolicy{Mode: ShrineTokenize}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ShrineCardNo("6222021234567890"); got != "******" {
+		t.Errorf("tokenized without key: %q", got)
+	}
+	SetShrineTokenKey([]byte("secret"))
+	a, b := ShrineCardNo("6222021234567890"), ShrineCardNo("6222021234567890")
+	c := ShrineCardNo("6222021234567891")
+	if a != b || !strings.HasPrefix(a, "{tok:") || len(a) != len("{tok:}")+tokenLen {
+		t.Errorf("tokens not stable: %q %q", a, b)
+	}
+	if a == c {
+		t.Errorf("distinct values share token %q", a)
+	}
+	SetShrineTokenKey([]byte("other"))
+	if ShrineCardNo("6222021234567890") == a {
+		t.Error("token does not depend on key")
+	}
+}
+
+// capture returns what f writes to *out, os.Stdout or os.Stderr.
+func capture(t *testing.T, out **os.File, f func()) string {
+	tmp, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmp.Close()
+	defer func(old *os.File) { *out = old }(*out)
+	*out = tmp
+	f()
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// Test that -logtostdout writes lines to standard output instead of files, and
+// -log_container writes them as JSON records.
+func TestLogToStdout(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func() { logging.toStdout, logging.container = false, false }()
+
+	logging.toStdout = true
+	out := capture(t, &os.Stdout, func() { Info("to-stdout") })
+	if !strings.HasPrefix(out, "I") || !strings.HasSuffix(out, "] to-stdout\n") {
+		t.Errorf("standard output is %q", out)
+	}
+	if contains(infoLog, "to-stdout", t) {
+		t.Errorf("line written to the files: %q", contents(infoLog))
+	}
+
+	logging.toStdout, logging.container = false, true
+	out = capture(t, &os.Stdout, func() { Warning("in-container") })
+	r, err := ParseRecord([]byte(out))
+	if err != nil {
+		t.Fatalf("standard output %q: %v", out, err)
+	}
+	if r.Severity != "WARNING" || r.Message != "in-container" || r.File != "glog_test.go" {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if contains(warningLog, "in-container", t) {
+		t.Errorf("line written to the files: %q", contents(warningLog))
+	}
+}
+
+// Test that -log_goroutine_id writes the goroutine ID, or the worker ID of the
+// line, in the header.
+func TestGoroutineID(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetGoroutineID(false)
+
+	Info("without-id")
+	if strings.Contains(contents(infoLog), fmt.Sprintf(" g%d ", goroutineID())) {
+		t.Errorf("goroutine ID written by default: %q", contents(infoLog))
+	}
+	SetGoroutineID(true)
+	Info("with-id")
+	WithFields(WorkerIDKey, "fetch-3").Info("with-worker")
+	lines := strings.Split(contents(infoLog), "\n")
+	want := fmt.Sprintf(" g%d glog_test.go:", goroutineID())
+	if !strings.Contains(lines[1], want) || !strings.HasSuffix(lines[1], "] with-id") {
+		t.Errorf("line %q does not contain %q", lines[1], want)
+	}
+	if !strings.Contains(lines[2], " fetch-3 glog_test.go:") || !strings.HasSuffix(lines[2], "] with-worker worker_id=fetch-3") {
+		t.Errorf("worker ID missing from %q", lines[2])
+	}
+}
@@ -0,0 +1,180 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Severity and V thresholds of the outputs.
+
+package glog
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// Outputs whose threshold is set by SetThreshold.
+const (
+	OutputFile   = "file"   // The log files; the -file_threshold flag.
+	OutputStderr = "stderr" // Standard error; the -stderrthreshold flag.
+)
+
+// SetThreshold sets the named severity, such as "WARNING", below which lines
+// are not written to output, OutputFile or OutputStderr, so that each output
+// receives only the lines it is meant for:
+//
+//	glog.SetThreshold(glog.OutputFile, "INFO")
+//	glog.SetThreshold(glog.OutputStderr, "ERROR")
+//	glog.AddWriter("WARNING", glog.NewTCPSink(cfg))
+//
+// The threshold of a writer is the severity it is added with. V-logged lines
+// are INFO lines; SetVThreshold and AddWriterV set how verbose each output
+// is. -logtostderr and -alsologtostderr send every line to standard error
+// regardless of its severity threshold.
+func SetThreshold(output, name string) error {
+	sev, ok := severityByName(name)
+	if !ok {
+		return fmt.Errorf("log: unknown severity %q", name)
+	}
+	switch output {
+	case OutputFile:
+		logging.fileThreshold.set(sev)
+	case OutputStderr:
+		logging.stderrThreshold.set(sev)
+	default:
+		return fmt.Errorf("log: unknown output %q", output)
+	}
+	return nil
+}
+
+// SetVThreshold sets the V level up to which lines of Logger.V are written to
+// output, OutputFile or OutputStderr, regardless of the -v and -vlogger
+// settings, so that the files can receive V(4) lines while standard error
+// stays at V(0):
+//
+//	glog.SetVThreshold(glog.OutputFile, 4)
+//	glog.Named("db").V(4).Info("written to the files only")
+//
+// Lines above the threshold are not written to output even if -v enables
+// them. A negative level removes the threshold, leaving output to -v and
+// -vlogger. AddWriterV sets the V threshold of a writer. The global V returns
+// a bool, not its level, so lines of the global V go to every output that
+// receives INFO lines.
+func SetVThreshold(output string, level Level) error {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	switch output {
+	case OutputFile:
+		logging.fileV.set(level)
+	case OutputStderr:
+		logging.stderrV.set(level)
+	default:
+		return fmt.Errorf("log: unknown output %q", output)
+	}
+	logging.updateMaxV()
+	return nil
+}
+
+// SetAlsoToStderrV arranges for lines up to V level level to be written to
+// standard error as well as to files, whatever their severity, in addition
+// to those at or above -stderrthreshold; it is the -alsologtostderr_v flag.
+// Lines not logged through V count as V level 0, so
+//
+//	glog.SetAlsoToStderrV(0)
+//
+// sends standard error the ERROR lines and above, plus every plain line but
+// no V-logged ones. The global V does not pass on its level, so its lines are
+// never selected; log through Logger.V for that. A negative level, the
+// default, turns this off.
+func SetAlsoToStderrV(level Level) {
+	logging.alsoStderrV.set(level)
+}
+
+// alsoStderrVValue implements flag.Value for the -alsologtostderr_v flag.
+type alsoStderrVValue struct{}
+
+// String is part of the flag.Value interface.
+func (alsoStderrVValue) String() string {
+	return strconv.Itoa(int(logging.alsoStderrV.get() - 1))
+}
+
+// Set is part of the flag.Value interface.
+func (alsoStderrVValue) Set(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	SetAlsoToStderrV(Level(n))
+	return nil
+}
+
+// vLine is the V level of a line logged through Logger.V, and whether -v or
+// -vlogger enable it. Other lines have the zero vLine.
+type vLine struct {
+	level  Level
+	gated  bool
+	global bool // Logged through the global V, whose level is unknown.
+}
+
+// globalV is the vLine of lines logged through the global V.
+var globalV = vLine{global: true}
+
+// vThreshold is the V threshold of an output plus one, so that zero means
+// none. Handled atomically.
+type vThreshold int32
+
+func (t *vThreshold) get() int32 {
+	return atomic.LoadInt32((*int32)(t))
+}
+
+func (t *vThreshold) set(level Level) {
+	if level < 0 {
+		level = -1
+	}
+	atomic.StoreInt32((*int32)(t), int32(level)+1)
+}
+
+// allows reports whether a line of V level v is written to the output.
+func (t *vThreshold) allows(v vLine) bool {
+	if v.level <= 0 {
+		return true
+	}
+	if th := t.get(); th > 0 {
+		return int32(v.level) < th
+	}
+	return v.gated
+}
+
+// selects reports whether a line of V level v is at or below the threshold.
+// Lines of the global V are not.
+func (t *vThreshold) selects(v vLine) bool {
+	th := t.get()
+	return th > 0 && !v.global && int32(v.level) < th
+}
+
+// updateMaxV recomputes maxV, the highest V threshold of any output, which
+// Logger.V consults to enable lines that only some output wants.
+// l.mu is held.
+func (l *loggingT) updateMaxV() {
+	max := l.fileV.get()
+	if th := l.stderrV.get(); th > max {
+		max = th
+	}
+	for _, t := range l.tees {
+		if th := t.v.get(); th > max {
+			max = th
+		}
+	}
+	atomic.StoreInt32(&l.maxV, max)
+}
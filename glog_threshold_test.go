@@ -0,0 +1,145 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Test that each output receives the lines at or above its own threshold.
+func TestSetThreshold(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetThreshold(OutputFile, "INFO")
+	defer SetThreshold(OutputStderr, "ERROR")
+	var buf bytes.Buffer
+	remove := AddWriter("INFO", &buf)
+	defer remove()
+
+	if err := SetThreshold(OutputFile, "WARNING"); err != nil {
+		t.Fatal(err)
+	}
+	Info("info-line")
+	Warning("warning-line")
+	if contains(infoLog, "info-line", t) {
+		t.Errorf("INFO line written to the files: %q", contents(infoLog))
+	}
+	if !contains(infoLog, "warning-line", t) || !contains(warningLog, "warning-line", t) {
+		t.Errorf("WARNING line missing from the files: %q", contents(infoLog))
+	}
+	if !strings.Contains(buf.String(), "info-line") {
+		t.Errorf("INFO line missing from the writer: %q", buf.String())
+	}
+
+	if err := SetThreshold(OutputStderr, "FATAL"); err != nil || logging.stderrThreshold.get() != fatalLog {
+		t.Errorf("stderr threshold not set: %v", err)
+	}
+	for _, bad := range [][2]string{{"kafka", "INFO"}, {OutputFile, "LOUD"}} {
+		if err := SetThreshold(bad[0], bad[1]); err == nil {
+			t.Errorf("%s=%s accepted", bad[0], bad[1])
+		}
+	}
+}
+
+// Test that each output receives the Logger.V lines up to its own V threshold.
+func TestSetVThreshold(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetVThreshold(OutputFile, -1)
+	var quiet, chatty bytes.Buffer
+	defer AddWriter("INFO", &quiet)()
+	defer AddWriterV("INFO", 2, &chatty)()
+
+	if err := SetVThreshold(OutputFile, 4); err != nil {
+		t.Fatal(err)
+	}
+	lg := Named("vth")
+	if !lg.V(4).Enabled() || lg.V(5).Enabled() {
+		t.Fatalf("V(4) enabled %v, V(5) enabled %v; want true, false", lg.V(4).Enabled(), lg.V(5).Enabled())
+	}
+	lg.V(4).Info("v4-line")
+	lg.V(2).Info("v2-line")
+	lg.V(0).Info("v0-line")
+	for _, line := range []string{"v4-line", "v2-line", "v0-line"} {
+		if !contains(infoLog, line, t) {
+			t.Errorf("%s missing from the files: %q", line, contents(infoLog))
+		}
+	}
+	if s := chatty.String(); strings.Contains(s, "v4-line") || !strings.Contains(s, "v2-line") {
+		t.Errorf("writer at V(2) got %q", s)
+	}
+	if s := quiet.String(); strings.Contains(s, "v2-line") || !strings.Contains(s, "v0-line") {
+		t.Errorf("writer without V threshold got %q", s)
+	}
+
+	// A threshold below -v keeps enabled lines out of its output.
+	defer func(v Level) { logging.verbosity.set(v) }(logging.verbosity.get())
+	logging.verbosity.set(3)
+	SetVThreshold(OutputFile, 1)
+	lg.V(3).Info("v3-line")
+	if contains(infoLog, "v3-line", t) {
+		t.Errorf("V(3) line written to the files at V threshold 1")
+	}
+	if !strings.Contains(quiet.String(), "v3-line") {
+		t.Errorf("V(3) line enabled by -v missing from the writer: %q", quiet.String())
+	}
+	if err := SetVThreshold("kafka", 1); err == nil {
+		t.Errorf("unknown output accepted")
+	}
+}
+
+// Test that -alsologtostderr_v sends lines up to its V level to standard
+// error, whatever their severity.
+func TestAlsoToStderrV(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetAlsoToStderrV(-1)
+	defer func(v Level) { logging.verbosity.set(v) }(logging.verbosity.get())
+	logging.verbosity.set(2)
+
+	lg := Named("also")
+	log := func() {
+		Info("plain-info")
+		lg.V(0).Info("logger-v0")
+		lg.V(2).Info("logger-v2")
+		V(0).Info("global-v0")
+		Error("plain-error")
+	}
+	out := capture(t, &os.Stderr, log)
+	if strings.Contains(out, "info") || strings.Contains(out, "v0") || !strings.Contains(out, "plain-error") {
+		t.Errorf("standard error without -alsologtostderr_v is %q", out)
+	}
+
+	SetAlsoToStderrV(0)
+	out = capture(t, &os.Stderr, log)
+	for _, want := range []string{"plain-info", "logger-v0", "plain-error"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%s missing from standard error %q", want, out)
+		}
+	}
+	for _, unwanted := range []string{"logger-v2", "global-v0"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("%s written to standard error %q", unwanted, out)
+		}
+	}
+	if !contains(infoLog, "logger-v2", t) || !contains(infoLog, "global-v0", t) {
+		t.Errorf("V lines missing from the files: %q", contents(infoLog))
+	}
+}
@@ -0,0 +1,116 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Logging of elapsed time.
+
+package glog
+
+import (
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// timingLevel is the V level at which elapsed times are logged; see
+// SetTimingLevel.
+var timingLevel Level
+
+// SetTimingLevel sets the V level at which TimeTrack and Scope log elapsed
+// times. The default, 0, logs them at INFO unconditionally; a higher level
+// logs them only where V(level) is enabled, taking -vmodule into account for
+// the file of the caller.
+func SetTimingLevel(level Level) {
+	timingLevel.set(level)
+}
+
+// TimeTrack logs the time elapsed since start, as in
+//
+//	func loadUsers() {
+//		defer glog.TimeTrack(time.Now(), "load users")
+//		...
+//	}
+//
+// which logs "load users took 12.3ms" at INFO when loadUsers returns, if the
+// timing level is enabled; see SetTimingLevel.
+func TimeTrack(start time.Time, name string) {
+	if vDepth(timingLevel.get(), 0) {
+		logging.printfDepth(infoLog, 0, "%s took %v", name, time.Since(start))
+	}
+}
+
+// A ScopeTimer measures the time spent in a scope; see Scope.
+type ScopeTimer struct {
+	name  string
+	start time.Time
+}
+
+// Scope returns a ScopeTimer for the named scope, started now. Calling its
+// End method, typically deferred, logs the time spent in the scope:
+//
+//	defer glog.Scope("handler").End()
+func Scope(name string) *ScopeTimer {
+	return &ScopeTimer{name: name, start: time.Now()}
+}
+
+// End logs the time elapsed since the ScopeTimer was started, like TimeTrack,
+// and returns it.
+func (t *ScopeTimer) End() time.Duration {
+	elapsed := time.Since(t.start)
+	if vDepth(timingLevel.get(), 0) {
+		logging.printfDepth(infoLog, 0, "%s took %v", t.name, elapsed)
+	}
+	return elapsed
+}
+
+// vDepth is like V for the caller of the function calling it, depth frames
+// up the stack.
+func vDepth(level Level, depth int) bool {
+	if logging.verbosity.get() >= level {
+		return true
+	}
+	if atomic.LoadInt32(&logging.filterLength) > 0 {
+		logging.mu.Lock()
+		defer logging.mu.Unlock()
+		if runtime.Callers(3+depth, logging.pcs[:]) == 0 {
+			return false
+		}
+		v, ok := logging.vmap[logging.pcs[0]]
+		if !ok {
+			v = logging.setV(logging.pcs[0])
+		}
+		return v >= level
+	}
+	return false
+}
+
+// timingLevelValue implements flag.Value for the -timing_v flag.
+type timingLevelValue struct{}
+
+// String is part of the flag.Value interface.
+func (timingLevelValue) String() string {
+	return strconv.Itoa(int(timingLevel.get()))
+}
+
+// Set is part of the flag.Value interface.
+func (timingLevelValue) Set(value string) error {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	SetTimingLevel(Level(v))
+	return nil
+}
@@ -0,0 +1,53 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"testing"
+	"time"
+)
+
+func loadUsers() {
+	defer TimeTrack(time.Now().Add(-time.Second), "load users")
+}
+
+func TestTimeTrack(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	loadUsers()
+	if !contains(infoLog, "glog_timing_test.go:26] load users took 1", t) {
+		t.Errorf("got %q", contents(infoLog))
+	}
+
+	if d := Scope("handler").End(); d <= 0 || !contains(infoLog, "handler took ", t) {
+		t.Errorf("got %v, %q", d, contents(infoLog))
+	}
+
+	defer SetTimingLevel(0)
+	SetTimingLevel(2)
+	logging.newBuffers()
+	Scope("hidden").End()
+	if contents(infoLog) != "" {
+		t.Errorf("logged above the timing level: %q", contents(infoLog))
+	}
+	defer logging.vmodule.Set("")
+	logging.vmodule.Set("glog_timing_test=2")
+	Scope("shown").End()
+	if !contains(infoLog, "shown took", t) {
+		t.Errorf("vmodule not applied: %q", contents(infoLog))
+	}
+}
@@ -0,0 +1,282 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Truncation of long lines.
+
+package glog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultTruncateSuffix is the default of the -log_truncate_suffix flag.
+const defaultTruncateSuffix = "..."
+
+// SetTruncation sets how lines longer than the -maxlogmessagelen limit, or
+// that of their severity, are truncated. If bytes is set, limits count bytes
+// rather than runes, which bounds disk usage precisely; lines are still cut
+// between runes. suffix ends truncated lines and counts towards the limit;
+// "%d" in it is replaced by the number of bytes or runes removed, as in
+// "...[truncated %d bytes]".
+func SetTruncation(bytes bool, suffix string) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.truncateBytes = bytes
+	logging.truncateSuffix = suffix
+}
+
+// SetMaxMessageLen sets the length limit of the lines of the named severity,
+// such as "ERROR", overriding -maxlogmessagelen. Zero restores the global
+// limit. As for -maxlogmessagelen, limits are counted including the header
+// and excluding the newline, and limits not greater than the header length,
+// 64, disable truncation.
+func SetMaxMessageLen(name string, n int) error {
+	sev, ok := severityByName(name)
+	if !ok {
+		return fmt.Errorf("log: unknown severity %q", name)
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.maxLen[sev] = n
+	return nil
+}
+
+// lineLimit returns the length limit of lines of severity s, or 0 if they are
+// not truncated. l.mu is held.
+func (l *loggingT) lineLimit(s severity) int {
+	max := l.maxLogMessageLen
+	if l.maxLen[s] != 0 {
+		max = l.maxLen[s]
+	}
+	if max <= headerLength {
+		return 0
+	}
+	return max
+}
+
+// lengthFunc returns the function measuring lines against their limit.
+// l.mu is held.
+func (l *loggingT) lengthFunc() func([]byte) int {
+	if l.truncateBytes {
+		return func(b []byte) int { return len(b) }
+	}
+	return utf8.RuneCount
+}
+
+// truncate returns data, a line of severity s, cut to its length limit.
+// l.mu is held.
+func (l *loggingT) truncate(s severity, data []byte) []byte {
+	max := l.lineLimit(s)
+	if max == 0 {
+		return data
+	}
+	line := data
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	count := l.lengthFunc()
+	total := count(line)
+	if total <= max {
+		return data
+	}
+	// The suffix for the whole line is at least as long as the final one.
+	keep := max - count([]byte(l.truncateSuffixFor(total)))
+	cut := 0
+	if keep > 0 {
+		if l.truncateBytes {
+			cut = keep
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+		} else {
+			for i := 0; i < keep; i++ {
+				_, size := utf8.DecodeRune(line[cut:])
+				cut += size
+			}
+		}
+	}
+	out := make([]byte, 0, cut+len(l.truncateSuffix)+24)
+	out = append(out, line[:cut]...)
+	out = append(out, l.truncateSuffixFor(total-count(line[:cut]))...)
+	return append(out, '\n')
+}
+
+// fitRecord encodes r, a record of severity s, within the length limit of
+// its lines. Rather than cutting the JSON, which would leave it invalid, it
+// drops stack frames, outermost first, then fields, last first, then shortens the message between runes until
+// the record fits, and marks it as truncated. l.mu is held.
+func (l *loggingT) fitRecord(s severity, r *logRecord) {
+	max := l.lineLimit(s)
+	if max == 0 {
+		return
+	}
+	count := l.lengthFunc()
+	fits := func() bool {
+		r.encoded = nil
+		r.encoded = r.encodeJSON()
+		return count(r.encoded)-1 <= max
+	}
+	if fits() {
+		return
+	}
+	r.Truncated = true
+	stack := r.Stack
+	for len(r.Stack) > 0 {
+		r.Stack = stack[:len(r.Stack)-1]
+		if fits() {
+			return
+		}
+	}
+	fields := r.Fields
+	for len(r.Fields) > 0 {
+		r.Fields = fields[:len(r.Fields)-1]
+		if fits() {
+			return
+		}
+	}
+	// Find the longest prefix of the message, ending between runes, that
+	// fits.
+	msg := r.Message
+	var ends []int
+	for i := range msg {
+		ends = append(ends, i)
+	}
+	lo, hi := 0, len(ends)-1 // Indexes in ends; ends[0] is 0.
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if r.Message = msg[:ends[mid]]; fits() {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	r.Message = msg[:0]
+	if len(ends) > 0 {
+		r.Message = msg[:ends[lo]]
+	}
+	fits()
+}
+
+// truncateSuffixFor returns the suffix of a line from which n bytes or runes
+// were removed. l.mu is held.
+func (l *loggingT) truncateSuffixFor(n int) string {
+	return strings.Replace(l.truncateSuffix, "%d", strconv.Itoa(n), -1)
+}
+
+// parseMaxLen parses the value of the -maxlogmessagelen_by_severity flag, a
+// comma-separated list of SEVERITY=limit, such as "ERROR=65536,INFO=4096".
+func parseMaxLen(value string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, spec := range strings.Split(value, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		eq := strings.Index(spec, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("syntax error in %q: expect SEVERITY=limit", spec)
+		}
+		name := strings.ToUpper(strings.TrimSpace(spec[:eq]))
+		if _, ok := severityByName(name); !ok {
+			return nil, fmt.Errorf("unknown severity %q", name)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(spec[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		limits[name] = n
+	}
+	return limits, nil
+}
+
+// maxLenValue implements flag.Value for the -maxlogmessagelen_by_severity
+// flag.
+type maxLenValue struct{}
+
+// String is part of the flag.Value interface.
+func (maxLenValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	var specs []string
+	for s, n := range logging.maxLen {
+		if n != 0 {
+			specs = append(specs, fmt.Sprintf("%s=%d", severityName[s], n))
+		}
+	}
+	sort.Strings(specs)
+	return strings.Join(specs, ",")
+}
+
+// Set is part of the flag.Value interface.
+func (maxLenValue) Set(value string) error {
+	limits, err := parseMaxLen(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	for s := range logging.maxLen {
+		logging.maxLen[s] = limits[severityName[s]]
+	}
+	return nil
+}
+
+// truncateSuffixValue implements flag.Value for the -log_truncate_suffix flag.
+type truncateSuffixValue struct{}
+
+// String is part of the flag.Value interface.
+func (truncateSuffixValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	return logging.truncateSuffix
+}
+
+// Set is part of the flag.Value interface.
+func (truncateSuffixValue) Set(value string) error {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.truncateSuffix = value
+	return nil
+}
+
+// truncateBytesValue implements flag.Value for the -log_truncate_bytes flag.
+type truncateBytesValue struct{}
+
+// String is part of the flag.Value interface.
+func (truncateBytesValue) String() string {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	return strconv.FormatBool(logging.truncateBytes)
+}
+
+// Set is part of the flag.Value interface.
+func (truncateBytesValue) Set(value string) error {
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.truncateBytes = on
+	return nil
+}
+
+// IsBoolFlag lets the flag be given without a value.
+func (truncateBytesValue) IsBoolFlag() bool { return true }
@@ -0,0 +1,93 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Masking of values by type.
+
+package glog
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	typeMaskersMu sync.RWMutex
+	typeMaskers   map[reflect.Type]func(v interface{}) interface{}
+	// numTypeMaskers is the number of registered type maskers, so that
+	// logging skips the lookup when there are none. Accessed atomically.
+	numTypeMaskers int32
+)
+
+// RegisterTypeMasker registers mask to mask or format the values of type t
+// wherever they appear in logged values: as arguments, fields, or inside
+// structs, maps and slices, directly or through pointers. mask is passed the
+// value, of type t, and returns what is logged in its place, as in
+//
+//	glog.RegisterTypeMasker(reflect.TypeOf(CardNumber("")), func(v interface{}) interface{} {
+//		return glog.ShrineCardNo(string(v.(CardNumber)))
+//	})
+//
+// Registered types are masked even when the filters are off. Only values of
+// exactly type t match; to mask the values implementing an interface, register
+// their concrete types. A nil mask removes the registration. It may be called
+// at any time.
+func RegisterTypeMasker(t reflect.Type, mask func(v interface{}) interface{}) {
+	typeMaskersMu.Lock()
+	defer typeMaskersMu.Unlock()
+	if mask == nil {
+		delete(typeMaskers, t)
+	} else {
+		if typeMaskers == nil {
+			typeMaskers = make(map[reflect.Type]func(v interface{}) interface{})
+		}
+		typeMaskers[t] = mask
+	}
+	atomic.StoreInt32(&numTypeMaskers, int32(len(typeMaskers)))
+}
+
+// typeMasker returns the masker registered for t, if any.
+func typeMasker(t reflect.Type) (func(v interface{}) interface{}, bool) {
+	typeMaskersMu.RLock()
+	defer typeMaskersMu.RUnlock()
+	mask, ok := typeMaskers[t]
+	return mask, ok
+}
+
+// maskByType returns v masked by the masker registered for its type, or for
+// the type of the value it points to, and true, or false if there is none.
+func maskByType(v reflect.Value) (interface{}, bool) {
+	if atomic.LoadInt32(&numTypeMaskers) == 0 {
+		return nil, false
+	}
+	for i := 0; i <= maxDerefHops && v.IsValid() && v.CanInterface(); i++ {
+		if mask, ok := typeMasker(v.Type()); ok {
+			return mask(v.Interface()), true
+		}
+		if (v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface) || v.IsNil() {
+			break
+		}
+		v = v.Elem()
+	}
+	return nil, false
+}
+
+// masking reports whether logged values go through transform: if any of the
+// filters that enable it is on, types are registered for masking, or sizes
+// are limited.
+func (l *loggingT) masking() bool {
+	return l.filterCard || l.filterIdentity || l.filterPhone || atomic.LoadInt32(&numTypeMaskers) > 0 || l.limited()
+}
@@ -0,0 +1,90 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testCardNumber string
+
+type testAmount struct{ cents int64 }
+
+type testOrder struct {
+	ID     int
+	Card   testCardNumber
+	Total  *testAmount
+	Backup []testCardNumber
+	Extra  map[string]interface{}
+}
+
+func TestRegisterTypeMasker(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer RegisterTypeMasker(reflect.TypeOf(testCardNumber("")), nil)
+	defer RegisterTypeMasker(reflect.TypeOf(testAmount{}), nil)
+	defer logging.SetFilter(true, true, true, true, true, true, true)
+
+	RegisterTypeMasker(reflect.TypeOf(testCardNumber("")), func(v interface{}) interface{} {
+		return ShrineCardNo(string(v.(testCardNumber)))
+	})
+	RegisterTypeMasker(reflect.TypeOf(testAmount{}), func(v interface{}) interface{} {
+		a := v.(testAmount)
+		return fmt.Sprintf("%d.%02d", a.cents/100, a.cents%100)
+	})
+	// Registered types are masked with the filters off.
+	logging.SetFilter(false, false, false, false, false, false, false)
+
+	card := testCardNumber("6222021234567890123")
+	order := testOrder{
+		ID:     7,
+		Card:   card,
+		Total:  &testAmount{cents: 1999},
+		Backup: []testCardNumber{card},
+		Extra:  map[string]interface{}{"alt": &card},
+	}
+	Info(order)
+	Info(card)
+	InfoFields("paid", Any("card", card))
+
+	got := contents(infoLog)
+	if strings.Contains(got, string(card)) {
+		t.Errorf("log contains the card number: %q", got)
+	}
+	masked := ShrineCardNo(string(card))
+	if n := strings.Count(got, masked); n != 5 {
+		t.Errorf("masked card number appears %d times, want 5: %q", n, got)
+	}
+	if !strings.Contains(got, "Total:19.99") {
+		t.Errorf("amount not formatted by its masker: %q", got)
+	}
+}
+
+func TestRegisterTypeMaskerRemove(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+
+	typ := reflect.TypeOf(testCardNumber(""))
+	RegisterTypeMasker(typ, func(interface{}) interface{} { return "masked" })
+	RegisterTypeMasker(typ, nil)
+	if _, ok := maskByType(reflect.ValueOf(testCardNumber("1"))); ok {
+		t.Error("masker still registered after removal")
+	}
+}
@@ -0,0 +1,142 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Verbosity raised for the requests matching a context.
+
+package glog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// vContextKey is the key of the V level set by WithVLevel in a context.
+type vContextKey struct{}
+
+// WithVLevel returns a copy of ctx in which VContext and Logger.VContext
+// enable the lines of V level up to level, whatever -v and -vmodule say. A
+// request handler can use it to debug the requests that ask for it, such as
+// those with a trace flag set:
+//
+//	if r.Header.Get("X-Debug") != "" {
+//		ctx = glog.WithVLevel(ctx, 4)
+//	}
+//	...
+//	glog.VContext(ctx, 4).Info("cache miss for ", key)
+//
+// DebugHandler does so for the requests with a signed header.
+func WithVLevel(ctx context.Context, level Level) context.Context {
+	return context.WithValue(ctx, vContextKey{}, level)
+}
+
+// VOverride raises the V level of the requests whose context carries a field,
+// added by NewContext, with the key Key and a value that fmt.Sprint formats
+// as Value, such as one tenant or user ID.
+type VOverride struct {
+	Key   string
+	Value string
+	Level Level
+	// Until, if not zero, is when the override expires, so that debugging
+	// left on in production turns itself off.
+	Until time.Time
+}
+
+var (
+	vOverridesMu sync.Mutex // Serializes changes to vOverrides.
+	// vOverrides holds the []*VOverride added. It is replaced, never
+	// modified, so that logging calls can read it without locking.
+	vOverrides atomic.Value
+)
+
+// AddVOverride raises the V level of the requests matching o, for lines
+// logged through VContext and Logger.VContext:
+//
+//	ctx = glog.NewContext(ctx, "tenant", tenantID)
+//	...
+//	remove := glog.AddVOverride(glog.VOverride{Key: "tenant", Value: "acme", Level: 4})
+//
+// Where several overrides match a request, the highest level applies. The
+// returned function removes o.
+func AddVOverride(o VOverride) (remove func()) {
+	p := &o
+	vOverridesMu.Lock()
+	defer vOverridesMu.Unlock()
+	old, _ := vOverrides.Load().([]*VOverride)
+	vOverrides.Store(append(old[:len(old):len(old)], p))
+	return func() {
+		vOverridesMu.Lock()
+		defer vOverridesMu.Unlock()
+		old, _ := vOverrides.Load().([]*VOverride)
+		for i, other := range old {
+			if other == p {
+				vOverrides.Store(append(old[:i:i], old[i+1:]...))
+				return
+			}
+		}
+	}
+}
+
+// contextV returns the V level that ctx raises its lines to, or zero.
+func contextV(ctx context.Context) Level {
+	if ctx == nil {
+		return 0
+	}
+	v, _ := ctx.Value(vContextKey{}).(Level)
+	overrides, _ := vOverrides.Load().([]*VOverride)
+	if len(overrides) == 0 {
+		return v
+	}
+	fields, _ := ctx.Value(contextKey{}).([]Field)
+	if len(fields) == 0 {
+		return v
+	}
+	now := logging.now()
+	for _, o := range overrides {
+		if o.Level <= v || !o.Until.IsZero() && !now.Before(o.Until) {
+			continue
+		}
+		for _, f := range fields {
+			if f.Key == o.Key && fmt.Sprint(f.value()) == o.Value {
+				v = o.Level
+				break
+			}
+		}
+	}
+	return v
+}
+
+// VContext is like V, but also reports true if ctx raises the V level of its
+// request to at least level, with WithVLevel or a matching override added by
+// AddVOverride.
+func VContext(ctx context.Context, level Level) Verbose {
+	if logging.verbosity.get() >= level || contextV(ctx) >= level {
+		return Verbose(true)
+	}
+	return moduleV(level)
+}
+
+// VContext is like lg.V, but also enables the line if ctx raises the V level
+// of its request to at least level; see the global VContext.
+func (lg *Logger) VContext(ctx context.Context, level Level) LoggerVerbose {
+	v := lg.V(level)
+	if !v.v.gated && contextV(ctx) >= level {
+		v.on, v.v.gated = true, true
+	}
+	return v
+}
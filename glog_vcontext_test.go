@@ -0,0 +1,75 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVContext(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetClock(nil)
+	now := time.Date(2030, 5, 6, 7, 8, 9, 0, time.Local)
+	SetClock(fixedClock{t: now, pending: new([]func())})
+
+	acme := NewContext(context.Background(), "tenant", "acme", "user_id", 42)
+	other := NewContext(context.Background(), "tenant", "globex")
+	if VContext(acme, 4) || VContext(nil, 1) {
+		t.Error("V enabled without override")
+	}
+	remove := AddVOverride(VOverride{Key: "tenant", Value: "acme", Level: 4})
+	defer AddVOverride(VOverride{Key: "user_id", Value: "42", Level: 6, Until: now.Add(time.Minute)})()
+	VContext(acme, 6).Info("user debug")
+	VContext(other, 1).Info("other tenant")
+	if !contains(infoLog, "user debug", t) || contains(infoLog, "other tenant", t) {
+		t.Errorf("got %q", contents(infoLog))
+	}
+
+	SetClock(fixedClock{t: now.Add(time.Minute), pending: new([]func())})
+	if VContext(acme, 5) || !VContext(acme, 4) {
+		t.Error("expired override still applies, or the other stopped")
+	}
+	remove()
+	if VContext(acme, 1) {
+		t.Error("removed override still applies")
+	}
+
+	debug := WithVLevel(other, 2)
+	if !VContext(debug, 2) || VContext(debug, 3) {
+		t.Error("WithVLevel not applied")
+	}
+	lg := Named("db")
+	lg.VContext(debug, 2).Info("query plan")
+	lg.VContext(other, 2).Info("hidden plan")
+	if !contains(infoLog, "[db] query plan", t) || contains(infoLog, "hidden plan", t) {
+		t.Errorf("got %q", contents(infoLog))
+	}
+}
+
+// Test that VContext still consults -vmodule for its caller.
+func TestVContextVmodule(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	logging.vmodule.Set("glog_vcontext_test=2")
+	defer logging.vmodule.Set("")
+	if !VContext(context.Background(), 2) || VContext(context.Background(), 3) {
+		t.Error("VContext ignores -vmodule")
+	}
+}
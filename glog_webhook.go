@@ -0,0 +1,333 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Forwarding of error entries to Sentry or a generic webhook.
+
+package glog
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WebhookConfig configures a Webhook. Exactly one of URL and SentryDSN must
+// be set.
+type WebhookConfig struct {
+	// URL receives each entry as a JSON object in a POST request.
+	URL string
+	// SentryDSN, such as "https://KEY@o1.ingest.sentry.io/42", sends entries
+	// as Sentry events instead.
+	SentryDSN string
+	// Header holds extra headers for each request, e.g. for authentication.
+	Header http.Header
+	// Severity is the lowest severity forwarded. The default is "ERROR".
+	Severity string
+	// RateLimit is the number of entries forwarded per minute; entries over
+	// the limit are dropped. The default is 60.
+	RateLimit int
+	// BufferSize is the number of entries waiting to be sent before further
+	// entries are dropped. The default is 100.
+	BufferSize int
+	// Timeout bounds each request. The default is 5s.
+	Timeout time.Duration
+	// Client sends the requests. The default is a client with Timeout.
+	Client *http.Client
+}
+
+// Webhook forwards entries to an error tracker, with their message, fields,
+// global labels and the stack of the logging goroutine. Values are masked as
+// in the logs. Install it with AddHook:
+//
+//	wh, err := glog.NewWebhook(glog.WebhookConfig{SentryDSN: dsn})
+//	...
+//	glog.AddHook(wh.Hook)
+//	defer wh.Close()
+//
+// Entries are sent by a background goroutine, so logging never waits on the
+// network, except for FATAL entries, which are sent before the program exits.
+type Webhook struct {
+	cfg      WebhookConfig
+	sev      severity
+	endpoint string
+	auth     string // X-Sentry-Auth header, for Sentry.
+	dropped  int64  // Updated atomically.
+
+	mu          sync.Mutex
+	windowStart time.Time // Start of the current rate limit minute.
+	sent        int       // Entries accepted in the current minute.
+	closed      bool
+
+	queue chan []byte
+	done  chan struct{} // Closed when the sender goroutine exits.
+}
+
+// NewWebhook returns a Webhook for cfg and starts its sender goroutine.
+func NewWebhook(cfg WebhookConfig) (*Webhook, error) {
+	if (cfg.URL == "") == (cfg.SentryDSN == "") {
+		return nil, errors.New("log: webhook needs exactly one of URL and SentryDSN")
+	}
+	if cfg.Severity == "" {
+		cfg.Severity = severityName[errorLog]
+	}
+	sev, ok := severityByName(cfg.Severity)
+	if !ok {
+		return nil, fmt.Errorf("log: webhook: unknown severity %q", cfg.Severity)
+	}
+	if cfg.RateLimit <= 0 {
+		cfg.RateLimit = 60
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 100
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: cfg.Timeout}
+	}
+	w := &Webhook{
+		cfg:      cfg,
+		sev:      sev,
+		endpoint: cfg.URL,
+		queue:    make(chan []byte, cfg.BufferSize),
+		done:     make(chan struct{}),
+	}
+	if cfg.SentryDSN != "" {
+		endpoint, auth, err := parseSentryDSN(cfg.SentryDSN)
+		if err != nil {
+			return nil, err
+		}
+		w.endpoint, w.auth = endpoint, auth
+	}
+	go w.run()
+	return w, nil
+}
+
+// parseSentryDSN returns the store endpoint and authentication header for
+// a Sentry DSN.
+func parseSentryDSN(dsn string) (endpoint, auth string, err error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", fmt.Errorf("log: sentry DSN: %v", err)
+	}
+	project := strings.Trim(u.Path, "/")
+	if u.User == nil || u.User.Username() == "" || u.Host == "" || project == "" {
+		return "", "", fmt.Errorf("log: sentry DSN %q is not of the form scheme://key@host/project", dsn)
+	}
+	prefix := ""
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+	endpoint = fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project)
+	auth = "Sentry sentry_version=7, sentry_client=glog/1.0, sentry_key=" + u.User.Username()
+	if secret, ok := u.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+	return endpoint, auth, nil
+}
+
+// webhookEvent is the JSON body posted to a generic webhook.
+type webhookEvent struct {
+	Time     time.Time         `json:"time"`
+	Severity string            `json:"severity"`
+	Host     string            `json:"host"`
+	PID      int               `json:"pid"`
+	File     string            `json:"file"`
+	Line     int               `json:"line"`
+	Logger   string            `json:"logger,omitempty"`
+	Message  string            `json:"message"`
+	Fields   fieldList         `json:"fields,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Stack    string            `json:"stack"`
+}
+
+// sentryEvent is the subset of the Sentry event payload that is sent.
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger,omitempty"`
+	Platform    string            `json:"platform"`
+	ServerName  string            `json:"server_name"`
+	Culprit     string            `json:"culprit"`
+	Message     string            `json:"message"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       fieldList         `json:"extra"`
+	Fingerprint []string          `json:"fingerprint,omitempty"` // Groups events; see SetFingerprint.
+}
+
+// Hook is the hook to register with AddHook. It never drops entries.
+func (w *Webhook) Hook(e *Entry) error {
+	sev, ok := severityByName(e.Severity)
+	if !ok || sev < w.sev {
+		return nil
+	}
+	if !w.allow(e.Time) {
+		atomic.AddInt64(&w.dropped, 1)
+		return nil
+	}
+	body, err := w.encode(e, string(stacks(false)))
+	if err != nil {
+		return err
+	}
+	if sev == fatalLog {
+		// The program is about to exit: send now.
+		return w.send(body)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		atomic.AddInt64(&w.dropped, 1)
+		return nil
+	}
+	select {
+	case w.queue <- body:
+	default:
+		atomic.AddInt64(&w.dropped, 1)
+	}
+	return nil
+}
+
+// allow reports whether an entry logged at t is within the rate limit.
+func (w *Webhook) allow(t time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return false
+	}
+	if t.Sub(w.windowStart) >= time.Minute || t.Before(w.windowStart) {
+		w.windowStart, w.sent = t, 0
+	}
+	if w.sent >= w.cfg.RateLimit {
+		return false
+	}
+	w.sent++
+	return true
+}
+
+// encode returns the request body for e.
+func (w *Webhook) encode(e *Entry, stack string) ([]byte, error) {
+	labels := globalLabels.Load().(*labelSet).labels
+	if w.auth == "" {
+		return json.Marshal(&webhookEvent{
+			Time:     e.Time,
+			Severity: e.Severity,
+			Host:     host,
+			PID:      pid,
+			File:     e.File,
+			Line:     e.Line,
+			Logger:   e.name,
+			Message:  e.Message,
+			Fields:   e.fields,
+			Labels:   labels,
+			Stack:    stack,
+		})
+	}
+	var id [16]byte
+	if _, err := io.ReadFull(rand.Reader, id[:]); err != nil {
+		return nil, err
+	}
+	var fingerprint []string
+	extra := make(fieldList, 0, len(e.fields)+1)
+	for _, f := range e.fields {
+		if f.Key == FingerprintKey {
+			fingerprint = []string{fmt.Sprint(f.value())}
+			continue
+		}
+		extra = append(extra, f)
+	}
+	extra = append(extra, Field{Key: "stack", Value: stack})
+	return json.Marshal(&sentryEvent{
+		EventID:     hex.EncodeToString(id[:]),
+		Timestamp:   e.Time.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		Level:       strings.ToLower(e.Severity),
+		Logger:      e.name,
+		Platform:    "go",
+		ServerName:  host,
+		Culprit:     fmt.Sprintf("%s:%d", e.File, e.Line),
+		Message:     e.Message,
+		Tags:        labels,
+		Extra:       extra,
+		Fingerprint: fingerprint,
+	})
+}
+
+// send posts body to the endpoint.
+func (w *Webhook) send(body []byte) error {
+	req, err := http.NewRequest("POST", w.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range w.cfg.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.auth != "" {
+		req.Header.Set("X-Sentry-Auth", w.auth)
+	}
+	resp, err := w.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("log: webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// run sends queued entries until the queue is closed.
+func (w *Webhook) run() {
+	defer close(w.done)
+	for body := range w.queue {
+		if err := w.send(body); err != nil {
+			atomic.AddInt64(&w.dropped, 1)
+		}
+	}
+}
+
+// Dropped returns the number of entries not delivered, because they were
+// over the rate limit, the queue was full or the request failed.
+func (w *Webhook) Dropped() int64 {
+	return atomic.LoadInt64(&w.dropped)
+}
+
+// Close sends the queued entries and stops the sender goroutine. Entries
+// passed to Hook afterwards are dropped.
+func (w *Webhook) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return errSinkClosed
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+	<-w.done
+	return nil
+}
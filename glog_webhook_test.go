@@ -0,0 +1,115 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// webhookServer records the requests it receives.
+type webhookServer struct {
+	mu     sync.Mutex
+	bodies []map[string]interface{}
+	auth   []string
+	paths  []string
+}
+
+func (ws *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, _ := io.ReadAll(r.Body)
+	var body map[string]interface{}
+	json.Unmarshal(data, &body)
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.bodies = append(ws.bodies, body)
+	ws.auth = append(ws.auth, r.Header.Get("X-Sentry-Auth"))
+	ws.paths = append(ws.paths, r.URL.Path)
+}
+
+// Test that errors are forwarded, masked, within the rate limit.
+func TestWebhook(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	var ws webhookServer
+	srv := httptest.NewServer(&ws)
+	defer srv.Close()
+	wh, err := NewWebhook(WebhookConfig{URL: srv.URL, RateLimit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remove := AddHook(wh.Hook)
+	defer remove()
+
+	Warning("not forwarded")
+	WithFields("mobile", "13812345678").Error("payment failed")
+	Error("second")
+	Error("over the limit")
+	wh.Close()
+
+	if len(ws.bodies) != 2 {
+		t.Fatalf("got %d requests, want 2", len(ws.bodies))
+	}
+	b := ws.bodies[0]
+	fields, _ := b["fields"].(map[string]interface{})
+	if b["message"] != "payment failed" || b["severity"] != "ERROR" || fields["mobile"] != "138****5678" {
+		t.Errorf("got %v", b)
+	}
+	if stack, _ := b["stack"].(string); !strings.Contains(stack, "TestWebhook") {
+		t.Errorf("stack missing: %q", stack)
+	}
+	if wh.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", wh.Dropped())
+	}
+}
+
+func TestWebhookSentry(t *testing.T) {
+	var ws webhookServer
+	srv := httptest.NewServer(&ws)
+	defer srv.Close()
+	dsn := strings.Replace(srv.URL, "://", "://public@", 1) + "/42"
+	wh, err := NewWebhook(WebhookConfig{SentryDSN: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := WithFields("order", 7)
+	e.Severity, e.Message, e.File, e.Line = "ERROR", "boom", "x.go", 3
+	wh.Hook(e)
+	wh.Close()
+
+	if len(ws.bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(ws.bodies))
+	}
+	if ws.paths[0] != "/api/42/store/" || !strings.Contains(ws.auth[0], "sentry_key=public") {
+		t.Errorf("got path %q, auth %q", ws.paths[0], ws.auth[0])
+	}
+	b := ws.bodies[0]
+	extra, _ := b["extra"].(map[string]interface{})
+	if b["level"] != "error" || b["message"] != "boom" || b["culprit"] != "x.go:3" || extra["order"] != float64(7) {
+		t.Errorf("got %v", b)
+	}
+
+	for _, bad := range []WebhookConfig{{}, {URL: "http://x", SentryDSN: dsn}, {SentryDSN: "https://host/1"}, {URL: "http://x", Severity: "LOUD"}} {
+		if _, err := NewWebhook(bad); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glogtest
+
+import (
+	"testing"
+
+	"github.com/biyizhen/glog"
+)
+
+// Benchmark measures log, a function logging a line, as the benchmark b: it
+// calls log b.N times, reports allocations and includes the flush of the
+// lines written in the time measured. For example, to measure the cost of
+// logging an order, masking included:
+//
+//	func BenchmarkLogOrder(b *testing.B) {
+//		glogtest.Benchmark(b, func() { glog.Info("order ", order) })
+//	}
+//
+// The lines go to the outputs configured, so run benchmarks with -log_dir
+// set to a fast file system, or with -logtostderr and standard error
+// redirected, according to what is to be measured.
+func Benchmark(b *testing.B, log func()) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		log()
+	}
+	glog.Flush()
+}
+
+// BenchmarkParallel is like Benchmark, but calls log from parallel
+// goroutines, as b.RunParallel does, to measure contention.
+func BenchmarkParallel(b *testing.B, log func()) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			log()
+		}
+	})
+	glog.Flush()
+}
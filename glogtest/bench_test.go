@@ -0,0 +1,47 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glogtest
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestBenchmark(t *testing.T) {
+	var calls int64
+	r := testing.Benchmark(func(b *testing.B) {
+		calls = 0
+		Benchmark(b, func() { calls++ })
+		if calls != int64(b.N) {
+			t.Errorf("log called %d times for b.N = %d", calls, b.N)
+		}
+	})
+	if r.N == 0 {
+		t.Error("benchmark did not run")
+	}
+
+	r = testing.Benchmark(func(b *testing.B) {
+		atomic.StoreInt64(&calls, 0)
+		BenchmarkParallel(b, func() { atomic.AddInt64(&calls, 1) })
+		if n := atomic.LoadInt64(&calls); n != int64(b.N) {
+			t.Errorf("log called %d times for b.N = %d", n, b.N)
+		}
+	})
+	if r.N == 0 {
+		t.Error("parallel benchmark did not run")
+	}
+}
@@ -0,0 +1,154 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glogtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/biyizhen/glog"
+)
+
+// FakeClock is a glog.Clock that stands still until advanced, for tests of
+// time-based behavior such as rotation:
+//
+//	clock := glogtest.NewFakeClock(time.Date(2026, 10, 16, 23, 59, 0, 0, time.UTC))
+//	glog.SetClock(clock)
+//	defer glog.SetClock(nil)
+//	glog.Info("before midnight")
+//	clock.Advance(2 * time.Minute)
+//	glog.Info("after midnight") // In a new file with daily rotation.
+//
+// It is safe for concurrent use.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	events []*fakeEvent // Pending timers and tickers.
+}
+
+// fakeEvent is a timer or ticker of a FakeClock.
+type fakeEvent struct {
+	at     time.Time
+	period time.Duration  // Of tickers; zero for timers.
+	c      chan time.Time // Of timers and tickers; nil for AfterFunc.
+	f      func()         // Of AfterFunc.
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns a timer that fires when the clock is advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) glog.Timer {
+	return fakeTimer{c, c.add(&fakeEvent{at: c.Now().Add(d), c: make(chan time.Time, 1)})}
+}
+
+// NewTicker returns a ticker that fires each time the clock is advanced past
+// a multiple of d. Like time.Ticker, it drops ticks that are not received.
+func (c *FakeClock) NewTicker(d time.Duration) glog.Ticker {
+	if d <= 0 {
+		panic("glogtest: non-positive interval for NewTicker")
+	}
+	return fakeTicker{c, c.add(&fakeEvent{at: c.Now().Add(d), period: d, c: make(chan time.Time, 1)})}
+}
+
+// AfterFunc arranges for f to be called when the clock is advanced by d. It
+// is called by Advance, before it returns.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) glog.Timer {
+	return fakeTimer{c, c.add(&fakeEvent{at: c.Now().Add(d), f: f})}
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers due by
+// then in order, with the clock set to the time of each.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.events, func(i, j int) bool { return c.events[i].at.Before(c.events[j].at) })
+		if len(c.events) == 0 || c.events[0].at.After(end) {
+			break
+		}
+		e := c.events[0]
+		c.now = e.at
+		if e.period > 0 {
+			e.at = e.at.Add(e.period)
+		} else {
+			c.events = c.events[1:]
+		}
+		if e.c != nil {
+			select {
+			case e.c <- c.now:
+			default:
+			}
+		}
+		if e.f != nil {
+			c.mu.Unlock()
+			e.f()
+			c.mu.Lock()
+		}
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// add registers e and returns it.
+func (c *FakeClock) add(e *fakeEvent) *fakeEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, e)
+	return e
+}
+
+// remove unregisters e and reports whether it was pending.
+func (c *FakeClock) remove(e *fakeEvent) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, p := range c.events {
+		if p == e {
+			c.events = append(c.events[:i], c.events[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	e     *fakeEvent
+}
+
+func (t fakeTimer) C() <-chan time.Time { return t.e.c }
+
+func (t fakeTimer) Stop() bool { return t.clock.remove(t.e) }
+
+type fakeTicker struct {
+	clock *FakeClock
+	e     *fakeEvent
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.e.c }
+
+func (t fakeTicker) Stop() { t.clock.remove(t.e) }
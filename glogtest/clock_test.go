@@ -0,0 +1,73 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glogtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/biyizhen/glog"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	timer := c.NewTimer(2 * time.Second)
+	ticker := c.NewTicker(time.Second)
+	var called []time.Time
+	c.AfterFunc(1500*time.Millisecond, func() { called = append(called, c.Now()) })
+	stopped := c.AfterFunc(time.Second, func() { t.Error("stopped timer fired") })
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("Stop did not report the pending timer once")
+	}
+
+	c.Advance(time.Second)
+	if got := <-ticker.C(); !got.Equal(start.Add(time.Second)) {
+		t.Errorf("tick at %v", got)
+	}
+	select {
+	case <-timer.C():
+		t.Error("timer fired early")
+	default:
+	}
+	c.Advance(time.Second)
+	if got := <-timer.C(); !got.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("timer fired at %v", got)
+	}
+	if len(called) != 1 || !called[0].Equal(start.Add(1500*time.Millisecond)) {
+		t.Errorf("AfterFunc called at %v", called)
+	}
+	ticker.Stop()
+	c.Advance(time.Hour)
+	if got := c.Now(); !got.Equal(start.Add(time.Hour + 2*time.Second)) {
+		t.Errorf("Now() = %v", got)
+	}
+}
+
+func TestFakeClockTimestamps(t *testing.T) {
+	if err := glog.Init(glog.WithToStderr(true)); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	glog.SetClock(NewFakeClock(at))
+	defer glog.SetClock(nil)
+	rec := Capture(t)
+	glog.Info("stamped")
+	if e := rec.Entries(); len(e) != 1 || !e[0].Time.Equal(at) {
+		t.Errorf("got %+v, want a line at %v", e, at)
+	}
+}
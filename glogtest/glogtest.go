@@ -0,0 +1,137 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package glogtest helps tests assert on what a program logs with glog.
+//
+// Basic example:
+//
+//	func TestCharge(t *testing.T) {
+//		rec := glogtest.Capture(t)
+//		charge(order)
+//		if !rec.Contains("ERROR", "declined") {
+//			t.Errorf("no error logged: %v", rec.Entries())
+//		}
+//	}
+//
+// Capturing does not stop the lines from also reaching the log files or
+// standard error as configured.
+//
+// A FakeClock passed to glog.SetClock fixes the timestamps of logged lines
+// and drives rotation, batching and flushing by calls to Advance.
+package glogtest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/biyizhen/glog"
+)
+
+// Entry is a captured log line.
+type Entry struct {
+	Time     time.Time
+	Severity string // "INFO", "WARNING", "ERROR" or "FATAL".
+	Logger   string // The name of the Logger, if any.
+	Message  string // The message, without header, fields or trailing newline.
+	// Fields holds the fields of the line, as decoded from JSON: numbers
+	// are float64 and structs are maps.
+	Fields map[string]interface{}
+	File   string // As selected by the -log_caller flag.
+	Line   int
+}
+
+// Recorder collects the lines logged while it is installed. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	entries []Entry
+	errs    []error
+}
+
+// Capture records the lines logged from now until the end of t, including
+// by other goroutines. Lines that cannot be decoded fail t.
+func Capture(t testing.TB) *Recorder {
+	r := new(Recorder)
+	remove := glog.AddJSONWriter("INFO", r)
+	t.Cleanup(func() {
+		remove()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, err := range r.errs {
+			t.Errorf("glogtest: %v", err)
+		}
+	})
+	return r
+}
+
+// Write is called by glog with one JSON record per line.
+func (r *Recorder) Write(p []byte) (int, error) {
+	rec, err := glog.ParseRecord(p)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errs = append(r.errs, err)
+		return len(p), nil
+	}
+	r.entries = append(r.entries, Entry{
+		Time:     rec.Time,
+		Severity: rec.Severity,
+		Logger:   rec.Logger,
+		Message:  rec.Message,
+		Fields:   rec.Fields,
+		File:     rec.File,
+		Line:     rec.Line,
+	})
+	return len(p), nil
+}
+
+// Entries returns the captured lines, oldest first.
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
+
+// Filter returns the captured lines of the named severity.
+func (r *Recorder) Filter(severity string) []Entry {
+	var out []Entry
+	for _, e := range r.Entries() {
+		if strings.EqualFold(e.Severity, severity) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Contains reports whether a line of the named severity whose message
+// contains substr was captured. An empty severity matches all lines.
+func (r *Recorder) Contains(severity, substr string) bool {
+	for _, e := range r.Entries() {
+		if (severity == "" || strings.EqualFold(e.Severity, severity)) && strings.Contains(e.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset discards the lines captured so far.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+}
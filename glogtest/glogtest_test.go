@@ -0,0 +1,54 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glogtest
+
+import (
+	"testing"
+
+	"github.com/biyizhen/glog"
+)
+
+func TestCapture(t *testing.T) {
+	if err := glog.Init(glog.WithToStderr(true)); err != nil {
+		t.Fatal(err)
+	}
+	rec := Capture(t)
+	glog.Info("starting")
+	glog.Named("payments").WithFields("order", 42, "mobile", "13812345678").Error("declined")
+
+	entries := rec.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(entries), entries)
+	}
+	e := entries[1]
+	if e.Severity != "ERROR" || e.Logger != "payments" || e.Message != "declined" || e.File != "glogtest_test.go" || e.Line == 0 {
+		t.Errorf("got %+v", e)
+	}
+	if e.Fields["order"] != float64(42) || e.Fields["mobile"] != "138****5678" {
+		t.Errorf("got fields %v", e.Fields)
+	}
+	if !rec.Contains("ERROR", "declin") || rec.Contains("WARNING", "declined") || !rec.Contains("", "start") {
+		t.Error("Contains mismatch")
+	}
+	if got := rec.Filter("info"); len(got) != 1 || got[0].Message != "starting" {
+		t.Errorf("Filter(info) = %+v", got)
+	}
+	rec.Reset()
+	if len(rec.Entries()) != 0 {
+		t.Error("Reset kept entries")
+	}
+}